@@ -1,76 +1,586 @@
 // Package main Tag Onboarding API server.
 //
-//	Schemes: http
+// Users and background jobs are kept in an in-memory database. Jobs do not survive a restart of the server: the
+// queued and running ones are lost with the files given to their imports, and are not recovered when it starts again.
+//
+//	Schemes: http, https
 //	Host: localhost:8089
 //	BasePath: /
 //	Version: 1.0.0
 //
 //	Consumes:
 //	- application/json
+//	- application/xml
+//	- text/csv
+//	- application/yaml
+//	- application/x-ndjson
 //
 //	Produces:
 //	- application/json
+//	- application/xml
+//	- text/csv
+//	- application/yaml
+//	- application/x-ndjson
+//
+//	SecurityDefinitions:
+//	bearer:
+//	  type: apiKey
+//	  name: Authorization
+//	  in: header
+//	  description: A signed JWT, sent as "Bearer <token>"
+//	apiKey:
+//	  type: apiKey
+//	  name: Authorization
+//	  in: header
+//	  description: An api key, sent as "ApiKey <key>"
+//
+//	Security:
+//	- bearer:
+//	- apiKey:
 //
 // swagger:meta
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"flag"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 	"github.com/go-chi/render"
-	"github.com/wexinc/ps-tag-onboarding-go/internal/constants"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/certs"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/config"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/database"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/gql"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/idempotency"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/jobs"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/outbox"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/redact"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/router"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/rpc"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/scim"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/webhook"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 
 	"time"
 )
 
 func main() {
+	cfg := config.Load()
+	redactor, err := newRedactor(cfg)
+	if err != nil {
+		log.Error.Fatalf("Unable to configure personal data redaction: %v", err)
+	}
+	redact.Configure(redactor)
+
+	catalog, err := i18n.NewCatalog(cfg.LocalesDir)
+	if err != nil {
+		log.Error.Fatalf("Unable to load message files: %v", err)
+	}
+
 	db := database.CreateNewGormDB()
 	userRepository := repository.UserRepository{DB: db}
-	userValidation := service.UserValidationService{&userRepository}
-	userService := service.UserService{&userRepository, &userValidation}
-	userController := controller.UserController{&userService}
-	userRoutes := router.UserRoutes{&userController}
-	handleRequests(&userRoutes)
+	if cfg.EncryptionKeyringFile != "" {
+		if userRepository.Cipher, err = fieldcrypt.LoadKeyring(cfg.EncryptionKeyringFile); err != nil {
+			log.Error.Fatalf("Unable to load encryption keyring: %v", err)
+		}
+		if err := userRepository.Migrate(); err != nil {
+			log.Error.Fatalf("Unable to add encryption indexes: %v", err)
+		}
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		os.Exit(rotateKeys(&userRepository, cfg))
+	}
+	if userRepository.Cipher != nil {
+		// the in-memory database is seeded in clear text, so its users are encrypted at every start
+		reencryptUsers(&userRepository, cfg)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := exportUsers(&userRepository, cfg, os.Args[2:]); err != nil {
+			log.Error.Fatalf("Unable to export users: %v", err)
+		}
+		return
+	}
+
+	// User events, sent to webhooks and to the configured sink
+	webhookRepository := repository.WebhookRepository{DB: db, Cipher: userRepository.Cipher}
+	sinks := outbox.MultiSink{&webhook.Sink{Repository: &webhookRepository}}
+	if cfg.OutboxSink != "" {
+		sink, err := newSink(cfg)
+		if err != nil {
+			log.Error.Fatalf("Unable to configure the event sink: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	userRepository.Events = true
+	dispatcher := outbox.Dispatcher{
+		Repository: &repository.OutboxRepository{DB: db, Cipher: userRepository.Cipher},
+		Sink:       sinks,
+		Interval:   cfg.OutboxInterval,
+		BatchSize:  int(cfg.OutboxBatchSize),
+	}
+	go dispatcher.Run(context.Background())
+	deliverer := webhook.Deliverer{
+		Repository:  &webhookRepository,
+		Client:      webhook.NewClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivateUrls),
+		MaxAttempts: int(cfg.WebhookMaxAttempts),
+		Backoff:     cfg.WebhookBackoff,
+		MaxBackoff:  cfg.WebhookMaxBackoff,
+	}
+	go deliverer.Run(context.Background())
+	userValidation := service.UserValidationService{Repository: &userRepository}
+	userNormalization := service.UserNormalizationService{
+		CapitalizeNames: cfg.CapitalizeNames,
+		LowercaseEmail:  cfg.LowercaseEmails,
+		NameParticles:   cfg.NameParticles,
+	}
+	userService := service.UserService{
+		Repository:           &userRepository,
+		ValidationService:    &userValidation,
+		NormalizationService: &userNormalization,
+	}
+	userImport := service.UserImportService{
+		Repository:           &userRepository,
+		ValidationService:    &userValidation,
+		NormalizationService: &userNormalization,
+		BatchSize:            int(cfg.ImportBatchSize),
+	}
+	userExport := service.UserExportService{Repository: &userRepository, ChunkSize: int(cfg.ExportChunkSize)}
+
+	// Background jobs, deleted with their files once finished for longer than the result TTL
+	jobRepository := repository.JobRepository{DB: db, Cipher: userRepository.Cipher}
+	userJobs := service.UserJobService{
+		ImportService:     &userImport,
+		ExportService:     &userExport,
+		UserService:       &userService,
+		ValidationService: &userValidation,
+		Repository:        &userRepository,
+		Dir:               cfg.JobsDir,
+		ChunkSize:         int(cfg.ExportChunkSize),
+	}
+	jobRunner := &jobs.Runner{
+		Repository: &jobRepository,
+		Handlers: map[string]jobs.Handler{
+			model.JOB_IMPORT:     userJobs.Import,
+			model.JOB_EXPORT:     userJobs.Export,
+			model.JOB_PURGE:      userJobs.Purge,
+			model.JOB_REVALIDATE: userJobs.Revalidate,
+		},
+		Workers:     int(cfg.JobWorkers),
+		MaxAttempts: int(cfg.JobMaxAttempts),
+		Backoff:     cfg.JobBackoff,
+	}
+	go jobRunner.Run(context.Background())
+	jobService := service.JobService{Repository: &jobRepository, Runner: jobRunner, Dir: cfg.JobsDir, ResultTTL: cfg.JobResultTTL}
+	go jobService.Sweep(context.Background())
+	verifier, err := newVerifier(cfg)
+	if err != nil {
+		log.Error.Fatalf("Unable to load token verification keys: %v", err)
+	}
+
+	apiKeyRepository := repository.ApiKeyRepository{DB: db}
+	apiKeyService := service.ApiKeyService{Repository: &apiKeyRepository}
+	webhookService := service.WebhookService{Repository: &webhookRepository, AllowPrivateUrls: cfg.WebhookAllowPrivateUrls}
+
+	var reloader *certs.Reloader
+	if cfg.TlsCertFile != "" {
+		reloader = &certs.Reloader{
+			CertFile:           cfg.TlsCertFile,
+			KeyFile:            cfg.TlsKeyFile,
+			ClientCAFile:       cfg.TlsClientCaFile,
+			ClientCertRequired: cfg.TlsClientCertRequired,
+			Interval:           cfg.TlsReloadInterval,
+		}
+		if err := reloader.Load(); err != nil {
+			log.Error.Fatalf("Unable to load TLS certificates: %v", err)
+		}
+	} else if cfg.TlsClientCaFile != "" {
+		log.Error.Fatalf("Client certificates need TLS, set TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+
+	// roles are only known from authenticated callers, so operations are authorized only when authentication is enabled,
+	// by token verification keys or by client certificates. Without either, the server only starts with AUTH_DISABLED.
+	// Api keys are created by administrators authenticated one of these ways, so they are only accepted alongside them.
+	userController := controller.UserController{UserService: &userService, ImportService: &userImport, ImportMaxBodySize: cfg.ImportMaxBodySize, ExportService: &userExport}
+	apiKeyController := controller.ApiKeyController{ApiKeyService: &apiKeyService}
+	webhookController := controller.WebhookController{WebhookService: &webhookService}
+	jobController := controller.JobController{JobService: &jobService, ImportMaxBodySize: cfg.ImportMaxBodySize}
+	broker := &sse.Broker{BufferSize: int(cfg.SseBufferSize), SubscriberBuffer: int(cfg.SseSubscriberBuffer)}
+	userService.Events = broker
+	userImport.Events = broker
+	userController.Events = &sse.Stream{Broker: broker, Heartbeat: cfg.SseHeartbeat}
+	userServer := rpc.UserServer{UserService: &userService, Events: broker}
+	grpcServer := rpc.Server{Users: &userServer, Catalog: catalog}
+	userSchema := gql.UserSchema{UserService: &userService}
+	scimHandler := scim.Handler{UserService: &userService, BaseURL: cfg.ScimBaseUrl, MaxBodySize: cfg.MaxBodySize}
+	authenticators := []auth.IAuthenticator{}
+	if verifier != nil {
+		authenticators = append(authenticators, verifier)
+	}
+	if len(authenticators) == 0 && cfg.TlsClientCaFile == "" {
+		if !cfg.AuthDisabled {
+			log.Error.Fatalf("No token verification key nor client certificate CA configured, set AUTH_DISABLED=true to serve every route without authentication")
+		}
+		log.Info.Println("Authentication is disabled by AUTH_DISABLED, every operation is allowed")
+	}
+	var authenticate func(http.Handler) http.Handler
+	if len(authenticators) > 0 || cfg.TlsClientCaFile != "" {
+		policy, err := authz.LoadPolicy(cfg.AuthzPolicyFile)
+		if err != nil {
+			log.Error.Fatalf("Unable to load authorization policy: %v", err)
+		}
+		userController.Policy = policy
+		apiKeyController.Policy = policy
+		webhookController.Policy = policy
+		jobController.Policy = policy
+		userServer.Policy = policy
+		userSchema.Policy = policy
+		scimHandler.Policy = policy
+		grpcServer.Authenticators = append(authenticators, &apiKeyService)
+		authenticate = auth.Middleware(grpcServer.Authenticators...)
+	}
+	if cfg.TlsClientCaFile != "" {
+		roles, err := parseClientRoles(cfg.TlsClientRoles)
+		if err != nil {
+			log.Error.Fatalf("Unable to read client certificate roles: %v", err)
+		}
+		clientCertificate := &auth.ClientCertificate{Roles: roles}
+		grpcServer.ClientCertificate = clientCertificate
+		verify := authenticate
+		authenticate = func(next http.Handler) http.Handler {
+			return clientCertificate.Middleware(verify(next))
+		}
+	}
+
+	codecs := codec.Default()
+	codecs.MaxBodySize = cfg.MaxBodySize
+	idempotencyGuard := idempotency.Guard{Store: &idempotency.MemoryStore{}, TTL: cfg.IdempotencyTTL, Wait: cfg.IdempotencyWait, MaxBodySize: cfg.MaxBodySize}
+	userRoutes := router.UserRoutes{Controller: &userController, Codecs: codecs, Idempotency: &idempotencyGuard}
+	apiKeyRoutes := router.ApiKeyRoutes{Controller: &apiKeyController, Codecs: codecs}
+	webhookRoutes := router.WebhookRoutes{Controller: &webhookController, Codecs: codecs}
+	groups := append(userRoutes.Groups(), apiKeyRoutes.Groups()...)
+	jobRoutes := router.JobRoutes{Controller: &jobController, Codecs: codecs}
+	groups = append(groups, webhookRoutes.Groups()...)
+	groups = append(groups, jobRoutes.Groups()...)
+	limiter, err := newLimiter(cfg)
+	if err != nil {
+		log.Error.Fatalf("Unable to read rate limits: %v", err)
+	}
+	ipLimiter, err := newIpLimiter(cfg)
+	if err != nil {
+		log.Error.Fatalf("Unable to read the rate limit of client IPs: %v", err)
+	}
+	schema, err := userSchema.Build()
+	if err != nil {
+		log.Error.Fatalf("Unable to build the GraphQL schema: %v", err)
+	}
+	graphqlRoutes := router.GraphqlRoutes{Handler: &gql.Handler{
+		Schema:       schema,
+		Limits:       gql.Limits{MaxDepth: int(cfg.GraphqlMaxDepth), MaxComplexity: int(cfg.GraphqlMaxComplexity)},
+		MaxBatchSize: int(cfg.GraphqlMaxBatchSize),
+		MaxMutations: int(cfg.GraphqlMaxMutations),
+		Limiter:      limiter,
+		MaxBodySize:  cfg.MaxBodySize,
+	}, Idempotency: &idempotencyGuard}
+	groups = append(groups, graphqlRoutes.Groups()...)
+	scimRoutes := router.ScimRoutes{Handler: &scimHandler}
+	groups = append(groups, scimRoutes.Groups()...)
+	grpcServer.Limiter, grpcServer.IpLimiter = limiter, ipLimiter
+	if cfg.GrpcPort != "" {
+		go serveGrpc(cfg, &grpcServer, reloader)
+	}
+	handleRequests(cfg, catalog, authenticate, limiter, ipLimiter, reloader, groups)
+}
+
+// serveGrpc serves the gRPC API on its own port, over TLS with the reloader certificates unless it is nil
+func serveGrpc(cfg *config.Config, grpcServer *rpc.Server, reloader *certs.Reloader) {
+	if reloader != nil {
+		grpcServer.TLSConfig = reloader.TLSConfig()
+	}
+
+	grpcPort := fmt.Sprintf(":%s", cfg.GrpcPort)
+	listener, err := net.Listen("tcp", grpcPort)
+	if err != nil {
+		log.Error.Fatalf("Unable to listen on %v for gRPC: %v", grpcPort, err)
+	}
+	log.Info.Printf("Starting gRPC server on %v\n", grpcPort)
+	log.Error.Println(grpcServer.GrpcServer().Serve(listener))
+}
+
+// parseClientRoles reads the roles of client certificates, written as "<common name>:<role>" with one entry per role
+func parseClientRoles(values []string) (map[string][]string, error) {
+	roles := map[string][]string{}
+	for _, value := range values {
+		commonName, role, found := strings.Cut(value, ":")
+		if !found || commonName == "" || role == "" {
+			return nil, fmt.Errorf("%q should be written as <common name>:<role>", value)
+		}
+		roles[commonName] = append(roles[commonName], role)
+	}
+	return roles, nil
+}
+
+// newSink returns the sink receiving the user events
+func newSink(cfg *config.Config) (outbox.ISink, error) {
+	switch cfg.OutboxSink {
+	case outbox.SINK_STDOUT:
+		return &outbox.StdoutSink{}, nil
+	case outbox.SINK_FILE:
+		return &outbox.FileSink{Path: cfg.OutboxFile}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink %q", cfg.OutboxSink)
+	}
+}
+
+// exportUsers writes the users matching the filter flags to a file, like the export route does.
+// Logs go to the standard output, so the export is always written to a file.
+func exportUsers(userRepository repository.IUserRepository, cfg *config.Config, args []string) error {
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", codec.FORMAT_CSV, "file format: csv, ndjson or json")
+	output := flags.String("output", "", `file written, "users.<format>" when empty`)
+	filter := model.UserFilter{}
+	flags.StringVar(&filter.FirstName, "first-name", "", "keep the users whose first name contains this text")
+	flags.StringVar(&filter.LastName, "last-name", "", "keep the users whose last name contains this text")
+	flags.StringVar(&filter.Email, "email", "", "keep the users whose email contains this text")
+	flags.Int64Var(&filter.MinAge, "min-age", 0, "keep the users at least this old")
+	flags.Int64Var(&filter.MaxAge, "max-age", 0, "keep the users at most this old")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = "users." + *format
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rows, _, err := codec.RowWriter(*format, file)
+	if err != nil {
+		return err
+	}
+
+	exportService := service.UserExportService{Repository: userRepository, ChunkSize: int(cfg.ExportChunkSize)}
+	written, err := exportService.ExportUsers(context.Background(), filter, rows)
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Info.Printf("Exported %v users to %v", written, *output)
+	return nil
+}
+
+// reencryptUsers encrypts with the primary key every user stored in clear text or with an older key
+func reencryptUsers(userRepository *repository.UserRepository, cfg *config.Config) {
+	rewritten, err := userRepository.ReencryptUsers(int(cfg.ReencryptBatchSize))
+	if err != nil {
+		log.Error.Fatalf("Unable to re-encrypt users after %v: %v", rewritten, err)
+	}
+	log.Info.Printf("Re-encrypted %v users", rewritten)
+}
+
+// rotateKeys runs the rotate-keys command, re-encrypting the users with the primary key, and returns its exit code
+func rotateKeys(userRepository *repository.UserRepository, cfg *config.Config) int {
+	if userRepository.Cipher == nil {
+		log.Error.Println("Set ENCRYPTION_KEYRING_FILE to rotate encryption keys")
+		return 2
+	}
+	rewritten, err := userRepository.ReencryptUsers(int(cfg.ReencryptBatchSize))
+	if err != nil {
+		log.Error.Printf("Key rotation failed after re-encrypting %v users: %v", rewritten, err)
+		return 1
+	}
+	log.Info.Printf("Key rotation done, %v users re-encrypted with the primary key", rewritten)
+	return 0
+}
+
+// newRedactor returns the redactor of personal data in logs for the configured mode
+func newRedactor(cfg *config.Config) (*redact.Redactor, error) {
+
+	redactor := &redact.Redactor{Mode: cfg.LogPiiMode}
+	switch cfg.LogPiiMode {
+	case redact.MODE_MASK:
+	case redact.MODE_OFF:
+		log.Info.Println("Personal data is written to logs as it is")
+	case redact.MODE_HASH:
+		if cfg.LogPiiHashKeyFile == "" {
+			// hashes are still consistent while the server runs
+			log.Info.Println("No personal data hash key configured, using a random key")
+			redactor.Key = make([]byte, 32)
+			_, err := rand.Read(redactor.Key)
+			return redactor, err
+		}
+		key, err := os.ReadFile(cfg.LogPiiHashKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		redactor.Key = bytes.TrimSpace(key)
+	default:
+		return nil, fmt.Errorf("unknown mode %q, expected %v, %v or %v", cfg.LogPiiMode, redact.MODE_MASK, redact.MODE_HASH, redact.MODE_OFF)
+	}
+	return redactor, nil
+}
+
+// newLimiter returns the rate limiter for the configured limits, or nil when no limit is configured
+func newLimiter(cfg *config.Config) (*ratelimit.Limiter, error) {
+
+	limiter := &ratelimit.Limiter{Store: &ratelimit.MemoryStore{}}
+	if cfg.RateLimit != "" {
+		limit, err := ratelimit.ParseLimit(cfg.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+		limiter.Default = limit
+	}
+	for _, value := range cfg.RateLimitRules {
+		rule, err := ratelimit.ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		limiter.Rules = append(limiter.Rules, rule)
+	}
+
+	if limiter.Default.Unlimited() && len(limiter.Rules) == 0 {
+		log.Info.Println("No rate limit configured, requests are not limited")
+		return nil, nil
+	}
+	return limiter, nil
+}
+
+// newIpLimiter returns the rate limiter of client IPs running before authentication, or nil when it has no limit.
+// It has its own store, so that its buckets are not shared with the per-client limiter keying anonymous callers by IP.
+func newIpLimiter(cfg *config.Config) (*ratelimit.Limiter, error) {
+	if cfg.RateLimitIp == "" {
+		return nil, nil
+	}
+	limit, err := ratelimit.ParseLimit(cfg.RateLimitIp)
+	if err != nil {
+		return nil, err
+	}
+	return &ratelimit.Limiter{Store: &ratelimit.MemoryStore{}, Default: limit}, nil
 }
 
-func handleRequests(userRoutes *router.UserRoutes) {
+// newVerifier returns the bearer token verifier for the configured keys, or nil when no key is configured
+func newVerifier(cfg *config.Config) (*auth.Verifier, error) {
+
+	keys := auth.KeySet{}
+	if cfg.JwtHmacSecretFile != "" {
+		secret, err := auth.LoadHmacSecret(cfg.JwtHmacSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		keys.Keys = append(keys.Keys, secret)
+	}
+
+	publicKeys, err := auth.LoadPublicKeys(cfg.JwtPublicKeyFiles...)
+	if err != nil {
+		return nil, err
+	}
+	keys.Keys = append(keys.Keys, publicKeys...)
+
+	verifier := &auth.Verifier{Issuer: cfg.JwtIssuer, Audience: cfg.JwtAudience, Leeway: cfg.JwtLeeway}
+	if len(keys.Keys) > 0 {
+		verifier.Keys = append(verifier.Keys, &keys)
+	}
+	if cfg.JwksUrl != "" {
+		verifier.Keys = append(verifier.Keys, &auth.RemoteKeySet{URL: cfg.JwksUrl})
+	}
+
+	if len(verifier.Keys) == 0 {
+		return nil, nil
+	}
+	return verifier, nil
+}
+
+// handleRequests serves the route groups, behind the authenticate middleware unless it is nil or the group is public,
+// and behind the limiter unless it is nil. The ipLimiter, unless it is nil, runs before authentication on every route.
+// They are served over TLS with the reloader certificates unless it is nil.
+func handleRequests(cfg *config.Config, catalog *i18n.Catalog, authenticate func(http.Handler) http.Handler, limiter *ratelimit.Limiter, ipLimiter *ratelimit.Limiter, reloader *certs.Reloader, groups []router.Group) {
 	r := chi.NewRouter()
 
 	// Config
 	r.Use(middleware.RequestID)
+	if cfg.TrustProxyHeaders {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(log.RequestLogger)
 	r.Use(log.RequestFileLogger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
+	r.Use(router.Timeout(30 * time.Second))
 	r.Use(render.SetContentType(render.ContentTypeJSON))
+	r.Use(catalog.Middleware)
 
 	// CORS
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Access-Control-Allow-Origin"},
-		ExposedHeaders:   []string{"Content-Type", "JWT-Token"},
-		AllowCredentials: false,
-		MaxAge:           300, // Maximum value not ignored by any of major browsers
-	}))
-
-	// Routes
-	//r.Group(protectedRoutes)
-	r.Group(userRoutes.UserRoutes)
-	r.Group(userRoutes.SwaggerRoutes)
+	corsPolicy := router.CorsPolicy{
+		AllowedOrigins:   cfg.CorsAllowedOrigins,
+		AllowedMethods:   cfg.CorsAllowedMethods,
+		AllowedHeaders:   cfg.CorsAllowedHeaders,
+		ExposedHeaders:   cfg.CorsExposedHeaders,
+		AllowCredentials: cfg.CorsAllowCredentials,
+		MaxAge:           int(cfg.CorsMaxAge),
+	}
+	r.Use(corsPolicy.Handler())
+
+	// Browser protections
+	securityHeaders := router.SecurityHeaders{
+		HstsMaxAge:            cfg.HstsMaxAge,
+		FrameOptions:          cfg.FrameOptions,
+		ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+	}
+	r.Use(securityHeaders.Handler)
+	if cfg.CsrfProtection {
+		r.Use((&router.Csrf{}).Handler)
+	}
+
+	// Routes, with no credentials read yet the ip limiter keys every request by its IP
+	if ipLimiter != nil {
+		r.Use(ipLimiter.Middleware)
+	}
+	var limit func(http.Handler) http.Handler
+	if limiter != nil {
+		limit = limiter.Middleware
+	}
+	router.Mount(r, groups, authenticate, limit, cfg.PublicRouteGroups)
+	if err := corsPolicy.Check(r); err != nil {
+		log.Error.Fatalf("Invalid CORS configuration: %v", err)
+	}
 
 	//Run
-	httpPort := fmt.Sprintf(":%s", constants.HTTP_PORT)
+	httpPort := fmt.Sprintf(":%s", cfg.HttpPort)
+	if reloader == nil {
+		log.Info.Printf("Starting server on %v\n", httpPort)
+		log.Error.Println(http.ListenAndServe(httpPort, r))
+		return
+	}
 
-	log.Info.Printf("Starting server on %v\n", httpPort)
-	log.Error.Println(http.ListenAndServe(httpPort, r))
+	if cfg.HttpRedirect {
+		go func() {
+			log.Info.Printf("Redirecting %v to HTTPS\n", httpPort)
+			log.Error.Println(http.ListenAndServe(httpPort, router.RedirectToHttps(cfg.HttpsPort)))
+		}()
+	}
+	server := &http.Server{Addr: fmt.Sprintf(":%s", cfg.HttpsPort), Handler: r, TLSConfig: reloader.TLSConfig()}
+	log.Info.Printf("Starting server on %v with TLS\n", server.Addr)
+	log.Error.Println(server.ListenAndServeTLS("", ""))
 }
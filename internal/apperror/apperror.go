@@ -0,0 +1,64 @@
+package apperror
+
+import (
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// Kinds of domain errors. Use errors.Is to check the kind of an error returned by any layer.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("unavailable")
+	ErrInternal    = errors.New("internal error")
+	ErrForbidden   = errors.New("forbidden")
+)
+
+// Error is a domain error of a given kind wrapping the error that caused it.
+// Use errors.As to get the message meant for clients and the validation details.
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []model.FieldError
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As
+func (e *Error) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Kind, e.Cause}
+	}
+	return []error{e.Kind}
+}
+
+func NotFound(message string, cause error) *Error {
+	return &Error{Kind: ErrNotFound, Message: message, Cause: cause}
+}
+
+func Conflict(message string, cause error) *Error {
+	return &Error{Kind: ErrConflict, Message: message, Cause: cause}
+}
+
+func Validation(message string, fields []model.FieldError) *Error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+func Unavailable(message string, cause error) *Error {
+	return &Error{Kind: ErrUnavailable, Message: message, Cause: cause}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func Internal(message string, cause error) *Error {
+	return &Error{Kind: ErrInternal, Message: message, Cause: cause}
+}
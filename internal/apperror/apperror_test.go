@@ -0,0 +1,48 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{name: "NotFound", err: NotFound("user not found", gorm.ErrRecordNotFound), kind: ErrNotFound},
+		{name: "Conflict", err: Conflict("user already exists", gorm.ErrDuplicatedKey), kind: ErrConflict},
+		{name: "Validation", err: Validation("invalid user", nil), kind: ErrValidation},
+		{name: "Unavailable", err: Unavailable("database unavailable", nil), kind: ErrUnavailable},
+		{name: "Internal", err: Internal("database error", errors.New("boom")), kind: ErrInternal},
+		{name: "Forbidden", err: Forbidden("not allowed"), kind: ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("service: %w", tt.err)
+
+			assert.True(t, errors.Is(wrapped, tt.kind))
+			for _, other := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrUnavailable, ErrInternal, ErrForbidden} {
+				if other != tt.kind {
+					assert.False(t, errors.Is(wrapped, other))
+				}
+			}
+
+			var appErr *Error
+			assert.True(t, errors.As(wrapped, &appErr))
+			assert.Equal(t, tt.kind, appErr.Kind)
+		})
+	}
+}
+
+func TestError_Cause(t *testing.T) {
+	err := NotFound("user not found with id 1", gorm.ErrRecordNotFound)
+
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+	assert.EqualValues(t, "user not found with id 1: record not found", err.Error())
+	assert.EqualValues(t, "user not found with id 1", err.Message)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+)
+
+// CERT_SUBJECT prefixes the subject of callers authenticated by a client certificate, e.g. "cert:CN=billing,O=Acme"
+const CERT_SUBJECT = "cert:"
+
+// ClientCertificate authenticates the callers presenting a client certificate verified during the TLS handshake
+type ClientCertificate struct {
+	// Roles maps the common name of a certificate subject to the roles of its holder
+	Roles map[string][]string
+}
+
+// Middleware puts the claims of a verified client certificate on the request context, for Middleware to accept them.
+// Requests without a verified certificate are passed on unchanged.
+func (cc *ClientCertificate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject := r.TLS.VerifiedChains[0][0].Subject
+		claims := &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: CERT_SUBJECT + subject.String()},
+			Roles:            cc.Roles[subject.CommonName],
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientCertificate_Middleware(t *testing.T) {
+	clientCertificate := &ClientCertificate{Roles: map[string][]string{"billing": {"service"}}}
+	verifier := &Verifier{Keys: []IKeySet{&KeySet{Keys: []Key{{Key: hmacSecret}}}}}
+	handler := clientCertificate.Middleware(Middleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject + " " + strings.Join(claims.Roles, ",")))
+	})))
+	chain := func(commonName string) *tls.ConnectionState {
+		certificate := &x509.Certificate{Subject: pkix.Name{CommonName: commonName, Organization: []string{"Acme"}}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+	}
+
+	tests := []struct {
+		name          string
+		tls           *tls.ConnectionState
+		authorization string
+		status        int
+		body          string
+	}{
+		{name: "Known certificate", tls: chain("billing"), status: http.StatusOK, body: "cert:CN=billing,O=Acme service"},
+		{name: "Unknown certificate", tls: chain("reporting"), status: http.StatusOK, body: "cert:CN=reporting,O=Acme "},
+		{name: "Unverified certificate", tls: &tls.ConnectionState{}, status: http.StatusUnauthorized},
+		{name: "Plain HTTP", status: http.StatusUnauthorized},
+		{name: "Certificate and invalid token", tls: chain("billing"), authorization: "Bearer not.a.token", status: http.StatusUnauthorized},
+		{name: "Certificate and token", tls: chain("billing"), authorization: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims()), status: http.StatusOK, body: "user-1 admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/", nil)
+			req.TLS = tt.tls
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+}
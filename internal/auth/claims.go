@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the claims of a verified bearer token
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Scopes limit an api key to these actions. They are never read from tokens, whose OAuth scopes such as
+	// "openid profile" are not actions of the API.
+	Scopes []string `json:"-"`
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the claims of the authenticated caller
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the authenticated caller, if the request was authenticated
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJwksRefreshInterval is how long a fetched JWKS document is used before it is fetched again
+	DefaultJwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits how often a token with an unknown kid can trigger a fetch
+	jwksMinRefreshInterval = time.Minute
+	jwksMaxSize            = 1 << 20
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJwks reads the RSA and EC signature keys of a JWKS document; other keys are skipped
+func ParseJwks(data []byte) (*KeySet, error) {
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	set := &KeySet{Keys: []Key{}}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", jwk.Kid, err)
+		}
+		set.Keys = append(set.Keys, Key{ID: jwk.Kid, Key: key})
+	}
+	return set, nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// RemoteKeySet serves the keys of a JWKS document fetched over HTTP.
+// The document is fetched again once RefreshInterval has passed, or when a token refers to an unknown kid.
+type RemoteKeySet struct {
+	URL             string
+	Client          *http.Client
+	RefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        *KeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func (rks *RemoteKeySet) Key(token *jwt.Token) (interface{}, error) {
+
+	keys, err := rks.keySet(false)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keys.Key(token)
+	if errors.Is(err, ErrNoKey) {
+		if keys, err = rks.keySet(true); err != nil {
+			return nil, err
+		}
+		return keys.Key(token)
+	}
+	return key, err
+}
+
+// keySet returns the cached keys, fetching them again when they are stale or when forced.
+// Fetches are attempted at most once a minute, the last keys fetched are kept when a fetch fails.
+func (rks *RemoteKeySet) keySet(force bool) (*KeySet, error) {
+
+	rks.mu.Lock()
+	defer rks.mu.Unlock()
+
+	refreshInterval := rks.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultJwksRefreshInterval
+	}
+
+	stale := rks.keys == nil || time.Since(rks.fetchedAt) >= refreshInterval
+	if (!stale && !force) || time.Since(rks.attemptedAt) < jwksMinRefreshInterval {
+		if rks.keys == nil {
+			return nil, ErrNoKey
+		}
+		return rks.keys, nil
+	}
+
+	rks.attemptedAt = time.Now()
+	keys, err := rks.fetch()
+	if err != nil {
+		log.Error.Printf("Unable to fetch JWKS from %v: %v", rks.URL, err)
+		if rks.keys != nil {
+			return rks.keys, nil
+		}
+		return nil, err
+	}
+
+	rks.keys = keys
+	rks.fetchedAt = rks.attemptedAt
+	return keys, nil
+}
+
+func (rks *RemoteKeySet) fetch() (*KeySet, error) {
+
+	client := rks.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	response, err := client.Get(rks.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", response.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, jwksMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJwks(data)
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func encode(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func jwksDocument(kid string) string {
+	return fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"%v","use":"sig","n":"%v","e":"%v"},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":"%v","y":"%v"},
+		{"kty":"RSA","kid":"enc-1","use":"enc","n":"%v","e":"AQAB"},
+		{"kty":"oct","kid":"oct-1","k":"c2VjcmV0"}
+	]}`, kid, encode(rsaKey.N), encode(big.NewInt(int64(rsaKey.E))), encode(ecKey.X), encode(ecKey.Y), encode(rsaKey.N))
+}
+
+func TestParseJwks(t *testing.T) {
+	set, err := ParseJwks([]byte(jwksDocument("rsa-1")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Key{{ID: "rsa-1", Key: &rsaKey.PublicKey}, {ID: "ec-1", Key: &ecKey.PublicKey}}, set.Keys)
+
+	_, err = ParseJwks([]byte(`{"keys":[{"kty":"EC","kid":"ec-2","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	assert.EqualError(t, err, `jwks: key "ec-2": point is not on the curve`)
+}
+
+func TestRemoteKeySet_Key(t *testing.T) {
+	var fetches atomic.Int32
+	kid := "rsa-1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(jwksDocument(kid)))
+	}))
+	defer server.Close()
+
+	verifier := &Verifier{Keys: []IKeySet{&RemoteKeySet{URL: server.URL}}}
+
+	// keys are fetched once and cached
+	for i := 0; i < 2; i++ {
+		_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims()))
+		assert.Nil(t, err)
+	}
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// an unknown kid does not fetch the keys again within a minute of the last fetch
+	kid = "rsa-2"
+	_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", validClaims()))
+	assert.ErrorIs(t, err, ErrNoKey)
+	assert.EqualValues(t, 1, fetches.Load())
+}
+
+func TestRemoteKeySet_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	verifier := &Verifier{Keys: []IKeySet{&RemoteKeySet{URL: server.URL}}}
+	_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims()))
+
+	assert.ErrorContains(t, err, "unexpected status 500")
+}
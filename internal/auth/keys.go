@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"strings"
+)
+
+// ErrNoKey is returned when no configured key can verify a token
+var ErrNoKey = errors.New("no key matches the token")
+
+// IKeySet looks up the keys that may verify a token, it is used as a jwt.Keyfunc
+type IKeySet interface {
+	Key(token *jwt.Token) (interface{}, error)
+}
+
+// Key is a verification key, with the id tokens refer to it by in their kid header
+type Key struct {
+	ID  string
+	Key interface{}
+}
+
+// KeySet is a fixed set of keys, loaded from files or a JWKS document
+type KeySet struct {
+	Keys []Key
+}
+
+// Key returns every key of the token's kid that is compatible with its signing method,
+// so an RSA public key can never be used as an HMAC secret
+func (ks *KeySet) Key(token *jwt.Token) (interface{}, error) {
+
+	kid, _ := token.Header["kid"].(string)
+	keys := []jwt.VerificationKey{}
+	for _, k := range ks.Keys {
+		if kid != "" && k.ID != "" && k.ID != kid {
+			continue
+		}
+		if compatible(token.Method, k.Key) {
+			keys = append(keys, k.Key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+func compatible(method jwt.SigningMethod, key interface{}) bool {
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok := key.([]byte)
+		return ok
+	case *jwt.SigningMethodRSA:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := key.(*ecdsa.PublicKey)
+		return ok
+	}
+	return false
+}
+
+// LoadHmacSecret reads an HS256 secret from a file, ignoring surrounding whitespace
+func LoadHmacSecret(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	secret := bytes.TrimSpace(data)
+	if len(secret) < 32 {
+		return Key{}, fmt.Errorf("hmac secret %v: must be at least 32 bytes", path)
+	}
+	return Key{Key: secret}, nil
+}
+
+// LoadPublicKeys reads RSA or EC public keys from PEM files, or from JWKS documents for files ending in .json.
+// Keys read from PEM files take the file name without extension as key id.
+func LoadPublicKeys(paths ...string) ([]Key, error) {
+
+	keys := []Key{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(path, ".json") {
+			set, err := ParseJwks(data)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", path, err)
+			}
+			keys = append(keys, set.Keys...)
+			continue
+		}
+
+		key, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		keys = append(keys, Key{ID: keyIdFromPath(path), Key: key})
+	}
+	return keys, nil
+}
+
+func parsePublicKey(data []byte) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("not a PEM encoded RSA or EC public key")
+}
+
+func keyIdFromPath(path string) string {
+	name := path[strings.LastIndexAny(path, `/\`)+1:]
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		name = name[:dot]
+	}
+	return name
+}
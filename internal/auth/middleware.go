@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// IAuthenticator checks the credentials sent with one Authorization scheme, e.g. "Bearer <token>"
+type IAuthenticator interface {
+	Scheme() string
+	Authenticate(ctx context.Context, credentials string) (*Claims, error)
+}
+
+// Middleware rejects requests without valid credentials for one of the authenticators,
+// and puts the claims of the caller on the request context.
+// Requests already authenticated, e.g. by a client certificate, are accepted unless they also send credentials.
+func Middleware(authenticators ...IAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			authorization := r.Header.Get("Authorization")
+			if _, ok := ClaimsFromContext(r.Context()); ok && authorization == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, credentials, _ := strings.Cut(authorization, " ")
+			credentials = strings.TrimSpace(credentials)
+
+			for _, authenticator := range authenticators {
+				if !strings.EqualFold(scheme, authenticator.Scheme()) {
+					continue
+				}
+				if credentials == "" {
+					break
+				}
+
+				claims, err := authenticator.Authenticate(r.Context(), credentials)
+				if errors.Is(err, apperror.ErrUnavailable) {
+					log.Error.Println(err)
+					utils.ResponseLocalizedError(w, r, http.StatusServiceUnavailable)
+					return
+				}
+				if err != nil {
+					log.Info.Printf("Rejected %v credentials: %v", authenticator.Scheme(), err)
+					unauthorized(w, r, authenticators, authenticator.Scheme())
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+				return
+			}
+
+			unauthorized(w, r, authenticators, "")
+		})
+	}
+}
+
+// unauthorized answers 401 with a challenge for every accepted scheme, as described in RFC 6750.
+// The challenge of the scheme whose credentials were rejected reports an invalid token.
+func unauthorized(w http.ResponseWriter, r *http.Request, authenticators []IAuthenticator, rejectedScheme string) {
+	for _, authenticator := range authenticators {
+		challenge := authenticator.Scheme()
+		if challenge == rejectedScheme {
+			challenge = fmt.Sprintf(`%v error="invalid_token"`, challenge)
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+	}
+	utils.ResponseLocalizedError(w, r, http.StatusUnauthorized)
+}
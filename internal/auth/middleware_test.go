@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type authenticatorMock struct {
+	scheme       string
+	authenticate func(credentials string) (*Claims, error)
+}
+
+func (am *authenticatorMock) Scheme() string {
+	return am.scheme
+}
+
+func (am *authenticatorMock) Authenticate(ctx context.Context, credentials string) (*Claims, error) {
+	return am.authenticate(credentials)
+}
+
+func TestMiddleware(t *testing.T) {
+	verifier := &Verifier{Keys: []IKeySet{&KeySet{Keys: []Key{{Key: hmacSecret}}}}}
+	apiKeys := &authenticatorMock{scheme: "ApiKey", authenticate: func(credentials string) (*Claims, error) {
+		switch credentials {
+		case "ak_1.secret":
+			return &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:1"}}, nil
+		case "ak_2.secret":
+			return nil, apperror.Unavailable("database unavailable", nil)
+		}
+		return nil, errors.New("invalid api key")
+	}}
+	handler := Middleware(verifier, apiKeys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		challenges    []string
+		body          string
+	}{
+		{name: "Bearer", authorization: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims()), status: http.StatusOK, body: "user-1"},
+		{name: "ApiKey", authorization: "ApiKey ak_1.secret", status: http.StatusOK, body: "apikey:1"},
+		{name: "Scheme case", authorization: "apikey ak_1.secret", status: http.StatusOK, body: "apikey:1"},
+		{name: "Missing", status: http.StatusUnauthorized, challenges: []string{"Bearer", "ApiKey"}},
+		{name: "Empty credentials", authorization: "ApiKey ", status: http.StatusUnauthorized, challenges: []string{"Bearer", "ApiKey"}},
+		{name: "Invalid ApiKey", authorization: "ApiKey ak_3.secret", status: http.StatusUnauthorized, challenges: []string{"Bearer", `ApiKey error="invalid_token"`}},
+		{name: "Unavailable", authorization: "ApiKey ak_2.secret", status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.challenges, rr.Header().Values("WWW-Authenticate"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"time"
+)
+
+// SigningMethods are the token algorithms accepted by the verifier
+var SigningMethods = []string{"HS256", "RS256", "ES256"}
+
+// Verifier authenticates requests carrying a bearer token signed by one of its keys.
+// Issuer and Audience are only checked when set, tokens must always carry an expiry.
+type Verifier struct {
+	Keys     []IKeySet
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// Verify checks the signature and the registered claims of a token and returns its claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(SigningMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.Leeway),
+	}
+	if v.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.Issuer))
+	}
+	if v.Audience != "" {
+		options = append(options, jwt.WithAudience(v.Audience))
+	}
+
+	claims := &Claims{}
+	if _, err := jwt.NewParser(options...).ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key gathers the candidate keys of every key set
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+
+	keys := []jwt.VerificationKey{}
+	for _, set := range v.Keys {
+		key, err := set.Key(token)
+		if errors.Is(err, ErrNoKey) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(jwt.VerificationKeySet).Keys...)
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+// Scheme returns the Authorization scheme of bearer tokens
+func (v *Verifier) Scheme() string {
+	return "Bearer"
+}
+
+// Authenticate verifies a bearer token
+func (v *Verifier) Authenticate(ctx context.Context, token string) (*Claims, error) {
+	return v.Verify(token)
+}
+
+// Middleware rejects requests without a valid bearer token and puts the token claims on the request context
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return Middleware(v)(next)
+}
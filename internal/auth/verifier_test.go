@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, _  = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _   = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims() *Claims {
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    "https://issuer.example.com",
+			Audience:  jwt.ClaimStrings{"users-api"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: []string{"admin"},
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	verifier := &Verifier{
+		Keys: []IKeySet{&KeySet{Keys: []Key{
+			{Key: hmacSecret},
+			{ID: "rsa-1", Key: &rsaKey.PublicKey},
+			{ID: "ec-1", Key: &ecKey.PublicKey},
+		}}},
+		Issuer:   "https://issuer.example.com",
+		Audience: "users-api",
+	}
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	otherIssuer := validClaims()
+	otherIssuer.Issuer = "https://other.example.com"
+	otherAudience := validClaims()
+	otherAudience.Audience = jwt.ClaimStrings{"orders-api"}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "HS256", token: sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims())},
+		{name: "RS256", token: sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims())},
+		{name: "ES256", token: sign(t, jwt.SigningMethodES256, ecKey, "", validClaims())},
+		{name: "Expired", token: sign(t, jwt.SigningMethodHS256, hmacSecret, "", expired), wantErr: jwt.ErrTokenExpired},
+		{name: "Without expiry", token: sign(t, jwt.SigningMethodHS256, hmacSecret, "", noExpiry), wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "Other issuer", token: sign(t, jwt.SigningMethodHS256, hmacSecret, "", otherIssuer), wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "Other audience", token: sign(t, jwt.SigningMethodHS256, hmacSecret, "", otherAudience), wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "Unknown key", token: sign(t, jwt.SigningMethodRS256, otherKey, "", validClaims()), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "Unknown kid", token: sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", validClaims()), wantErr: ErrNoKey},
+		{name: "Unsupported method", token: sign(t, jwt.SigningMethodHS512, hmacSecret, "", validClaims()), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "None", token: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims()), wantErr: jwt.ErrTokenSignatureInvalid},
+		{
+			name:    "Public key used as HMAC secret",
+			token:   sign(t, jwt.SigningMethodHS256, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), "rsa-1", validClaims()),
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, claims)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "user-1", claims.Subject)
+			assert.Equal(t, []string{"admin"}, claims.Roles)
+		})
+	}
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	verifier := &Verifier{Keys: []IKeySet{&KeySet{Keys: []Key{{Key: hmacSecret}}}}}
+	handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		assert.True(t, ok)
+		w.Write([]byte(claims.Subject))
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		challenge     string
+		body          string
+	}{
+		{name: "Valid", authorization: "Bearer " + sign(t, jwt.SigningMethodHS256, hmacSecret, "", validClaims()), status: http.StatusOK, body: "user-1"},
+		{name: "Missing", status: http.StatusUnauthorized, challenge: "Bearer", body: `{"code":401,"message":"Authorization information is missing or invalid."}`},
+		{name: "Other scheme", authorization: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "Invalid", authorization: "Bearer not.a.token", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+			assert.Equal(t, tt.challenge, rr.Header().Get("WWW-Authenticate"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	rsaDer, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	ecDer, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+
+	secret, err := LoadHmacSecret(write("secret", append(hmacSecret, '\n')))
+	assert.Nil(t, err)
+	assert.Equal(t, hmacSecret, secret.Key)
+
+	_, err = LoadHmacSecret(write("short", []byte("secret")))
+	assert.EqualError(t, err, "hmac secret "+filepath.Join(dir, "short")+": must be at least 32 bytes")
+
+	keys, err := LoadPublicKeys(
+		write("rsa-1.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaDer})),
+		write("ec-1.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecDer})),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []Key{{ID: "rsa-1", Key: &rsaKey.PublicKey}, {ID: "ec-1", Key: &ecKey.PublicKey}}, keys)
+
+	_, err = LoadPublicKeys(write("invalid.pem", []byte("not a key")))
+	assert.EqualError(t, err, filepath.Join(dir, "invalid.pem")+": not a PEM encoded RSA or EC public key")
+}
@@ -0,0 +1,161 @@
+package authz
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"os"
+	"strconv"
+)
+
+// Actions on user records
+const (
+	ACTION_LIST     = "users:list"
+	ACTION_READ     = "users:read"
+	ACTION_CREATE   = "users:create"
+	ACTION_UPDATE   = "users:update"
+	ACTION_DELETE   = "users:delete"
+	ACTION_VALIDATE = "users:validate"
+)
+
+// Actions on api keys
+const (
+	ACTION_API_KEYS_LIST   = "apikeys:list"
+	ACTION_API_KEYS_CREATE = "apikeys:create"
+	ACTION_API_KEYS_REVOKE = "apikeys:revoke"
+)
+
+// Actions on webhooks
+const (
+	ACTION_WEBHOOKS_LIST      = "webhooks:list"
+	ACTION_WEBHOOKS_CREATE    = "webhooks:create"
+	ACTION_WEBHOOKS_DELETE    = "webhooks:delete"
+	ACTION_WEBHOOKS_REDELIVER = "webhooks:redeliver"
+)
+
+// Actions on background jobs, submitting a job needing the permission of the action it performs on users
+const (
+	ACTION_JOBS_LIST   = "jobs:list"
+	ACTION_JOBS_CANCEL = "jobs:cancel"
+)
+
+const (
+	// ROLE_ANONYMOUS is the role of callers that did not authenticate, it has no permission unless the policy grants some
+	ROLE_ANONYMOUS = "anonymous"
+	// ROLE_SERVICE is the role of callers authenticated with an api key, whose scopes narrow it down
+	ROLE_SERVICE = "service"
+)
+
+var actions = []string{
+	ACTION_LIST, ACTION_READ, ACTION_CREATE, ACTION_UPDATE, ACTION_DELETE, ACTION_VALIDATE,
+	ACTION_API_KEYS_LIST, ACTION_API_KEYS_CREATE, ACTION_API_KEYS_REVOKE,
+	ACTION_WEBHOOKS_LIST, ACTION_WEBHOOKS_CREATE, ACTION_WEBHOOKS_DELETE, ACTION_WEBHOOKS_REDELIVER,
+	ACTION_JOBS_LIST, ACTION_JOBS_CANCEL,
+}
+
+// KnownAction tells whether an action can be granted by a policy or a scope
+func KnownAction(action string) bool {
+	return contains(actions, action)
+}
+
+//go:embed policy.json
+var defaultPolicy []byte
+
+type IPolicy interface {
+	Authorize(ctx context.Context, action string, userId int64) error
+}
+
+// Authorize asks the policy whether the caller on the context may perform the action, on the given user when userId
+// is not 0. A nil policy allows every action, servers only run without one when AUTH_DISABLED is set.
+func Authorize(ctx context.Context, policy IPolicy, action string, userId int64) error {
+	if policy == nil {
+		return nil
+	}
+	return policy.Authorize(ctx, action, userId)
+}
+
+// Role lists the actions granted to the callers holding it
+type Role struct {
+	// Permissions are the actions allowed on any user
+	Permissions []string `json:"permissions"`
+	// OwnPermissions are the actions allowed on the caller's own user, whose id is the token subject
+	OwnPermissions []string `json:"own_permissions"`
+}
+
+// Policy grants actions to roles, a caller may perform an action when any of its roles allows it
+type Policy struct {
+	Roles map[string]Role `json:"roles"`
+}
+
+// LoadPolicy reads a policy from a JSON file, or returns the built-in policy when path is empty
+func LoadPolicy(path string) (*Policy, error) {
+
+	data := defaultPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("policy %v: %w", path, err)
+	}
+
+	for name, role := range policy.Roles {
+		for _, action := range append(role.Permissions, role.OwnPermissions...) {
+			if !KnownAction(action) {
+				return nil, fmt.Errorf("policy %v: role %q: unknown action %q", path, name, action)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// Authorize returns a forbidden error unless the caller on the context may perform the action.
+// userId is the id of the user acted upon, or 0 when the action does not target a single user.
+// When the caller is an api key limited to scopes, the action must also be one of its scopes.
+func (p *Policy) Authorize(ctx context.Context, action string, userId int64) error {
+
+	roles := []string{ROLE_ANONYMOUS}
+	subject := ""
+	scopes := []string{}
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		roles = claims.Roles
+		subject = claims.Subject
+		scopes = claims.Scopes
+	}
+
+	if len(scopes) > 0 && !contains(scopes, action) {
+		return apperror.Forbidden(fmt.Sprintf("%q with scopes %v may not %v", subject, scopes, action))
+	}
+
+	own := userId != 0 && subject == strconv.FormatInt(userId, 10)
+	for _, name := range roles {
+		role, found := p.Roles[name]
+		if !found {
+			continue
+		}
+		if contains(role.Permissions, action) || (own && contains(role.OwnPermissions, action)) {
+			return nil
+		}
+	}
+
+	if userId != 0 {
+		return apperror.Forbidden(fmt.Sprintf("%q with roles %v may not %v user %v", subject, roles, action, userId))
+	}
+	return apperror.Forbidden(fmt.Sprintf("%q with roles %v may not %v", subject, roles, action))
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func callerContext(subject string, roles ...string) context.Context {
+	if subject == "" && len(roles) == 0 {
+		return context.Background()
+	}
+	return auth.NewContext(context.Background(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Roles: roles})
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	policy, err := LoadPolicy("")
+	assert.Nil(t, err)
+
+	// claims read from a token the way the verifier reads them, with the OAuth scopes of an identity provider
+	oidc := &auth.Claims{}
+	if err := json.Unmarshal([]byte(`{"sub":"4","roles":["editor"],"scope":"openid profile email"}`), oidc); err != nil {
+		t.Fatal(err)
+	}
+
+	const ownId, otherId = 7, 8
+	callers := map[string]context.Context{
+		"admin":     callerContext("1", "admin"),
+		"editor":    callerContext("2", "editor"),
+		"viewer":    callerContext("3", "viewer"),
+		"user":      callerContext("7", "user"),
+		"no role":   callerContext("7"),
+		"unknown":   callerContext("7", "auditor"),
+		"anonymous": callerContext(""),
+		"service":   callerContext("apikey:1", "service"),
+		"oidc":      auth.NewContext(context.Background(), oidc),
+		"scoped": auth.NewContext(context.Background(), &auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:2"}, Roles: []string{"service"}, Scopes: []string{"users:list", "users:read"},
+		}),
+	}
+
+	tests := []struct {
+		caller  string
+		action  string
+		userId  int64
+		allowed bool
+	}{
+		{caller: "admin", action: ACTION_LIST, allowed: true},
+		{caller: "admin", action: ACTION_READ, userId: otherId, allowed: true},
+		{caller: "admin", action: ACTION_CREATE, allowed: true},
+		{caller: "admin", action: ACTION_UPDATE, userId: otherId, allowed: true},
+		{caller: "admin", action: ACTION_DELETE, userId: otherId, allowed: true},
+		{caller: "admin", action: ACTION_VALIDATE, allowed: true},
+		{caller: "admin", action: ACTION_API_KEYS_LIST, allowed: true},
+		{caller: "admin", action: ACTION_API_KEYS_CREATE, allowed: true},
+		{caller: "admin", action: ACTION_API_KEYS_REVOKE, allowed: true},
+		{caller: "admin", action: ACTION_WEBHOOKS_CREATE, allowed: true},
+		{caller: "admin", action: ACTION_WEBHOOKS_REDELIVER, allowed: true},
+		{caller: "admin", action: ACTION_JOBS_CANCEL, allowed: true},
+
+		{caller: "editor", action: ACTION_LIST, allowed: true},
+		{caller: "editor", action: ACTION_READ, userId: otherId, allowed: true},
+		{caller: "editor", action: ACTION_CREATE, allowed: true},
+		{caller: "editor", action: ACTION_UPDATE, userId: otherId, allowed: true},
+		{caller: "editor", action: ACTION_DELETE, userId: otherId, allowed: false},
+		{caller: "editor", action: ACTION_VALIDATE, allowed: true},
+		{caller: "editor", action: ACTION_API_KEYS_CREATE, allowed: false},
+		{caller: "editor", action: ACTION_WEBHOOKS_LIST, allowed: false},
+		{caller: "editor", action: ACTION_JOBS_LIST, allowed: true},
+
+		{caller: "viewer", action: ACTION_LIST, allowed: true},
+		{caller: "viewer", action: ACTION_READ, userId: otherId, allowed: true},
+		{caller: "viewer", action: ACTION_CREATE, allowed: false},
+		{caller: "viewer", action: ACTION_JOBS_LIST, allowed: false},
+		{caller: "viewer", action: ACTION_UPDATE, userId: otherId, allowed: false},
+		{caller: "viewer", action: ACTION_DELETE, userId: otherId, allowed: false},
+		{caller: "viewer", action: ACTION_VALIDATE, allowed: false},
+
+		{caller: "user", action: ACTION_LIST, allowed: false},
+		{caller: "user", action: ACTION_READ, userId: ownId, allowed: true},
+		{caller: "user", action: ACTION_READ, userId: otherId, allowed: false},
+		{caller: "user", action: ACTION_CREATE, allowed: false},
+		{caller: "user", action: ACTION_UPDATE, userId: ownId, allowed: true},
+		{caller: "user", action: ACTION_UPDATE, userId: otherId, allowed: false},
+		{caller: "user", action: ACTION_DELETE, userId: ownId, allowed: false},
+		{caller: "user", action: ACTION_DELETE, userId: otherId, allowed: false},
+		{caller: "user", action: ACTION_VALIDATE, allowed: false},
+
+		{caller: "service", action: ACTION_LIST, allowed: true},
+		{caller: "service", action: ACTION_DELETE, userId: otherId, allowed: true},
+		{caller: "service", action: ACTION_API_KEYS_CREATE, allowed: false},
+		{caller: "oidc", action: ACTION_LIST, allowed: true},
+		{caller: "oidc", action: ACTION_UPDATE, userId: otherId, allowed: true},
+		{caller: "oidc", action: ACTION_DELETE, userId: otherId, allowed: false},
+		{caller: "scoped", action: ACTION_LIST, allowed: true},
+		{caller: "scoped", action: ACTION_READ, userId: otherId, allowed: true},
+		{caller: "scoped", action: ACTION_CREATE, allowed: false},
+		{caller: "scoped", action: ACTION_DELETE, userId: otherId, allowed: false},
+
+		{caller: "no role", action: ACTION_READ, userId: ownId, allowed: false},
+		{caller: "unknown", action: ACTION_READ, userId: ownId, allowed: false},
+		{caller: "anonymous", action: ACTION_LIST, allowed: false},
+		{caller: "anonymous", action: ACTION_READ, userId: ownId, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caller+" "+tt.action, func(t *testing.T) {
+			err := policy.Authorize(callers[tt.caller], tt.action, tt.userId)
+			if tt.allowed {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, apperror.ErrForbidden)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	policy, err := LoadPolicy(write("anonymous.json", `{"roles":{"anonymous":{"permissions":["users:list"]}}}`))
+	assert.Nil(t, err)
+	assert.Nil(t, policy.Authorize(context.Background(), ACTION_LIST, 0))
+	assert.ErrorIs(t, policy.Authorize(callerContext("1", "admin"), ACTION_LIST, 0), apperror.ErrForbidden)
+
+	path := write("unknown.json", `{"roles":{"admin":{"permissions":["users:purge"]}}}`)
+	_, err = LoadPolicy(path)
+	assert.EqualError(t, err, `policy `+path+`: role "admin": unknown action "users:purge"`)
+
+	_, err = LoadPolicy(filepath.Join(dir, "missing.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAuthorize(t *testing.T) {
+	// Given
+	policy, err := LoadPolicy("")
+	assert.Nil(t, err)
+
+	// When
+	allowed := Authorize(callerContext("3", "viewer"), policy, ACTION_LIST, 0)
+	denied := Authorize(callerContext("3", "viewer"), policy, ACTION_DELETE, 1)
+	disabled := Authorize(context.Background(), nil, ACTION_DELETE, 1)
+
+	// Then
+	assert.Nil(t, allowed)
+	assert.ErrorIs(t, denied, apperror.ErrForbidden)
+	assert.Nil(t, disabled)
+}
@@ -0,0 +1,137 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the certificate files are checked for changes
+const DefaultReloadInterval = 30 * time.Second
+
+// Reloader serves the TLS certificate of the server, and the CA bundle verifying client certificates,
+// reloading them when their files change so that rotated certificates are used without a restart
+type Reloader struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM bundle verifying client certificates, which are not requested when empty
+	ClientCAFile string
+	// ClientCertRequired rejects clients without a verified certificate, otherwise they are only verified when sent
+	ClientCertRequired bool
+	// Interval is how often the files are checked, DefaultReloadInterval is used when 0
+	Interval time.Duration
+
+	mu      sync.Mutex
+	config  *tls.Config
+	stamp   string
+	checked time.Time
+}
+
+// Load reads the files, it must succeed before the server starts
+func (rl *Reloader) Load() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	stamp, err := rl.fileStamp()
+	if err != nil {
+		return err
+	}
+	return rl.load(stamp)
+}
+
+// TLSConfig returns the server configuration, whose certificates are reloaded on handshakes once their files change
+func (rl *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return rl.current()
+		},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			config, err := rl.current()
+			if err != nil {
+				return nil, err
+			}
+			return &config.Certificates[0], nil
+		},
+	}
+}
+
+// current returns the configuration of the latest readable files.
+// A failed reload keeps the previous configuration, as files may be caught half written during a rotation.
+func (rl *Reloader) current() (*tls.Config, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.config == nil {
+		return nil, errors.New("certs: certificates are not loaded")
+	}
+
+	interval := rl.Interval
+	if interval == 0 {
+		interval = DefaultReloadInterval
+	}
+	if time.Since(rl.checked) < interval {
+		return rl.config, nil
+	}
+	rl.checked = time.Now()
+
+	stamp, err := rl.fileStamp()
+	if err == nil && stamp != rl.stamp {
+		err = rl.load(stamp)
+		if err == nil {
+			log.Info.Printf("Reloaded TLS certificate %v", rl.CertFile)
+		}
+	}
+	if err != nil {
+		log.Error.Printf("Unable to reload TLS certificates, keeping the previous ones: %v", err)
+	}
+	return rl.config, nil
+}
+
+func (rl *Reloader) load(stamp string) error {
+
+	certificate, err := tls.LoadX509KeyPair(rl.CertFile, rl.KeyFile)
+	if err != nil {
+		return fmt.Errorf("certs: loading %v: %w", rl.CertFile, err)
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{certificate}}
+	if rl.ClientCAFile != "" {
+		pem, err := os.ReadFile(rl.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("certs: reading %v: %w", rl.ClientCAFile, err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("certs: no certificate found in %v", rl.ClientCAFile)
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if rl.ClientCertRequired {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	rl.config, rl.stamp, rl.checked = config, stamp, time.Now()
+	return nil
+}
+
+// fileStamp identifies the current version of the files from their sizes and modification times
+func (rl *Reloader) fileStamp() (string, error) {
+	stamp := ""
+	for _, path := range []string{rl.CertFile, rl.KeyFile, rl.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("certs: %w", err)
+		}
+		stamp += fmt.Sprintf("%v:%v:%v;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp, nil
+}
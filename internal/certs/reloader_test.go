@@ -0,0 +1,185 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type issued struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// issue creates a certificate signed by the parent, or self-signed when parent is nil
+func issue(t *testing.T, commonName string, parent *issued, isCA bool) *issued {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{"localhost"},
+	}
+	signer := &issued{certificate: template, key: key}
+	if parent != nil {
+		signer = parent
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.certificate, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	return &issued{certificate: certificate, key: key}
+}
+
+func (i *issued) write(t *testing.T, certFile string, keyFile string) {
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.certificate.Raw})
+	if err := os.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, _ := x509.MarshalECPrivateKey(i.key)
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (i *issued) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{i.certificate.Raw}, PrivateKey: i.key}
+}
+
+// serve accepts TLS connections with the reloader configuration until the test ends
+func serve(t *testing.T, reloader *Reloader) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+				conn.Write([]byte("ok"))
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// handshake connects to the server and returns the serial number of its certificate
+func handshake(address string, ca *issued, client *issued) (*big.Int, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if client != nil {
+		config.Certificates = []tls.Certificate{client.tlsCertificate()}
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// client certificate failures are reported by the server after the client side of the handshake
+	if _, err := conn.Read(make([]byte, 2)); err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber, nil
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	ca := issue(t, "ca", nil, true)
+	first := issue(t, "localhost", ca, false)
+	first.write(t, certFile, keyFile)
+
+	reloader := &Reloader{CertFile: certFile, KeyFile: keyFile, Interval: time.Nanosecond}
+	assert.Nil(t, reloader.Load())
+	address := serve(t, reloader)
+
+	serial, err := handshake(address, ca, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, first.certificate.SerialNumber, serial)
+
+	// a rotation caught half way, with the new certificate but the old key, keeps the previous certificate
+	second := issue(t, "localhost", ca, false)
+	second.write(t, certFile, "")
+	serial, err = handshake(address, ca, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, first.certificate.SerialNumber, serial)
+
+	second.write(t, certFile, keyFile)
+	serial, err = handshake(address, ca, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, second.certificate.SerialNumber, serial)
+}
+
+func TestReloader_ClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt")
+	ca := issue(t, "ca", nil, true)
+	issue(t, "localhost", ca, false).write(t, certFile, keyFile)
+	ca.write(t, caFile, "")
+	client := issue(t, "billing", ca, false)
+	stranger := issue(t, "billing", issue(t, "other ca", nil, true), false)
+
+	tests := []struct {
+		name     string
+		required bool
+		client   *issued
+		ok       bool
+	}{
+		{name: "Required with certificate", required: true, client: client, ok: true},
+		{name: "Required without certificate", required: true, ok: false},
+		{name: "Required with unknown issuer", required: true, client: stranger, ok: false},
+		{name: "Optional without certificate", required: false, ok: true},
+		// clients only send a certificate issued by one of the CAs the server asks for
+		{name: "Optional with unknown issuer", required: false, client: stranger, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloader := &Reloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientCertRequired: tt.required}
+			assert.Nil(t, reloader.Load())
+
+			_, err := handshake(serve(t, reloader), ca, tt.client)
+
+			assert.Equal(t, tt.ok, err == nil, "%v", err)
+		})
+	}
+}
+
+func TestReloader_Load(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	issue(t, "localhost", nil, false).write(t, certFile, keyFile)
+
+	assert.Nil(t, (&Reloader{CertFile: certFile, KeyFile: keyFile}).Load())
+	assert.NotNil(t, (&Reloader{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")}).Load())
+	assert.NotNil(t, (&Reloader{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}).Load(), "a bundle without certificates is refused")
+}
@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBodySize is the largest request body accepted when none is configured
+const DefaultMaxBodySize int64 = 1 << 20
+
+// ErrUnsupportedValue is returned by a codec that cannot represent a value, e.g. a nested struct in CSV
+var ErrUnsupportedValue = errors.New("value cannot be represented in this format")
+
+// Codec encodes and decodes values in one format
+type Codec interface {
+	// MediaTypes returns the media types handled by the codec, the first one is used for responses
+	MediaTypes() []string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Registry holds the codecs available for requests and responses, the first one being the default
+type Registry struct {
+	codecs      []Codec
+	MaxBodySize int64
+}
+
+// NewRegistry returns a registry of the given codecs
+func NewRegistry(maxBodySize int64, codecs ...Codec) *Registry {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	return &Registry{codecs: codecs, MaxBodySize: maxBodySize}
+}
+
+// Default returns a registry of every built-in codec with JSON as the default
+func Default() *Registry {
+	return NewRegistry(DefaultMaxBodySize, JSON{}, XML{}, CSV{}, YAML{}, NDJSON{})
+}
+
+// Register adds a codec, replacing the codecs already registered for the same media types
+func (reg *Registry) Register(c Codec) {
+	for _, mediaType := range c.MediaTypes() {
+		if existing, ok := reg.lookup(mediaType); ok {
+			reg.remove(existing)
+		}
+	}
+	reg.codecs = append(reg.codecs, c)
+}
+
+// MediaTypes returns the media types of every registered codec
+func (reg *Registry) MediaTypes() []string {
+	mediaTypes := []string{}
+	for _, c := range reg.codecs {
+		mediaTypes = append(mediaTypes, c.MediaTypes()...)
+	}
+	return mediaTypes
+}
+
+// ForContentType returns the codec for a Content-Type header; a missing header selects the default codec
+func (reg *Registry) ForContentType(contentType string) (Codec, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return reg.codecs[0], true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	return reg.lookup(mediaType)
+}
+
+// ForAccept returns the preferred codec for an Accept header; a missing header selects the default codec
+func (reg *Registry) ForAccept(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return reg.codecs[0], true
+	}
+	for _, mediaRange := range parseAccept(accept) {
+		switch {
+		case mediaRange == "*/*":
+			return reg.codecs[0], true
+		case strings.HasSuffix(mediaRange, "/*"):
+			prefix := strings.TrimSuffix(mediaRange, "*")
+			for _, c := range reg.codecs {
+				for _, mediaType := range c.MediaTypes() {
+					if strings.HasPrefix(mediaType, prefix) {
+						return c, true
+					}
+				}
+			}
+		default:
+			if c, ok := reg.lookup(mediaRange); ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (reg *Registry) lookup(mediaType string) (Codec, bool) {
+	for _, c := range reg.codecs {
+		for _, candidate := range c.MediaTypes() {
+			if strings.EqualFold(candidate, mediaType) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (reg *Registry) remove(c Codec) {
+	for i, existing := range reg.codecs {
+		if existing == c {
+			reg.codecs = append(reg.codecs[:i], reg.codecs[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseAccept returns the media ranges of an Accept header, most preferred first
+func parseAccept(accept string) []string {
+
+	type weightedRange struct {
+		mediaRange string
+		weight     float64
+	}
+
+	ranges := []weightedRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+		if mediaRange == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if q, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, weightedRange{mediaRange: mediaRange, weight: weight})
+	}
+
+	// more specific ranges win over wildcards of the same weight
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].weight != ranges[j].weight {
+			return ranges[i].weight > ranges[j].weight
+		}
+		return strings.Count(ranges[i].mediaRange, "*") < strings.Count(ranges[j].mediaRange, "*")
+	})
+
+	mediaRanges := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaRanges[i] = r.mediaRange
+	}
+	return mediaRanges
+}
@@ -0,0 +1,202 @@
+package codec
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"testing"
+)
+
+func TestRegistry_ForAccept(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{accept: "", want: "application/json", ok: true},
+		{accept: "*/*", want: "application/json", ok: true},
+		{accept: "application/xml", want: "application/xml", ok: true},
+		{accept: "text/*", want: "application/xml", ok: true},
+		{accept: "application/json;q=0.5, application/yaml", want: "application/yaml", ok: true},
+		{accept: "*/*;q=0.1, text/xml", want: "application/xml", ok: true},
+		{accept: "application/json;q=0, text/csv", want: "text/csv", ok: true},
+		{accept: "application/pdf", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			c, ok := registry.ForAccept(tt.accept)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, c.MediaTypes()[0])
+			}
+		})
+	}
+}
+
+func TestRegistry_ForContentType(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		contentType string
+		want        string
+		ok          bool
+	}{
+		{contentType: "", want: "application/json", ok: true},
+		{contentType: "application/json; charset=utf-8", want: "application/json", ok: true},
+		{contentType: "Application/X-YAML", want: "application/yaml", ok: true},
+		{contentType: "application/ndjson", want: "application/x-ndjson", ok: true},
+		{contentType: "application/pdf", ok: false},
+		{contentType: "not a media type;", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			c, ok := registry.ForContentType(tt.contentType)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, c.MediaTypes()[0])
+			}
+		})
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry(0, JSON{}, XML{})
+	registry.Register(YAML{})
+	registry.Register(XML{})
+
+	assert.Equal(t, DefaultMaxBodySize, registry.MaxBodySize)
+	assert.Equal(t, []string{"application/json", "application/yaml", "application/x-yaml", "text/yaml", "application/xml", "text/xml"}, registry.MediaTypes())
+}
+
+func TestCodecs_Encode(t *testing.T) {
+	users := []model.User{
+		{Id: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+		{Id: 2, FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "JSON",
+			codec: JSON{},
+			value: users[0],
+			want:  `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}`,
+		},
+		{
+			name:  "XML user",
+			codec: XML{},
+			value: &users[0],
+			want: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<user><id>1</id><first_name>Ada</first_name><last_name>Lovelace</last_name><email>ada@example.com</email><age>36</age></user>`,
+		},
+		{
+			name:  "XML users",
+			codec: XML{},
+			value: users[:1],
+			want: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<users><user><id>1</id><first_name>Ada</first_name><last_name>Lovelace</last_name><email>ada@example.com</email><age>36</age></user></users>`,
+		},
+		{
+			name:  "XML validation result",
+			codec: XML{},
+			value: model.ValidationResult{Errors: []model.FieldError{{Field: "age", Code: "age_minimum", Message: "too young"}}},
+			want: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<validation_result><valid>false</valid><errors><error><field>age</field><code>age_minimum</code><message>too young</message></error></errors></validation_result>`,
+		},
+		{
+			name:  "CSV users",
+			codec: CSV{},
+			value: users,
+			want:  "id,first_name,last_name,email,age\n1,Ada,Lovelace,ada@example.com,36\n2,Grace,Hopper,grace@example.com,85\n",
+		},
+		{
+			name:  "YAML",
+			codec: YAML{},
+			value: users[0],
+			want:  "id: 1\nfirst_name: Ada\nlast_name: Lovelace\nemail: ada@example.com\nage: 36\n",
+		},
+		{
+			name:  "NDJSON users",
+			codec: NDJSON{},
+			value: users,
+			want: `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n" +
+				`{"id":2,"first_name":"Grace","last_name":"Hopper","email":"grace@example.com","age":85}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Nil(t, tt.codec.Encode(&buf, tt.value))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestCodecs_Encode_Unsupported(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorIs(t, CSV{}.Encode(&buf, model.ValidationResult{}), ErrUnsupportedValue)
+	assert.ErrorIs(t, XML{}.Encode(&buf, map[string]string{"status": "deleted"}), ErrUnsupportedValue)
+}
+
+func TestCodecs_Decode(t *testing.T) {
+	want := model.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}
+
+	tests := []struct {
+		name    string
+		codec   Codec
+		body    string
+		wantErr string
+	}{
+		{name: "JSON", codec: JSON{}, body: `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}`},
+		{name: "JSON unknown field", codec: JSON{}, body: `{"nick":"Ada"}`, wantErr: `json: unknown field "nick"`},
+		{name: "JSON invalid type", codec: JSON{}, body: `{"AGE":"old"}`, wantErr: "json: cannot unmarshal string into Go struct field User.age of type int64"},
+		{name: "XML", codec: XML{}, body: `<user><first_name>Ada</first_name><last_name>Lovelace</last_name><email>ada@example.com</email><age>36</age></user>`},
+		{name: "CSV", codec: CSV{}, body: "first_name,last_name,email,age\nAda,Lovelace,ada@example.com,36\n"},
+		{name: "CSV unknown column", codec: CSV{}, body: "nick\nAda\n", wantErr: `csv: unknown column "nick"`},
+		{name: "CSV invalid number", codec: CSV{}, body: "age\nold\n", wantErr: `csv: line 2, column "age": strconv.ParseInt: parsing "old": invalid syntax`},
+		{name: "CSV several rows", codec: CSV{}, body: "age\n1\n2\n", wantErr: "csv: expected a single row"},
+		{name: "YAML", codec: YAML{}, body: "first_name: Ada\nlast_name: Lovelace\nemail: ada@example.com\nage: 36\n"},
+		{name: "YAML unknown field", codec: YAML{}, body: "nick: Ada\n", wantErr: "yaml: unmarshal errors:\n  line 1: field nick not found in type model.User"},
+		{name: "NDJSON", codec: NDJSON{}, body: `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n"},
+		{name: "NDJSON several lines", codec: NDJSON{}, body: "{}\n{}\n", wantErr: "ndjson: expected a single line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user model.User
+			err := tt.codec.Decode(bytes.NewBufferString(tt.body), &user)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, want, user)
+		})
+	}
+}
+
+func TestCodecs_Decode_Slice(t *testing.T) {
+	want := []model.User{{FirstName: "Ada", Age: 36}, {FirstName: "Grace", Age: 85}}
+
+	tests := []struct {
+		name  string
+		codec Codec
+		body  string
+	}{
+		{name: "XML", codec: XML{}, body: `<users><user><first_name>Ada</first_name><age>36</age></user><user><first_name>Grace</first_name><age>85</age></user></users>`},
+		{name: "CSV", codec: CSV{}, body: "first_name,age\nAda,36\nGrace,85\n"},
+		{name: "NDJSON", codec: NDJSON{}, body: `{"first_name":"Ada","age":36}` + "\n" + `{"first_name":"Grace","age":85}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var users []model.User
+			assert.Nil(t, tt.codec.Decode(bytes.NewBufferString(tt.body), &users))
+			assert.Equal(t, want, users)
+		})
+	}
+}
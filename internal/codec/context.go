@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"context"
+)
+
+type contextKey struct{}
+
+type negotiation struct {
+	registry *Registry
+	response Codec
+}
+
+// NewContext returns a copy of ctx carrying the registry and the codec negotiated for the response
+func NewContext(ctx context.Context, reg *Registry, response Codec) context.Context {
+	return context.WithValue(ctx, contextKey{}, negotiation{registry: reg, response: response})
+}
+
+// RegistryFromContext returns the registry carried by ctx, or the default registry when there is none
+func RegistryFromContext(ctx context.Context) *Registry {
+	if n, ok := ctx.Value(contextKey{}).(negotiation); ok && n.registry != nil {
+		return n.registry
+	}
+	return Default()
+}
+
+// ResponseFromContext returns the codec negotiated for the response, if any
+func ResponseFromContext(ctx context.Context) (Codec, bool) {
+	n, ok := ctx.Value(contextKey{}).(negotiation)
+	if !ok || n.response == nil {
+		return nil, false
+	}
+	return n.response, true
+}
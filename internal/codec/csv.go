@@ -0,0 +1,198 @@
+package codec
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CSV encodes flat structs, or slices of them, as a header row named after the json tags followed by one row per value
+type CSV struct{}
+
+func (CSV) MediaTypes() []string {
+	return []string{"text/csv"}
+}
+
+func (CSV) Encode(w io.Writer, v interface{}) error {
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rows := rv
+	if rv.Kind() != reflect.Slice {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rv.Type()), 0, 1), rv)
+	}
+
+	columns, err := csvColumns(rows.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < rows.Len(); i++ {
+		item := reflect.Indirect(rows.Index(i))
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = fmt.Sprint(item.Field(column.index).Interface())
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Decode reads every row into a slice target, or exactly one row into a struct target
+func (CSV) Decode(r io.Reader, v interface{}) error {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return ErrUnsupportedValue
+	}
+	target := rv.Elem()
+	itemType := target.Type()
+	if target.Kind() == reflect.Slice {
+		itemType = itemType.Elem()
+	}
+
+	columns, err := csvColumns(itemType)
+	if err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("csv: reading header: %w", err)
+	}
+
+	byName := map[string]csvColumn{}
+	for _, column := range columns {
+		byName[column.name] = column
+	}
+	fields := make([]csvColumn, len(header))
+	for i, name := range header {
+		column, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("csv: unknown column %q", name)
+		}
+		fields[i] = column
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if target.Kind() != reflect.Slice && line == 2 {
+				return fmt.Errorf("csv: expected a row after the header")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if target.Kind() != reflect.Slice && line > 2 {
+			return fmt.Errorf("csv: expected a single row")
+		}
+
+		item := reflect.New(itemType).Elem()
+		for i, value := range record {
+			if err := setField(item.Field(fields[i].index), value); err != nil {
+				return fmt.Errorf("csv: line %d, column %q: %w", line, fields[i].name, err)
+			}
+		}
+
+		if target.Kind() == reflect.Slice {
+			target.Set(reflect.Append(target, item))
+		} else {
+			target.Set(item)
+		}
+	}
+}
+
+type csvColumn struct {
+	name  string
+	index int
+}
+
+// csvColumns returns the exported scalar fields of a struct, named after their json tag
+func csvColumns(t reflect.Type) ([]csvColumn, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, ErrUnsupportedValue
+	}
+
+	columns := []csvColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		switch field.Type.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface, reflect.Array:
+			return nil, ErrUnsupportedValue
+		}
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns, nil
+}
+
+func setField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+		return nil
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	default:
+		return ErrUnsupportedValue
+	}
+	return nil
+}
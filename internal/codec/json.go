@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// JSON encodes values as a single JSON document and rejects unknown fields when decoding
+type JSON struct{}
+
+func (JSON) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func (JSON) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (JSON) Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return fieldNames(dec.Decode(v), v)
+}
+
+// fieldNames gives the json names of the fields in the path of a type error, instead of the keys of the body which the
+// decoder matches ignoring case, so that the message does not echo the keys as the client wrote them
+func fieldNames(err error, v interface{}) error {
+
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return err
+	}
+	t := reflect.TypeOf(v)
+	names := []string{}
+	for _, key := range strings.Split(typeErr.Field, ".") {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return err
+		}
+		field, name, ok := jsonField(t, key)
+		if !ok {
+			return err
+		}
+		names = append(names, name)
+		t = field.Type
+	}
+	typeErr.Field = strings.Join(names, ".")
+	return err
+}
+
+// jsonField returns the field of a struct decoded from a key, an exact match of its json name first
+func jsonField(t reflect.Type, key string) (reflect.StructField, string, bool) {
+	var folded *reflect.StructField
+	var foldedName string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return field, name, true
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded, foldedName = &field, name
+		}
+	}
+	if folded == nil {
+		return reflect.StructField{}, "", false
+	}
+	return *folded, foldedName, true
+}
@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// NDJSON encodes each element of a slice as a JSON document on its own line
+type NDJSON struct{}
+
+func (NDJSON) MediaTypes() []string {
+	return []string{"application/x-ndjson", "application/ndjson"}
+}
+
+func (NDJSON) Encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice {
+		return enc.Encode(v)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads every line into a slice target, or exactly one line into any other target
+func (NDJSON) Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		if err := dec.Decode(v); err != nil {
+			return err
+		}
+		if dec.More() {
+			return errors.New("ndjson: expected a single line")
+		}
+		return nil
+	}
+
+	slice := rv.Elem()
+	for dec.More() {
+		item := reflect.New(slice.Type().Elem())
+		if err := dec.Decode(item.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+	return nil
+}
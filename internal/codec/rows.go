@@ -0,0 +1,369 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// IRowReader reads a body one row at a time, so that a row that cannot be read does not stop the rows after it
+type IRowReader interface {
+	// Read decodes the next row into the struct v points to and returns the line it starts on.
+	// It returns a *RowError for a row that cannot be read, io.EOF after the last row, and any other error when the
+	// rest of the body cannot be read.
+	Read(v interface{}) (int, error)
+}
+
+// RowError is a row that cannot be read, Column being the field at fault when it is known
+type RowError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("line %d, column %q: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// CSVReader reads rows whose header names the fields of the target struct.
+// A header matches the json name of a field ignoring case and any character but letters and digits, so that
+// "First Name" fills first_name and "E-mail" fills email. Columns maps other headers to json names, a header mapped
+// to "-" being skipped.
+type CSVReader struct {
+	Reader  io.Reader
+	Columns map[string]string
+
+	reader  *csv.Reader
+	columns []*csvColumn
+}
+
+func (cr *CSVReader) Read(v interface{}) (int, error) {
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return 0, ErrUnsupportedValue
+	}
+	target = target.Elem()
+
+	if cr.reader == nil {
+		if err := cr.readHeader(target.Type()); err != nil {
+			return 0, err
+		}
+	}
+
+	record, err := cr.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return 0, err
+	}
+	line, _ := cr.reader.FieldPos(0)
+	if len(record) != len(cr.columns) {
+		return line, &RowError{Line: line, Err: fmt.Errorf("csv: expected %d columns, got %d", len(cr.columns), len(record))}
+	}
+
+	for i, value := range record {
+		column := cr.columns[i]
+		if column == nil {
+			continue
+		}
+		if err := setField(target.Field(column.index), value); err != nil {
+			return line, &RowError{Line: line, Column: column.name, Err: err}
+		}
+	}
+	return line, nil
+}
+
+// readHeader maps each column of the header to a field of t, or to nil when it is skipped
+func (cr *CSVReader) readHeader(t reflect.Type) error {
+
+	fields, err := csvColumns(t)
+	if err != nil {
+		return err
+	}
+	byName := map[string]csvColumn{}
+	byKey := map[string]csvColumn{}
+	for _, field := range fields {
+		byName[field.name] = field
+		byKey[headerKey(field.name)] = field
+	}
+
+	cr.reader = csv.NewReader(cr.Reader)
+	cr.reader.TrimLeadingSpace = true
+	cr.reader.FieldsPerRecord = -1
+	cr.reader.ReuseRecord = true
+	header, err := cr.reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("csv: expected a header")
+	}
+	if err != nil {
+		return fmt.Errorf("csv: reading header: %w", err)
+	}
+
+	cr.columns = make([]*csvColumn, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		// spreadsheets often start their exports with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		fieldName, mapped := cr.Columns[name]
+		if fieldName == "-" {
+			continue
+		}
+		field, ok := byKey[headerKey(name)]
+		if mapped {
+			field, ok = byName[fieldName]
+		}
+		if !ok {
+			return fmt.Errorf("csv: unknown column %q", name)
+		}
+		if seen[field.name] {
+			return fmt.Errorf("csv: more than one column for %q", field.name)
+		}
+		seen[field.name] = true
+		cr.columns[i] = &field
+	}
+	return nil
+}
+
+// headerKey is the lower case letters and digits of a header
+func headerKey(header string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, header)
+}
+
+// NDJSONReader reads one JSON document per line, blank lines being skipped
+type NDJSONReader struct {
+	Reader io.Reader
+
+	reader *bufio.Reader
+	line   int
+}
+
+func (nr *NDJSONReader) Read(v interface{}) (int, error) {
+
+	if nr.reader == nil {
+		nr.reader = bufio.NewReader(nr.Reader)
+	}
+
+	for {
+		data, err := nr.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if len(data) == 0 && err == io.EOF {
+			return 0, io.EOF
+		}
+		nr.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			rowErr := &RowError{Line: nr.line, Err: err}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				rowErr.Column = typeErr.Field
+			} else if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+				rowErr.Column, _ = strconv.Unquote(field)
+			}
+			return nr.line, rowErr
+		}
+		if dec.More() {
+			return nr.line, &RowError{Line: nr.line, Err: errors.New("ndjson: expected a single document")}
+		}
+		return nr.line, nil
+	}
+}
+
+// IRowWriter writes values one row at a time, so that a body of any size is written with constant memory
+type IRowWriter interface {
+	// Write encodes a row, the first one being preceded by whatever the format starts with
+	Write(v interface{}) error
+	// Flush sends the buffered rows to the underlying writer, and flushes it too when it can be
+	Flush() error
+	// Close ends the body and flushes it
+	Close() error
+}
+
+// rowBuffer buffers the rows written to Writer, which is left untouched until something is written
+type rowBuffer struct {
+	buffer *bufio.Writer
+}
+
+func (rb *rowBuffer) init(w io.Writer) {
+	if rb.buffer == nil {
+		rb.buffer = bufio.NewWriter(w)
+	}
+}
+
+func (rb *rowBuffer) flush(w io.Writer) error {
+	if rb.buffer == nil {
+		return nil
+	}
+	if err := rb.buffer.Flush(); err != nil {
+		return err
+	}
+	// an http.ResponseWriter sends the chunk to the client
+	if flusher, ok := w.(interface{ Flush() }); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// CSVWriter writes flat structs as a header row named after their json tags followed by one row per value,
+// nothing being written when there is no row
+type CSVWriter struct {
+	Writer io.Writer
+
+	rowBuffer
+	writer  *csv.Writer
+	columns []csvColumn
+}
+
+func (cw *CSVWriter) Write(v interface{}) error {
+
+	item := reflect.Indirect(reflect.ValueOf(v))
+	if cw.writer == nil {
+		columns, err := csvColumns(item.Type())
+		if err != nil {
+			return err
+		}
+		cw.init(cw.Writer)
+		cw.writer = csv.NewWriter(cw.buffer)
+		cw.columns = columns
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.name
+		}
+		if err := cw.writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(cw.columns))
+	for i, column := range cw.columns {
+		record[i] = fmt.Sprint(item.Field(column.index).Interface())
+	}
+	return cw.writer.Write(record)
+}
+
+func (cw *CSVWriter) Flush() error {
+	if cw.writer != nil {
+		cw.writer.Flush()
+		if err := cw.writer.Error(); err != nil {
+			return err
+		}
+	}
+	return cw.flush(cw.Writer)
+}
+
+func (cw *CSVWriter) Close() error {
+	return cw.Flush()
+}
+
+// NDJSONWriter writes each value as a JSON document on its own line
+type NDJSONWriter struct {
+	Writer io.Writer
+
+	rowBuffer
+}
+
+func (nw *NDJSONWriter) Write(v interface{}) error {
+	nw.init(nw.Writer)
+	return json.NewEncoder(nw.buffer).Encode(v)
+}
+
+func (nw *NDJSONWriter) Flush() error {
+	return nw.flush(nw.Writer)
+}
+
+func (nw *NDJSONWriter) Close() error {
+	return nw.Flush()
+}
+
+// JSONArrayWriter writes the values as the elements of a JSON array, one per line
+type JSONArrayWriter struct {
+	Writer io.Writer
+
+	rowBuffer
+	rows int
+}
+
+func (jw *JSONArrayWriter) Write(v interface{}) error {
+	jw.init(jw.Writer)
+	separator := ",\n"
+	if jw.rows == 0 {
+		separator = "[\n"
+	}
+	if _, err := jw.buffer.WriteString(separator); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	jw.rows++
+	_, err = jw.buffer.Write(data)
+	return err
+}
+
+func (jw *JSONArrayWriter) Flush() error {
+	return jw.flush(jw.Writer)
+}
+
+func (jw *JSONArrayWriter) Close() error {
+	jw.init(jw.Writer)
+	end := "\n]\n"
+	if jw.rows == 0 {
+		end = "[]\n"
+	}
+	if _, err := jw.buffer.WriteString(end); err != nil {
+		return err
+	}
+	return jw.Flush()
+}
+
+// Names of the formats rows can be written in
+const (
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+	FORMAT_JSON   = "json"
+)
+
+// RowWriter returns the writer of rows in the named format, along with the media type of the format
+func RowWriter(format string, w io.Writer) (IRowWriter, string, error) {
+	switch format {
+	case FORMAT_CSV:
+		return &CSVWriter{Writer: w}, CSV{}.MediaTypes()[0], nil
+	case FORMAT_NDJSON:
+		return &NDJSONWriter{Writer: w}, NDJSON{}.MediaTypes()[0], nil
+	case FORMAT_JSON:
+		return &JSONArrayWriter{Writer: w}, JSON{}.MediaTypes()[0], nil
+	default:
+		return nil, "", fmt.Errorf("unknown format %q, expected %v, %v or %v", format, FORMAT_CSV, FORMAT_NDJSON, FORMAT_JSON)
+	}
+}
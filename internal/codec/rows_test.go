@@ -0,0 +1,200 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"io"
+	"testing"
+)
+
+type row struct {
+	line   int
+	user   model.User
+	column string
+	err    string
+}
+
+func TestRowReaders(t *testing.T) {
+	ada := model.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}
+
+	tests := []struct {
+		name    string
+		reader  func(body io.Reader) IRowReader
+		body    string
+		want    []row
+		wantErr string
+	}{
+		{
+			name:   "CSV",
+			reader: func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			body:   "first_name,last_name,email,age\nAda,Lovelace,ada@example.com,36\n\nGrace,Hopper,grace@example.com,85\n",
+			want: []row{
+				{line: 2, user: ada},
+				{line: 4, user: model.User{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85}},
+			},
+		},
+		{
+			name:   "CSV spreadsheet header",
+			reader: func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			body:   "\ufeffFirst Name,last-name,E-mail,AGE\nAda,Lovelace,ada@example.com,36\n",
+			want:   []row{{line: 2, user: ada}},
+		},
+		{
+			name: "CSV mapped columns",
+			reader: func(body io.Reader) IRowReader {
+				return &CSVReader{Reader: body, Columns: map[string]string{"Given name": "first_name", "Surname": "last_name", "Department": "-"}}
+			},
+			body: "Given name,Surname,Department,email,age\nAda,Lovelace,Research,ada@example.com,36\n",
+			want: []row{{line: 2, user: ada}},
+		},
+		{
+			name:   "CSV invalid rows",
+			reader: func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			body:   "first_name,last_name,email,age\nAda,Lovelace,ada@example.com,old\nAda,Lovelace\nAda,\"Love\"lace,ada@example.com,36\nAda,Lovelace,ada@example.com,36\n",
+			want: []row{
+				{line: 2, column: "age", err: `line 2, column "age": strconv.ParseInt: parsing "old": invalid syntax`},
+				{line: 3, err: "line 3: csv: expected 4 columns, got 2"},
+				{line: 4, err: `line 4: extraneous or missing " in quoted-field`},
+				{line: 5, user: ada},
+			},
+		},
+		{
+			name:    "CSV unknown column",
+			reader:  func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			body:    "first_name,nick\nAda,Lovelace\n",
+			wantErr: `csv: unknown column "nick"`,
+		},
+		{
+			name: "CSV column mapped to unknown field",
+			reader: func(body io.Reader) IRowReader {
+				return &CSVReader{Reader: body, Columns: map[string]string{"Nick": "nickname"}}
+			},
+			body:    "Nick\nAda\n",
+			wantErr: `csv: unknown column "Nick"`,
+		},
+		{
+			name:    "CSV column mapped twice",
+			reader:  func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			body:    "first_name,First Name\nAda,Ada\n",
+			wantErr: `csv: more than one column for "first_name"`,
+		},
+		{
+			name:    "CSV empty",
+			reader:  func(body io.Reader) IRowReader { return &CSVReader{Reader: body} },
+			wantErr: "csv: expected a header",
+		},
+		{
+			name:   "NDJSON",
+			reader: func(body io.Reader) IRowReader { return &NDJSONReader{Reader: body} },
+			body:   `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n\n" + `{"first_name":"Grace","age":85}`,
+			want: []row{
+				{line: 1, user: ada},
+				{line: 3, user: model.User{FirstName: "Grace", Age: 85}},
+			},
+		},
+		{
+			name:   "NDJSON invalid rows",
+			reader: func(body io.Reader) IRowReader { return &NDJSONReader{Reader: body} },
+			body:   `{"age":"old"}` + "\n" + `{"nick":"Ada"}` + "\n" + `{"first_name":` + "\n" + `{} {}` + "\n" + `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n",
+			want: []row{
+				{line: 1, column: "age", err: "line 1, column \"age\": json: cannot unmarshal string into Go struct field User.age of type int64"},
+				{line: 2, column: "nick", err: "line 2, column \"nick\": json: unknown field \"nick\""},
+				{line: 3, err: "line 3: unexpected EOF"},
+				{line: 4, err: "line 4: ndjson: expected a single document"},
+				{line: 5, user: ada},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := tt.reader(bytes.NewBufferString(tt.body))
+			got := []row{}
+			for {
+				var user model.User
+				line, err := reader.Read(&user)
+				if err == io.EOF {
+					break
+				}
+				var rowErr *RowError
+				if errors.As(err, &rowErr) {
+					got = append(got, row{line: line, column: rowErr.Column, err: rowErr.Error()})
+					continue
+				}
+				if tt.wantErr != "" {
+					assert.EqualError(t, err, tt.wantErr)
+					return
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				got = append(got, row{line: line, user: user})
+			}
+			assert.Empty(t, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRowWriters(t *testing.T) {
+	users := []model.User{
+		{Id: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+		{Id: 2, FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		users   []model.User
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "CSV",
+			format: FORMAT_CSV,
+			users:  users,
+			want:   "id,first_name,last_name,email,age\n1,Ada,Lovelace,ada@example.com,36\n2,Grace,Hopper,grace@example.com,85\n",
+		},
+		{
+			name:   "NDJSON",
+			format: FORMAT_NDJSON,
+			users:  users,
+			want: `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n" +
+				`{"id":2,"first_name":"Grace","last_name":"Hopper","email":"grace@example.com","age":85}` + "\n",
+		},
+		{
+			name:   "JSON",
+			format: FORMAT_JSON,
+			users:  users,
+			want: "[\n" + `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + ",\n" +
+				`{"id":2,"first_name":"Grace","last_name":"Hopper","email":"grace@example.com","age":85}` + "\n]\n",
+		},
+		{name: "CSV empty", format: FORMAT_CSV},
+		{name: "NDJSON empty", format: FORMAT_NDJSON},
+		{name: "JSON empty", format: FORMAT_JSON, want: "[]\n"},
+		{name: "Unknown format", format: "xlsx", wantErr: `unknown format "xlsx", expected csv, ndjson or json`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			body := &bytes.Buffer{}
+			rows, _, err := RowWriter(tt.format, body)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			// When
+			for i := range tt.users {
+				assert.Nil(t, rows.Write(&tt.users[i]))
+				// rows are buffered until flushed
+				assert.Empty(t, body.String())
+			}
+			assert.Nil(t, rows.Close())
+
+			// Then
+			assert.Equal(t, tt.want, body.String())
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// XML encodes a struct as an element named after its type in snake case, e.g. <user>,
+// and a slice as a plural root element holding one such element per item, e.g. <users><user>...
+type XML struct{}
+
+func (XML) MediaTypes() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (XML) Encode(w io.Writer, v interface{}) error {
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Map || (rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Map) {
+		return ErrUnsupportedValue
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	if rv.Kind() != reflect.Slice {
+		if err := enc.EncodeElement(v, startElement(elementName(rv.Type()))); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+
+	root := startElement(elementName(rv.Type().Elem()) + "s")
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		if err := enc.EncodeElement(item.Interface(), startElement(elementName(item.Type()))); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// Decode reads the root element into a struct target, or each child of the root element into a slice target
+func (XML) Decode(r io.Reader, v interface{}) error {
+
+	dec := xml.NewDecoder(r)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Slice {
+		return dec.Decode(v)
+	}
+
+	slice := rv.Elem()
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				depth++
+				continue
+			}
+			item := reflect.New(slice.Type().Elem())
+			if err := dec.DecodeElement(item.Interface(), &t); err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, item.Elem()))
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+func startElement(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+// elementName returns the snake case name of a type, e.g. validation_result for ValidationResult
+func elementName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var b strings.Builder
+	for i, r := range t.Name() {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
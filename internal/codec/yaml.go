@@ -0,0 +1,28 @@
+package codec
+
+import (
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+// YAML encodes values as a single YAML document and rejects unknown fields when decoding
+type YAML struct{}
+
+func (YAML) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+func (YAML) Encode(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (YAML) Decode(r io.Reader, v interface{}) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"github.com/wexinc/ps-tag-onboarding-go/internal/certs"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/constants"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/gql"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/idempotency"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/jobs"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/outbox"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/redact"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/router"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/webhook"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings read from the environment at startup
+type Config struct {
+	HttpPort        string
+	LocalesDir      string
+	MaxBodySize     int64
+	CapitalizeNames bool
+	LowercaseEmails bool
+	NameParticles   []string
+
+	// JwtHmacSecretFile, JwtPublicKeyFiles and JwksUrl are the sources of the keys verifying bearer tokens
+	JwtHmacSecretFile string
+	JwtPublicKeyFiles []string
+	JwksUrl           string
+	JwtIssuer         string
+	JwtAudience       string
+	JwtLeeway         time.Duration
+	// PublicRouteGroups are the route groups served without authentication
+	PublicRouteGroups []string
+	// AuthDisabled lets the server start without token keys nor client certificates, allowing every operation
+	AuthDisabled bool
+	// AuthzPolicyFile holds the roles and their permissions, the built-in policy is used when empty
+	AuthzPolicyFile string
+
+	// RateLimit applies to each client on the routes matched by no rule, e.g. "300/1m", requests are not limited when empty
+	RateLimit string
+	// RateLimitRules set stricter limits on some routes, e.g. "POST /users=30/1m"
+	RateLimitRules []string
+	// RateLimitIp applies to each client IP over every route before authentication, so that floods of requests with
+	// invalid credentials are limited too, e.g. "3000/1m", they are not limited when empty
+	RateLimitIp string
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For or X-Real-IP, only set it behind a trusted proxy
+	TrustProxyHeaders bool
+
+	// IdempotencyTTL is how long the responses of requests sent with an Idempotency-Key are replayed
+	IdempotencyTTL time.Duration
+	// IdempotencyWait is how long a retry waits for the first request with its key to complete before getting 409
+	IdempotencyWait time.Duration
+
+	// CorsAllowedOrigins are the browser origins allowed to call the API, e.g. "https://*.example.com", or "*" for any
+	CorsAllowedOrigins   []string
+	CorsAllowedMethods   []string
+	CorsAllowedHeaders   []string
+	CorsExposedHeaders   []string
+	CorsAllowCredentials bool
+	CorsMaxAge           int64
+
+	// HstsMaxAge is how long browsers only use HTTPS for the host, disabled when zero
+	HstsMaxAge time.Duration
+	// FrameOptions is the X-Frame-Options header, "DENY" or "SAMEORIGIN"
+	FrameOptions string
+	// ContentSecurityPolicy applies to every route but the documentation pages, which have their own
+	ContentSecurityPolicy string
+	// CsrfProtection requires a double-submit token on unsafe requests carrying cookies
+	CsrfProtection bool
+
+	// TlsCertFile and TlsKeyFile enable HTTPS on HttpsPort, the files are reloaded when they change
+	TlsCertFile string
+	TlsKeyFile  string
+	HttpsPort   string
+	// HttpRedirect redirects plain HTTP requests on HttpPort to HTTPS
+	HttpRedirect bool
+	// TlsClientCaFile enables client certificates, verified against its CA bundle
+	TlsClientCaFile       string
+	TlsClientCertRequired bool
+	// TlsClientRoles give roles to client certificates, as "<common name>:<role>" entries
+	TlsClientRoles    []string
+	TlsReloadInterval time.Duration
+
+	// LogPiiMode is how personal data is written to logs: "mask", "hash" or "off"
+	LogPiiMode string
+	// LogPiiHashKeyFile holds the key of the "hash" mode, a random key is used when empty
+	LogPiiHashKeyFile string
+
+	// EncryptionKeyringFile holds the keys encrypting the personal data of users, stored in clear text when empty
+	EncryptionKeyringFile string
+	// ReencryptBatchSize is the number of users re-encrypted per transaction when keys are rotated
+	ReencryptBatchSize int64
+
+	// OutboxSink also receives the user events sent to webhooks: "stdout" or "file", none when empty
+	OutboxSink string
+	// OutboxFile is the NDJSON file the "file" sink appends events to
+	OutboxFile      string
+	OutboxInterval  time.Duration
+	OutboxBatchSize int64
+
+	// WebhookMaxAttempts is the number of failed attempts after which a webhook delivery is dead
+	WebhookMaxAttempts int64
+	WebhookTimeout     time.Duration
+	// WebhookBackoff is the delay before the first retry of a delivery, doubled at each retry up to WebhookMaxBackoff
+	WebhookBackoff    time.Duration
+	WebhookMaxBackoff time.Duration
+	// WebhookAllowPrivateUrls lets webhooks target loopback, private and link-local addresses, for local development
+	WebhookAllowPrivateUrls bool
+
+	// SseBufferSize is the number of user events kept for event stream clients resuming with Last-Event-ID
+	SseBufferSize int64
+	// SseSubscriberBuffer is the number of events queued for a client before it is dropped for falling behind
+	SseSubscriberBuffer int64
+	SseHeartbeat        time.Duration
+
+	// GraphqlMaxDepth and GraphqlMaxComplexity reject the GraphQL operations too expensive to execute
+	GraphqlMaxDepth      int64
+	GraphqlMaxComplexity int64
+	// GraphqlMaxBatchSize is the largest number of GraphQL requests sent in one HTTP request
+	GraphqlMaxBatchSize int64
+	// GraphqlMaxMutations is the largest number of GraphQL mutations sent in one HTTP request, batches included
+	GraphqlMaxMutations int64
+
+	// ImportMaxBodySize is the largest file accepted by a bulk import of users
+	ImportMaxBodySize int64
+	// ImportBatchSize is the number of imported users inserted per transaction
+	ImportBatchSize int64
+	// ExportChunkSize is the number of users read from the database at a time by an export
+	ExportChunkSize int64
+
+	// JobWorkers is the number of background jobs run at the same time
+	JobWorkers int64
+	// JobMaxAttempts is the number of runs after which a failing job is failed, JobBackoff being the delay before its
+	// first retry, doubled at each retry
+	JobMaxAttempts int64
+	JobBackoff     time.Duration
+	// JobsDir holds the files written by export jobs, made readable by the server alone
+	JobsDir string
+	// JobResultTTL is how long finished jobs and the files of exports are kept
+	JobResultTTL time.Duration
+
+	// GrpcPort serves the gRPC API alongside the REST API, over TLS when HTTPS is enabled, disabled when empty
+	GrpcPort string
+
+	// ScimBaseUrl is the url the SCIM API is reached at, e.g. behind a proxy, the locations of SCIM resources being
+	// relative to the host when empty
+	ScimBaseUrl string
+}
+
+// Load reads the configuration from environment variables, falling back to the defaults in constants
+func Load() *Config {
+	return &Config{
+		HttpPort:        getEnv("HTTP_PORT", constants.HTTP_PORT),
+		LocalesDir:      getEnv("LOCALES_DIR", ""),
+		MaxBodySize:     getEnvInt64("MAX_BODY_SIZE", codec.DefaultMaxBodySize),
+		CapitalizeNames: getEnvBool("CAPITALIZE_NAMES", true),
+		LowercaseEmails: getEnvBool("LOWERCASE_EMAILS", true),
+		NameParticles:   getEnvList("NAME_PARTICLES", nil),
+
+		JwtHmacSecretFile: getEnv("JWT_HMAC_SECRET_FILE", ""),
+		JwtPublicKeyFiles: getEnvList("JWT_PUBLIC_KEY_FILES", nil),
+		JwksUrl:           getEnv("JWKS_URL", ""),
+		JwtIssuer:         getEnv("JWT_ISSUER", ""),
+		JwtAudience:       getEnv("JWT_AUDIENCE", ""),
+		JwtLeeway:         getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		PublicRouteGroups: getEnvList("PUBLIC_ROUTE_GROUPS", []string{"docs"}),
+		AuthDisabled:      getEnvBool("AUTH_DISABLED", false),
+		AuthzPolicyFile:   getEnv("AUTHZ_POLICY_FILE", ""),
+
+		RateLimit:         getEnv("RATE_LIMIT", "300/1m"),
+		RateLimitRules:    getEnvList("RATE_LIMIT_RULES", []string{"POST /users=30/1m"}),
+		RateLimitIp:       getEnv("RATE_LIMIT_IP", "3000/1m"),
+		TrustProxyHeaders: getEnvBool("TRUST_PROXY_HEADERS", false),
+
+		IdempotencyTTL:  getEnvDuration("IDEMPOTENCY_TTL", idempotency.DefaultTTL),
+		IdempotencyWait: getEnvDuration("IDEMPOTENCY_WAIT", idempotency.DefaultWait),
+
+		CorsAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		CorsAllowedMethods: getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+		CorsAllowedHeaders: getEnvList("CORS_ALLOWED_HEADERS", []string{"Accept", "Accept-Language", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key", "Last-Event-ID", "Access-Control-Allow-Origin"}),
+		CorsExposedHeaders: getEnvList("CORS_EXPOSED_HEADERS", []string{"Content-Language", "Content-Type", "JWT-Token", "WWW-Authenticate",
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", "Idempotent-Replayed", "Content-Disposition", "Link"}),
+		CorsAllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		CorsMaxAge:           getEnvInt64("CORS_MAX_AGE", 300), // Maximum value not ignored by any of major browsers
+
+		HstsMaxAge:            getEnvDuration("HSTS_MAX_AGE", 365*24*time.Hour),
+		FrameOptions:          getEnv("FRAME_OPTIONS", "DENY"),
+		ContentSecurityPolicy: getEnv("CONTENT_SECURITY_POLICY", router.API_CONTENT_SECURITY_POLICY),
+		CsrfProtection:        getEnvBool("CSRF_PROTECTION", true),
+
+		TlsCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TlsKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		HttpsPort:             getEnv("HTTPS_PORT", constants.HTTPS_PORT),
+		HttpRedirect:          getEnvBool("HTTP_REDIRECT", false),
+		TlsClientCaFile:       getEnv("TLS_CLIENT_CA_FILE", ""),
+		TlsClientCertRequired: getEnvBool("TLS_CLIENT_CERT_REQUIRED", true),
+		TlsClientRoles:        getEnvList("TLS_CLIENT_ROLES", nil),
+		TlsReloadInterval:     getEnvDuration("TLS_RELOAD_INTERVAL", certs.DefaultReloadInterval),
+
+		LogPiiMode:        getEnv("LOG_PII_MODE", redact.MODE_MASK),
+		LogPiiHashKeyFile: getEnv("LOG_PII_HASH_KEY_FILE", ""),
+
+		EncryptionKeyringFile: getEnv("ENCRYPTION_KEYRING_FILE", ""),
+		ReencryptBatchSize:    getEnvInt64("REENCRYPT_BATCH_SIZE", repository.DefaultReencryptBatchSize),
+
+		OutboxSink:      getEnv("OUTBOX_SINK", ""),
+		OutboxFile:      getEnv("OUTBOX_FILE", "events.ndjson"),
+		OutboxInterval:  getEnvDuration("OUTBOX_INTERVAL", outbox.DefaultInterval),
+		OutboxBatchSize: getEnvInt64("OUTBOX_BATCH_SIZE", outbox.DefaultBatchSize),
+
+		WebhookMaxAttempts:      getEnvInt64("WEBHOOK_MAX_ATTEMPTS", webhook.DefaultMaxAttempts),
+		WebhookTimeout:          getEnvDuration("WEBHOOK_TIMEOUT", webhook.DefaultTimeout),
+		WebhookBackoff:          getEnvDuration("WEBHOOK_BACKOFF", webhook.DefaultBackoff),
+		WebhookMaxBackoff:       getEnvDuration("WEBHOOK_MAX_BACKOFF", webhook.DefaultMaxBackoff),
+		WebhookAllowPrivateUrls: getEnvBool("WEBHOOK_ALLOW_PRIVATE_URLS", false),
+
+		SseBufferSize:       getEnvInt64("SSE_BUFFER_SIZE", sse.DefaultBufferSize),
+		SseSubscriberBuffer: getEnvInt64("SSE_SUBSCRIBER_BUFFER", sse.DefaultSubscriberBuffer),
+		SseHeartbeat:        getEnvDuration("SSE_HEARTBEAT", sse.DefaultHeartbeat),
+
+		GraphqlMaxDepth:      getEnvInt64("GRAPHQL_MAX_DEPTH", gql.DefaultMaxDepth),
+		GraphqlMaxComplexity: getEnvInt64("GRAPHQL_MAX_COMPLEXITY", gql.DefaultMaxComplexity),
+		GraphqlMaxBatchSize:  getEnvInt64("GRAPHQL_MAX_BATCH_SIZE", gql.DefaultMaxBatchSize),
+		GraphqlMaxMutations:  getEnvInt64("GRAPHQL_MAX_MUTATIONS", gql.DefaultMaxMutations),
+
+		ImportMaxBodySize: getEnvInt64("IMPORT_MAX_BODY_SIZE", controller.DefaultImportMaxBodySize),
+		ImportBatchSize:   getEnvInt64("IMPORT_BATCH_SIZE", service.DefaultImportBatchSize),
+		ExportChunkSize:   getEnvInt64("EXPORT_CHUNK_SIZE", service.DefaultExportChunkSize),
+
+		JobWorkers:     getEnvInt64("JOB_WORKERS", jobs.DefaultWorkers),
+		JobMaxAttempts: getEnvInt64("JOB_MAX_ATTEMPTS", jobs.DefaultMaxAttempts),
+		JobBackoff:     getEnvDuration("JOB_BACKOFF", jobs.DefaultBackoff),
+		JobsDir:        getEnv("JOBS_DIR", service.DefaultJobsDir),
+		JobResultTTL:   getEnvDuration("JOB_RESULT_TTL", service.DefaultJobResultTTL),
+
+		GrpcPort: getEnv("GRPC_PORT", ""),
+
+		ScimBaseUrl: getEnv("SCIM_BASE_URL", ""),
+	}
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Error.Printf("Ignoring invalid %v %q: %v", key, value, err)
+		return fallback
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Error.Printf("Ignoring invalid %v %q: %v", key, value, err)
+		return fallback
+	}
+	return parsed
+}
+
+// getEnvList reads a comma separated list, empty entries are dropped
+func getEnvList(key string, fallback []string) []string {
+	if getEnv(key, "") == "" {
+		return fallback
+	}
+	values := []string{}
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Error.Printf("Ignoring invalid %v %q: %v", key, value, err)
+		return fallback
+	}
+	return parsed
+}
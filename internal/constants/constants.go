@@ -10,5 +10,6 @@ const (
 	// LogFile is the name of the log file
 	LogFile = "../../api_logs.log"
 
-	HTTP_PORT = "8089"
+	HTTP_PORT  = "8089"
+	HTTPS_PORT = "8443"
 )
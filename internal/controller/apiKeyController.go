@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+type IApiKeyController interface {
+	ListApiKeys(w http.ResponseWriter, r *http.Request)
+	CreateApiKey(w http.ResponseWriter, r *http.Request)
+	RevokeApiKey(w http.ResponseWriter, r *http.Request)
+}
+
+type ApiKeyController struct {
+	ApiKeyService service.IApiKeyService
+	// Policy decides which callers may manage api keys
+	Policy authz.IPolicy
+}
+
+// ListApiKeys Get a list of all api keys
+//
+// This will return every api key, without its secret.
+//
+// swagger:route GET /apikeys/ listApiKeys
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+// - application/x-ndjson
+//
+// Responses:
+//
+//	200: []ApiKey
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (akc *ApiKeyController) ListApiKeys(w http.ResponseWriter, r *http.Request) {
+
+	if err := akc.authorize(r, authz.ACTION_API_KEYS_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	keys, err := akc.ApiKeyService.ListApiKeys()
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusOK, keys)
+}
+
+// CreateApiKey creates a new api key
+//
+// This will create a new api key with the given scopes. The plaintext key is only returned in this response.
+//
+// swagger:route POST /apikeys createApiKey
+//
+// Consumes:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	201: ApiKeyCreated
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (akc *ApiKeyController) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+
+	if err := akc.authorize(r, authz.ACTION_API_KEYS_CREATE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var body model.ApiKeyRequest
+	if err := utils.ParseBody(w, r, &body); err != nil {
+		log.Error.Println(err)
+		utils.ResponseMessageErr(w, err)
+		return
+	}
+
+	created, err := akc.ApiKeyService.CreateApiKey(&body)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusCreated, created)
+}
+
+// RevokeApiKey revokes an api key
+//
+// This will revoke an api key, which is then refused by the API. The key is kept for auditing.
+//
+// swagger:route DELETE /apikeys/{key_id} revokeApiKey
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	200: StatusResponse
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (akc *ApiKeyController) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+
+	id, parseErr := strconv.ParseInt(chi.URLParam(r, "key_id"), 10, 64)
+	if parseErr != nil {
+		utils.ResponseMessageErr(w, utils.BadRequestError("api key id should be a number"))
+		return
+	}
+
+	if err := akc.authorize(r, authz.ACTION_API_KEYS_REVOKE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := akc.ApiKeyService.RevokeApiKey(id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusOK, model.StatusResponse{Status: "revoked"})
+}
+
+// authorize asks the policy whether the caller may perform the action on api keys
+func (akc *ApiKeyController) authorize(r *http.Request, action string) error {
+	return authz.Authorize(r.Context(), akc.Policy, action, 0)
+}
+
+// swagger:parameters revokeApiKey
+type ApiKeyPathParam struct {
+	// in: path
+	KeyId string `json:"key_id"`
+}
+
+// swagger:parameters createApiKey
+type ApiKeyBodyParam struct {
+	// in:body
+	ApiKey model.ApiKeyRequest
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// MessageErrFromError translates an error returned by the service layer into the MessageErr sent to clients,
+// with validation and generic messages in the language negotiated for the request.
+// This is the single place where domain errors are mapped to HTTP statuses.
+func MessageErrFromError(ctx context.Context, err error) utils.MessageErr {
+
+	var msgErr utils.MessageErr
+	if errors.As(err, &msgErr) {
+		return msgErr
+	}
+
+	t := i18n.FromContext(ctx)
+	message := t.Status(http.StatusInternalServerError)
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) && !errors.Is(err, apperror.ErrInternal) {
+		message = appErr.Message
+	}
+
+	switch {
+	case errors.As(err, new(*http.MaxBytesError)):
+		return utils.RequestEntityTooLargeError(t.Status(http.StatusRequestEntityTooLarge))
+	case errors.Is(err, apperror.ErrNotFound):
+		return utils.NotFoundError(message)
+	case errors.Is(err, apperror.ErrValidation):
+		if appErr == nil {
+			message = t.Status(http.StatusBadRequest)
+		} else if len(appErr.Fields) > 0 {
+			messages := []string{}
+			for _, fieldErr := range appErr.Fields {
+				messages = append(messages, t.FieldError(fieldErr))
+			}
+			message = strings.Join(messages, ",")
+		}
+		return utils.BadRequestError(message)
+	case errors.Is(err, apperror.ErrForbidden):
+		return utils.ForbiddenError(t.Status(http.StatusForbidden))
+	case errors.Is(err, apperror.ErrConflict):
+		return utils.ConflictError(message)
+	case errors.Is(err, apperror.ErrUnavailable):
+		return utils.ServiceUnavailableError(message)
+	default:
+		return utils.InternalServerError(message)
+	}
+}
+
+// respondError logs the error and writes it to the client as a MessageErr
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Error.Println(err)
+	utils.ResponseMessageErr(w, MessageErrFromError(r.Context(), err))
+}
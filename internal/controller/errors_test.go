@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMessageErrFromError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		errMsg     string
+		errErr     string
+	}{
+		{
+			name:       "NotFound",
+			err:        apperror.NotFound("user not found with id 1", gorm.ErrRecordNotFound),
+			statusCode: http.StatusNotFound,
+			errMsg:     "user not found with id 1",
+			errErr:     "not_found",
+		},
+		{
+			name:       "Validation",
+			err:        apperror.Validation("User does not meet minimum age requirement", nil),
+			statusCode: http.StatusBadRequest,
+			errMsg:     "User does not meet minimum age requirement",
+			errErr:     "bad_request",
+		},
+		{
+			name:       "Validation sentinel",
+			err:        fmt.Errorf("importing users: %w", apperror.ErrValidation),
+			statusCode: http.StatusBadRequest,
+			errMsg:     "The request had invalid inputs or otherwise cannot be served.",
+			errErr:     "bad_request",
+		},
+		{
+			name:       "Forbidden",
+			err:        apperror.Forbidden(`"3" with roles [viewer] may not users:delete user 1`),
+			statusCode: http.StatusForbidden,
+			errMsg:     "You are not allowed to perform this operation.",
+			errErr:     "forbidden",
+		},
+		{
+			name:       "Conflict",
+			err:        apperror.Conflict("user already exists", gorm.ErrDuplicatedKey),
+			statusCode: http.StatusConflict,
+			errMsg:     "user already exists",
+			errErr:     "conflict",
+		},
+		{
+			name:       "Unavailable",
+			err:        fmt.Errorf("listing users: %w", apperror.Unavailable("database unavailable", nil)),
+			statusCode: http.StatusServiceUnavailable,
+			errMsg:     "database unavailable",
+			errErr:     "service_unavailable",
+		},
+		{
+			name:       "Internal",
+			err:        apperror.Internal("database error", errors.New("disk I/O error")),
+			statusCode: http.StatusInternalServerError,
+			errMsg:     "An error was encountered.",
+			errErr:     "server_error",
+		},
+		{
+			name:       "Unknown",
+			err:        errors.New("disk I/O error"),
+			statusCode: http.StatusInternalServerError,
+			errMsg:     "An error was encountered.",
+			errErr:     "server_error",
+		},
+		{
+			name:       "BodyTooLarge",
+			err:        &apperror.Error{Kind: apperror.ErrValidation, Message: "Unable to read the imported users", Cause: &http.MaxBytesError{Limit: 10}},
+			statusCode: http.StatusRequestEntityTooLarge,
+			errMsg:     "The request body is too large.",
+			errErr:     "request_too_large",
+		},
+		{
+			name:       "MessageErr",
+			err:        utils.BadRequestError("user id should be a number"),
+			statusCode: http.StatusBadRequest,
+			errMsg:     "user id should be a number",
+			errErr:     "bad_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			msgErr := MessageErrFromError(context.Background(), tt.err)
+
+			// Then
+			assert.EqualValues(t, tt.statusCode, msgErr.Status())
+			assert.EqualValues(t, tt.errMsg, msgErr.Message())
+			assert.EqualValues(t, tt.errErr, msgErr.Error())
+		})
+	}
+}
+
+func TestGetUser_Domain_Not_Found(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	getUserService = func(msgId int64) (*model.User, error) {
+		return nil, apperror.NotFound("user not found with id 1", gorm.ErrRecordNotFound)
+	}
+	r := chi.NewRouter()
+	req, _ := http.NewRequest(http.MethodGet, "/users/1", nil)
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Get("/users/{user_id}", userController.GetUser)
+	r.ServeHTTP(rr, req)
+
+	// Then
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+	assert.EqualValues(t, http.StatusNotFound, apiErr.Status())
+	assert.EqualValues(t, "user not found with id 1", apiErr.Message())
+	assert.EqualValues(t, "not_found", apiErr.Error())
+}
@@ -0,0 +1,458 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type IJobController interface {
+	SubmitImport(w http.ResponseWriter, r *http.Request)
+	SubmitExport(w http.ResponseWriter, r *http.Request)
+	SubmitPurge(w http.ResponseWriter, r *http.Request)
+	SubmitRevalidation(w http.ResponseWriter, r *http.Request)
+	ListJobs(w http.ResponseWriter, r *http.Request)
+	GetJob(w http.ResponseWriter, r *http.Request)
+	CancelJob(w http.ResponseWriter, r *http.Request)
+	DownloadJobFile(w http.ResponseWriter, r *http.Request)
+}
+
+type JobController struct {
+	JobService service.IJobService
+	// Policy decides which callers may submit and follow jobs
+	Policy authz.IPolicy
+	// ImportMaxBodySize is the largest file accepted by an import job, DefaultImportMaxBodySize when zero
+	ImportMaxBodySize int64
+}
+
+// SubmitImport Queues a bulk import of users from a CSV or NDJSON file
+//
+// This will run the import of POST /users/import in the background, with the same parameters. The file is stored with
+// the job in memory until it runs, so it is lost if the server stops before, and the report is the result of the job.
+//
+// swagger:route POST /jobs/import submitImportJob
+//
+// Consumes:
+// - text/csv
+// - application/x-ndjson
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: Job
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) SubmitImport(w http.ResponseWriter, r *http.Request) {
+
+	if err := jc.authorize(r, authz.ACTION_CREATE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	dryRun, columns, msgErr := importParams(r.URL.Query())
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+
+	var format string
+	requestCodec, _ := codec.RegistryFromContext(r.Context()).ForContentType(r.Header.Get("Content-Type"))
+	switch requestCodec.(type) {
+	case codec.CSV:
+		format = codec.FORMAT_CSV
+	case codec.NDJSON:
+		format = codec.FORMAT_NDJSON
+	default:
+		utils.ResponseLocalizedError(w, r, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	maxBodySize := jc.ImportMaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultImportMaxBodySize
+	}
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	jc.submit(w, r, &model.Job{
+		Type:   model.JOB_IMPORT,
+		Params: model.JobParams{Format: format, DryRun: dryRun, Columns: columns},
+		Input:  string(input),
+	})
+}
+
+// SubmitExport Queues an export of users to a file
+//
+// This will write the users matching the same filters as the list to a CSV, NDJSON or JSON file, downloaded from
+// GET /jobs/{job_id}/file once the job succeeded.
+//
+// swagger:route POST /jobs/export submitExportJob
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: Job
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) SubmitExport(w http.ResponseWriter, r *http.Request) {
+
+	if err := jc.authorize(r, authz.ACTION_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, msgErr := userFilter(query)
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = codec.FORMAT_CSV
+	}
+	if _, _, err := codec.RowWriter(format, io.Discard); err != nil {
+		utils.ResponseMessageErr(w, utils.BadRequestError("format should be csv, ndjson or json"))
+		return
+	}
+
+	jc.submit(w, r, &model.Job{Type: model.JOB_EXPORT, Params: model.JobParams{Format: format, Filter: filter}})
+}
+
+// SubmitPurge Queues the deletion of the users matching a filter
+//
+// This will delete every user matching the same filters as the list, which cannot all be empty. Each deletion is
+// published like the deletion of a single user.
+//
+// swagger:route POST /jobs/purge submitPurgeJob
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: Job
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) SubmitPurge(w http.ResponseWriter, r *http.Request) {
+
+	if err := jc.authorize(r, authz.ACTION_DELETE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter, msgErr := userFilter(r.URL.Query())
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+	if filter == (model.UserFilter{}) {
+		utils.ResponseMessageErr(w, utils.BadRequestError(service.RESPONSE_PURGE_NO_FILTER))
+		return
+	}
+
+	jc.submit(w, r, &model.Job{Type: model.JOB_PURGE, Params: model.JobParams{Filter: filter}})
+}
+
+// SubmitRevalidation Queues the validation of every stored user
+//
+// This will run the validation rules on every stored user, and report those that no longer pass them, e.g. after the
+// rules changed.
+//
+// swagger:route POST /jobs/revalidate submitRevalidationJob
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: Job
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) SubmitRevalidation(w http.ResponseWriter, r *http.Request) {
+
+	if err := jc.authorize(r, authz.ACTION_VALIDATE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	jc.submit(w, r, &model.Job{Type: model.JOB_REVALIDATE})
+}
+
+// ListJobs Get a list of all jobs
+//
+// This will return every job, the latest first, with its progress and outcome.
+//
+// swagger:route GET /jobs/ listJobs
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+// - application/x-ndjson
+//
+// Responses:
+//
+//	200: []Job
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) ListJobs(w http.ResponseWriter, r *http.Request) {
+
+	if err := jc.authorize(r, authz.ACTION_JOBS_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	jobs, err := jc.JobService.ListJobs()
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	for i := range jobs {
+		localizeJob(r, &jobs[i])
+	}
+	utils.Respond(w, r, http.StatusOK, jobs)
+}
+
+// GetJob Get a job
+//
+// This will return the state, progress and outcome of a job, polled until the job is finished.
+//
+// swagger:route GET /jobs/{job_id} getJob
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	200: Job
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) GetJob(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := idParam(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	if err := jc.authorize(r, authz.ACTION_JOBS_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	job, err := jc.JobService.GetJob(id)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	localizeJob(r, job)
+	utils.Respond(w, r, http.StatusOK, job)
+}
+
+// CancelJob cancels a job
+//
+// This will cancel a queued job at once, and stop a running job, which is canceled once it stopped. The changes made
+// before it stopped, such as imported or deleted users, are kept. A finished job is left as it is.
+//
+// swagger:route POST /jobs/{job_id}/cancel cancelJob
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: Job
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) CancelJob(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := idParam(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	if err := jc.authorize(r, authz.ACTION_JOBS_CANCEL); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	job, err := jc.JobService.CancelJob(id)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	localizeJob(r, job)
+	utils.Respond(w, r, http.StatusAccepted, job)
+}
+
+// DownloadJobFile Downloads the file of an export job
+//
+// This will download the file written by a succeeded export job.
+//
+// swagger:route GET /jobs/{job_id}/file downloadJobFile
+//
+// Produces:
+// - text/csv
+// - application/x-ndjson
+// - application/json
+//
+// Responses:
+//
+//	200: []User
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (jc *JobController) DownloadJobFile(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := idParam(w, r, "job_id")
+	if !ok {
+		return
+	}
+
+	// the file holds users, which the caller must be allowed to list too
+	for _, action := range []string{authz.ACTION_JOBS_LIST, authz.ACTION_LIST} {
+		if err := jc.authorize(r, action); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	path, err := jc.JobService.JobFile(id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = apperror.NotFound(fmt.Sprintf(service.RESPONSE_JOB_NO_FILE, id), err)
+	}
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	if _, mediaType, err := codec.RowWriter(format, io.Discard); err == nil {
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%v"`, format))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// submit queues a job and answers 202 with the job, located at the url it is followed with
+func (jc *JobController) submit(w http.ResponseWriter, r *http.Request, job *model.Job) {
+
+	created, err := jc.JobService.SubmitJob(job)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%v", created.Id))
+	utils.Respond(w, r, http.StatusAccepted, created)
+}
+
+// authorize asks the policy whether the caller may perform the action on jobs or on users
+func (jc *JobController) authorize(r *http.Request, action string) error {
+	return authz.Authorize(r.Context(), jc.Policy, action, 0)
+}
+
+// localizeJob translates the messages of the errors reported by a job
+func localizeJob(r *http.Request, job *model.Job) {
+	if job.Result == nil {
+		return
+	}
+	t := i18n.FromContext(r.Context())
+	if job.Result.Import != nil {
+		for i, importErr := range job.Result.Import.Errors {
+			job.Result.Import.Errors[i].Message = t.FieldError(model.FieldError{Field: importErr.Field, Code: importErr.Code, Message: importErr.Message})
+		}
+	}
+	if job.Result.Revalidation != nil {
+		for i, userErr := range job.Result.Revalidation.Errors {
+			job.Result.Revalidation.Errors[i].Message = t.FieldError(model.FieldError{Field: userErr.Field, Code: userErr.Code, Message: userErr.Message})
+		}
+	}
+}
+
+// swagger:parameters getJob cancelJob downloadJobFile
+type JobPathParam struct {
+	// in: path
+	JobId string `json:"job_id"`
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	mocksAuthz "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/authz"
+	mocksService "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubmitJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		wantJob     *model.Job
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "Import",
+			url:         "/jobs/import?dry_run=true&column=Given%20name:first_name",
+			contentType: "text/csv",
+			wantJob: &model.Job{Type: model.JOB_IMPORT, Input: "Given name\nJohn\n",
+				Params: model.JobParams{Format: "csv", DryRun: true, Columns: map[string]string{"Given name": "first_name"}}},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:        "Import JSON",
+			url:         "/jobs/import",
+			contentType: "application/json",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantBody:    `{"message":"The request body format is not supported.","code":415}`,
+		},
+		{
+			name:       "Export",
+			url:        "/jobs/export?format=ndjson&last_name=lovelace",
+			wantJob:    &model.Job{Type: model.JOB_EXPORT, Params: model.JobParams{Format: "ndjson", Filter: model.UserFilter{LastName: "lovelace"}}},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "Export CSV by default",
+			url:        "/jobs/export",
+			wantJob:    &model.Job{Type: model.JOB_EXPORT, Params: model.JobParams{Format: "csv"}},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "Export unknown format",
+			url:        "/jobs/export?format=xlsx",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"format should be csv, ndjson or json","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:       "Purge",
+			url:        "/jobs/purge?max_age=17",
+			wantJob:    &model.Job{Type: model.JOB_PURGE, Params: model.JobParams{Filter: model.UserFilter{MaxAge: 17}}},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "Purge everything",
+			url:        "/jobs/purge",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"A purge needs at least one filter","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:       "Revalidation",
+			url:        "/jobs/revalidate",
+			wantJob:    &model.Job{Type: model.JOB_REVALIDATE},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			jobService := new(mocksService.IJobService)
+			if tt.wantJob != nil {
+				jobService.On("SubmitJob", tt.wantJob).Return(func(job *model.Job) *model.Job {
+					submitted := *job
+					submitted.Id, submitted.Status = 12, model.JOB_QUEUED
+					return &submitted
+				}, nil)
+			}
+			jobController := JobController{JobService: jobService}
+			r := chi.NewRouter()
+			r.Post("/jobs/import", jobController.SubmitImport)
+			r.Post("/jobs/export", jobController.SubmitExport)
+			r.Post("/jobs/purge", jobController.SubmitPurge)
+			r.Post("/jobs/revalidate", jobController.SubmitRevalidation)
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString("Given name\nJohn\n"))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.ServeHTTP(rr, req)
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			jobService.AssertExpectations(t)
+			if tt.wantJob == nil {
+				assert.JSONEq(t, tt.wantBody, rr.Body.String())
+				return
+			}
+			assert.Equal(t, "/jobs/12", rr.Header().Get("Location"))
+			var job model.Job
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &job))
+			assert.Equal(t, int64(12), job.Id)
+			assert.Equal(t, model.JOB_QUEUED, job.Status)
+			assert.Empty(t, job.Input)
+		})
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	// Given
+	jobService := new(mocksService.IJobService)
+	jobService.On("GetJob", int64(12)).Return(&model.Job{Id: 12, Type: model.JOB_REVALIDATE, Status: model.JOB_SUCCEEDED,
+		Result: &model.JobResult{Revalidation: &model.RevalidationReport{Users: 1, Invalid: 1, Errors: []model.RevalidationError{
+			{UserId: 3, Field: "email", Code: "email_format", Message: "email failed on the 'email' tag"},
+		}}}}, nil)
+	jobService.On("GetJob", int64(13)).Return(nil, apperror.NotFound("job not found with id 13", nil))
+	jobController := JobController{JobService: jobService}
+	r := chi.NewRouter()
+	r.Get("/jobs/{job_id}", jobController.GetJob)
+
+	// When
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/12", nil))
+	missing := httptest.NewRecorder()
+	r.ServeHTTP(missing, httptest.NewRequest(http.MethodGet, "/jobs/13", nil))
+
+	// Then
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	var job model.Job
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.Equal(t, "User email must be properly formatted", job.Result.Revalidation.Errors[0].Message)
+	assert.EqualValues(t, http.StatusNotFound, missing.Code)
+}
+
+func TestCancelJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		denied     bool
+		wantStatus int
+	}{
+		{name: "Canceled", wantStatus: http.StatusAccepted},
+		{name: "Forbidden", denied: true, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			jobService := new(mocksService.IJobService)
+			jobService.On("CancelJob", int64(12)).Return(&model.Job{Id: 12, Type: model.JOB_EXPORT, Status: model.JOB_RUNNING, CancelRequested: true}, nil)
+			var denied error
+			if tt.denied {
+				denied = apperror.Forbidden("not allowed to cancel jobs")
+			}
+			policy := new(mocksAuthz.IPolicy)
+			policy.On("Authorize", mock.Anything, authz.ACTION_JOBS_CANCEL, int64(0)).Return(denied)
+			jobController := JobController{JobService: jobService, Policy: policy}
+			r := chi.NewRouter()
+			r.Post("/jobs/{job_id}/cancel", jobController.CancelJob)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/jobs/12/cancel", nil))
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			if tt.denied {
+				jobService.AssertNotCalled(t, "CancelJob", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDownloadJobFile(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "job-12.csv"), []byte("id,first_name\n1,Ada\n"), 0600))
+	jobService := new(mocksService.IJobService)
+	jobService.On("JobFile", int64(12)).Return(filepath.Join(dir, "job-12.csv"), nil)
+	jobService.On("JobFile", int64(13)).Return(filepath.Join(dir, "job-13.csv"), nil)
+	actions := []string{}
+	policy := new(mocksAuthz.IPolicy)
+	policy.On("Authorize", mock.Anything, mock.Anything, int64(0)).Return(func(ctx context.Context, action string, userId int64) error {
+		actions = append(actions, action)
+		return nil
+	})
+	jobController := JobController{JobService: jobService, Policy: policy}
+	r := chi.NewRouter()
+	r.Get("/jobs/{job_id}/file", jobController.DownloadJobFile)
+
+	// When
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/12/file", nil))
+	removed := httptest.NewRecorder()
+	r.ServeHTTP(removed, httptest.NewRequest(http.MethodGet, "/jobs/13/file", nil))
+
+	// Then
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "id,first_name\n1,Ada\n", rr.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, []string{authz.ACTION_JOBS_LIST, authz.ACTION_LIST, authz.ACTION_JOBS_LIST, authz.ACTION_LIST}, actions)
+	assert.EqualValues(t, http.StatusNotFound, removed.Code)
+}
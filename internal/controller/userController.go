@@ -1,55 +1,141 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type IUserController interface {
 	ListUsers(w http.ResponseWriter, r *http.Request)
+	StreamEvents(w http.ResponseWriter, r *http.Request)
 	GetUser(w http.ResponseWriter, r *http.Request)
 	SaveUser(w http.ResponseWriter, r *http.Request)
 	UpdateUser(w http.ResponseWriter, r *http.Request)
 	DeleteUser(w http.ResponseWriter, r *http.Request)
+	ValidateUser(w http.ResponseWriter, r *http.Request)
+	ValidateUserField(w http.ResponseWriter, r *http.Request)
+	ImportUsers(w http.ResponseWriter, r *http.Request)
+	ExportUsers(w http.ResponseWriter, r *http.Request)
 }
 
+// DefaultImportMaxBodySize is the largest file accepted by an import when none is configured
+const DefaultImportMaxBodySize int64 = 10 << 20
+
+// MAX_PAGE_LIMIT is the largest page of users listed at a time
+const MAX_PAGE_LIMIT = 1000
+
 type UserController struct {
 	UserService service.IUserService
+	// Policy decides which callers may perform each operation
+	Policy authz.IPolicy
+	// Events streams the changes of users, the stream is not found when nil
+	Events http.Handler
+	// ImportService creates users in bulk, imports are not found when nil
+	ImportService service.IUserImportService
+	// ImportMaxBodySize is the largest file accepted by an import, DefaultImportMaxBodySize when zero
+	ImportMaxBodySize int64
+	// ExportService writes users as they are read from the database, exports are not found when nil
+	ExportService service.IUserExportService
 }
 
 // GetUser Get a list of all users
 //
 // This will returns a list of users.
+// With a limit, the users are listed a page at a time ordered by id, the Link header linking to the next page until
+// the last one.
 //
 // swagger:route GET /users/ getAllUser
 //
-// Consumes:
-// - application/json
-//
-// Produces:
-// - application/json
-//
 // Responses:
 //
 //	201: User
 //	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
 //	500: MessageErr
 func (uc *UserController) ListUsers(w http.ResponseWriter, r *http.Request) {
 
-	userList, err := uc.UserService.GetAllUsers()
+	if err := uc.authorize(r, authz.ACTION_LIST, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	filter, msgErr := userFilter(r.URL.Query())
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+
+	afterId, limit, msgErr := userPage(r.URL.Query())
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+
+	// one more user than the page tells whether there is a next page
+	fetch := 0
+	if limit > 0 {
+		fetch = limit + 1
+	}
+	matching, err := uc.UserService.ListUsersAfter(filter, afterId, fetch)
 
 	if err != nil {
-		log.Error.Println(err)
-		utils.ResponseMessageErr(w, err)
+		respondError(w, r, err)
+		return
+	}
+
+	if limit > 0 && len(matching) > limit {
+		matching = matching[:limit]
+		w.Header().Set("Link", nextPageLink(r.URL, matching[limit-1].Id))
+	}
+
+	utils.Respond(w, r, http.StatusOK, matching)
+}
+
+// StreamEvents Stream the changes of users
+//
+// This will push a UserCreated, UserUpdated or UserDeleted Server-Sent Event for every change of a user, as it happens.
+// A client reconnecting with the Last-Event-ID header first gets the events it missed, or a reset event when they
+// are no longer buffered.
+//
+// swagger:route GET /users/events streamUserEvents
+//
+// Produces:
+// - text/event-stream
+//
+// Responses:
+//
+//	200: Event
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+func (uc *UserController) StreamEvents(w http.ResponseWriter, r *http.Request) {
+
+	if uc.Events == nil {
+		utils.ResponseLocalizedError(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err := uc.authorize(r, authz.ACTION_LIST, 0); err != nil {
+		respondError(w, r, err)
 		return
 	}
 
-	utils.ResponseJson(w, http.StatusOK, userList)
+	uc.Events.ServeHTTP(w, r)
 }
 
 // GetUser Get a user
@@ -58,17 +144,15 @@ func (uc *UserController) ListUsers(w http.ResponseWriter, r *http.Request) {
 //
 // swagger:route GET /users/{user_id} getUser
 //
-// Consumes:
-// - application/json
-//
-// Produces:
-// - application/json
-//
 // Responses:
 //
 //	200: User
 //	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
 //	404: MessageErr
+//	406: MessageErr
+//	429: MessageErr
 //	500: MessageErr
 func (uc *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 
@@ -78,15 +162,19 @@ func (uc *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := uc.authorize(r, authz.ACTION_READ, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
 	user, errApi := uc.UserService.GetUser(id)
 
 	if errApi != nil {
-		log.Error.Println(errApi)
-		utils.ResponseMessageErr(w, errApi)
+		respondError(w, r, errApi)
 		return
 	}
 
-	utils.ResponseJson(w, http.StatusOK, user)
+	utils.Respond(w, r, http.StatusOK, user)
 }
 
 // SaveUser creates a new user
@@ -95,25 +183,32 @@ func (uc *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 //
 // swagger:route POST /users saveUser
 //
-// Consumes:
-// - application/json
-//
-// Produces:
-// - application/json
-//
 // Responses:
 //
 //	201: User
 //	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	409: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	422: MessageErr
+//	429: MessageErr
 //	500: MessageErr
 //
 // responses.createUserCreated.headers.body.type: UserResponse
 func (uc *UserController) SaveUser(w http.ResponseWriter, r *http.Request) {
 
+	if err := uc.authorize(r, authz.ACTION_CREATE, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
 	var body model.User
-	if err := utils.ParseJson(r, &body); err != nil {
+	if err := utils.ParseBody(w, r, &body); err != nil {
 		log.Error.Println(err)
-		utils.ResponseMessageErr(w, utils.BadRequestError(err.Error()))
+		utils.ResponseMessageErr(w, err)
 		return
 	}
 
@@ -121,42 +216,60 @@ func (uc *UserController) SaveUser(w http.ResponseWriter, r *http.Request) {
 	user, err := uc.UserService.SaveUser(&body)
 
 	if err != nil {
-		log.Error.Println(err)
-		utils.ResponseMessageErr(w, err)
+		respondError(w, r, err)
 		return
 	}
 
 	log.Info.Printf("User created : %v", user)
 
-	utils.ResponseJson(w, http.StatusCreated, user)
+	utils.Respond(w, r, http.StatusCreated, user)
 
 }
 
 // UpdateUser Updates a user by ID
 //
-// This will Update a user.
+// This will Update a user. An id in the request body must be the id in the path.
 //
 // swagger:route PUT /users/{user_id} updateUser
 //
-// Consumes:
-// - application/json
-//
-// Produces:
-// - application/json
-//
 // Responses:
 //
 //	200: User
 //	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
 //	500: MessageErr
 func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	log.Info.Printf("User update service ")
 
 	var body model.User
-	if err := utils.ParseJson(r, &body); err != nil {
+	if err := utils.ParseBody(w, r, &body); err != nil {
 		log.Error.Println(err)
-		utils.ResponseMessageErr(w, utils.BadRequestError(err.Error()))
+		utils.ResponseMessageErr(w, err)
+		return
+	}
+
+	id, idErr := getUserId(chi.URLParam(r, "user_id"))
+	if idErr != nil {
+		utils.ResponseMessageErr(w, idErr)
+		return
+	}
+
+	// The path names the user acted upon, a body naming another one is rejected before it could be authorized
+	if body.Id != 0 && body.Id != id {
+		utils.ResponseMessageErr(w, utils.BadRequestError("user id in the body does not match the path"))
+		return
+	}
+	body.Id = id
+
+	if err := uc.authorize(r, authz.ACTION_UPDATE, id); err != nil {
+		respondError(w, r, err)
 		return
 	}
 
@@ -165,14 +278,13 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	user, err := uc.UserService.UpdateUser(&body)
 
 	if err != nil {
-		log.Error.Println(err)
-		utils.ResponseMessageErr(w, err)
+		respondError(w, r, err)
 		return
 	}
 
 	log.Info.Printf("User updated : %v", user)
 
-	utils.ResponseJson(w, http.StatusOK, user)
+	utils.Respond(w, r, http.StatusOK, user)
 
 }
 
@@ -182,17 +294,15 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 //
 // swagger:route DELETE /users/{user_id} deleteUser
 //
-// Consumes:
-// - application/json
-//
-// Produces:
-// - application/json
-//
 // Responses:
 //
-//	200: User
+//	200: StatusResponse
 //	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
 //	404: MessageErr
+//	406: MessageErr
+//	429: MessageErr
 //	500: MessageErr
 func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
@@ -202,18 +312,372 @@ func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := uc.authorize(r, authz.ACTION_DELETE, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
 	errApi := uc.UserService.DeleteUser(id)
 
 	if errApi != nil {
-		log.Error.Println(errApi)
-		utils.ResponseMessageErr(w, errApi)
+		respondError(w, r, errApi)
 		return
 	}
 
 	log.Info.Print("User deleted with id : ", id)
 
-	utils.ResponseJson(w, http.StatusOK, map[string]string{"status": "deleted"})
+	utils.Respond(w, r, http.StatusOK, model.StatusResponse{Status: "deleted"})
+
+}
+
+// ValidateUser Validates a user without saving it
+//
+// This will run every validation rule, including name uniqueness, against the user in the request body.
+// Nothing is persisted.
+//
+// swagger:route POST /users/validate validateUser
+//
+// Responses:
+//
+//	200: ValidationResult
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (uc *UserController) ValidateUser(w http.ResponseWriter, r *http.Request) {
+
+	if err := uc.authorize(r, authz.ACTION_VALIDATE, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
 
+	var body model.User
+	if err := utils.ParseBody(w, r, &body); err != nil {
+		log.Error.Println(err)
+		utils.ResponseMessageErr(w, err)
+		return
+	}
+
+	result, err := uc.UserService.ValidateUser(&body)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	result.Errors = i18n.FromContext(r.Context()).FieldErrors(result.Errors)
+
+	utils.Respond(w, r, http.StatusOK, result)
+}
+
+// ValidateUserField Validates a single user field without saving it
+//
+// This will run the validation rules of the field named in the path against the user in the request body.
+// Nothing is persisted.
+//
+// swagger:route POST /users/validate/{field} validateUserField
+//
+// Responses:
+//
+//	200: ValidationResult
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (uc *UserController) ValidateUserField(w http.ResponseWriter, r *http.Request) {
+
+	if err := uc.authorize(r, authz.ACTION_VALIDATE, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var body model.User
+	if err := utils.ParseBody(w, r, &body); err != nil {
+		log.Error.Println(err)
+		utils.ResponseMessageErr(w, err)
+		return
+	}
+
+	result, err := uc.UserService.ValidateUserField(&body, chi.URLParam(r, "field"))
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	result.Errors = i18n.FromContext(r.Context()).FieldErrors(result.Errors)
+
+	utils.Respond(w, r, http.StatusOK, result)
+}
+
+// ImportUsers Creates users in bulk from a CSV or NDJSON file
+//
+// This will read the file one row at a time, validate every row like a created user and insert the valid rows in
+// batches. The rejected rows do not stop the import, they are listed in the report with their line, field and error.
+// CSV headers are matched to the user fields ignoring case, spaces and punctuation, other headers are mapped with the
+// column parameter. Nothing is saved with dry_run=true.
+// The report is downloaded as one line per error when text/csv or application/x-ndjson is accepted.
+// An import failing after some of its batches were inserted answers with the status of the error and the report of
+// the rows read before it, whose error field tells why it stopped.
+//
+// swagger:route POST /users/import importUsers
+//
+// Consumes:
+// - text/csv
+// - application/x-ndjson
+//
+// Responses:
+//
+//	200: ImportReport
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+//	503: MessageErr
+func (uc *UserController) ImportUsers(w http.ResponseWriter, r *http.Request) {
+
+	if uc.ImportService == nil {
+		utils.ResponseLocalizedError(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err := uc.authorize(r, authz.ACTION_CREATE, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	dryRun, columns, msgErr := importParams(r.URL.Query())
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+
+	maxBodySize := uc.ImportMaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultImportMaxBodySize
+	}
+	body := http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	var rows codec.IRowReader
+	requestCodec, _ := codec.RegistryFromContext(r.Context()).ForContentType(r.Header.Get("Content-Type"))
+	switch requestCodec.(type) {
+	case codec.CSV:
+		rows = &codec.CSVReader{Reader: body, Columns: columns}
+	case codec.NDJSON:
+		rows = &codec.NDJSONReader{Reader: body}
+	default:
+		utils.ResponseLocalizedError(w, r, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	status := http.StatusOK
+	report, err := uc.ImportService.ImportUsers(r.Context(), rows, dryRun)
+	if err != nil && (report == nil || report.DryRun || report.Imported == 0) {
+		respondError(w, r, err)
+		return
+	}
+	if err != nil {
+		log.Error.Println(err)
+		msgErr := MessageErrFromError(r.Context(), err)
+		status, report.Error = msgErr.Status(), msgErr.Message()
+	}
+
+	t := i18n.FromContext(r.Context())
+	for i, importErr := range report.Errors {
+		report.Errors[i].Message = t.FieldError(model.FieldError{Field: importErr.Field, Code: importErr.Code, Message: importErr.Message})
+	}
+
+	var payload interface{} = report
+	responseCodec, ok := codec.ResponseFromContext(r.Context())
+	if !ok {
+		responseCodec, _ = codec.RegistryFromContext(r.Context()).ForAccept(r.Header.Get("Accept"))
+	}
+	switch responseCodec.(type) {
+	case codec.CSV:
+		payload = report.Errors
+		w.Header().Set("Content-Disposition", `attachment; filename="users-import-report.csv"`)
+	case codec.NDJSON:
+		payload = report.Errors
+		w.Header().Set("Content-Disposition", `attachment; filename="users-import-report.ndjson"`)
+	}
+
+	utils.Respond(w, r, status, payload)
+}
+
+// ExportUsers Downloads the users as a CSV, NDJSON or JSON file
+//
+// This will stream the users matching the same filters as the list, reading them from the database in chunks so that
+// exports of any size take constant memory. The format parameter chooses the file format, the Accept header choosing
+// it otherwise. An export failing after its first rows is cut short, leaving an incomplete file.
+//
+// swagger:route GET /users/export exportUsers
+//
+// Produces:
+// - text/csv
+// - application/x-ndjson
+// - application/json
+//
+// Responses:
+//
+//	200: []User
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+//	503: MessageErr
+func (uc *UserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+
+	if uc.ExportService == nil {
+		utils.ResponseLocalizedError(w, r, http.StatusNotFound)
+		return
+	}
+
+	if err := uc.authorize(r, authz.ACTION_LIST, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, msgErr := userFilter(query)
+	if msgErr != nil {
+		utils.ResponseMessageErr(w, msgErr)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = exportFormat(r)
+	}
+	rows, mediaType, err := codec.RowWriter(format, w)
+	if err != nil {
+		utils.ResponseMessageErr(w, utils.BadRequestError("format should be csv, ndjson or json"))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%v"`, format))
+	written, err := uc.ExportService.ExportUsers(r.Context(), filter, rows)
+	if err != nil && written > 0 {
+		// the status is already sent, aborting tells the client the file is incomplete
+		log.Error.Printf("Export stopped after %v users: %v", written, err)
+		panic(http.ErrAbortHandler)
+	}
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+		respondError(w, r, err)
+	}
+}
+
+// authorize asks the policy whether the caller may perform the action, on the given user when userId is not 0
+func (uc *UserController) authorize(r *http.Request, action string, userId int64) error {
+	return authz.Authorize(r.Context(), uc.Policy, action, userId)
+}
+
+// importParams reads the dry_run and column parameters of an import
+func importParams(query url.Values) (bool, map[string]string, utils.MessageErr) {
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, nil, utils.BadRequestError("dry_run should be true or false")
+		}
+		dryRun = parsed
+	}
+	columns, msgErr := importColumns(query["column"])
+	if msgErr != nil {
+		return false, nil, msgErr
+	}
+	return dryRun, columns, nil
+}
+
+// importColumns reads the column parameters, written as "<header>:<field>", into the header mapping of a CSV import
+func importColumns(values []string) (map[string]string, utils.MessageErr) {
+	columns := map[string]string{}
+	for _, value := range values {
+		// headers may contain colons, fields never do
+		separator := strings.LastIndex(value, ":")
+		if separator <= 0 || separator == len(value)-1 {
+			return nil, utils.BadRequestError("column should be written as <header>:<field>")
+		}
+		columns[strings.TrimSpace(value[:separator])] = strings.TrimSpace(value[separator+1:])
+	}
+	return columns, nil
+}
+
+// userFilter reads the filter parameters of the list and export of users
+func userFilter(query url.Values) (model.UserFilter, utils.MessageErr) {
+	filter := model.UserFilter{
+		FirstName: query.Get("first_name"),
+		LastName:  query.Get("last_name"),
+		Email:     query.Get("email"),
+	}
+	for name, bound := range map[string]*int64{"min_age": &filter.MinAge, "max_age": &filter.MaxAge} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return model.UserFilter{}, utils.BadRequestError(name + " should be a number")
+			}
+			*bound = parsed
+		}
+	}
+	return filter, nil
+}
+
+// userPage reads the page parameters of the list of users, a zero limit meaning every user
+func userPage(query url.Values) (int64, int, utils.MessageErr) {
+	var afterId int64
+	if value := query.Get("after_id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return 0, 0, utils.BadRequestError("after_id should be a user id")
+		}
+		afterId = parsed
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > MAX_PAGE_LIMIT {
+			return 0, 0, utils.BadRequestError(fmt.Sprintf("limit should be a number between 1 and %v", MAX_PAGE_LIMIT))
+		}
+		limit = parsed
+	}
+	return afterId, limit, nil
+}
+
+// nextPageLink returns the Link header of the page following the user afterId, with the same filters
+func nextPageLink(current *url.URL, afterId int64) string {
+	query := current.Query()
+	query.Set("after_id", strconv.FormatInt(afterId, 10))
+	next := url.URL{Path: current.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf(`<%v>; rel="next"`, next.String())
+}
+
+// exportFormat returns the export format of the accepted media type, JSON unless CSV or NDJSON is accepted
+func exportFormat(r *http.Request) string {
+	responseCodec, _ := codec.RegistryFromContext(r.Context()).ForAccept(r.Header.Get("Accept"))
+	switch responseCodec.(type) {
+	case codec.CSV:
+		return codec.FORMAT_CSV
+	case codec.NDJSON:
+		return codec.FORMAT_NDJSON
+	default:
+		return codec.FORMAT_JSON
+	}
 }
 
 func getUserId(userIdParam string) (int64, utils.MessageErr) {
@@ -230,7 +694,71 @@ type UserPathParam struct {
 	UserId string `json:"user_id"`
 }
 
-// swagger:parameters updateUser saveUser
+// swagger:parameters validateUserField
+type FieldPathParam struct {
+	// in: path
+	Field string `json:"field"`
+}
+
+// swagger:parameters saveUser
+type IdempotencyKeyParam struct {
+	// Retries sent with the same key get the response of the first request instead of creating another user
+	// in: header
+	IdempotencyKey string `json:"Idempotency-Key"`
+}
+
+// swagger:parameters getAllUser exportUsers submitExportJob submitPurgeJob
+type UserFilterParams struct {
+	// Keeps the users whose first name contains this text, ignoring case
+	// in: query
+	FirstName string `json:"first_name"`
+	// Keeps the users whose last name contains this text, ignoring case
+	// in: query
+	LastName string `json:"last_name"`
+	// Keeps the users whose email contains this text, ignoring case
+	// in: query
+	Email string `json:"email"`
+	// Keeps the users at least this old
+	// in: query
+	MinAge int64 `json:"min_age"`
+	// Keeps the users at most this old
+	// in: query
+	MaxAge int64 `json:"max_age"`
+}
+
+// swagger:parameters getAllUser
+type PageParams struct {
+	// Lists at most this number of users, ordered by id, the next page being linked in the Link header
+	// in: query
+	// maximum: 1000
+	Limit int64 `json:"limit"`
+	// Lists the users after this id, e.g. the last id of the previous page
+	// in: query
+	AfterId int64 `json:"after_id"`
+}
+
+// swagger:parameters exportUsers submitExportJob
+type ExportParams struct {
+	// The file format, chosen from the Accept header when missing
+	// in: query
+	// enum: csv,ndjson,json
+	Format string `json:"format"`
+}
+
+// swagger:parameters importUsers submitImportJob
+type ImportParams struct {
+	// Validates the rows and reports the errors without saving any user
+	// in: query
+	DryRun bool `json:"dry_run"`
+	// Maps a CSV header to a user field, written as "<header>:<field>", a header mapped to "-" being skipped
+	// in: query
+	Column []string `json:"column"`
+	// The CSV file, with a header, or the NDJSON file, with one user per line
+	// in: body
+	File string
+}
+
+// swagger:parameters updateUser saveUser validateUser validateUserField
 type UserBodyParam struct {
 	// in:body
 	User model.User
@@ -2,44 +2,80 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	mocksAuthz "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/authz"
+	mocksService "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	//"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 var (
-	getUserService    func(msgId int64) (*model.User, utils.MessageErr)
-	createUserService func(message *model.User) (*model.User, utils.MessageErr)
-	updateUserService func(message *model.User) (*model.User, utils.MessageErr)
-	deleteUserService func(msgId int64) utils.MessageErr
-	getAllUserService func() ([]model.User, utils.MessageErr)
+	getUserService    func(msgId int64) (*model.User, error)
+	createUserService func(message *model.User) (*model.User, error)
+	updateUserService func(message *model.User) (*model.User, error)
+	deleteUserService func(msgId int64) error
+	getAllUserService func() ([]model.User, error)
+	validateService   func(message *model.User, field string) (*model.ValidationResult, error)
 )
 
 type serviceMock struct{}
 
-func (sm *serviceMock) GetUser(msgId int64) (*model.User, utils.MessageErr) {
+func (sm *serviceMock) GetUser(msgId int64) (*model.User, error) {
 	return getUserService(msgId)
 }
 
-func (sm *serviceMock) SaveUser(message *model.User) (*model.User, utils.MessageErr) {
+func (sm *serviceMock) GetUserByEmail(email string) (*model.User, error) {
+	return nil, apperror.Internal("not used by the controller", nil)
+}
+
+func (sm *serviceMock) SaveUser(message *model.User) (*model.User, error) {
 	return createUserService(message)
 }
-func (sm *serviceMock) UpdateUser(message *model.User) (*model.User, utils.MessageErr) {
+func (sm *serviceMock) UpdateUser(message *model.User) (*model.User, error) {
 	return updateUserService(message)
 }
-func (sm *serviceMock) DeleteUser(msgId int64) utils.MessageErr {
+func (sm *serviceMock) DeleteUser(msgId int64) error {
 	return deleteUserService(msgId)
 }
-func (sm *serviceMock) GetAllUsers() ([]model.User, utils.MessageErr) {
+func (sm *serviceMock) GetAllUsers() ([]model.User, error) {
 	return getAllUserService()
 }
+// ListUsersAfter lists the users of getAllUserService the way the repository does
+func (sm *serviceMock) ListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+	users, err := getAllUserService()
+	if err != nil {
+		return nil, err
+	}
+	matching := []model.User{}
+	for _, user := range users {
+		if filter.Matches(user) && user.Id > afterId && (limit <= 0 || len(matching) < limit) {
+			matching = append(matching, user)
+		}
+	}
+	return matching, nil
+}
+func (sm *serviceMock) ValidateUser(message *model.User) (*model.ValidationResult, error) {
+	return validateService(message, "")
+}
+func (sm *serviceMock) ValidateUserField(message *model.User, field string) (*model.ValidationResult, error) {
+	return validateService(message, field)
+}
 
 // /////////////////////////////////////////////////////////////
 // "GetUser" test cases
@@ -47,9 +83,9 @@ func (sm *serviceMock) GetAllUsers() ([]model.User, utils.MessageErr) {
 func TestUserController_GetUser_Success(t *testing.T) {
 	// Given
 	var service service.IUserService = &serviceMock{}
-	var userController = UserController{service}
+	var userController = UserController{UserService: service}
 
-	getUserService = func(msgId int64) (*model.User, utils.MessageErr) {
+	getUserService = func(msgId int64) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -81,9 +117,9 @@ func TestUserController_GetUser_Success(t *testing.T) {
 func TestGetMessage_Invalid_Id(t *testing.T) {
 	// Given
 	var service service.IUserService = &serviceMock{}
-	var userController = UserController{service}
+	var userController = UserController{UserService: service}
 
-	getUserService = func(msgId int64) (*model.User, utils.MessageErr) {
+	getUserService = func(msgId int64) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -113,8 +149,8 @@ func TestGetMessage_Invalid_Id(t *testing.T) {
 func TestGetUser_User_Not_Found(t *testing.T) {
 	// Given
 	var service service.IUserService = &serviceMock{}
-	var userController = UserController{service}
-	getUserService = func(msgId int64) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: service}
+	getUserService = func(msgId int64) (*model.User, error) {
 		return nil, utils.NotFoundError("message not found")
 	}
 	msgId := "1" //valid id
@@ -138,8 +174,8 @@ func TestGetUser_User_Not_Found(t *testing.T) {
 func TestGetUser_User_Database_Error(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	getUserService = func(msgId int64) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	getUserService = func(msgId int64) (*model.User, error) {
 		return nil, utils.InternalServerError("database error")
 	}
 	msgId := "1" //valid id
@@ -166,8 +202,8 @@ func TestGetUser_User_Database_Error(t *testing.T) {
 func TestCreateUser_Success(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	createUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	createUserService = func(message *model.User) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -204,7 +240,7 @@ func TestCreateUser_Success(t *testing.T) {
 func TestCreateUser_Invalid_Json(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
+	var userController = UserController{UserService: userService}
 	inputJson := `{"Id": 1, "first_name": 456, "last_name": "Doe", "email": "john.doe@gmail.com", "age": 30}`
 	r := chi.NewRouter()
 	req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(inputJson))
@@ -227,11 +263,64 @@ func TestCreateUser_Invalid_Json(t *testing.T) {
 	assert.EqualValues(t, "bad_request", apiErr.Error())
 }
 
+func TestCreateUser_Xml(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	createUserService = func(message *model.User) (*model.User, error) {
+		message.Id = 1
+		return message, nil
+	}
+	xmlBody := `<user><first_name>John</first_name><last_name>Doe</last_name><email>john.doe@gmail.com</email><age>30</age></user>`
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(xmlBody))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Post("/users", userController.SaveUser)
+	r.ServeHTTP(rr, req)
+
+	// Then
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+	assert.EqualValues(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, "id,first_name,last_name,email,age\n1,John,Doe,john.doe@gmail.com,30\n", rr.Body.String())
+}
+
+func TestCreateUser_Unsupported_Media_Type(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPost, "/users", bytes.NewBufferString("first_name=John"))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Post("/users", userController.SaveUser)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+
+	// Then
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusUnsupportedMediaType, apiErr.Status())
+	assert.EqualValues(t, "The request body format is not supported.", apiErr.Message())
+	assert.EqualValues(t, "unsupported_media_type", apiErr.Error())
+}
+
 func TestCreateUser_Empty_FirstName(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	createUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	createUserService = func(message *model.User) (*model.User, error) {
 		return nil, utils.UnprocessibleEntityError("Please enter a valid firstname")
 	}
 	inputJson := `{"Id": 1, "first_name": "", "last_name": "Doe", "email": "john.doe@gmail.com", "age": 30}`
@@ -259,8 +348,8 @@ func TestCreateUser_Empty_FirstName(t *testing.T) {
 func TestCreateUser_Empty_Lastname(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	createUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	createUserService = func(message *model.User) (*model.User, error) {
 		return nil, utils.UnprocessibleEntityError("Please enter a valid lastname")
 	}
 	inputJson := `{"Id": 1, "first_name": "John", "last_name": "", "email": "john.doe@gmail.com", "age": 30}`
@@ -291,8 +380,8 @@ func TestCreateUser_Empty_Lastname(t *testing.T) {
 func TestUpdateUser_Success(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	updateUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	updateUserService = func(message *model.User) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "Johnny",
@@ -331,7 +420,7 @@ func TestUpdateUser_Success(t *testing.T) {
 func TestUpdateUser_Invalid_Id(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
+	var userController = UserController{UserService: userService}
 	jsonBody := `{"Id": "abc", "first_name": "Johnny", "last_name": "Dover", "email": "johnny.dover@gmail.com", "age": 37}`
 	r := chi.NewRouter()
 	id := "abc"
@@ -357,7 +446,7 @@ func TestUpdateUser_Invalid_Id(t *testing.T) {
 func TestUpdateUser_Invalid_Json(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
+	var userController = UserController{UserService: userService}
 	inputJson := `{"Id": 1, "first_name": 456, "last_name": "Doe", "email": "john.doe@gmail.com", "age": 30}`
 	r := chi.NewRouter()
 	id := "1"
@@ -381,11 +470,36 @@ func TestUpdateUser_Invalid_Json(t *testing.T) {
 	assert.EqualValues(t, "bad_request", apiErr.Error())
 }
 
+func TestUpdateUser_Malformed_Json(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	inputJson := `{"first_name": "John" "last_name": "Doe"}`
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPut, "/users/1", bytes.NewBufferString(inputJson))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Put("/users/{user_id}", userController.UpdateUser)
+	r.ServeHTTP(rr, req)
+
+	// Then the message quoting the body is escaped
+	assert.True(t, json.Valid(rr.Body.Bytes()), rr.Body.String())
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, `invalid character '"' after object key:value pair`, apiErr.Message())
+	assert.EqualValues(t, "bad_request", apiErr.Error())
+}
+
 func TestUpdateUser_Empty_Firstname(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	updateUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	updateUserService = func(message *model.User) (*model.User, error) {
 		return nil, utils.BadRequestError("Please enter a valid firstname")
 	}
 	inputJson := `{"Id": 1, "first_name": "", "last_name": "Doe", "email": "john.doe@gmail.com", "age": 30}`
@@ -413,8 +527,8 @@ func TestUpdateUser_Empty_Firstname(t *testing.T) {
 func TestUpdateUser_Empty_Lastname(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	updateUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	updateUserService = func(message *model.User) (*model.User, error) {
 		return nil, utils.BadRequestError("Please enter a valid lastname")
 	}
 	inputJson := `{"Id": 1, "first_name": "John", "last_name": "", "email": "john.doe@gmail.com", "age": 30}`
@@ -442,8 +556,8 @@ func TestUpdateUser_Empty_Lastname(t *testing.T) {
 func TestUpdateUser_Error_Updating(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	updateUserService = func(message *model.User) (*model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	updateUserService = func(message *model.User) (*model.User, error) {
 		return nil, utils.InternalServerError("error when updating user")
 	}
 	jsonBody := `{"Id": 1, "first_name": "Johnny", "last_name": "Dover", "email": "johnny.dover@gmail.com", "age": 37}`
@@ -468,14 +582,54 @@ func TestUpdateUser_Error_Updating(t *testing.T) {
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
 
+func TestUpdateUser_Other_User_Own_Permissions(t *testing.T) {
+	// Given a caller allowed to update only its own user, user 2
+	policy, err := authz.LoadPolicy("")
+	assert.Nil(t, err)
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService, Policy: policy}
+	updateUserService = func(message *model.User) (*model.User, error) {
+		t.Errorf("user %v must not be updated by another user", message.Id)
+		return message, nil
+	}
+	caller := auth.NewContext(context.Background(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "2"}, Roles: []string{"user"}})
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"own id in the body", `{"Id": 2, "first_name": "Johnny", "last_name": "Dover", "email": "johnny.dover@gmail.com", "age": 37}`, http.StatusBadRequest},
+		{"no id in the body", `{"first_name": "Johnny", "last_name": "Dover", "email": "johnny.dover@gmail.com", "age": 37}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			req, err := http.NewRequestWithContext(caller, http.MethodPut, "/users/1", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Errorf("this is the error: %v\n", err)
+			}
+			rr := httptest.NewRecorder()
+
+			// When user 2 puts to the path of user 1
+			r.Put("/users/{user_id}", userController.UpdateUser)
+			r.ServeHTTP(rr, req)
+
+			// Then
+			apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+			assert.Nil(t, err)
+			assert.EqualValues(t, tt.wantStatus, apiErr.Status())
+		})
+	}
+}
+
 // /////////////////////////////////////////////////////////////
 // "DeleteUser" test cases
 // /////////////////////////////////////////////////////////////
 func TestDeleteUser_Success(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	deleteUserService = func(msg int64) utils.MessageErr {
+	var userController = UserController{UserService: userService}
+	deleteUserService = func(msg int64) error {
 		return nil
 	}
 	r := chi.NewRouter()
@@ -503,7 +657,7 @@ func TestDeleteUser_Success(t *testing.T) {
 func TestDeleteUser_Invalid_Id(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
+	var userController = UserController{UserService: userService}
 
 	r := chi.NewRouter()
 	id := "abc"
@@ -532,8 +686,8 @@ func TestDeleteUser_Invalid_Id(t *testing.T) {
 func TestDeleteUser_Failure(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	deleteUserService = func(msg int64) utils.MessageErr {
+	var userController = UserController{UserService: userService}
+	deleteUserService = func(msg int64) error {
 		return utils.InternalServerError("error deleting message")
 	}
 	r := chi.NewRouter()
@@ -560,14 +714,43 @@ func TestDeleteUser_Failure(t *testing.T) {
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
 
+func TestDeleteUser_Forbidden(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	policy := new(mocksAuthz.IPolicy)
+	policy.On("Authorize", mock.Anything, authz.ACTION_DELETE, int64(1)).Return(apperror.Forbidden("viewer may not delete users"))
+	var userController = UserController{UserService: userService, Policy: policy}
+	deleteUserService = func(msg int64) error {
+		t.Error("a forbidden user must not be deleted")
+		return nil
+	}
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodDelete, "/users/1", nil)
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Delete("/users/{user_id}", userController.DeleteUser)
+	r.ServeHTTP(rr, req)
+
+	// Then
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, apiErr.Status())
+	assert.EqualValues(t, "You are not allowed to perform this operation.", apiErr.Message())
+	assert.EqualValues(t, "forbidden", apiErr.Error())
+}
+
 // /////////////////////////////////////////////////////////////
 // "GetAllUsers" test cases
 // /////////////////////////////////////////////////////////////
 func TestGetAllUsers_Success(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	getAllUserService = func() ([]model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	getAllUserService = func() ([]model.User, error) {
 		return []model.User{
 			{
 				Id:        1,
@@ -615,8 +798,8 @@ func TestGetAllUsers_Success(t *testing.T) {
 func TestGetAllUsers_Failure(t *testing.T) {
 	// Given
 	var userService service.IUserService = &serviceMock{}
-	var userController = UserController{userService}
-	getAllUserService = func() ([]model.User, utils.MessageErr) {
+	var userController = UserController{UserService: userService}
+	getAllUserService = func() ([]model.User, error) {
 		return nil, utils.InternalServerError("error getting messages")
 	}
 	r := chi.NewRouter()
@@ -638,3 +821,445 @@ func TestGetAllUsers_Failure(t *testing.T) {
 	assert.EqualValues(t, "server_error", apiErr.Error())
 	assert.EqualValues(t, http.StatusInternalServerError, apiErr.Status())
 }
+
+func TestGetAllUsers_Filtered(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+		wantLink   string
+	}{
+		{
+			name:       "Filtered",
+			url:        "/users?last_name=DOV&min_age=30",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":2,"first_name":"Johnny","last_name":"Dover","email":"johnny@gmail.com","age":30}]`,
+		},
+		{
+			name:       "Invalid age",
+			url:        "/users?max_age=old",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"max_age should be a number","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:       "First page",
+			url:        "/users?last_name=do&limit=2",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":1,"first_name":"John","last_name":"Doe","email":"john.doe@gmail.com","age":30},{"id":2,"first_name":"Johnny","last_name":"Dover","email":"johnny@gmail.com","age":30}]`,
+			wantLink:   `</users?after_id=2&last_name=do&limit=2>; rel="next"`,
+		},
+		{
+			name:       "Last page",
+			url:        "/users?last_name=do&limit=2&after_id=2",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":3,"first_name":"Jim","last_name":"Dover","email":"jim@gmail.com","age":12}]`,
+		},
+		{
+			name:       "Invalid limit",
+			url:        "/users?limit=0",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"limit should be a number between 1 and 1000","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:       "Invalid after id",
+			url:        "/users?after_id=last",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"message":"after_id should be a user id","status":400,"error":"bad_request"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			userController := UserController{UserService: &serviceMock{}}
+			getAllUserService = func() ([]model.User, error) {
+				return []model.User{
+					{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+					{Id: 2, FirstName: "Johnny", LastName: "Dover", Email: "johnny@gmail.com", Age: 30},
+					{Id: 3, FirstName: "Jim", LastName: "Dover", Email: "jim@gmail.com", Age: 12},
+				}, nil
+			}
+			r := chi.NewRouter()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.Get("/users", userController.ListUsers)
+			r.ServeHTTP(rr, req)
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			assert.Equal(t, tt.wantLink, rr.Header().Get("Link"))
+		})
+	}
+}
+
+func TestGetAllUsers_Page_Query(t *testing.T) {
+	// Given a page of two users after user 4, the service holding one more
+	userService := new(mocksService.IUserService)
+	userService.On("ListUsersAfter", model.UserFilter{LastName: "do"}, int64(4), 3).Return([]model.User{{Id: 5}, {Id: 7}, {Id: 8}}, nil)
+	userController := UserController{UserService: userService}
+	r := chi.NewRouter()
+	req := httptest.NewRequest(http.MethodGet, "/users?last_name=do&limit=2&after_id=4", nil)
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Get("/users", userController.ListUsers)
+	r.ServeHTTP(rr, req)
+
+	// Then the extra user is not listed, and only tells that there is a next page
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	var users []model.User
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &users))
+	assert.Equal(t, []model.User{{Id: 5}, {Id: 7}}, users)
+	assert.Equal(t, `</users?after_id=7&last_name=do&limit=2>; rel="next"`, rr.Header().Get("Link"))
+	userService.AssertExpectations(t)
+}
+
+// /////////////////////////////////////////////////////////////
+// "ValidateUser" test cases
+// /////////////////////////////////////////////////////////////
+func TestValidateUser_Success(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	validateService = func(message *model.User, field string) (*model.ValidationResult, error) {
+		return &model.ValidationResult{
+			Valid:  false,
+			Errors: []model.FieldError{{Field: "age", Code: "age_minimum", Message: "User does not meet minimum age requirement"}},
+		}, nil
+	}
+	jsonBody := `{"first_name": "John", "last_name": "Doe", "email": "john.doe@gmail.com", "age": 9}`
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPost, "/users/validate", bytes.NewBufferString(jsonBody))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Post("/users/validate", userController.ValidateUser)
+	r.ServeHTTP(rr, req)
+
+	// Then
+	var result model.ValidationResult
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.False(t, result.Valid)
+	assert.Len(t, result.Errors, 1)
+	assert.EqualValues(t, "age", result.Errors[0].Field)
+	assert.EqualValues(t, "age_minimum", result.Errors[0].Code)
+}
+
+func TestValidateUserField_Unknown_Field(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	validateService = func(message *model.User, field string) (*model.ValidationResult, error) {
+		return nil, utils.BadRequestError("unknown user field '" + field + "'")
+	}
+	jsonBody := `{"first_name": "John"}`
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPost, "/users/validate/nickname", bytes.NewBufferString(jsonBody))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Post("/users/validate/{field}", userController.ValidateUserField)
+	r.ServeHTTP(rr, req)
+
+	// Then
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.NotNil(t, apiErr)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "unknown user field 'nickname'", apiErr.Message())
+	assert.EqualValues(t, "bad_request", apiErr.Error())
+}
+
+func TestValidateUserField_Unknown_Field_Quoted(t *testing.T) {
+	// Given
+	var userService service.IUserService = &serviceMock{}
+	var userController = UserController{UserService: userService}
+	validateService = func(message *model.User, field string) (*model.ValidationResult, error) {
+		text := fmt.Sprintf(service.ERROR_UNKNOWN_FIELD, field)
+		return nil, apperror.Validation(text, []model.FieldError{{Field: field, Code: service.CODE_UNKNOWN_FIELD, Message: text}})
+	}
+	r := chi.NewRouter()
+	req, err := http.NewRequest(http.MethodPost, "/users/validate/nick%22name", bytes.NewBufferString(`{"first_name": "John"}`))
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+
+	// When
+	r.Post("/users/validate/{field}", userController.ValidateUserField)
+	r.ServeHTTP(rr, req)
+
+	// Then the field echoed in the message is escaped
+	assert.True(t, json.Valid(rr.Body.Bytes()), rr.Body.String())
+	apiErr, err := utils.ApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, `unknown user field 'nick"name'`, apiErr.Message())
+}
+
+func TestImportUsers(t *testing.T) {
+	report := &model.ImportReport{Rows: 2, Imported: 1, Rejected: 1, Errors: []model.ImportError{
+		{Row: 3, Field: "email", Code: "email_format", Message: "email failed on the 'email' tag"},
+	}}
+
+	tests := []struct {
+		name            string
+		url             string
+		contentType     string
+		accept          string
+		noImport        bool
+		wantRows        interface{}
+		wantDryRun      bool
+		wantStatus      int
+		wantBody        string
+		wantDisposition string
+	}{
+		{
+			name:        "CSV",
+			url:         "/users/import?column=Given%20name:first_name&column=Department:-",
+			contentType: "text/csv",
+			wantRows:    &codec.CSVReader{},
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"dry_run":false,"rows":2,"imported":1,"rejected":1,"errors":[{"row":3,"field":"email","code":"email_format","message":"User email must be properly formatted"}]}`,
+		},
+		{
+			name:            "NDJSON dry run downloaded as CSV",
+			url:             "/users/import?dry_run=true",
+			contentType:     "application/x-ndjson",
+			accept:          "text/csv",
+			wantRows:        &codec.NDJSONReader{},
+			wantDryRun:      true,
+			wantStatus:      http.StatusOK,
+			wantBody:        "row,field,code,message\n3,email,email_format,User email must be properly formatted\n",
+			wantDisposition: `attachment; filename="users-import-report.csv"`,
+		},
+		{
+			name:        "Invalid dry run",
+			url:         "/users/import?dry_run=maybe",
+			contentType: "text/csv",
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"message":"dry_run should be true or false","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:        "Invalid column",
+			url:         "/users/import?column=first_name",
+			contentType: "text/csv",
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"message":"column should be written as <header>:<field>","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:        "JSON",
+			url:         "/users/import",
+			contentType: "application/json",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantBody:    `{"message":"The request body format is not supported.","code":415}`,
+		},
+		{
+			name:        "Disabled",
+			url:         "/users/import",
+			contentType: "text/csv",
+			noImport:    true,
+			wantStatus:  http.StatusNotFound,
+			wantBody:    `{"message":"Unable to find requested record.","code":404}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			importService := new(mocksService.IUserImportService)
+			if tt.wantRows != nil {
+				importService.On("ImportUsers", mock.Anything, mock.AnythingOfType(fmt.Sprintf("%T", tt.wantRows)), tt.wantDryRun).Return(report, nil)
+			}
+			userController := UserController{UserService: &serviceMock{}, ImportService: importService}
+			if tt.noImport {
+				userController.ImportService = nil
+			}
+			r := chi.NewRouter()
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString("first_name\nJohn\n"))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.Post("/users/import", userController.ImportUsers)
+			r.ServeHTTP(rr, req)
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			if strings.HasPrefix(tt.wantBody, "{") {
+				assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			} else {
+				assert.Equal(t, tt.wantBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.wantDisposition, rr.Header().Get("Content-Disposition"))
+			importService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportUsers_Failed(t *testing.T) {
+	tests := []struct {
+		name       string
+		report     *model.ImportReport
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Nothing imported",
+			report:     &model.ImportReport{Rows: 2, Errors: []model.ImportError{}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"message":"database unavailable","status":503,"error":"service_unavailable"}`,
+		},
+		{
+			name:       "Later batch failed",
+			report:     &model.ImportReport{Rows: 4, Imported: 2, Errors: []model.ImportError{}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"dry_run":false,"rows":4,"imported":2,"rejected":0,"errors":[],"error":"database unavailable"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			importService := new(mocksService.IUserImportService)
+			importService.On("ImportUsers", mock.Anything, mock.Anything, false).Return(tt.report, apperror.Unavailable("database unavailable", nil))
+			userController := UserController{UserService: &serviceMock{}, ImportService: importService}
+			req := httptest.NewRequest(http.MethodPost, "/users/import", bytes.NewBufferString("first_name\nJohn\n"))
+			req.Header.Set("Content-Type", "text/csv")
+			rr := httptest.NewRecorder()
+
+			// When
+			userController.ImportUsers(rr, req)
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+		})
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	tests := []struct {
+		name            string
+		url             string
+		accept          string
+		noExport        bool
+		exportErr       error
+		wantFilter      model.UserFilter
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantDisposition string
+	}{
+		{
+			name:            "CSV",
+			url:             "/users/export?format=csv&first_name=ad&min_age=18&max_age=65",
+			wantFilter:      model.UserFilter{FirstName: "ad", MinAge: 18, MaxAge: 65},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "id,first_name,last_name,email,age\n1,Ada,Lovelace,ada@example.com,36\n",
+			wantDisposition: `attachment; filename="users.csv"`,
+		},
+		{
+			name:            "NDJSON accepted",
+			url:             "/users/export",
+			accept:          "application/x-ndjson",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x-ndjson; charset=utf-8",
+			wantBody:        `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n",
+			wantDisposition: `attachment; filename="users.ndjson"`,
+		},
+		{
+			name:            "JSON by default",
+			url:             "/users/export",
+			accept:          "application/xml",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `[{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}]`,
+			wantDisposition: `attachment; filename="users.json"`,
+		},
+		{
+			name:            "Failed before the first row",
+			url:             "/users/export?format=csv",
+			exportErr:       apperror.Unavailable("database unavailable", nil),
+			wantStatus:      http.StatusServiceUnavailable,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"message":"database unavailable","status":503,"error":"service_unavailable"}`,
+		},
+		{
+			name:            "Unknown format",
+			url:             "/users/export?format=xlsx",
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"message":"format should be csv, ndjson or json","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:            "Invalid age",
+			url:             "/users/export?min_age=adult",
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"message":"min_age should be a number","status":400,"error":"bad_request"}`,
+		},
+		{
+			name:            "Disabled",
+			url:             "/users/export",
+			noExport:        true,
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        `{"message":"Unable to find requested record.","code":404}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			exportService := new(mocksService.IUserExportService)
+			if tt.wantStatus == http.StatusOK || tt.exportErr != nil {
+				written := 1
+				if tt.exportErr != nil {
+					written = 0
+				}
+				exportService.On("ExportUsers", mock.Anything, tt.wantFilter, mock.Anything).Run(func(args mock.Arguments) {
+					if tt.exportErr != nil {
+						return
+					}
+					rows := args.Get(2).(codec.IRowWriter)
+					assert.Nil(t, rows.Write(&model.User{Id: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}))
+					assert.Nil(t, rows.Close())
+				}).Return(written, tt.exportErr)
+			}
+			userController := UserController{UserService: &serviceMock{}, ExportService: exportService}
+			if tt.noExport {
+				userController.ExportService = nil
+			}
+			r := chi.NewRouter()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.Get("/users/export", userController.ExportUsers)
+			r.ServeHTTP(rr, req)
+
+			// Then
+			assert.EqualValues(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.wantContentType, rr.Header().Get("Content-Type"))
+			if strings.HasPrefix(tt.wantBody, "{") || strings.HasPrefix(tt.wantBody, "[") {
+				assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			} else {
+				assert.Equal(t, tt.wantBody, rr.Body.String())
+			}
+			assert.Equal(t, tt.wantDisposition, rr.Header().Get("Content-Disposition"))
+			exportService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+type IWebhookController interface {
+	ListWebhooks(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	ListDeliveries(w http.ResponseWriter, r *http.Request)
+	Redeliver(w http.ResponseWriter, r *http.Request)
+}
+
+type WebhookController struct {
+	WebhookService service.IWebhookService
+	// Policy decides which callers may manage webhooks
+	Policy authz.IPolicy
+}
+
+// ListWebhooks Get a list of all webhooks
+//
+// This will return every webhook, without its secret.
+//
+// swagger:route GET /webhooks/ listWebhooks
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+// - application/x-ndjson
+//
+// Responses:
+//
+//	200: []Webhook
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (wc *WebhookController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	if err := wc.authorize(r, authz.ACTION_WEBHOOKS_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	webhooks, err := wc.WebhookService.ListWebhooks()
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusOK, webhooks)
+}
+
+// CreateWebhook creates a new webhook
+//
+// This will subscribe a url to user events. The secret signing the deliveries is only returned in this response.
+//
+// swagger:route POST /webhooks createWebhook
+//
+// Consumes:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	201: WebhookCreated
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	406: MessageErr
+//	413: MessageErr
+//	415: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+
+	if err := wc.authorize(r, authz.ACTION_WEBHOOKS_CREATE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var body model.WebhookRequest
+	if err := utils.ParseBody(w, r, &body); err != nil {
+		log.Error.Println(err)
+		utils.ResponseMessageErr(w, err)
+		return
+	}
+
+	created, err := wc.WebhookService.CreateWebhook(&body)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusCreated, created)
+}
+
+// DeleteWebhook deletes a webhook
+//
+// This will stop the deliveries to a webhook, and delete its delivery log.
+//
+// swagger:route DELETE /webhooks/{webhook_id} deleteWebhook
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	200: StatusResponse
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := idParam(w, r, "webhook_id")
+	if !ok {
+		return
+	}
+
+	if err := wc.authorize(r, authz.ACTION_WEBHOOKS_DELETE); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := wc.WebhookService.DeleteWebhook(id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusOK, model.StatusResponse{Status: "deleted"})
+}
+
+// ListDeliveries Get the delivery log of a webhook
+//
+// This will return the deliveries of a webhook, the latest first, with the outcome of their last attempt.
+//
+// swagger:route GET /webhooks/{webhook_id}/deliveries listWebhookDeliveries
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+// - application/x-ndjson
+//
+// Responses:
+//
+//	200: []WebhookDelivery
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	406: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (wc *WebhookController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	id, ok := idParam(w, r, "webhook_id")
+	if !ok {
+		return
+	}
+
+	if err := wc.authorize(r, authz.ACTION_WEBHOOKS_LIST); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	deliveries, err := wc.WebhookService.ListDeliveries(id)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusOK, deliveries)
+}
+
+// Redeliver sends a delivery again
+//
+// This will queue a delivery again, whether it was delivered or is dead, with all its attempts.
+//
+// swagger:route POST /webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver redeliverWebhook
+//
+// Produces:
+// - application/json
+// - application/xml
+// - application/yaml
+//
+// Responses:
+//
+//	202: WebhookDelivery
+//	400: MessageErr
+//	401: MessageErr
+//	403: MessageErr
+//	404: MessageErr
+//	429: MessageErr
+//	500: MessageErr
+func (wc *WebhookController) Redeliver(w http.ResponseWriter, r *http.Request) {
+
+	webhookId, ok := idParam(w, r, "webhook_id")
+	if !ok {
+		return
+	}
+	deliveryId, ok := idParam(w, r, "delivery_id")
+	if !ok {
+		return
+	}
+
+	if err := wc.authorize(r, authz.ACTION_WEBHOOKS_REDELIVER); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	delivery, err := wc.WebhookService.Redeliver(webhookId, deliveryId)
+
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	utils.Respond(w, r, http.StatusAccepted, delivery)
+}
+
+// authorize asks the policy whether the caller may perform the action on webhooks
+func (wc *WebhookController) authorize(r *http.Request, action string) error {
+	return authz.Authorize(r.Context(), wc.Policy, action, 0)
+}
+
+// idParam reads a numeric id from the path, answering 400 when it is not one
+func idParam(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil {
+		utils.ResponseMessageErr(w, utils.BadRequestError(name+" should be a number"))
+		return 0, false
+	}
+	return id, true
+}
+
+// swagger:parameters deleteWebhook listWebhookDeliveries redeliverWebhook
+type WebhookPathParam struct {
+	// in: path
+	WebhookId string `json:"webhook_id"`
+}
+
+// swagger:parameters redeliverWebhook
+type DeliveryPathParam struct {
+	// in: path
+	DeliveryId string `json:"delivery_id"`
+}
+
+// swagger:parameters createWebhook
+type WebhookBodyParam struct {
+	// in:body
+	Webhook model.WebhookRequest
+}
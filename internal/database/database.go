@@ -4,16 +4,28 @@ import (
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log"
+	"os"
+	"time"
 )
 
+// sqlLogger logs slow and failed queries like the default gorm logger, without their parameters which may hold personal data
+var sqlLogger = logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+	SlowThreshold:        200 * time.Millisecond,
+	LogLevel:             logger.Warn,
+	Colorful:             true,
+	ParameterizedQueries: true,
+})
+
 func CreateNewGormDB() *gorm.DB {
 
-	db, err := gorm.Open(sqlite.Open("file:userdb?mode=memory&cache=shared"), &gorm.Config{}, &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open("file:userdb?mode=memory&cache=shared"), &gorm.Config{TranslateError: true, Logger: sqlLogger})
 	if err != nil {
 		panic(err)
 	}
 
-	err = db.AutoMigrate(&model.User{})
+	err = db.AutoMigrate(&model.User{}, &model.ApiKey{}, &model.Event{}, &model.Webhook{}, &model.WebhookDelivery{}, &model.Job{})
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,145 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PREFIX marks encrypted values, written as "enc:<key id>:<nonce and ciphertext in base64>"
+const PREFIX = "enc:"
+
+// blindIndexLength is the number of hex characters kept from an index hash, 128 bits
+const blindIndexLength = 32
+
+var ErrUnknownKey = errors.New("fieldcrypt: unknown key")
+
+// ICipher encrypts the values of personal data columns
+type ICipher interface {
+	Encrypt(field string, plaintext string) (string, error)
+	// Decrypt returns values which are not encrypted as they are, so that columns can be encrypted progressively
+	Decrypt(field string, value string) (string, error)
+	// BlindIndex returns a keyed hash of a value, to look up encrypted values by equality
+	BlindIndex(field string, value string) string
+	// NeedsRotation reports whether a value is not encrypted with the primary key
+	NeedsRotation(value string) bool
+}
+
+// Keyring encrypts values with AES-256-GCM, binding each ciphertext to its field
+type Keyring struct {
+	// Primary is the id of the key encrypting new values, the other keys only decrypt older values
+	Primary string
+	Keys    map[string][]byte
+	// IndexKey computes the blind indexes, it is not rotated as every index would have to be recomputed at once
+	IndexKey []byte
+}
+
+type keyringFile struct {
+	Primary  string            `json:"primary"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// LoadKeyring reads a JSON keyring file holding base64 keys:
+// {"primary": "2024-06", "keys": {"2024-01": "...", "2024-06": "..."}, "index_key": "..."}
+func LoadKeyring(path string) (*Keyring, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("fieldcrypt: reading %v: %w", path, err)
+	}
+
+	keyring := &Keyring{Primary: file.Primary, Keys: map[string][]byte{}}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("fieldcrypt: key id %q should be non empty and without ':'", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("fieldcrypt: key %q should be 32 bytes in base64", id)
+		}
+		keyring.Keys[id] = key
+	}
+	if _, ok := keyring.Keys[keyring.Primary]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: primary key %q is not in the keyring", keyring.Primary)
+	}
+	keyring.IndexKey, err = base64.StdEncoding.DecodeString(file.IndexKey)
+	if err != nil || len(keyring.IndexKey) < 32 {
+		return nil, errors.New("fieldcrypt: index key should be at least 32 bytes in base64")
+	}
+	return keyring, nil
+}
+
+func (k *Keyring) Encrypt(field string, plaintext string) (string, error) {
+
+	aead, err := k.aead(k.Primary)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return PREFIX + k.Primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) Decrypt(field string, value string) (string, error) {
+
+	encrypted, found := strings.CutPrefix(value, PREFIX)
+	if !found {
+		return value, nil
+	}
+	id, encoded, found := strings.Cut(encrypted, ":")
+	if !found {
+		return "", errors.New("fieldcrypt: malformed encrypted value")
+	}
+	aead, err := k.aead(id)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("fieldcrypt: malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: decrypting %v: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+func (k *Keyring) BlindIndex(field string, value string) string {
+	h := hmac.New(sha256.New, k.IndexKey)
+	h.Write([]byte(field + "\x00" + value))
+	return hex.EncodeToString(h.Sum(nil))[:blindIndexLength]
+}
+
+func (k *Keyring) NeedsRotation(value string) bool {
+	return !strings.HasPrefix(value, PREFIX+k.Primary+":")
+}
+
+func (k *Keyring) aead(id string) (cipher.AEAD, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,109 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeyring(primary string) *Keyring {
+	return &Keyring{
+		Primary: primary,
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+			"k2": bytes.Repeat([]byte{2}, 32),
+		},
+		IndexKey: bytes.Repeat([]byte{3}, 32),
+	}
+}
+
+func TestKeyring_Encrypt(t *testing.T) {
+	keyring := testKeyring("k1")
+
+	encrypted, err := keyring.Encrypt("email", "john.doe@yahoo.com")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:k1:"))
+	assert.NotContains(t, encrypted, "john")
+
+	again, _ := keyring.Encrypt("email", "john.doe@yahoo.com")
+	assert.NotEqual(t, encrypted, again, "every encryption uses a new nonce")
+
+	decrypted, err := keyring.Decrypt("email", encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, "john.doe@yahoo.com", decrypted)
+
+	_, err = keyring.Decrypt("first_name", encrypted)
+	assert.NotNil(t, err, "ciphertexts cannot be moved to another field")
+
+	plaintext, err := keyring.Decrypt("email", "john.doe@yahoo.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "john.doe@yahoo.com", plaintext, "values in clear text are returned as they are")
+
+	_, err = keyring.Decrypt("email", "enc:k1:not-base64!")
+	assert.NotNil(t, err)
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	old := testKeyring("k1")
+	rotated := testKeyring("k2")
+	encrypted, _ := old.Encrypt("last_name", "Doe")
+
+	assert.False(t, old.NeedsRotation(encrypted))
+	assert.True(t, rotated.NeedsRotation(encrypted))
+	assert.True(t, rotated.NeedsRotation("Doe"))
+
+	decrypted, err := rotated.Decrypt("last_name", encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, "Doe", decrypted)
+
+	delete(rotated.Keys, "k1")
+	_, err = rotated.Decrypt("last_name", encrypted)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeyring_BlindIndex(t *testing.T) {
+	keyring := testKeyring("k1")
+
+	index := keyring.BlindIndex("first_name", "John")
+	assert.Len(t, index, 32)
+	assert.Equal(t, index, testKeyring("k2").BlindIndex("first_name", "John"), "indexes do not depend on the primary key")
+	assert.NotEqual(t, index, keyring.BlindIndex("last_name", "John"))
+	assert.NotEqual(t, index, keyring.BlindIndex("first_name", "Johnny"))
+}
+
+func TestLoadKeyring(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	tests := []struct {
+		name    string
+		content string
+		err     bool
+	}{
+		{name: "Valid", content: `{"primary":"k1","keys":{"k1":"` + key + `"},"index_key":"` + key + `"}`},
+		{name: "Unknown primary", content: `{"primary":"k2","keys":{"k1":"` + key + `"},"index_key":"` + key + `"}`, err: true},
+		{name: "Short key", content: `{"primary":"k1","keys":{"k1":"c2hvcnQ="},"index_key":"` + key + `"}`, err: true},
+		{name: "Key id with colon", content: `{"primary":"k:1","keys":{"k:1":"` + key + `"},"index_key":"` + key + `"}`, err: true},
+		{name: "Missing index key", content: `{"primary":"k1","keys":{"k1":"` + key + `"}}`, err: true},
+		{name: "Not json", content: `k1=` + key, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			keyring, err := LoadKeyring(path)
+
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "k1", keyring.Primary)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+)
+
+// Codes of the GraphQL errors, sent in their extensions
+const (
+	CODE_BAD_USER_INPUT     = "BAD_USER_INPUT"
+	CODE_UNAUTHENTICATED    = "UNAUTHENTICATED"
+	CODE_FORBIDDEN          = "FORBIDDEN"
+	CODE_NOT_FOUND          = "NOT_FOUND"
+	CODE_CONFLICT           = "CONFLICT"
+	CODE_UNAVAILABLE        = "SERVICE_UNAVAILABLE"
+	CODE_INTERNAL           = "INTERNAL_SERVER_ERROR"
+	CODE_QUERY_TOO_DEEP     = "QUERY_TOO_DEEP"
+	CODE_QUERY_TOO_COMPLEX  = "QUERY_TOO_COMPLEX"
+	CODE_TOO_MANY_MUTATIONS = "TOO_MANY_MUTATIONS"
+	CODE_RATE_LIMITED       = "RATE_LIMITED"
+)
+
+// codesByStatus maps the HTTP statuses of the MessageErr sent by the REST API to GraphQL error codes
+var codesByStatus = map[int]string{
+	http.StatusBadRequest:          CODE_BAD_USER_INPUT,
+	http.StatusUnauthorized:        CODE_UNAUTHENTICATED,
+	http.StatusForbidden:           CODE_FORBIDDEN,
+	http.StatusNotFound:            CODE_NOT_FOUND,
+	http.StatusConflict:            CODE_CONFLICT,
+	http.StatusTooManyRequests:     CODE_RATE_LIMITED,
+	http.StatusServiceUnavailable:  CODE_UNAVAILABLE,
+	http.StatusInternalServerError: CODE_INTERNAL,
+}
+
+// Error is a GraphQL error whose extensions carry its code, the HTTP status the REST API would send for it, and the
+// field errors of a validation failure
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+	Fields  []model.FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions are added to the error in the response
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	if e.Status != 0 {
+		extensions["status"] = e.Status
+	}
+	if len(e.Fields) > 0 {
+		extensions["fields"] = e.Fields
+	}
+	return extensions
+}
+
+// errorFromService logs an error returned by the service layer and translates it into a GraphQL error, with the
+// message the REST API sends and the validation errors localized for the request
+func errorFromService(ctx context.Context, err error) error {
+
+	log.Error.Println(err)
+	msgErr := controller.MessageErrFromError(ctx, err)
+	code, ok := codesByStatus[msgErr.Status()]
+	if !ok {
+		code = CODE_INTERNAL
+	}
+	gqlErr := &Error{Code: code, Message: msgErr.Message(), Status: msgErr.Status()}
+
+	var appErr *apperror.Error
+	if errors.Is(err, apperror.ErrValidation) && errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+		gqlErr.Fields = i18n.FromContext(ctx).FieldErrors(appErr.Fields)
+	}
+
+	return gqlErr
+}
@@ -0,0 +1,297 @@
+package gql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"io"
+	"net/http"
+)
+
+// Default limits of the requests sent in one HTTP request when none is configured
+const (
+	DefaultMaxBatchSize = 10
+	DefaultMaxMutations = 10
+)
+
+const (
+	ERROR_BODY_INVALID       = "body should be a GraphQL request, or an array of them"
+	ERROR_BATCH_TOO_LARGE    = "batch of %v requests exceeds the maximum of %v"
+	ERROR_TOO_MANY_MUTATIONS = "%v mutations exceed the maximum of %v per request"
+	ERROR_QUERY_REQUIRED     = "query is required"
+	ERROR_MUTATION_WITH_GET  = "mutations should be sent with POST"
+)
+
+// Request is a GraphQL operation sent over HTTP
+// swagger:model GraphqlRequest
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response documents the result of a request, as written by graphql.Result
+// swagger:model GraphqlResponse
+type Response struct {
+	// Data has the fields selected by the operation, null when it could not be executed
+	Data map[string]interface{} `json:"data"`
+	// Errors carry their code in extensions.code, and the field errors of a validation failure in extensions.fields
+	Errors []map[string]interface{} `json:"errors,omitempty"`
+}
+
+// swagger:parameters graphql
+type graphqlRequestParameter struct {
+	// A GraphQL request, or an array of them
+	// in: body
+	Request Request
+}
+
+// mutationRoutes are the REST routes the mutations mirror, whose rate limits and buckets they share
+var mutationRoutes = map[string]struct{ method, path string }{
+	"createUser": {http.MethodPost, "/users"},
+	"updateUser": {http.MethodPut, "/users/{user_id}"},
+	"deleteUser": {http.MethodDelete, "/users/{user_id}"},
+}
+
+// Handler serves GraphQL over HTTP: a request in the query string of a GET, or in the JSON body of a POST, where an
+// array of requests is answered by an array of results. Mutations are only executed from POST.
+type Handler struct {
+	Schema graphql.Schema
+	Limits Limits
+	// MaxBatchSize is the largest number of requests in a batch, DefaultMaxBatchSize when zero
+	MaxBatchSize int
+	// MaxMutations is the largest number of mutation fields sent in one HTTP request, over every request of a batch and
+	// every alias of a field. DefaultMaxMutations is used when zero.
+	MaxMutations int
+	// Limiter charges each mutation to the rate limit of the REST route it mirrors, when set, so that mutations sent
+	// together are limited like as many REST requests
+	Limiter *ratelimit.Limiter
+	// MaxBodySize is the largest body accepted, codec.DefaultMaxBodySize when zero
+	MaxBodySize int64
+}
+
+// ServeHTTP Run GraphQL queries and mutations on users
+//
+// This will execute a GraphQL request, or a batch of them, with the schema of the users.
+//
+// swagger:route POST /graphql graphql
+//
+// Consumes:
+// - application/json
+//
+// Produces:
+// - application/json
+//
+// Responses:
+//
+//	200: GraphqlResponse
+//	400: GraphqlResponse
+//	401: MessageErr
+//	405: GraphqlResponse
+//	413: GraphqlResponse
+//	429: MessageErr
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		request := Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				respondErrors(w, http.StatusBadRequest, ERROR_BODY_INVALID)
+				return
+			}
+		}
+		result, status := h.execute(r, h.prepare(request, true))
+		utils.ResponseJson(w, status, result)
+		return
+	}
+
+	maxBodySize := h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = codec.DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		respondErrors(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("[")) {
+		var request Request
+		if err := json.Unmarshal(body, &request); err != nil {
+			respondErrors(w, http.StatusBadRequest, ERROR_BODY_INVALID)
+			return
+		}
+		operation := h.prepare(request, false)
+		if err := h.checkMutations(operation); err != nil {
+			utils.ResponseJson(w, http.StatusBadRequest, errorResult(err))
+			return
+		}
+		result, status := h.execute(r, operation)
+		utils.ResponseJson(w, status, result)
+		return
+	}
+
+	var requests []Request
+	if err := json.Unmarshal(body, &requests); err != nil || len(requests) == 0 {
+		respondErrors(w, http.StatusBadRequest, ERROR_BODY_INVALID)
+		return
+	}
+	maxBatchSize := h.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	if len(requests) > maxBatchSize {
+		respondErrors(w, http.StatusBadRequest, fmt.Sprintf(ERROR_BATCH_TOO_LARGE, len(requests), maxBatchSize))
+		return
+	}
+
+	// the whole batch is rejected when it has too many mutations, before any of them runs
+	operations := make([]*prepared, len(requests))
+	for i, request := range requests {
+		operations[i] = h.prepare(request, false)
+	}
+	if err := h.checkMutations(operations...); err != nil {
+		utils.ResponseJson(w, http.StatusBadRequest, errorResult(err))
+		return
+	}
+
+	// the requests of a batch run in order, so that a query sees the changes of the mutations before it
+	results := make([]*graphql.Result, len(requests))
+	for i, operation := range operations {
+		results[i], _ = h.execute(r, operation)
+	}
+	utils.ResponseJson(w, http.StatusOK, results)
+}
+
+// prepared is a request parsed, validated and measured, or the result rejecting it before its execution
+type prepared struct {
+	request  Request
+	document *ast.Document
+	// mutations are the root fields of a mutation
+	mutations []string
+	rejected  *graphql.Result
+	status    int
+}
+
+// prepare parses, validates and measures a request, and rejects it with the status of a single request when it cannot
+// be executed
+func (h *Handler) prepare(request Request, readOnly bool) *prepared {
+
+	if request.Query == "" {
+		return rejected(&Error{Code: CODE_BAD_USER_INPUT, Message: ERROR_QUERY_REQUIRED}, http.StatusBadRequest)
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(request.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &prepared{rejected: &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, status: http.StatusBadRequest}
+	}
+	validation := graphql.ValidateDocument(&h.Schema, document, nil)
+	if !validation.IsValid {
+		return &prepared{rejected: &graphql.Result{Errors: validation.Errors}, status: http.StatusBadRequest}
+	}
+
+	p := &prepared{request: request, document: document}
+	// an unknown operation name is reported by Execute
+	if operation := operationOf(document, request.OperationName); operation != nil {
+		if readOnly && operation.Operation != ast.OperationTypeQuery {
+			return rejected(&Error{Code: CODE_BAD_USER_INPUT, Message: ERROR_MUTATION_WITH_GET}, http.StatusMethodNotAllowed)
+		}
+		if err := h.Limits.Check(document, operation, request.Variables); err != nil {
+			return rejected(err, http.StatusBadRequest)
+		}
+		p.mutations = mutationFields(document, operation)
+	}
+	return p
+}
+
+// checkMutations returns the error rejecting requests sent together when they have too many mutations
+func (h *Handler) checkMutations(operations ...*prepared) error {
+
+	maxMutations := h.MaxMutations
+	if maxMutations <= 0 {
+		maxMutations = DefaultMaxMutations
+	}
+	mutations := 0
+	for _, operation := range operations {
+		mutations += len(operation.mutations)
+	}
+	if mutations > maxMutations {
+		return &Error{Code: CODE_TOO_MANY_MUTATIONS, Message: fmt.Sprintf(ERROR_TOO_MANY_MUTATIONS, mutations, maxMutations)}
+	}
+	return nil
+}
+
+// execute charges the mutations of a prepared request to the rate limits, then runs it and returns its result with
+// the status of a single request: OK unless the request could not be executed
+func (h *Handler) execute(r *http.Request, operation *prepared) (*graphql.Result, int) {
+
+	if operation.rejected != nil {
+		return operation.rejected, operation.status
+	}
+
+	if h.Limiter != nil {
+		for _, field := range operation.mutations {
+			route := mutationRoutes[field]
+			if _, result := h.Limiter.Take(r.Context(), route.method, route.path, ratelimit.ClientKey(r)); !result.Allowed {
+				message := i18n.FromContext(r.Context()).Status(http.StatusTooManyRequests)
+				return errorResult(&Error{Code: CODE_RATE_LIMITED, Message: message, Status: http.StatusTooManyRequests}), http.StatusTooManyRequests
+			}
+		}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.Schema,
+		AST:           operation.document,
+		OperationName: operation.request.OperationName,
+		Args:          operation.request.Variables,
+		Context:       r.Context(),
+	}), http.StatusOK
+}
+
+// operationOf returns the operation of a document to execute, nil when there is no such operation
+func operationOf(document *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" && found != nil {
+			// several operations need a name
+			return nil
+		}
+		if name == "" || (operation.Name != nil && operation.Name.Value == name) {
+			found = operation
+		}
+	}
+	return found
+}
+
+// rejected returns a request rejected before its execution with an error
+func rejected(err error, status int) *prepared {
+	return &prepared{rejected: errorResult(err), status: status}
+}
+
+// errorResult returns the result of a request rejected before its execution, with the extensions of the error
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(graphql.NewLocatedError(err, nil))}}
+}
+
+// respondErrors writes the response to a body that is not a GraphQL request
+func respondErrors(w http.ResponseWriter, status int, message string) {
+	utils.ResponseJson(w, status, errorResult(&Error{Code: CODE_BAD_USER_INPUT, Message: message}))
+}
@@ -0,0 +1,333 @@
+package gql
+
+import (
+	"encoding/json"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	mocksAuthz "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/authz"
+	mocks "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var users = []model.User{
+	{Id: 3, FirstName: "Ben", LastName: "Jefferson", Email: "t.jefferson@yahoo.com", Age: 39},
+	{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+	{Id: 2, FirstName: "Jane", LastName: "Doe", Email: "jane.doe@gmail.com", Age: 25},
+}
+
+// serve builds the schema on the service and sends a request to its handler, returning the status and the JSON body
+func serve(t *testing.T, userSchema *UserSchema, handler *Handler, request *http.Request) (int, string) {
+	schema, err := userSchema.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Schema = schema
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request.WithContext(i18n.NewContext(request.Context(), i18n.Default().Translator("en"))))
+	return recorder.Code, recorder.Body.String()
+}
+
+func post(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		mock     func(userService *mocks.IUserService)
+		request  *http.Request
+		status   int
+		response string
+	}{
+		{
+			name:     "User with the selected fields",
+			mock:     func(userService *mocks.IUserService) { userService.On("GetUser", int64(1)).Return(&users[1], nil) },
+			request:  post(`{"query":"{ user(id: 1) { first_name email } }"}`),
+			status:   http.StatusOK,
+			response: `{"data":{"user":{"first_name":"John","email":"john.doe@gmail.com"}}}`,
+		},
+		{
+			name: "Missing user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(9)).Return(nil, apperror.NotFound("user not found", nil))
+			},
+			request:  post(`{"query":"query($id: Int!) { user(id: $id) { id } }","variables":{"id":9}}`),
+			status:   http.StatusOK,
+			response: `{"data":{"user":null}}`,
+		},
+		{
+			name:     "Users filtered and paged",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("ListUsersAfter", model.UserFilter{LastName: "doe"}, int64(0), 0).Return([]model.User{users[1], users[2]}, nil)
+			},
+			request:  post(`{"query":"{ users(filter: {last_name: \"doe\"}, page: {limit: 1}) { users { id } total_count has_next_page } }"}`),
+			status:   http.StatusOK,
+			response: `{"data":{"users":{"users":[{"id":1}],"total_count":2,"has_next_page":true}}}`,
+		},
+		{
+			name:     "Users by age",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("ListUsersAfter", model.UserFilter{MinAge: 30}, int64(0), 0).Return([]model.User{users[1], users[0]}, nil)
+			},
+			request:  httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape("{ users(filter: {min_age: 30}) { users { id } } }"), nil),
+			status:   http.StatusOK,
+			response: `{"data":{"users":{"users":[{"id":1},{"id":3}]}}}`,
+		},
+		{
+			name: "Create user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", &model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}).Return(&users[1], nil)
+			},
+			request:  post(`{"query":"mutation { createUser(user: {first_name: \"John\", last_name: \"Doe\", email: \"john.doe@gmail.com\", age: 30}) { id } }"}`),
+			status:   http.StatusOK,
+			response: `{"data":{"createUser":{"id":1}}}`,
+		},
+		{
+			name: "Create invalid user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", mock.Anything).Return(nil,
+					apperror.Validation("invalid user", []model.FieldError{{Field: "first_name", Code: "required", Message: "invalid_request"}}))
+			},
+			request: post(`{"query":"mutation { createUser(user: {last_name: \"Doe\"}) { id } }"}`),
+			status:  http.StatusOK,
+			response: `{"data":{"createUser":null},"errors":[{"message":"User first name is required","locations":[{"line":1,"column":12}],"path":["createUser"],
+				"extensions":{"code":"BAD_USER_INPUT","status":400,"fields":[{"field":"first_name","code":"required","message":"User first name is required"}]}}]}`,
+		},
+		{
+			name: "Update user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("UpdateUser", &model.User{Id: 2, FirstName: "Jane", LastName: "Roe", Email: "jane.doe@gmail.com", Age: 26}).Return(&users[2], nil)
+			},
+			request:  post(`{"query":"mutation { updateUser(id: 2, user: {first_name: \"Jane\", last_name: \"Roe\", email: \"jane.doe@gmail.com\", age: 26}) { id } }"}`),
+			status:   http.StatusOK,
+			response: `{"data":{"updateUser":{"id":2}}}`,
+		},
+		{
+			name:     "Delete user",
+			mock:     func(userService *mocks.IUserService) { userService.On("DeleteUser", int64(2)).Return(nil) },
+			request:  post(`{"query":"mutation { deleteUser(id: 2) }"}`),
+			status:   http.StatusOK,
+			response: `{"data":{"deleteUser":true}}`,
+		},
+		{
+			name:     "Mutation with GET",
+			mock:     func(userService *mocks.IUserService) {},
+			request:  httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape("mutation { deleteUser(id: 2) }"), nil),
+			status:   http.StatusMethodNotAllowed,
+			response: `{"data":null,"errors":[{"message":"mutations should be sent with POST","locations":[],"extensions":{"code":"BAD_USER_INPUT"}}]}`,
+		},
+		{
+			name: "Batch",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(1)).Return(&users[1], nil)
+				userService.On("GetUser", int64(2)).Return(&users[2], nil)
+			},
+			request:  post(`[{"query":"{ user(id: 1) { first_name } }"},{"query":"{ user(id: 2) { first_name } }"}]`),
+			status:   http.StatusOK,
+			response: `[{"data":{"user":{"first_name":"John"}}},{"data":{"user":{"first_name":"Jane"}}}]`,
+		},
+		{
+			name:     "Batch too large",
+			mock:     func(userService *mocks.IUserService) {},
+			request:  post(`[{"query":"{ user(id: 1) { id } }"},{"query":"{ user(id: 1) { id } }"},{"query":"{ user(id: 1) { id } }"}]`),
+			status:   http.StatusBadRequest,
+			response: `{"data":null,"errors":[{"message":"batch of 3 requests exceeds the maximum of 2","locations":[],"extensions":{"code":"BAD_USER_INPUT"}}]}`,
+		},
+		{
+			name:     "Unknown field",
+			mock:     func(userService *mocks.IUserService) {},
+			request:  post(`{"query":"{ user(id: 1) { password } }"}`),
+			status:   http.StatusBadRequest,
+			response: `{"data":null,"errors":[{"message":"Cannot query field \"password\" on type \"User\".","locations":[{"line":1,"column":17}]}]}`,
+		},
+		{
+			name:     "Not a request",
+			mock:     func(userService *mocks.IUserService) {},
+			request:  post(`"users"`),
+			status:   http.StatusBadRequest,
+			response: `{"data":null,"errors":[{"message":"body should be a GraphQL request, or an array of them","locations":[],"extensions":{"code":"BAD_USER_INPUT"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := new(mocks.IUserService)
+			tt.mock(userService)
+
+			status, body := serve(t, &UserSchema{UserService: userService}, &Handler{MaxBatchSize: 2}, tt.request)
+
+			assert.Equal(t, tt.status, status)
+			assert.JSONEq(t, tt.response, body)
+			userService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_MaxMutations(t *testing.T) {
+	create := `createUser(user: {first_name: \"John\"}) { id }`
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		message string
+	}{
+		{
+			name:    "Aliased mutations",
+			request: post(`{"query":"mutation { a: ` + create + ` b: ` + create + ` c: ` + create + ` }"}`),
+			status:  http.StatusBadRequest,
+			message: "3 mutations exceed the maximum of 2 per request",
+		},
+		{
+			name:    "Mutations in fragments",
+			request: post(`{"query":"mutation { ...create c: ` + create + ` } fragment create on Mutation { a: ` + create + ` ... on Mutation { b: ` + create + ` } }"}`),
+			status:  http.StatusBadRequest,
+			message: "3 mutations exceed the maximum of 2 per request",
+		},
+		{
+			name:    "Mutations of a batch",
+			request: post(`[{"query":"mutation { a: ` + create + ` b: ` + create + ` }"},{"query":"mutation { ` + create + ` }"}]`),
+			status:  http.StatusBadRequest,
+			message: "3 mutations exceed the maximum of 2 per request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			userService := new(mocks.IUserService)
+
+			// When
+			status, body := serve(t, &UserSchema{UserService: userService}, &Handler{MaxMutations: 2}, tt.request)
+
+			// Then
+			assert.Equal(t, tt.status, status)
+			assert.JSONEq(t, `{"data":null,"errors":[{"message":"`+tt.message+`","locations":[],"extensions":{"code":"TOO_MANY_MUTATIONS"}}]}`, body)
+			userService.AssertNotCalled(t, "SaveUser", mock.Anything)
+		})
+	}
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	// Given
+	userService := new(mocks.IUserService)
+	userService.On("SaveUser", mock.Anything).Return(&users[1], nil)
+	now := time.Now()
+	limiter := &ratelimit.Limiter{
+		Store: &ratelimit.MemoryStore{Now: func() time.Time { return now }},
+		Rules: []ratelimit.Rule{{Method: http.MethodPost, Path: "/users", Limit: ratelimit.Limit{Requests: 2, Period: time.Minute}}},
+	}
+	handler := &Handler{Limiter: limiter}
+	create := `createUser(user: {first_name: \"John\"}) { id }`
+
+	// When
+	allowed, _ := serve(t, &UserSchema{UserService: userService}, handler, post(`{"query":"mutation { a: `+create+` b: `+create+` }"}`))
+	limited, body := serve(t, &UserSchema{UserService: userService}, handler, post(`{"query":"mutation { `+create+` }"}`))
+
+	// Then
+	assert.Equal(t, http.StatusOK, allowed)
+	assert.Equal(t, http.StatusTooManyRequests, limited)
+	assert.JSONEq(t, `{"data":null,"errors":[{"message":"Request rate too high, requests from this this user are throttled.","locations":[],
+		"extensions":{"code":"RATE_LIMITED","status":429}}]}`, body)
+	userService.AssertNumberOfCalls(t, "SaveUser", 2)
+
+	// the REST route shares the bucket of the mutations
+	rr := httptest.NewRecorder()
+	limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestHandler_Authorization(t *testing.T) {
+	userService := new(mocks.IUserService)
+	userService.On("GetUser", int64(1)).Return(&users[1], nil)
+	policy := new(mocksAuthz.IPolicy)
+	policy.On("Authorize", mock.Anything, authz.ACTION_READ, mock.Anything).Return(nil)
+	policy.On("Authorize", mock.Anything, mock.Anything, mock.Anything).Return(apperror.Forbidden("forbidden"))
+
+	_, body := serve(t, &UserSchema{UserService: userService, Policy: policy}, &Handler{},
+		post(`{"query":"{ user(id: 1) { id } users { total_count } }"}`))
+
+	var result struct {
+		Data   map[string]interface{}
+		Errors []struct {
+			Path       []string
+			Extensions map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatal(err)
+	}
+	// the fields allowed are still resolved
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, result.Data["user"])
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, []string{"users"}, result.Errors[0].Path)
+		assert.Equal(t, CODE_FORBIDDEN, result.Errors[0].Extensions["code"])
+	}
+}
+
+func TestLimits_Check(t *testing.T) {
+	schema, err := (&UserSchema{UserService: new(mocks.IUserService)}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := Limits{MaxDepth: 3, MaxComplexity: 100}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		code      string
+	}{
+		{name: "Within the limits", query: "{ user(id: 1) { id first_name } }"},
+		{name: "Page within the limits", query: "{ users(page: {limit: 30}) { users { id first_name } } }"},
+		{name: "Deepest allowed", query: "{ users(page: {limit: 10}) { users { id } } }"},
+		{name: "Page too large", query: "{ users(page: {limit: 40}) { users { id first_name } } }", code: CODE_QUERY_TOO_COMPLEX},
+		{name: "Default page too large", query: "{ users { users { id first_name } } }", code: CODE_QUERY_TOO_COMPLEX},
+		{
+			name:      "Page size from variables",
+			query:     "query($page: PageInput) { users(page: $page) { users { id first_name } } }",
+			variables: map[string]interface{}{"page": map[string]interface{}{"limit": float64(40)}},
+			code:      CODE_QUERY_TOO_COMPLEX,
+		},
+		{
+			name:  "Fragments",
+			query: "{ users(page: {limit: 40}) { ...page } } fragment page on UserPage { users { ...names } } fragment names on User { id first_name }",
+			code:  CODE_QUERY_TOO_COMPLEX,
+		},
+		{name: "Aliases", query: "{ a: user(id: 1) { id } b: user(id: 2) { id } c: user(id: 3) { id } }"},
+		{name: "Introspection", query: "{ __schema { types { fields { type { ofType { ofType { name } } } } } } }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if validation := graphql.ValidateDocument(&schema, document, nil); !validation.IsValid {
+				t.Fatal(validation.Errors)
+			}
+
+			err = limits.Check(document, document.Definitions[0].(*ast.OperationDefinition), tt.variables)
+
+			if tt.code == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.code, err.(*Error).Code)
+			}
+		})
+	}
+
+	document, _ := parser.Parse(parser.ParseParams{Source: "{ users { users { id } } }"})
+	err = Limits{MaxDepth: 2}.Check(document, document.Definitions[0].(*ast.OperationDefinition), nil)
+	assert.Equal(t, CODE_QUERY_TOO_DEEP, err.(*Error).Code)
+}
@@ -0,0 +1,186 @@
+package gql
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+	"strings"
+)
+
+// Default limits of the queries
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxComplexity = 10000
+)
+
+// Limits rejects the operations too deep or too complex to be executed, before any resolver runs.
+// Each field costs 1, and the fields selected under the users query cost once per user of the requested page.
+// The introspection fields are not counted, the schema they describe being small and fixed.
+type Limits struct {
+	// MaxDepth is the deepest nesting of fields, DefaultMaxDepth when zero
+	MaxDepth int
+	// MaxComplexity is the highest total cost of the fields, DefaultMaxComplexity when zero
+	MaxComplexity int
+}
+
+// Check measures an operation of a validated document, whose variables are those sent with the request
+func (l Limits) Check(document *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) error {
+
+	maxDepth := l.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	maxComplexity := l.MaxComplexity
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+
+	m := measure{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	depth, complexity := m.selectionSet(operation.SelectionSet, true)
+
+	if depth > maxDepth {
+		return &Error{Code: CODE_QUERY_TOO_DEEP, Message: fmt.Sprintf("query depth %v exceeds the maximum of %v", depth, maxDepth)}
+	}
+	if complexity > maxComplexity {
+		return &Error{Code: CODE_QUERY_TOO_COMPLEX, Message: fmt.Sprintf("query complexity %v exceeds the maximum of %v", complexity, maxComplexity)}
+	}
+	return nil
+}
+
+// mutationFields returns the root fields of a mutation, fragments included, an aliased field counting once per alias
+func mutationFields(document *ast.Document, operation *ast.OperationDefinition) []string {
+
+	if operation.Operation != ast.OperationTypeMutation {
+		return nil
+	}
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	var fields func(set *ast.SelectionSet) []string
+	fields = func(set *ast.SelectionSet) []string {
+		names := []string{}
+		if set == nil {
+			return names
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if !strings.HasPrefix(selection.Name.Value, "__") {
+					names = append(names, selection.Name.Value)
+				}
+			case *ast.InlineFragment:
+				names = append(names, fields(selection.SelectionSet)...)
+			case *ast.FragmentSpread:
+				// validation rejects the unknown and cyclic fragments before the mutations are counted
+				if fragment, ok := fragments[selection.Name.Value]; ok {
+					names = append(names, fields(fragment.SelectionSet)...)
+				}
+			}
+		}
+		return names
+	}
+	return fields(operation.SelectionSet)
+}
+
+type measure struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// selectionSet returns the depth and the cost of the fields of a selection set, fragments included, root being set for
+// the fields of the operation
+func (m *measure) selectionSet(set *ast.SelectionSet, root bool) (int, int) {
+
+	if set == nil {
+		return 0, 0
+	}
+
+	depth, complexity := 0, 0
+	for _, selection := range set.Selections {
+		var selectionDepth, selectionComplexity int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			childDepth, childComplexity := m.selectionSet(selection.SelectionSet, false)
+			multiplier := 1
+			if root {
+				multiplier = m.multiplier(selection)
+			}
+			selectionDepth = childDepth + 1
+			selectionComplexity = 1 + childComplexity*multiplier
+		case *ast.InlineFragment:
+			selectionDepth, selectionComplexity = m.selectionSet(selection.SelectionSet, root)
+		case *ast.FragmentSpread:
+			// validation rejects the unknown and cyclic fragments before the limits are checked
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				selectionDepth, selectionComplexity = m.selectionSet(fragment.SelectionSet, root)
+			}
+		}
+		if selectionDepth > depth {
+			depth = selectionDepth
+		}
+		complexity += selectionComplexity
+	}
+	return depth, complexity
+}
+
+// multiplier returns how many times the selection of a root field is resolved, the page limit for the users query
+func (m *measure) multiplier(field *ast.Field) int {
+
+	if field.Name.Value != "users" {
+		return 1
+	}
+
+	args := map[string]interface{}{}
+	for _, argument := range field.Arguments {
+		args[argument.Name.Value] = m.value(argument.Value)
+	}
+	_, limit := pageArgs(args)
+	return limit
+}
+
+// value returns the Go value of an argument, with the types the resolvers get, reading variables from the request
+func (m *measure) value(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.Variable:
+		return normalize(m.variables[value.Name.Value])
+	case *ast.IntValue:
+		number, _ := strconv.Atoi(value.Value)
+		return number
+	case *ast.ObjectValue:
+		object := map[string]interface{}{}
+		for _, field := range value.Fields {
+			object[field.Name.Value] = m.value(field.Value)
+		}
+		return object
+	default:
+		return nil
+	}
+}
+
+// normalize converts the numbers of JSON variables to the int the resolvers get
+func normalize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case float64:
+		return int(value)
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for key, field := range value {
+			object[key] = normalize(field)
+		}
+		return object
+	default:
+		return value
+	}
+}
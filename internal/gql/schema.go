@@ -0,0 +1,321 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"reflect"
+	"strings"
+)
+
+// Page sizes of the users query
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// UserSchema builds the GraphQL schema of the user API. Its User type is derived from model.User, with the json names
+// of its fields, and every operation goes through the service layer with the same authorization as the REST API.
+type UserSchema struct {
+	UserService service.IUserService
+	// Policy decides which callers may perform each operation
+	Policy authz.IPolicy
+}
+
+// userPage is the result of the users query
+type userPage struct {
+	Users       []model.User `json:"users"`
+	TotalCount  int          `json:"total_count"`
+	HasNextPage bool         `json:"has_next_page"`
+}
+
+func (us *UserSchema) Build() (graphql.Schema, error) {
+
+	userType := reflect.TypeOf(model.User{})
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "User",
+		Fields: outputFields(userType),
+	})
+	userInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserInput",
+		Description: "The fields of a user, its id being given by the server",
+		Fields:      inputFields(userType, "id"),
+	})
+	userFilter := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserFilter",
+		Description: "Names and email match when they contain the given text, ignoring case",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"first_name": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"last_name":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"email":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"min_age":    &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"max_age":    &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		},
+	})
+	pageInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "PageInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"offset": &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: 0},
+			"limit":  &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: DefaultPageSize},
+		},
+	})
+	page := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"users":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user)))},
+			"total_count":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"has_next_page": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:        user,
+				Description: "The user with the given id, null when there is none",
+				Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve:     us.resolveUser,
+			},
+			"users": &graphql.Field{
+				Type:        page,
+				Description: "The users matching the filter ordered by id, limit being at most 1000",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: userFilter},
+					"page":   &graphql.ArgumentConfig{Type: pageInput},
+				},
+				Resolve: us.resolveUsers,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type:    user,
+				Args:    graphql.FieldConfigArgument{"user": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInput)}},
+				Resolve: us.resolveCreateUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{
+					"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"user": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInput)},
+				},
+				Resolve: us.resolveUpdateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type:    graphql.Boolean,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: us.resolveDeleteUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (us *UserSchema) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+
+	id := int64(p.Args["id"].(int))
+	if err := us.authorize(p.Context, authz.ACTION_READ, id); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	user, err := us.UserService.GetUser(id)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	return user, nil
+}
+
+func (us *UserSchema) resolveUsers(p graphql.ResolveParams) (interface{}, error) {
+
+	if err := us.authorize(p.Context, authz.ACTION_LIST, 0); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+	offset, limit := pageArgs(p.Args)
+
+	matching, err := us.UserService.ListUsersAfter(userFilter(p.Args["filter"]), 0, 0)
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	result := userPage{Users: []model.User{}, TotalCount: len(matching)}
+	if offset < len(matching) {
+		end := offset + limit
+		if end > len(matching) {
+			end = len(matching)
+		}
+		result.Users = matching[offset:end]
+		result.HasNextPage = end < len(matching)
+	}
+
+	return result, nil
+}
+
+func (us *UserSchema) resolveCreateUser(p graphql.ResolveParams) (interface{}, error) {
+
+	if err := us.authorize(p.Context, authz.ACTION_CREATE, 0); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	user, err := toUser(p.Args["user"])
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+	created, err := us.UserService.SaveUser(user)
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	return created, nil
+}
+
+func (us *UserSchema) resolveUpdateUser(p graphql.ResolveParams) (interface{}, error) {
+
+	id := int64(p.Args["id"].(int))
+	if err := us.authorize(p.Context, authz.ACTION_UPDATE, id); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	user, err := toUser(p.Args["user"])
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+	user.Id = id
+	updated, err := us.UserService.UpdateUser(user)
+	if err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	return updated, nil
+}
+
+func (us *UserSchema) resolveDeleteUser(p graphql.ResolveParams) (interface{}, error) {
+
+	id := int64(p.Args["id"].(int))
+	if err := us.authorize(p.Context, authz.ACTION_DELETE, id); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	if err := us.UserService.DeleteUser(id); err != nil {
+		return nil, errorFromService(p.Context, err)
+	}
+
+	return true, nil
+}
+
+// authorize asks the policy whether the caller may perform the action, on the given user when userId is not zero
+func (us *UserSchema) authorize(ctx context.Context, action string, userId int64) error {
+	return authz.Authorize(ctx, us.Policy, action, userId)
+}
+
+// outputFields derives the fields of an object type from the json tags of a struct, none of them being null
+func outputFields(t reflect.Type) graphql.Fields {
+	fields := graphql.Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		name, scalar := fieldOf(t.Field(i))
+		if scalar != nil {
+			fields[name] = &graphql.Field{Type: graphql.NewNonNull(scalar)}
+		}
+	}
+	return fields
+}
+
+// inputFields derives the fields of an input type from the json tags of a struct, all of them optional so that missing
+// fields are reported by the validation of the service layer
+func inputFields(t reflect.Type, excluded ...string) graphql.InputObjectConfigFieldMap {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for i := 0; i < t.NumField(); i++ {
+		name, scalar := fieldOf(t.Field(i))
+		if scalar != nil && !contains(excluded, name) {
+			fields[name] = &graphql.InputObjectFieldConfig{Type: scalar}
+		}
+	}
+	return fields
+}
+
+// fieldOf returns the json name of a struct field and its GraphQL scalar, nil for the kinds the models do not use
+func fieldOf(field reflect.StructField) (string, *graphql.Scalar) {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return "", nil
+	}
+	switch field.Type.Kind() {
+	case reflect.String:
+		return name, graphql.String
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return name, graphql.Int
+	case reflect.Bool:
+		return name, graphql.Boolean
+	case reflect.Float32, reflect.Float64:
+		return name, graphql.Float
+	default:
+		return "", nil
+	}
+}
+
+// toUser reads a UserInput argument, whose fields have the json names of model.User
+func toUser(input interface{}) (*model.User, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, apperror.Internal("unable to read user input", err)
+	}
+	user := &model.User{}
+	if err := json.Unmarshal(data, user); err != nil {
+		return nil, apperror.Internal("unable to read user input", err)
+	}
+	return user, nil
+}
+
+// pageArgs returns the offset and limit of the page argument, within the allowed page sizes
+func pageArgs(args map[string]interface{}) (int, int) {
+	offset, limit := 0, DefaultPageSize
+	if page, ok := args["page"].(map[string]interface{}); ok {
+		if value, ok := page["offset"].(int); ok && value > 0 {
+			offset = value
+		}
+		if value, ok := page["limit"].(int); ok && value > 0 {
+			limit = value
+		}
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	return offset, limit
+}
+
+// userFilter reads a UserFilter argument
+func userFilter(arg interface{}) model.UserFilter {
+	fields, _ := arg.(map[string]interface{})
+	filter := model.UserFilter{}
+	filter.FirstName, _ = fields["first_name"].(string)
+	filter.LastName, _ = fields["last_name"].(string)
+	filter.Email, _ = fields["email"].(string)
+	if minAge, ok := fields["min_age"].(int); ok {
+		filter.MinAge = int64(minAge)
+	}
+	if maxAge, ok := fields["max_age"].(int); ok {
+		filter.MaxAge = int64(maxAge)
+	}
+	return filter
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FallbackLocale is used for any message missing from the negotiated locales
+const FallbackLocale = "en"
+
+//go:embed locales/*.json
+var embeddedMessages embed.FS
+
+var (
+	defaultCatalog     *Catalog
+	defaultCatalogOnce sync.Once
+)
+
+// Catalog holds the translated messages of every supported locale
+type Catalog struct {
+	uni       *ut.UniversalTranslator
+	supported map[string]string
+}
+
+// NewCatalog loads the built-in message files, then the ones found in dir if it is not empty.
+// Message files are JSON objects of key to text named after their locale, e.g. fr_CA.json.
+// Messages from dir override the built-in ones, so a team can both add a locale and reword an existing one.
+func NewCatalog(dir string) (*Catalog, error) {
+	c := &Catalog{
+		uni:       ut.New(en.New(), en.New()),
+		supported: map[string]string{},
+	}
+
+	if err := c.loadMessageFiles(embeddedMessages, "locales"); err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if err := c.loadMessageFiles(os.DirFS(dir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Default returns the catalog with the built-in messages only
+func Default() *Catalog {
+	defaultCatalogOnce.Do(func() {
+		c, err := NewCatalog("")
+		if err != nil {
+			panic(err)
+		}
+		defaultCatalog = c
+	})
+	return defaultCatalog
+}
+
+// AddMessages registers messages for a locale; existing messages with the same key are replaced
+func (c *Catalog) AddMessages(locale string, messages map[string]string) error {
+
+	newLocale, ok := knownLocales[locale]
+	if !ok {
+		return fmt.Errorf("unsupported locale '%v'", locale)
+	}
+
+	trans, found := c.uni.GetTranslator(locale)
+	if !found {
+		if err := c.uni.AddTranslator(newLocale(), false); err != nil {
+			return err
+		}
+		trans, _ = c.uni.GetTranslator(locale)
+	}
+
+	for key, text := range messages {
+		if strings.Count(text, "{") > maxParams {
+			return fmt.Errorf("message '%v' for locale '%v' has more than %v placeholders", key, locale, maxParams)
+		}
+		if err := trans.Add(key, text, true); err != nil {
+			return fmt.Errorf("invalid message '%v' for locale '%v': %w", key, locale, err)
+		}
+	}
+
+	c.supported[strings.ToLower(locale)] = locale
+	return nil
+}
+
+// Locales returns the locales that have messages, sorted
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.supported))
+	for _, locale := range c.supported {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Translator returns a translator for the first of the preferred locales the catalog supports.
+// A locale such as fr_CA falls back to fr, then to any other region of the same language,
+// and every message missing from the chosen locale falls back to FallbackLocale.
+func (c *Catalog) Translator(preferred ...string) *Translator {
+
+	chain := []ut.Translator{}
+	for _, tag := range preferred {
+		if locale, ok := c.match(tag); ok {
+			chain = c.appendLocale(chain, locale)
+			if base, _, found := strings.Cut(locale, "_"); found {
+				chain = c.appendLocale(chain, base)
+			}
+			break
+		}
+	}
+
+	return &Translator{chain: c.appendLocale(chain, FallbackLocale)}
+}
+
+func (c *Catalog) match(tag string) (string, bool) {
+
+	tag = strings.ToLower(strings.ReplaceAll(tag, "-", "_"))
+	if locale, ok := c.supported[tag]; ok {
+		return locale, true
+	}
+
+	base, _, _ := strings.Cut(tag, "_")
+	if locale, ok := c.supported[base]; ok {
+		return locale, true
+	}
+
+	for _, locale := range c.Locales() {
+		if strings.HasPrefix(strings.ToLower(locale), base+"_") {
+			return locale, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *Catalog) appendLocale(chain []ut.Translator, locale string) []ut.Translator {
+	if _, ok := c.supported[strings.ToLower(locale)]; !ok {
+		return chain
+	}
+	trans, _ := c.uni.GetTranslator(locale)
+	for _, t := range chain {
+		if t.Locale() == trans.Locale() {
+			return chain
+		}
+	}
+	return append(chain, trans)
+}
+
+func (c *Catalog) loadMessageFiles(fsys fs.FS, dir string) error {
+
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("invalid message file %v: %w", file, err)
+		}
+
+		if err := c.AddMessages(strings.TrimSuffix(path.Base(file), ".json"), messages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package i18n
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{header: "", want: []string{}},
+		{header: "fr-CA", want: []string{"fr-CA"}},
+		{header: "en;q=0.5, fr-CA, pt-BR;q=0.8", want: []string{"fr-CA", "pt-BR", "en"}},
+		{header: "*, de;q=0, it;q=bad, nl;q=0.1", want: []string{"nl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseAcceptLanguage(tt.header))
+		})
+	}
+}
+
+func TestCatalog_Translator(t *testing.T) {
+	catalog := Default()
+
+	tests := []struct {
+		name      string
+		preferred []string
+		locale    string
+		email     string
+		age       string
+	}{
+		{
+			name:      "Regional messages fall back to the language",
+			preferred: []string{"fr-CA"},
+			locale:    "fr_CA",
+			email:     "Le courriel de l'utilisateur doit être correctement formaté",
+			age:       "L'utilisateur n'a pas l'âge minimum requis",
+		},
+		{
+			name:      "Unsupported region uses the language",
+			preferred: []string{"fr-BE"},
+			locale:    "fr",
+			email:     "L'adresse e-mail de l'utilisateur doit être correctement formatée",
+			age:       "L'utilisateur n'a pas l'âge minimum requis",
+		},
+		{
+			name:      "Language uses a supported region",
+			preferred: []string{"pt"},
+			locale:    "pt_BR",
+			email:     "O e-mail do usuário deve estar formatado corretamente",
+			age:       "O usuário não atende ao requisito de idade mínima",
+		},
+		{
+			name:      "Unsupported languages fall back to english",
+			preferred: []string{"ja", "de"},
+			locale:    "en",
+			email:     "User email must be properly formatted",
+			age:       "User does not meet minimum age requirement",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translator := catalog.Translator(tt.preferred...)
+
+			assert.Equal(t, tt.locale, translator.Locale())
+			assert.Equal(t, tt.email, translator.FieldError(model.FieldError{Field: "email", Code: "email_format"}))
+			assert.Equal(t, tt.age, translator.FieldError(model.FieldError{Field: "age", Code: "age_minimum"}))
+		})
+	}
+}
+
+func TestTranslator_FieldError(t *testing.T) {
+	translator := Default().Translator("fr")
+
+	assert.Equal(t, "Le champ prénom est obligatoire", translator.FieldError(model.FieldError{Field: "first_name", Code: "required"}))
+	assert.Equal(t, "champ utilisateur inconnu 'nickname'", translator.FieldError(model.FieldError{Field: "nickname", Code: "unknown_field"}))
+	assert.Equal(t, "original message", translator.FieldError(model.FieldError{Field: "age", Code: "lte", Message: "original message"}))
+	assert.Equal(t, "Une erreur est survenue.", translator.Status(http.StatusInternalServerError))
+	assert.Equal(t, "", translator.Status(http.StatusTeapot))
+}
+
+func TestNewCatalog_From_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "es.json"), `{"validation.age_minimum": "El usuario no cumple con la edad mínima"}`)
+	writeFile(t, filepath.Join(dir, "en.json"), `{"validation.age_minimum": "User is too young"}`)
+
+	catalog, err := NewCatalog(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"en", "es", "fr", "fr_CA", "pt_BR"}, catalog.Locales())
+	assert.Equal(t, "El usuario no cumple con la edad mínima", catalog.Translator("es-MX").T("validation.age_minimum"))
+	assert.Equal(t, "User email must be properly formatted", catalog.Translator("es").T("validation.email_format"))
+	assert.Equal(t, "User is too young", catalog.Translator("en").T("validation.age_minimum"))
+}
+
+func TestNewCatalog_Invalid_Files(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "Unknown locale", file: "xx.json", content: `{}`},
+		{name: "Invalid json", file: "es.json", content: `{`},
+		{name: "Unbalanced placeholder", file: "es.json", content: `{"validation.required": "{0 es obligatorio"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, tt.file), tt.content)
+
+			catalog, err := NewCatalog(dir)
+
+			assert.Nil(t, catalog)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var locale string
+	handler := Default().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale = FromContext(r.Context()).Locale()
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept-Language", "pt-BR,pt;q=0.9")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "pt_BR", locale)
+	assert.Equal(t, "pt-BR", rr.Header().Get("Content-Language"))
+}
+
+func writeFile(t *testing.T, name string, content string) {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
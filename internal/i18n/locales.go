@@ -0,0 +1,36 @@
+package i18n
+
+import (
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/en_CA"
+	"github.com/go-playground/locales/en_GB"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/es_MX"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/fr_CA"
+	"github.com/go-playground/locales/it"
+	"github.com/go-playground/locales/nl"
+	"github.com/go-playground/locales/pt"
+	"github.com/go-playground/locales/pt_BR"
+	"github.com/go-playground/locales/pt_PT"
+)
+
+// knownLocales lists the locales a message file can be provided for.
+// Add the matching go-playground/locales package here to support a new one.
+var knownLocales = map[string]func() locales.Translator{
+	"de":    de.New,
+	"en":    en.New,
+	"en_CA": en_CA.New,
+	"en_GB": en_GB.New,
+	"es":    es.New,
+	"es_MX": es_MX.New,
+	"fr":    fr.New,
+	"fr_CA": fr_CA.New,
+	"it":    it.New,
+	"nl":    nl.New,
+	"pt":    pt.New,
+	"pt_BR": pt_BR.New,
+	"pt_PT": pt_PT.New,
+}
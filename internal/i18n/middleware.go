@@ -0,0 +1,60 @@
+package i18n
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Middleware negotiates the locale from the Accept-Language header and puts its translator on the request context
+func (c *Catalog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := c.Translator(ParseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+		w.Header().Set("Content-Language", strings.ReplaceAll(t.Locale(), "_", "-"))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), t)))
+	})
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header ordered by preference
+func ParseAcceptLanguage(header string) []string {
+
+	type weightedTag struct {
+		tag    string
+		weight float64
+	}
+
+	tags := []weightedTag{}
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		tags = append(tags, weightedTag{tag: tag, weight: weight})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].weight > tags[j].weight
+	})
+
+	preferred := make([]string, len(tags))
+	for i, t := range tags {
+		preferred[i] = t.tag
+	}
+	return preferred
+}
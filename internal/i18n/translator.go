@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"context"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strconv"
+)
+
+// maxParams is the number of placeholders a message may use, from {0} to {4}
+const maxParams = 5
+
+type contextKey struct{}
+
+// Translator translates message keys using a chain of locales, the first one being the negotiated locale
+type Translator struct {
+	chain []ut.Translator
+}
+
+// NewContext returns a copy of ctx carrying the translator
+func NewContext(ctx context.Context, t *Translator) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the translator negotiated for the request, or the fallback locale one
+func FromContext(ctx context.Context) *Translator {
+	if t, ok := ctx.Value(contextKey{}).(*Translator); ok {
+		return t
+	}
+	return Default().Translator()
+}
+
+// Locale returns the negotiated locale
+func (t *Translator) Locale() string {
+	return t.chain[0].Locale()
+}
+
+// T returns the message for key in the first locale of the chain that has it, or key itself
+func (t *Translator) T(key string, params ...string) string {
+	for _, trans := range t.chain {
+		if text, ok := translate(trans, key, params); ok {
+			return text
+		}
+	}
+	return key
+}
+
+// Status returns the generic message for an HTTP status code, or "" if there is none
+func (t *Translator) Status(code int) string {
+	key := "status." + strconv.Itoa(code)
+	if text := t.T(key); text != key {
+		return text
+	}
+	return ""
+}
+
+// FieldError returns the message for a validation failure, keeping the original message for unknown codes
+func (t *Translator) FieldError(fieldErr model.FieldError) string {
+	label := t.T("field." + fieldErr.Field)
+	if label == "field."+fieldErr.Field {
+		label = fieldErr.Field
+	}
+
+	key := "validation." + fieldErr.Code
+	if text := t.T(key, label); text != key {
+		return text
+	}
+	return fieldErr.Message
+}
+
+// FieldErrors translates the message of every validation failure
+func (t *Translator) FieldErrors(fieldErrors []model.FieldError) []model.FieldError {
+	translated := make([]model.FieldError, len(fieldErrors))
+	for i, fieldErr := range fieldErrors {
+		translated[i] = fieldErr
+		translated[i].Message = t.FieldError(fieldErr)
+	}
+	return translated
+}
+
+func translate(trans ut.Translator, key string, params []string) (string, bool) {
+	// universal-translator expects a param for every placeholder of the message
+	padded := make([]string, maxParams)
+	copy(padded, params)
+	text, err := trans.T(key, padded...)
+	return text, err == nil
+}
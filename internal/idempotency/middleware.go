@@ -0,0 +1,201 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// Headers read and written by the guard
+const (
+	HEADER_KEY      = "Idempotency-Key"
+	HEADER_REPLAYED = "Idempotent-Replayed"
+)
+
+// Default settings of the guard
+const (
+	DefaultTTL  = 24 * time.Hour
+	DefaultWait = 10 * time.Second
+)
+
+const (
+	maxKeyLength = 255
+	pollInterval = 50 * time.Millisecond
+)
+
+// Guard makes requests sent with an Idempotency-Key header safe to retry: the response of the first request is stored
+// with a fingerprint of the request, and replayed to the retries sending the same key.
+type Guard struct {
+	Store IStore
+	// TTL is how long responses are kept, DefaultTTL when zero
+	TTL time.Duration
+	// Wait is how long a retry waits for the first request to complete before getting 409, not at all when zero
+	Wait time.Duration
+	// MaxBodySize is the largest body fingerprinted, larger bodies are left to the handler to reject.
+	// codec.DefaultMaxBodySize is used when zero.
+	MaxBodySize int64
+}
+
+// Middleware replays stored responses, answers 409 while the first request is still in progress and 422 when a key
+// is reused for another request. Keys are scoped to the caller and the route.
+// Responses with a server error are not stored, so that the request can be retried.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key := r.Header.Get(HEADER_KEY)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validKey(key) {
+			respondError(w, r, http.StatusBadRequest, "idempotency.invalid_key")
+			return
+		}
+
+		fingerprint, ok, err := g.fingerprint(r)
+		if err != nil {
+			log.Error.Printf("Unable to read the request body: %v", err)
+			utils.ResponseLocalizedError(w, r, http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		storeKey := ratelimit.ClientKey(r) + "|" + r.Method + " " + r.URL.Path + "|" + key
+		ttl := g.TTL
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+		deadline := time.Now().Add(g.Wait)
+		for {
+			record, reserved, err := g.Store.Begin(r.Context(), storeKey, fingerprint, ttl)
+			if err != nil {
+				log.Error.Printf("Unable to check idempotency key: %v", err)
+				utils.ResponseLocalizedError(w, r, http.StatusServiceUnavailable)
+				return
+			}
+			switch {
+			case reserved:
+				g.serve(w, r, next, storeKey)
+				return
+			case record.Fingerprint != fingerprint:
+				log.Info.Printf("Idempotency key reused for another request by %v", ratelimit.ClientKey(r))
+				respondError(w, r, http.StatusUnprocessableEntity, "idempotency.key_reused")
+				return
+			case record.Response != nil:
+				replay(w, record.Response)
+				return
+			case !time.Now().Before(deadline):
+				w.Header().Set("Retry-After", "1")
+				respondError(w, r, http.StatusConflict, "idempotency.in_progress")
+				return
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(pollInterval):
+			}
+		}
+	})
+}
+
+// serve runs the request holding the key and stores its response, or releases the key when it fails
+func (g *Guard) serve(w http.ResponseWriter, r *http.Request, next http.Handler, storeKey string) {
+
+	completed := false
+	defer func() {
+		if !completed {
+			if err := g.Store.Release(r.Context(), storeKey); err != nil {
+				log.Error.Printf("Unable to release idempotency key: %v", err)
+			}
+		}
+	}()
+
+	before := w.Header().Clone()
+	var body bytes.Buffer
+	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	ww.Tee(&body)
+	next.ServeHTTP(ww, r)
+
+	status := ww.Status()
+	if status == 0 || status >= http.StatusInternalServerError {
+		return
+	}
+	response := Response{Status: status, Header: http.Header{}, Body: body.Bytes()}
+	for name, values := range w.Header() {
+		if !reflect.DeepEqual(before[name], values) {
+			response.Header[name] = values
+		}
+	}
+	if err := g.Store.Complete(r.Context(), storeKey, response); err != nil {
+		log.Error.Printf("Unable to store idempotent response: %v", err)
+		return
+	}
+	completed = true
+}
+
+// fingerprint hashes the method, path, content type and body of the request, and restores the body for the handler.
+// It reports false when the body is too large to be fingerprinted.
+func (g *Guard) fingerprint(r *http.Request) (string, bool, error) {
+
+	maxBodySize := g.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = codec.DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return "", false, err
+	}
+	if int64(len(body)) > maxBodySize {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return "", false, nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.Header.Get("Content-Type")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), true, nil
+}
+
+func replay(w http.ResponseWriter, response *Response) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HEADER_REPLAYED, "true")
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, code int, messageKey string) {
+	utils.ResponseCustomError(w, code, i18n.FromContext(r.Context()).T(messageKey))
+}
+
+// validKey accepts keys of printable ASCII characters, such as UUIDs
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for _, c := range []byte(key) {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuard_Middleware(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	guard := &Guard{Store: &MemoryStore{Now: func() time.Time { return now }}, TTL: time.Hour}
+	var calls atomic.Int32
+	status := http.StatusCreated
+	handler := guard.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":` + strconv.Itoa(int(n)) + `}`))
+	}))
+
+	call := func(key string, remoteAddr string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set(HEADER_KEY, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call("key-1", "10.0.0.1:1234", `{"first_name":"John"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, `{"id":1}`, rr.Body.String())
+	assert.Empty(t, rr.Header().Get(HEADER_REPLAYED))
+
+	rr = call("key-1", "10.0.0.1:5678", `{"first_name":"John"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, "retries get the stored response")
+	assert.Equal(t, `{"id":1}`, rr.Body.String())
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "true", rr.Header().Get(HEADER_REPLAYED))
+	assert.Equal(t, int32(1), calls.Load())
+
+	rr = call("key-1", "10.0.0.1:1234", `{"first_name":"Jane"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, `{"code":422,"message":"Idempotency key was already used for a different request"}`, rr.Body.String())
+
+	assert.Equal(t, `{"id":2}`, call("key-1", "10.0.0.2:1234", `{"first_name":"John"}`).Body.String(), "keys are scoped to the client")
+	assert.Equal(t, `{"id":3}`, call("", "10.0.0.1:1234", `{"first_name":"John"}`).Body.String(), "requests without key are not guarded")
+	assert.Equal(t, http.StatusBadRequest, call("bad key", "10.0.0.1:1234", `{}`).Code)
+
+	status = http.StatusInternalServerError
+	assert.Equal(t, http.StatusInternalServerError, call("key-2", "10.0.0.1:1234", `{}`).Code)
+	status = http.StatusCreated
+	assert.Equal(t, `{"id":5}`, call("key-2", "10.0.0.1:1234", `{}`).Body.String(), "server errors are not stored")
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, `{"id":6}`, call("key-1", "10.0.0.1:1234", `{"first_name":"Jane"}`).Body.String(), "keys expire after the TTL")
+}
+
+func TestGuard_Middleware_Concurrent(t *testing.T) {
+	tests := []struct {
+		name           string
+		wait           time.Duration
+		expectedStatus int
+	}{
+		{name: "NO_WAIT", expectedStatus: http.StatusConflict},
+		{name: "WAIT", wait: 5 * time.Second, expectedStatus: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &Guard{Store: &MemoryStore{}, Wait: tt.wait}
+			started, release := make(chan struct{}), make(chan struct{})
+			handler := guard.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusCreated)
+			}))
+			call := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+				req.Header.Set(HEADER_KEY, "key")
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				return rr
+			}
+
+			first := make(chan *httptest.ResponseRecorder)
+			go func() { first <- call() }()
+			<-started
+			if tt.wait > 0 {
+				time.AfterFunc(100*time.Millisecond, func() { close(release) })
+			}
+
+			rr := call()
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.wait == 0 {
+				assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+				close(release)
+			}
+			assert.Equal(t, http.StatusCreated, (<-first).Code)
+		})
+	}
+}
+
+func TestMemoryStore_Release(t *testing.T) {
+	store := &MemoryStore{}
+	ctx := context.Background()
+
+	_, reserved, _ := store.Begin(ctx, "key", "a", time.Hour)
+	assert.True(t, reserved)
+	record, reserved, _ := store.Begin(ctx, "key", "b", time.Hour)
+	assert.False(t, reserved)
+	assert.Equal(t, "a", record.Fingerprint)
+	assert.Nil(t, record.Response)
+
+	assert.Nil(t, store.Release(ctx, "key"))
+	_, reserved, _ = store.Begin(ctx, "key", "b", time.Hour)
+	assert.True(t, reserved)
+}
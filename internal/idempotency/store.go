@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is the response stored for a key, replayed to the retries of the request
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what is stored under a key, Response is nil while the first request is in progress
+type Record struct {
+	Fingerprint string
+	Response    *Response
+	ExpiresAt   time.Time
+}
+
+// IStore keeps the records of the idempotency keys, a store shared by several instances can replace the in-memory one
+type IStore interface {
+	// Begin reserves the key for a request unless a record is already stored under it, which is then returned
+	Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (record *Record, reserved bool, err error)
+	// Complete stores the response of the request holding the key
+	Complete(ctx context.Context, key string, response Response) error
+	// Release frees the key of a request that failed, so that it can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// sweepInterval is how often the memory store drops the expired records
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the records of a single instance in memory
+type MemoryStore struct {
+	// Now returns the current time, time.Now is used when nil
+	Now func() time.Time
+
+	mu        sync.Mutex
+	records   map[string]*Record
+	lastSweep time.Time
+}
+
+func (ms *MemoryStore) Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+
+	now := ms.now()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.records == nil {
+		ms.records = map[string]*Record{}
+	}
+	if now.Sub(ms.lastSweep) >= sweepInterval {
+		ms.sweep(now)
+	}
+
+	if record, ok := ms.records[key]; ok && now.Before(record.ExpiresAt) {
+		copied := *record
+		return &copied, false, nil
+	}
+	record := &Record{Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	ms.records[key] = record
+	copied := *record
+	return &copied, true, nil
+}
+
+func (ms *MemoryStore) Complete(ctx context.Context, key string, response Response) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if record, ok := ms.records[key]; ok {
+		record.Response = &response
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Release(ctx context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.records, key)
+	return nil
+}
+
+func (ms *MemoryStore) now() time.Time {
+	if ms.Now != nil {
+		return ms.Now()
+	}
+	return time.Now()
+}
+
+// sweep drops the expired records, an expired record behaves as a missing one
+func (ms *MemoryStore) sweep(now time.Time) {
+	for key, record := range ms.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(ms.records, key)
+		}
+	}
+	ms.lastSweep = now
+}
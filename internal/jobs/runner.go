@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"sync"
+	"time"
+)
+
+// Default settings of the runner
+const (
+	DefaultWorkers          = 2
+	DefaultMaxAttempts      = 3
+	DefaultBackoff          = 30 * time.Second
+	DefaultInterval         = time.Second
+	DefaultProgressInterval = time.Second
+)
+
+const maxErrorLength = 255
+
+// Handler runs a job of one type and returns its result. It reports how far it went with progress, and stops when
+// ctx is done, either because the job is canceled or because the server stops.
+type Handler func(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error)
+
+// Runner runs the queued jobs with a pool of workers, recording their progress and outcome in the jobs table.
+// A job failing for a reason that may go away is retried with an exponential backoff, until it ran MaxAttempts times.
+// The jobs interrupted by a stop of the runner are queued again when it starts again on the same database, which the
+// in-memory database of the server does not outlive: jobs are not recovered after a restart of the server.
+type Runner struct {
+	Repository repository.IJobRepository
+	// Handlers run the jobs of each type, a job of another type fails
+	Handlers map[string]Handler
+	// Workers is the number of jobs run at the same time, DefaultWorkers when zero
+	Workers int
+	// MaxAttempts is the number of runs after which a failing job is failed, DefaultMaxAttempts when zero
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled at each retry, DefaultBackoff when zero
+	Backoff time.Duration
+	// Interval is how often idle workers look for queued jobs, DefaultInterval when zero
+	Interval time.Duration
+	// ProgressInterval is how often the progress of a job is written, DefaultProgressInterval when zero
+	ProgressInterval time.Duration
+	// Now returns the current time, time.Now is used when nil
+	Now func() time.Time
+
+	once    sync.Once
+	mutex   sync.Mutex
+	running map[int64]context.CancelFunc
+	wake    chan struct{}
+}
+
+// Run queues again the jobs interrupted by the last stop, then runs the queued jobs until ctx is done
+func (r *Runner) Run(ctx context.Context) {
+	r.init()
+
+	requeued, err := r.Repository.DbRequeueRunningJobs(r.now())
+	if err != nil {
+		log.Error.Printf("Unable to queue the interrupted jobs again: %v", err)
+	} else if requeued > 0 {
+		log.Info.Printf("Queued %v interrupted jobs again", requeued)
+	}
+
+	workers := r.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Wake makes an idle worker look for queued jobs at once, instead of at its next poll
+func (r *Runner) Wake() {
+	r.init()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel stops a job running on this runner, which is canceled once its handler returns
+func (r *Runner) Cancel(id int64) {
+	r.init()
+	r.mutex.Lock()
+	cancel, ok := r.running[id]
+	r.mutex.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// RunNext claims the oldest due job and runs it, and tells whether there was one
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	r.init()
+
+	job, err := r.Repository.DbClaimJob(r.now())
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	r.run(ctx, job)
+	return true, r.Repository.DbUpdateJob(job)
+}
+
+func (r *Runner) init() {
+	r.once.Do(func() {
+		r.running = map[int64]context.CancelFunc{}
+		r.wake = make(chan struct{}, 1)
+	})
+}
+
+// work runs the due jobs one after the other, then waits for the next poll or for a new job
+func (r *Runner) work(ctx context.Context) {
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			ran, err := r.RunNext(ctx)
+			if err != nil {
+				log.Error.Printf("Unable to run jobs: %v", err)
+			}
+			if !ran || err != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// run runs a claimed job with its handler, and sets its state from the outcome
+func (r *Runner) run(ctx context.Context, job *model.Job) {
+
+	maxAttempts := r.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	handler, ok := r.Handlers[job.Type]
+	if !ok {
+		r.finish(job, model.JOB_FAILED, fmt.Errorf("unknown job type %q", job.Type))
+		return
+	}
+	// attempts are counted when a job is claimed, so a job that keeps crashing the server is not run forever
+	if job.Attempts > maxAttempts {
+		r.finish(job, model.JOB_FAILED, errors.New("interrupted too many times"))
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.mutex.Lock()
+	r.running[job.Id] = cancel
+	r.mutex.Unlock()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.running, job.Id)
+		r.mutex.Unlock()
+	}()
+	// the job may have been asked to stop between its claim and now
+	if current, err := r.Repository.DbGetJob(job.Id); err == nil && current.CancelRequested {
+		cancel()
+	}
+
+	progressInterval := r.ProgressInterval
+	if progressInterval <= 0 {
+		progressInterval = DefaultProgressInterval
+	}
+	var written time.Time
+	progress := func(progress model.JobProgress) {
+		job.Progress = progress
+		if now := r.now(); now.Sub(written) >= progressInterval {
+			written = now
+			if err := r.Repository.DbUpdateProgress(job.Id, progress); err != nil {
+				log.Error.Printf("Unable to record the progress of job %v: %v", job.Id, err)
+			}
+		}
+	}
+
+	log.Info.Printf("Job %v (%v) started, attempt %v", job.Id, job.Type, job.Attempts)
+	result, err := handler(jobCtx, job, progress)
+
+	switch {
+	case ctx.Err() != nil:
+		// the runner stops, the job runs again when it starts again on the same database without counting this attempt
+		log.Info.Printf("Job %v (%v) interrupted", job.Id, job.Type)
+		job.Status, job.Attempts = model.JOB_QUEUED, job.Attempts-1
+	case jobCtx.Err() != nil:
+		r.finish(job, model.JOB_CANCELED, nil)
+	case err == nil:
+		job.Result = result
+		r.finish(job, model.JOB_SUCCEEDED, nil)
+	case retryable(err) && job.Attempts < maxAttempts:
+		next := r.now().Add(r.RetryDelay(job.Attempts))
+		log.Error.Printf("Job %v (%v) failed, attempt %v retried at %v: %v", job.Id, job.Type, job.Attempts, next, err)
+		job.Status, job.NextAttemptAt, job.Error = model.JOB_QUEUED, &next, truncate(err.Error())
+	default:
+		r.finish(job, model.JOB_FAILED, err)
+	}
+}
+
+// finish sets the final state of a job, with the error that failed it
+func (r *Runner) finish(job *model.Job, status string, err error) {
+
+	now := r.now()
+	job.Status, job.NextAttemptAt, job.FinishedAt, job.Error = status, nil, &now, ""
+	if err != nil {
+		job.Error = truncate(err.Error())
+		log.Error.Printf("Job %v (%v) %v after %v attempts: %v", job.Id, job.Type, status, job.Attempts, err)
+		return
+	}
+	log.Info.Printf("Job %v (%v) %v", job.Id, job.Type, status)
+}
+
+// RetryDelay returns the delay after the given number of failed attempts, the backoff doubled at each attempt
+func (r *Runner) RetryDelay(attempts int) time.Duration {
+
+	delay := r.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+	}
+	return delay
+}
+
+func (r *Runner) now() time.Time {
+	if r.Now != nil {
+		return r.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// retryable tells whether a failure may go away by running the job again, rejected input never does
+func retryable(err error) bool {
+	for _, kind := range []error{apperror.ErrValidation, apperror.ErrNotFound, apperror.ErrConflict, apperror.ErrForbidden} {
+		if errors.Is(err, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+func truncate(message string) string {
+	if len(message) > maxErrorLength {
+		return message[:maxErrorLength]
+	}
+	return message
+}
@@ -0,0 +1,248 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func newJobRepository(t *testing.T) *repository.JobRepository {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Job{}); err != nil {
+		t.Fatal(err)
+	}
+	return &repository.JobRepository{DB: db}
+}
+
+func TestRunner_RunNext(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		jobType     string
+		attempts    int
+		err         error
+		wantStatus  string
+		wantError   string
+		wantResult  *model.JobResult
+		wantRetryAt *time.Time
+	}{
+		{
+			name:       "Succeeded",
+			jobType:    model.JOB_PURGE,
+			wantStatus: model.JOB_SUCCEEDED,
+			wantResult: &model.JobResult{Deleted: 3},
+		},
+		{
+			name:        "Retried",
+			jobType:     model.JOB_PURGE,
+			err:         apperror.Unavailable("database unavailable", nil),
+			wantStatus:  model.JOB_QUEUED,
+			wantError:   "database unavailable",
+			wantRetryAt: func() *time.Time { at := now.Add(time.Minute); return &at }(),
+		},
+		{
+			name:       "Rejected",
+			jobType:    model.JOB_PURGE,
+			err:        apperror.Validation("A purge needs at least one filter", nil),
+			wantStatus: model.JOB_FAILED,
+			wantError:  "A purge needs at least one filter",
+		},
+		{
+			name:       "Out of attempts",
+			jobType:    model.JOB_PURGE,
+			attempts:   2,
+			err:        errors.New("disk full"),
+			wantStatus: model.JOB_FAILED,
+			wantError:  "disk full",
+		},
+		{
+			name:       "Interrupted too many times",
+			jobType:    model.JOB_PURGE,
+			attempts:   3,
+			wantStatus: model.JOB_FAILED,
+			wantError:  "interrupted too many times",
+		},
+		{
+			name:       "Unknown type",
+			jobType:    "reindex",
+			wantStatus: model.JOB_FAILED,
+			wantError:  `unknown job type "reindex"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			jobRepository := newJobRepository(t)
+			job, err := jobRepository.DbCreateJob(&model.Job{Type: tt.jobType, Status: model.JOB_QUEUED, Attempts: tt.attempts})
+			assert.Nil(t, err)
+			runner := Runner{
+				Repository: jobRepository,
+				Handlers: map[string]Handler{
+					model.JOB_PURGE: func(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+						progress(model.JobProgress{Done: 3})
+						if tt.err != nil {
+							return nil, tt.err
+						}
+						return &model.JobResult{Deleted: 3}, nil
+					},
+				},
+				MaxAttempts: 3,
+				Backoff:     time.Minute,
+				Now:         func() time.Time { return now },
+			}
+
+			// When
+			ran, err := runner.RunNext(context.Background())
+
+			// Then
+			assert.Nil(t, err)
+			assert.True(t, ran)
+			stored, err := jobRepository.DbGetJob(job.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantStatus, stored.Status)
+			assert.Equal(t, tt.attempts+1, stored.Attempts)
+			assert.Equal(t, tt.wantError, stored.Error)
+			assert.Equal(t, tt.wantResult, stored.Result)
+			if tt.wantRetryAt != nil {
+				assert.True(t, tt.wantRetryAt.Equal(*stored.NextAttemptAt), stored.NextAttemptAt)
+				assert.Nil(t, stored.FinishedAt)
+			} else {
+				assert.Nil(t, stored.NextAttemptAt)
+				assert.NotNil(t, stored.FinishedAt)
+			}
+
+			// a job waiting for its retry is not due yet
+			ran, err = runner.RunNext(context.Background())
+			assert.Nil(t, err)
+			assert.False(t, ran)
+		})
+	}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	// Given
+	jobRepository := newJobRepository(t)
+	job, err := jobRepository.DbCreateJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	runner := Runner{
+		Repository: jobRepository,
+		Handlers: map[string]Handler{
+			model.JOB_EXPORT: func(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+				close(started)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runner.RunNext(context.Background())
+	}()
+
+	// When
+	<-started
+	_, err = jobRepository.DbCancelJob(job.Id, time.Now())
+	assert.Nil(t, err)
+	runner.Cancel(job.Id)
+	<-done
+
+	// Then
+	stored, err := jobRepository.DbGetJob(job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_CANCELED, stored.Status)
+	assert.Empty(t, stored.Error)
+}
+
+func TestRunner_Run(t *testing.T) {
+	// Given
+	jobRepository := newJobRepository(t)
+	interrupted, err := jobRepository.DbCreateJob(&model.Job{Type: model.JOB_REVALIDATE, Status: model.JOB_RUNNING, Attempts: 1})
+	assert.Nil(t, err)
+	canceling, err := jobRepository.DbCreateJob(&model.Job{Type: model.JOB_REVALIDATE, Status: model.JOB_RUNNING, Attempts: 1, CancelRequested: true})
+	assert.Nil(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	finished := make(chan int64, 2)
+	runner := Runner{
+		Repository: jobRepository,
+		Handlers: map[string]Handler{
+			model.JOB_REVALIDATE: func(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+				finished <- job.Id
+				return &model.JobResult{Revalidation: &model.RevalidationReport{Users: 5, Errors: []model.RevalidationError{}}}, nil
+			},
+		},
+		Workers:  2,
+		Interval: time.Hour,
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		runner.Run(ctx)
+	}()
+
+	// When
+	// the interrupted job runs again at once, a new job as soon as it is submitted
+	assert.Equal(t, interrupted.Id, <-finished)
+	submitted, err := jobRepository.DbCreateJob(&model.Job{Type: model.JOB_REVALIDATE, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	runner.Wake()
+	assert.Equal(t, submitted.Id, <-finished)
+	stop()
+	<-stopped
+
+	// Then
+	for id, wantStatus := range map[int64]string{interrupted.Id: model.JOB_SUCCEEDED, canceling.Id: model.JOB_CANCELED, submitted.Id: model.JOB_SUCCEEDED} {
+		stored, err := jobRepository.DbGetJob(id)
+		assert.Nil(t, err)
+		assert.Equal(t, wantStatus, stored.Status, id)
+	}
+	stored, _ := jobRepository.DbGetJob(interrupted.Id)
+	assert.Equal(t, 2, stored.Attempts)
+}
+
+func TestRunner_Run_Stop(t *testing.T) {
+	// Given
+	jobRepository := newJobRepository(t)
+	job, err := jobRepository.DbCreateJob(&model.Job{Type: model.JOB_IMPORT, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	runner := Runner{
+		Repository: jobRepository,
+		Handlers: map[string]Handler{
+			model.JOB_IMPORT: func(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+				stop()
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+	}
+
+	// When
+	runner.Run(ctx)
+
+	// Then
+	// the job runs again when the runner starts again, without counting the interrupted attempt
+	stored, err := jobRepository.DbGetJob(job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_QUEUED, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+}
+
+func TestRunner_RetryDelay(t *testing.T) {
+	runner := Runner{Backoff: 10 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second} {
+		assert.Equal(t, want, runner.RetryDelay(attempts), attempts)
+	}
+}
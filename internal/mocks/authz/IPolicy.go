@@ -0,0 +1,42 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IPolicy is an autogenerated mock type for the IPolicy type
+type IPolicy struct {
+	mock.Mock
+}
+
+// Authorize provides a mock function with given fields: ctx, action, userId
+func (_m *IPolicy) Authorize(ctx context.Context, action string, userId int64) error {
+	ret := _m.Called(ctx, action, userId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, action, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIPolicy creates a new instance of IPolicy. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIPolicy(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IPolicy {
+	mock := &IPolicy{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,43 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	http "net/http"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IApiKeyController is an autogenerated mock type for the IApiKeyController type
+type IApiKeyController struct {
+	mock.Mock
+}
+
+// CreateApiKey provides a mock function with given fields: w, r
+func (_m *IApiKeyController) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ListApiKeys provides a mock function with given fields: w, r
+func (_m *IApiKeyController) ListApiKeys(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// RevokeApiKey provides a mock function with given fields: w, r
+func (_m *IApiKeyController) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// NewIApiKeyController creates a new instance of IApiKeyController. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIApiKeyController(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IApiKeyController {
+	mock := &IApiKeyController{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
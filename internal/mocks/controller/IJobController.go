@@ -0,0 +1,68 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	http "net/http"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IJobController is an autogenerated mock type for the IJobController type
+type IJobController struct {
+	mock.Mock
+}
+
+// CancelJob provides a mock function with given fields: w, r
+func (_m *IJobController) CancelJob(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// DownloadJobFile provides a mock function with given fields: w, r
+func (_m *IJobController) DownloadJobFile(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// GetJob provides a mock function with given fields: w, r
+func (_m *IJobController) GetJob(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ListJobs provides a mock function with given fields: w, r
+func (_m *IJobController) ListJobs(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// SubmitExport provides a mock function with given fields: w, r
+func (_m *IJobController) SubmitExport(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// SubmitImport provides a mock function with given fields: w, r
+func (_m *IJobController) SubmitImport(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// SubmitPurge provides a mock function with given fields: w, r
+func (_m *IJobController) SubmitPurge(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// SubmitRevalidation provides a mock function with given fields: w, r
+func (_m *IJobController) SubmitRevalidation(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// NewIJobController creates a new instance of IJobController. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIJobController(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IJobController {
+	mock := &IJobController{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -18,11 +18,21 @@ func (_m *IUserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// ExportUsers provides a mock function with given fields: w, r
+func (_m *IUserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // GetUser provides a mock function with given fields: w, r
 func (_m *IUserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// ImportUsers provides a mock function with given fields: w, r
+func (_m *IUserController) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // ListUsers provides a mock function with given fields: w, r
 func (_m *IUserController) ListUsers(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
@@ -33,11 +43,26 @@ func (_m *IUserController) SaveUser(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// StreamEvents provides a mock function with given fields: w, r
+func (_m *IUserController) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // UpdateUser provides a mock function with given fields: w, r
 func (_m *IUserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	_m.Called(w, r)
 }
 
+// ValidateUser provides a mock function with given fields: w, r
+func (_m *IUserController) ValidateUser(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ValidateUserField provides a mock function with given fields: w, r
+func (_m *IUserController) ValidateUserField(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
 // NewIUserController creates a new instance of IUserController. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewIUserController(t interface {
@@ -0,0 +1,53 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	http "net/http"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// IWebhookController is an autogenerated mock type for the IWebhookController type
+type IWebhookController struct {
+	mock.Mock
+}
+
+// CreateWebhook provides a mock function with given fields: w, r
+func (_m *IWebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// DeleteWebhook provides a mock function with given fields: w, r
+func (_m *IWebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ListDeliveries provides a mock function with given fields: w, r
+func (_m *IWebhookController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// ListWebhooks provides a mock function with given fields: w, r
+func (_m *IWebhookController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// Redeliver provides a mock function with given fields: w, r
+func (_m *IWebhookController) Redeliver(w http.ResponseWriter, r *http.Request) {
+	_m.Called(w, r)
+}
+
+// NewIWebhookController creates a new instance of IWebhookController. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIWebhookController(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IWebhookController {
+	mock := &IWebhookController{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
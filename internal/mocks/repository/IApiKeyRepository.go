@@ -0,0 +1,135 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+
+	time "time"
+)
+
+// IApiKeyRepository is an autogenerated mock type for the IApiKeyRepository type
+type IApiKeyRepository struct {
+	mock.Mock
+}
+
+// DbCreateApiKey provides a mock function with given fields: key
+func (_m *IApiKeyRepository) DbCreateApiKey(key *model.ApiKey) (*model.ApiKey, error) {
+	ret := _m.Called(key)
+
+	var r0 *model.ApiKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.ApiKey) (*model.ApiKey, error)); ok {
+		return rf(key)
+	}
+	if rf, ok := ret.Get(0).(func(*model.ApiKey) *model.ApiKey); ok {
+		r0 = rf(key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ApiKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.ApiKey) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbGetApiKeyByPrefix provides a mock function with given fields: prefix
+func (_m *IApiKeyRepository) DbGetApiKeyByPrefix(prefix string) (*model.ApiKey, error) {
+	ret := _m.Called(prefix)
+
+	var r0 *model.ApiKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*model.ApiKey, error)); ok {
+		return rf(prefix)
+	}
+	if rf, ok := ret.Get(0).(func(string) *model.ApiKey); ok {
+		r0 = rf(prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ApiKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(prefix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbListApiKeys provides a mock function with given fields:
+func (_m *IApiKeyRepository) DbListApiKeys() ([]model.ApiKey, error) {
+	ret := _m.Called()
+
+	var r0 []model.ApiKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.ApiKey, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.ApiKey); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ApiKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbRevokeApiKey provides a mock function with given fields: id, revokedAt
+func (_m *IApiKeyRepository) DbRevokeApiKey(id int64, revokedAt time.Time) error {
+	ret := _m.Called(id, revokedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, time.Time) error); ok {
+		r0 = rf(id, revokedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbTouchApiKey provides a mock function with given fields: id, usedAt
+func (_m *IApiKeyRepository) DbTouchApiKey(id int64, usedAt time.Time) error {
+	ret := _m.Called(id, usedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, time.Time) error); ok {
+		r0 = rf(id, usedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIApiKeyRepository creates a new instance of IApiKeyRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIApiKeyRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IApiKeyRepository {
+	mock := &IApiKeyRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
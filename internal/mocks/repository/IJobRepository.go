@@ -0,0 +1,235 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+
+	time "time"
+)
+
+// IJobRepository is an autogenerated mock type for the IJobRepository type
+type IJobRepository struct {
+	mock.Mock
+}
+
+// DbCancelJob provides a mock function with given fields: id, now
+func (_m *IJobRepository) DbCancelJob(id int64, now time.Time) (*model.Job, error) {
+	ret := _m.Called(id, now)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, time.Time) (*model.Job, error)); ok {
+		return rf(id, now)
+	}
+	if rf, ok := ret.Get(0).(func(int64, time.Time) *model.Job); ok {
+		r0 = rf(id, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, time.Time) error); ok {
+		r1 = rf(id, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbClaimJob provides a mock function with given fields: now
+func (_m *IJobRepository) DbClaimJob(now time.Time) (*model.Job, error) {
+	ret := _m.Called(now)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (*model.Job, error)); ok {
+		return rf(now)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) *model.Job); ok {
+		r0 = rf(now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbCreateJob provides a mock function with given fields: job
+func (_m *IJobRepository) DbCreateJob(job *model.Job) (*model.Job, error) {
+	ret := _m.Called(job)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.Job) (*model.Job, error)); ok {
+		return rf(job)
+	}
+	if rf, ok := ret.Get(0).(func(*model.Job) *model.Job); ok {
+		r0 = rf(job)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.Job) error); ok {
+		r1 = rf(job)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbDeleteFinishedJobs provides a mock function with given fields: before
+func (_m *IJobRepository) DbDeleteFinishedJobs(before time.Time) (int, error) {
+	ret := _m.Called(before)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(before)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbGetJob provides a mock function with given fields: id
+func (_m *IJobRepository) DbGetJob(id int64) (*model.Job, error) {
+	ret := _m.Called(id)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.Job, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *model.Job); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbListJobs provides a mock function with given fields:
+func (_m *IJobRepository) DbListJobs() ([]model.Job, error) {
+	ret := _m.Called()
+
+	var r0 []model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.Job, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.Job); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbRequeueRunningJobs provides a mock function with given fields: now
+func (_m *IJobRepository) DbRequeueRunningJobs(now time.Time) (int, error) {
+	ret := _m.Called(now)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(now)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(now)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbUpdateJob provides a mock function with given fields: job
+func (_m *IJobRepository) DbUpdateJob(job *model.Job) error {
+	ret := _m.Called(job)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*model.Job) error); ok {
+		r0 = rf(job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbUpdateProgress provides a mock function with given fields: id, progress
+func (_m *IJobRepository) DbUpdateProgress(id int64, progress model.JobProgress) error {
+	ret := _m.Called(id, progress)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, model.JobProgress) error); ok {
+		r0 = rf(id, progress)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIJobRepository creates a new instance of IJobRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIJobRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IJobRepository {
+	mock := &IJobRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
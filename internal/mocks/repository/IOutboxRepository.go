@@ -0,0 +1,81 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IOutboxRepository is an autogenerated mock type for the IOutboxRepository type
+type IOutboxRepository struct {
+	mock.Mock
+}
+
+// DbDeleteEvent provides a mock function with given fields: id
+func (_m *IOutboxRepository) DbDeleteEvent(id int64) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbFailEvent provides a mock function with given fields: id, reason
+func (_m *IOutboxRepository) DbFailEvent(id int64, reason string) error {
+	ret := _m.Called(id, reason)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, string) error); ok {
+		r0 = rf(id, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbPendingEvents provides a mock function with given fields: afterId, limit
+func (_m *IOutboxRepository) DbPendingEvents(afterId int64, limit int) ([]model.Event, error) {
+	ret := _m.Called(afterId, limit)
+
+	var r0 []model.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int) ([]model.Event, error)); ok {
+		return rf(afterId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int) []model.Event); ok {
+		r0 = rf(afterId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int) error); ok {
+		r1 = rf(afterId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIOutboxRepository creates a new instance of IOutboxRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIOutboxRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IOutboxRepository {
+	mock := &IOutboxRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -5,8 +5,6 @@ package mocks
 import (
 	mock "github.com/stretchr/testify/mock"
 	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
-
-	utils "github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 )
 
 // IUserRepository is an autogenerated mock type for the IUserRepository type
@@ -15,12 +13,12 @@ type IUserRepository struct {
 }
 
 // DbCreateUser provides a mock function with given fields: user
-func (_m *IUserRepository) DbCreateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (_m *IUserRepository) DbCreateUser(user *model.User) (*model.User, error) {
 	ret := _m.Called(user)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, error)); ok {
 		return rf(user)
 	}
 	if rf, ok := ret.Get(0).(func(*model.User) *model.User); ok {
@@ -31,40 +29,62 @@ func (_m *IUserRepository) DbCreateUser(user *model.User) (*model.User, utils.Me
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(*model.User) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
 		r1 = rf(user)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbCreateUsers provides a mock function with given fields: users
+func (_m *IUserRepository) DbCreateUsers(users []model.User) ([]model.User, error) {
+	ret := _m.Called(users)
+
+	var r0 []model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]model.User) ([]model.User, error)); ok {
+		return rf(users)
+	}
+	if rf, ok := ret.Get(0).(func([]model.User) []model.User); ok {
+		r0 = rf(users)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func([]model.User) error); ok {
+		r1 = rf(users)
+	} else {
+		r1 = ret.Error(1)
+	}
+
 	return r0, r1
 }
 
 // DbDeleteUser provides a mock function with given fields: id
-func (_m *IUserRepository) DbDeleteUser(id int64) utils.MessageErr {
+func (_m *IUserRepository) DbDeleteUser(id int64) error {
 	ret := _m.Called(id)
 
-	var r0 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(int64) utils.MessageErr); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
 		r0 = rf(id)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(utils.MessageErr)
-		}
+		r0 = ret.Error(0)
 	}
 
 	return r0
 }
 
 // DbGetUser provides a mock function with given fields: id
-func (_m *IUserRepository) DbGetUser(id int64) (*model.User, utils.MessageErr) {
+func (_m *IUserRepository) DbGetUser(id int64) (*model.User, error) {
 	ret := _m.Called(id)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(int64) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.User, error)); ok {
 		return rf(id)
 	}
 	if rf, ok := ret.Get(0).(func(int64) *model.User); ok {
@@ -75,24 +95,48 @@ func (_m *IUserRepository) DbGetUser(id int64) (*model.User, utils.MessageErr) {
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
 		r1 = rf(id)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbGetUserByEmail provides a mock function with given fields: email
+func (_m *IUserRepository) DbGetUserByEmail(email string) (*model.User, error) {
+	ret := _m.Called(email)
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*model.User, error)); ok {
+		return rf(email)
+	}
+	if rf, ok := ret.Get(0).(func(string) *model.User); ok {
+		r0 = rf(email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
 	return r0, r1
 }
 
 // DbListUsers provides a mock function with given fields:
-func (_m *IUserRepository) DbListUsers() ([]model.User, utils.MessageErr) {
+func (_m *IUserRepository) DbListUsers() ([]model.User, error) {
 	ret := _m.Called()
 
 	var r0 []model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func() ([]model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.User, error)); ok {
 		return rf()
 	}
 	if rf, ok := ret.Get(0).(func() []model.User); ok {
@@ -103,24 +147,48 @@ func (_m *IUserRepository) DbListUsers() ([]model.User, utils.MessageErr) {
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func() error); ok {
 		r1 = rf()
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbListUsersAfter provides a mock function with given fields: filter, afterId, limit
+func (_m *IUserRepository) DbListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+	ret := _m.Called(filter, afterId, limit)
+
+	var r0 []model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(model.UserFilter, int64, int) ([]model.User, error)); ok {
+		return rf(filter, afterId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(model.UserFilter, int64, int) []model.User); ok {
+		r0 = rf(filter, afterId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(model.UserFilter, int64, int) error); ok {
+		r1 = rf(filter, afterId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
 	return r0, r1
 }
 
 // DbUpdateUser provides a mock function with given fields: user
-func (_m *IUserRepository) DbUpdateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (_m *IUserRepository) DbUpdateUser(user *model.User) (*model.User, error) {
 	ret := _m.Called(user)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, error)); ok {
 		return rf(user)
 	}
 	if rf, ok := ret.Get(0).(func(*model.User) *model.User); ok {
@@ -131,24 +199,22 @@ func (_m *IUserRepository) DbUpdateUser(user *model.User) (*model.User, utils.Me
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(*model.User) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
 		r1 = rf(user)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
-		}
+		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
 
 // ExistsByFirstNameAndLastName provides a mock function with given fields: firstName, lastName
-func (_m *IUserRepository) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, utils.MessageErr) {
+func (_m *IUserRepository) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, error) {
 	ret := _m.Called(firstName, lastName)
 
 	var r0 bool
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(string, string) (bool, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (bool, error)); ok {
 		return rf(firstName, lastName)
 	}
 	if rf, ok := ret.Get(0).(func(string, string) bool); ok {
@@ -157,12 +223,10 @@ func (_m *IUserRepository) ExistsByFirstNameAndLastName(firstName string, lastNa
 		r0 = ret.Get(0).(bool)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
 		r1 = rf(firstName, lastName)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
-		}
+		r1 = ret.Error(1)
 	}
 
 	return r0, r1
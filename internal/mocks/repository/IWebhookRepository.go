@@ -0,0 +1,227 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+
+	time "time"
+)
+
+// IWebhookRepository is an autogenerated mock type for the IWebhookRepository type
+type IWebhookRepository struct {
+	mock.Mock
+}
+
+// DbCreateDeliveries provides a mock function with given fields: deliveries
+func (_m *IWebhookRepository) DbCreateDeliveries(deliveries []model.WebhookDelivery) error {
+	ret := _m.Called(deliveries)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]model.WebhookDelivery) error); ok {
+		r0 = rf(deliveries)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbCreateWebhook provides a mock function with given fields: webhook
+func (_m *IWebhookRepository) DbCreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	ret := _m.Called(webhook)
+
+	var r0 *model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.Webhook) (*model.Webhook, error)); ok {
+		return rf(webhook)
+	}
+	if rf, ok := ret.Get(0).(func(*model.Webhook) *model.Webhook); ok {
+		r0 = rf(webhook)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.Webhook) error); ok {
+		r1 = rf(webhook)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbDeleteWebhook provides a mock function with given fields: id
+func (_m *IWebhookRepository) DbDeleteWebhook(id int64) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DbDueDeliveries provides a mock function with given fields: now, limit
+func (_m *IWebhookRepository) DbDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(now, limit)
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) ([]model.WebhookDelivery, error)); ok {
+		return rf(now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, int) []model.WebhookDelivery); ok {
+		r0 = rf(now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, int) error); ok {
+		r1 = rf(now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbGetWebhook provides a mock function with given fields: id
+func (_m *IWebhookRepository) DbGetWebhook(id int64) (*model.Webhook, error) {
+	ret := _m.Called(id)
+
+	var r0 *model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.Webhook, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *model.Webhook); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbListDeliveries provides a mock function with given fields: webhookId
+func (_m *IWebhookRepository) DbListDeliveries(webhookId int64) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(webhookId)
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]model.WebhookDelivery, error)); ok {
+		return rf(webhookId)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []model.WebhookDelivery); ok {
+		r0 = rf(webhookId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(webhookId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbListWebhooks provides a mock function with given fields:
+func (_m *IWebhookRepository) DbListWebhooks() ([]model.Webhook, error) {
+	ret := _m.Called()
+
+	var r0 []model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.Webhook, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.Webhook); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbRedeliver provides a mock function with given fields: webhookId, deliveryId, now
+func (_m *IWebhookRepository) DbRedeliver(webhookId int64, deliveryId int64, now time.Time) (*model.WebhookDelivery, error) {
+	ret := _m.Called(webhookId, deliveryId, now)
+
+	var r0 *model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64, time.Time) (*model.WebhookDelivery, error)); ok {
+		return rf(webhookId, deliveryId, now)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64, time.Time) *model.WebhookDelivery); ok {
+		r0 = rf(webhookId, deliveryId, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64, time.Time) error); ok {
+		r1 = rf(webhookId, deliveryId, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DbUpdateDelivery provides a mock function with given fields: delivery
+func (_m *IWebhookRepository) DbUpdateDelivery(delivery *model.WebhookDelivery) error {
+	ret := _m.Called(delivery)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*model.WebhookDelivery) error); ok {
+		r0 = rf(delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIWebhookRepository creates a new instance of IWebhookRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIWebhookRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IWebhookRepository {
+	mock := &IWebhookRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,93 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IApiKeyService is an autogenerated mock type for the IApiKeyService type
+type IApiKeyService struct {
+	mock.Mock
+}
+
+// CreateApiKey provides a mock function with given fields: request
+func (_m *IApiKeyService) CreateApiKey(request *model.ApiKeyRequest) (*model.ApiKeyCreated, error) {
+	ret := _m.Called(request)
+
+	var r0 *model.ApiKeyCreated
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.ApiKeyRequest) (*model.ApiKeyCreated, error)); ok {
+		return rf(request)
+	}
+	if rf, ok := ret.Get(0).(func(*model.ApiKeyRequest) *model.ApiKeyCreated); ok {
+		r0 = rf(request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ApiKeyCreated)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.ApiKeyRequest) error); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListApiKeys provides a mock function with given fields:
+func (_m *IApiKeyService) ListApiKeys() ([]model.ApiKey, error) {
+	ret := _m.Called()
+
+	var r0 []model.ApiKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.ApiKey, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.ApiKey); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.ApiKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeApiKey provides a mock function with given fields: id
+func (_m *IApiKeyService) RevokeApiKey(id int64) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewIApiKeyService creates a new instance of IApiKeyService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIApiKeyService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IApiKeyService {
+	mock := &IApiKeyService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,34 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// IJobRunner is an autogenerated mock type for the IJobRunner type
+type IJobRunner struct {
+	mock.Mock
+}
+
+// Cancel provides a mock function with given fields: id
+func (_m *IJobRunner) Cancel(id int64) {
+	_m.Called(id)
+}
+
+// Wake provides a mock function with given fields:
+func (_m *IJobRunner) Wake() {
+	_m.Called()
+}
+
+// NewIJobRunner creates a new instance of IJobRunner. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIJobRunner(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IJobRunner {
+	mock := &IJobRunner{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
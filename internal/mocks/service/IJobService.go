@@ -0,0 +1,155 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IJobService is an autogenerated mock type for the IJobService type
+type IJobService struct {
+	mock.Mock
+}
+
+// CancelJob provides a mock function with given fields: id
+func (_m *IJobService) CancelJob(id int64) (*model.Job, error) {
+	ret := _m.Called(id)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.Job, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *model.Job); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetJob provides a mock function with given fields: id
+func (_m *IJobService) GetJob(id int64) (*model.Job, error) {
+	ret := _m.Called(id)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.Job, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *model.Job); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// JobFile provides a mock function with given fields: id
+func (_m *IJobService) JobFile(id int64) (string, error) {
+	ret := _m.Called(id)
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (string, error)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(int64) string); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListJobs provides a mock function with given fields:
+func (_m *IJobService) ListJobs() ([]model.Job, error) {
+	ret := _m.Called()
+
+	var r0 []model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.Job, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.Job); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SubmitJob provides a mock function with given fields: job
+func (_m *IJobService) SubmitJob(job *model.Job) (*model.Job, error) {
+	ret := _m.Called(job)
+
+	var r0 *model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.Job) (*model.Job, error)); ok {
+		return rf(job)
+	}
+	if rf, ok := ret.Get(0).(func(*model.Job) *model.Job); ok {
+		r0 = rf(job)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Job)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.Job) error); ok {
+		r1 = rf(job)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIJobService creates a new instance of IJobService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIJobService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IJobService {
+	mock := &IJobService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,56 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	codec "github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+
+	mock "github.com/stretchr/testify/mock"
+
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IUserExportService is an autogenerated mock type for the IUserExportService type
+type IUserExportService struct {
+	mock.Mock
+}
+
+// ExportUsers provides a mock function with given fields: ctx, filter, rows
+func (_m *IUserExportService) ExportUsers(ctx context.Context, filter model.UserFilter, rows codec.IRowWriter) (int, error) {
+	ret := _m.Called(ctx, filter, rows)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter, codec.IRowWriter) (int, error)); ok {
+		return rf(ctx, filter, rows)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserFilter, codec.IRowWriter) int); ok {
+		r0 = rf(ctx, filter, rows)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserFilter, codec.IRowWriter) error); ok {
+		r1 = rf(ctx, filter, rows)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIUserExportService creates a new instance of IUserExportService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIUserExportService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IUserExportService {
+	mock := &IUserExportService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
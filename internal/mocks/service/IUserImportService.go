@@ -0,0 +1,58 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	codec "github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+
+	mock "github.com/stretchr/testify/mock"
+
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IUserImportService is an autogenerated mock type for the IUserImportService type
+type IUserImportService struct {
+	mock.Mock
+}
+
+// ImportUsers provides a mock function with given fields: ctx, rows, dryRun
+func (_m *IUserImportService) ImportUsers(ctx context.Context, rows codec.IRowReader, dryRun bool) (*model.ImportReport, error) {
+	ret := _m.Called(ctx, rows, dryRun)
+
+	var r0 *model.ImportReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, codec.IRowReader, bool) (*model.ImportReport, error)); ok {
+		return rf(ctx, rows, dryRun)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, codec.IRowReader, bool) *model.ImportReport); ok {
+		r0 = rf(ctx, rows, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ImportReport)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, codec.IRowReader, bool) error); ok {
+		r1 = rf(ctx, rows, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIUserImportService creates a new instance of IUserImportService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIUserImportService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IUserImportService {
+	mock := &IUserImportService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
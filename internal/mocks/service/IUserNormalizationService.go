@@ -0,0 +1,32 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IUserNormalizationService is an autogenerated mock type for the IUserNormalizationService type
+type IUserNormalizationService struct {
+	mock.Mock
+}
+
+// NormalizeUser provides a mock function with given fields: user
+func (_m *IUserNormalizationService) NormalizeUser(user *model.User) {
+	_m.Called(user)
+}
+
+// NewIUserNormalizationService creates a new instance of IUserNormalizationService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIUserNormalizationService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IUserNormalizationService {
+	mock := &IUserNormalizationService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
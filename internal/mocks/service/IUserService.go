@@ -5,8 +5,6 @@ package mocks
 import (
 	mock "github.com/stretchr/testify/mock"
 	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
-
-	utils "github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 )
 
 // IUserService is an autogenerated mock type for the IUserService type
@@ -15,28 +13,26 @@ type IUserService struct {
 }
 
 // DeleteUser provides a mock function with given fields: id
-func (_m *IUserService) DeleteUser(id int64) utils.MessageErr {
+func (_m *IUserService) DeleteUser(id int64) error {
 	ret := _m.Called(id)
 
-	var r0 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(int64) utils.MessageErr); ok {
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
 		r0 = rf(id)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(utils.MessageErr)
-		}
+		r0 = ret.Error(0)
 	}
 
 	return r0
 }
 
 // GetAllUsers provides a mock function with given fields:
-func (_m *IUserService) GetAllUsers() ([]model.User, utils.MessageErr) {
+func (_m *IUserService) GetAllUsers() ([]model.User, error) {
 	ret := _m.Called()
 
 	var r0 []model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func() ([]model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.User, error)); ok {
 		return rf()
 	}
 	if rf, ok := ret.Get(0).(func() []model.User); ok {
@@ -47,24 +43,22 @@ func (_m *IUserService) GetAllUsers() ([]model.User, utils.MessageErr) {
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func() error); ok {
 		r1 = rf()
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
-		}
+		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
 
 // GetUser provides a mock function with given fields: id
-func (_m *IUserService) GetUser(id int64) (*model.User, utils.MessageErr) {
+func (_m *IUserService) GetUser(id int64) (*model.User, error) {
 	ret := _m.Called(id)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(int64) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*model.User, error)); ok {
 		return rf(id)
 	}
 	if rf, ok := ret.Get(0).(func(int64) *model.User); ok {
@@ -75,24 +69,74 @@ func (_m *IUserService) GetUser(id int64) (*model.User, utils.MessageErr) {
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
 		r1 = rf(id)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserByEmail provides a mock function with given fields: email
+func (_m *IUserService) GetUserByEmail(email string) (*model.User, error) {
+	ret := _m.Called(email)
+
+	var r0 *model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*model.User, error)); ok {
+		return rf(email)
+	}
+	if rf, ok := ret.Get(0).(func(string) *model.User); ok {
+		r0 = rf(email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUsersAfter provides a mock function with given fields: filter, afterId, limit
+func (_m *IUserService) ListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+	ret := _m.Called(filter, afterId, limit)
+
+	var r0 []model.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(model.UserFilter, int64, int) ([]model.User, error)); ok {
+		return rf(filter, afterId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(model.UserFilter, int64, int) []model.User); ok {
+		r0 = rf(filter, afterId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(model.UserFilter, int64, int) error); ok {
+		r1 = rf(filter, afterId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
 	return r0, r1
 }
 
 // SaveUser provides a mock function with given fields: user
-func (_m *IUserService) SaveUser(user *model.User) (*model.User, utils.MessageErr) {
+func (_m *IUserService) SaveUser(user *model.User) (*model.User, error) {
 	ret := _m.Called(user)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, error)); ok {
 		return rf(user)
 	}
 	if rf, ok := ret.Get(0).(func(*model.User) *model.User); ok {
@@ -103,24 +147,22 @@ func (_m *IUserService) SaveUser(user *model.User) (*model.User, utils.MessageEr
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(*model.User) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
 		r1 = rf(user)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
-		}
+		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
 
 // UpdateUser provides a mock function with given fields: user
-func (_m *IUserService) UpdateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (_m *IUserService) UpdateUser(user *model.User) (*model.User, error) {
 	ret := _m.Called(user)
 
 	var r0 *model.User
-	var r1 utils.MessageErr
-	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, utils.MessageErr)); ok {
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) (*model.User, error)); ok {
 		return rf(user)
 	}
 	if rf, ok := ret.Get(0).(func(*model.User) *model.User); ok {
@@ -131,14 +173,64 @@ func (_m *IUserService) UpdateUser(user *model.User) (*model.User, utils.Message
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(*model.User) utils.MessageErr); ok {
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
 		r1 = rf(user)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).(utils.MessageErr)
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateUser provides a mock function with given fields: user
+func (_m *IUserService) ValidateUser(user *model.User) (*model.ValidationResult, error) {
+	ret := _m.Called(user)
+
+	var r0 *model.ValidationResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) (*model.ValidationResult, error)); ok {
+		return rf(user)
+	}
+	if rf, ok := ret.Get(0).(func(*model.User) *model.ValidationResult); ok {
+		r0 = rf(user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ValidationResult)
 		}
 	}
 
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
+		r1 = rf(user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateUserField provides a mock function with given fields: user, field
+func (_m *IUserService) ValidateUserField(user *model.User, field string) (*model.ValidationResult, error) {
+	ret := _m.Called(user, field)
+
+	var r0 *model.ValidationResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User, string) (*model.ValidationResult, error)); ok {
+		return rf(user, field)
+	}
+	if rf, ok := ret.Get(0).(func(*model.User, string) *model.ValidationResult); ok {
+		r0 = rf(user, field)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ValidationResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.User, string) error); ok {
+		r1 = rf(user, field)
+	} else {
+		r1 = ret.Error(1)
+	}
+
 	return r0, r1
 }
 
@@ -13,10 +13,14 @@ type IUserValidationService struct {
 }
 
 // ValidateUser provides a mock function with given fields: user
-func (_m *IUserValidationService) ValidateUser(user *model.User) []string {
+func (_m *IUserValidationService) ValidateUser(user *model.User) ([]string, error) {
 	ret := _m.Called(user)
 
 	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) ([]string, error)); ok {
+		return rf(user)
+	}
 	if rf, ok := ret.Get(0).(func(*model.User) []string); ok {
 		r0 = rf(user)
 	} else {
@@ -25,7 +29,65 @@ func (_m *IUserValidationService) ValidateUser(user *model.User) []string {
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
+		r1 = rf(user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateUserField provides a mock function with given fields: user, field
+func (_m *IUserValidationService) ValidateUserField(user *model.User, field string) ([]model.FieldError, error) {
+	ret := _m.Called(user, field)
+
+	var r0 []model.FieldError
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User, string) ([]model.FieldError, error)); ok {
+		return rf(user, field)
+	}
+	if rf, ok := ret.Get(0).(func(*model.User, string) []model.FieldError); ok {
+		r0 = rf(user, field)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.FieldError)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.User, string) error); ok {
+		r1 = rf(user, field)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ValidateUserFields provides a mock function with given fields: user
+func (_m *IUserValidationService) ValidateUserFields(user *model.User) ([]model.FieldError, error) {
+	ret := _m.Called(user)
+
+	var r0 []model.FieldError
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.User) ([]model.FieldError, error)); ok {
+		return rf(user)
+	}
+	if rf, ok := ret.Get(0).(func(*model.User) []model.FieldError); ok {
+		r0 = rf(user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.FieldError)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.User) error); ok {
+		r1 = rf(user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // NewIUserValidationService creates a new instance of IUserValidationService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
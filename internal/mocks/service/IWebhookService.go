@@ -0,0 +1,145 @@
+// Code generated by mockery v2.36.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	mock "github.com/stretchr/testify/mock"
+	model "github.com/wexinc/ps-tag-onboarding-go/internal/model"
+)
+
+// IWebhookService is an autogenerated mock type for the IWebhookService type
+type IWebhookService struct {
+	mock.Mock
+}
+
+// CreateWebhook provides a mock function with given fields: request
+func (_m *IWebhookService) CreateWebhook(request *model.WebhookRequest) (*model.WebhookCreated, error) {
+	ret := _m.Called(request)
+
+	var r0 *model.WebhookCreated
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.WebhookRequest) (*model.WebhookCreated, error)); ok {
+		return rf(request)
+	}
+	if rf, ok := ret.Get(0).(func(*model.WebhookRequest) *model.WebhookCreated); ok {
+		r0 = rf(request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.WebhookCreated)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.WebhookRequest) error); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteWebhook provides a mock function with given fields: id
+func (_m *IWebhookService) DeleteWebhook(id int64) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListDeliveries provides a mock function with given fields: webhookId
+func (_m *IWebhookService) ListDeliveries(webhookId int64) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(webhookId)
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]model.WebhookDelivery, error)); ok {
+		return rf(webhookId)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []model.WebhookDelivery); ok {
+		r0 = rf(webhookId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(webhookId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListWebhooks provides a mock function with given fields:
+func (_m *IWebhookService) ListWebhooks() ([]model.Webhook, error) {
+	ret := _m.Called()
+
+	var r0 []model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]model.Webhook, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []model.Webhook); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Redeliver provides a mock function with given fields: webhookId, deliveryId
+func (_m *IWebhookService) Redeliver(webhookId int64, deliveryId int64) (*model.WebhookDelivery, error) {
+	ret := _m.Called(webhookId, deliveryId)
+
+	var r0 *model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64) (*model.WebhookDelivery, error)); ok {
+		return rf(webhookId, deliveryId)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64) *model.WebhookDelivery); ok {
+		r0 = rf(webhookId, deliveryId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64) error); ok {
+		r1 = rf(webhookId, deliveryId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewIWebhookService creates a new instance of IWebhookService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewIWebhookService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *IWebhookService {
+	mock := &IWebhookService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ApiKey represents a key used by services to call the API.
+// Only a hash of the secret part of the key is stored.
+// swagger:model
+type ApiKey struct {
+	Id         int64      `json:"id" xml:"id" yaml:"id" gorm:"primary_key"`
+	Name       string     `json:"name" xml:"name" yaml:"name"`
+	Prefix     string     `json:"prefix" xml:"prefix" yaml:"prefix" gorm:"uniqueIndex"`
+	Hash       string     `json:"-" xml:"-" yaml:"-"`
+	Scopes     []string   `json:"scopes" xml:"scopes>scope" yaml:"scopes" gorm:"serializer:json"`
+	CreatedAt  time.Time  `json:"created_at" xml:"created_at" yaml:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" xml:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" xml:"last_used_at,omitempty" yaml:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" xml:"revoked_at,omitempty" yaml:"revoked_at,omitempty"`
+}
+
+// ApiKeyRequest represents the key to create.
+// swagger:model
+type ApiKeyRequest struct {
+	Name      string     `json:"name" xml:"name" yaml:"name"`
+	Scopes    []string   `json:"scopes" xml:"scopes>scope" yaml:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" xml:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// ApiKeyCreated represents a newly created key, with the only copy of its plaintext value.
+// swagger:model
+type ApiKeyCreated struct {
+	ApiKey `yaml:",inline"`
+	Key    string `json:"key" xml:"key" yaml:"key"`
+}
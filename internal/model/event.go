@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of the user domain events
+const (
+	EVENT_USER_CREATED = "UserCreated"
+	EVENT_USER_UPDATED = "UserUpdated"
+	EVENT_USER_DELETED = "UserDeleted"
+)
+
+// Event is a domain event, stored in the outbox with the change it describes until it is dispatched.
+// swagger:model
+type Event struct {
+	Id         int64           `json:"id" gorm:"primary_key"`
+	Type       string          `json:"type"`
+	UserId     int64           `json:"user_id" gorm:"index"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data" gorm:"-"`
+	// Payload is Data as stored, encrypted when the personal data of users is
+	Payload   string `json:"-"`
+	Attempts  int    `json:"-"`
+	LastError string `json:"-"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// UserDeletedData is the data of a UserDeleted event, deleted users are not kept
+type UserDeletedData struct {
+	Id int64 `json:"id"`
+}
@@ -0,0 +1,25 @@
+package model
+
+// ImportReport represents the outcome of a bulk import of users.
+// Rows is the number of rows read, each of them being either imported or rejected.
+// swagger:model
+type ImportReport struct {
+	DryRun   bool `json:"dry_run" xml:"dry_run" yaml:"dry_run"`
+	Rows     int  `json:"rows" xml:"rows" yaml:"rows"`
+	Imported int  `json:"imported" xml:"imported" yaml:"imported"`
+	Rejected int  `json:"rejected" xml:"rejected" yaml:"rejected"`
+	// Errors lists every failure of the rejected rows, a row failing several rules having several errors
+	Errors []ImportError `json:"errors" xml:"errors>error" yaml:"errors"`
+	// Error tells why an import stopped before the end of the file, the users counted in Imported staying imported
+	Error string `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ImportError represents a failure of a rejected row, Row being its line in the imported file.
+// Field is empty when the whole row cannot be read.
+// swagger:model
+type ImportError struct {
+	Row     int    `json:"row" xml:"row" yaml:"row"`
+	Field   string `json:"field" xml:"field" yaml:"field"`
+	Code    string `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
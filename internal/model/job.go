@@ -0,0 +1,94 @@
+package model
+
+import "time"
+
+// Types of background jobs
+const (
+	JOB_IMPORT     = "import"
+	JOB_EXPORT     = "export"
+	JOB_PURGE      = "purge"
+	JOB_REVALIDATE = "revalidate"
+)
+
+// States of a background job
+const (
+	JOB_QUEUED    = "queued"
+	JOB_RUNNING   = "running"
+	JOB_SUCCEEDED = "succeeded"
+	JOB_FAILED    = "failed"
+	JOB_CANCELED  = "canceled"
+)
+
+// Job represents a long operation on users run in the background, with its progress and outcome.
+// swagger:model
+type Job struct {
+	Id     int64     `json:"id" xml:"id" yaml:"id" gorm:"primary_key"`
+	Type   string    `json:"type" xml:"type" yaml:"type"`
+	Status string    `json:"status" xml:"status" yaml:"status" gorm:"index"`
+	Params JobParams `json:"params" xml:"params" yaml:"params" gorm:"serializer:json"`
+	// Input is the file read by an import job
+	Input    string      `json:"-" xml:"-" yaml:"-"`
+	Progress JobProgress `json:"progress" xml:"progress" yaml:"progress" gorm:"embedded;embeddedPrefix:progress_"`
+	// Attempts counts the runs of the job, a failed run being retried until MaxAttempts
+	Attempts      int        `json:"attempts" xml:"attempts" yaml:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" xml:"next_attempt_at,omitempty" yaml:"next_attempt_at,omitempty"`
+	// CancelRequested is set on a running job asked to stop, until it does
+	CancelRequested bool       `json:"cancel_requested" xml:"cancel_requested" yaml:"cancel_requested"`
+	Error           string     `json:"error,omitempty" xml:"error,omitempty" yaml:"error,omitempty"`
+	Result          *JobResult `json:"result,omitempty" xml:"result,omitempty" yaml:"result,omitempty" gorm:"serializer:json"`
+	CreatedAt       time.Time  `json:"created_at" xml:"created_at" yaml:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty" xml:"started_at,omitempty" yaml:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty" xml:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+}
+
+// Finished tells whether the job reached a state it does not leave
+func (j *Job) Finished() bool {
+	return j.Status == JOB_SUCCEEDED || j.Status == JOB_FAILED || j.Status == JOB_CANCELED
+}
+
+// JobParams represents the parameters of a job, each type using its own
+type JobParams struct {
+	// Format is the file format of an import or an export
+	Format string `json:"format,omitempty" xml:"format,omitempty" yaml:"format,omitempty"`
+	// DryRun and Columns are the parameters of an import
+	DryRun  bool              `json:"dry_run,omitempty" xml:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	Columns map[string]string `json:"columns,omitempty" xml:"-" yaml:"columns,omitempty"`
+	// Filter selects the users of an export or a purge
+	Filter UserFilter `json:"filter" xml:"filter" yaml:"filter"`
+}
+
+// JobProgress represents how far a job went, Total being zero when it is not known in advance
+type JobProgress struct {
+	Done  int `json:"done" xml:"done" yaml:"done"`
+	Total int `json:"total,omitempty" xml:"total,omitempty" yaml:"total,omitempty"`
+}
+
+// JobResult represents the outcome of a succeeded job, in the field of its type.
+// swagger:model
+type JobResult struct {
+	Import *ImportReport `json:"import,omitempty" xml:"import,omitempty" yaml:"import,omitempty"`
+	// Exported is the number of users in the file of an export, downloaded from the job file
+	Exported int `json:"exported,omitempty" xml:"exported,omitempty" yaml:"exported,omitempty"`
+	// File is the name of the downloaded file of an export
+	File         string              `json:"file,omitempty" xml:"file,omitempty" yaml:"file,omitempty"`
+	Deleted      int                 `json:"deleted,omitempty" xml:"deleted,omitempty" yaml:"deleted,omitempty"`
+	Revalidation *RevalidationReport `json:"revalidation,omitempty" xml:"revalidation,omitempty" yaml:"revalidation,omitempty"`
+}
+
+// RevalidationReport represents the stored users that no longer pass validation, after the rules changed.
+// swagger:model
+type RevalidationReport struct {
+	Users   int `json:"users" xml:"users" yaml:"users"`
+	Invalid int `json:"invalid" xml:"invalid" yaml:"invalid"`
+	// Errors lists every failure of the invalid users, a user failing several rules having several errors
+	Errors []RevalidationError `json:"errors" xml:"errors>error" yaml:"errors"`
+}
+
+// RevalidationError represents a failed rule of a stored user.
+// swagger:model
+type RevalidationError struct {
+	UserId  int64  `json:"user_id" xml:"user_id" yaml:"user_id"`
+	Field   string `json:"field" xml:"field" yaml:"field"`
+	Code    string `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
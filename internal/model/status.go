@@ -0,0 +1,7 @@
+package model
+
+// StatusResponse represents the outcome of an operation that returns no resource.
+// swagger:model
+type StatusResponse struct {
+	Status string `json:"status" xml:"status" yaml:"status"`
+}
@@ -1,11 +1,48 @@
 package model
 
+import (
+	"github.com/wexinc/ps-tag-onboarding-go/internal/redact"
+	"strings"
+)
+
 // User represents a user.
 // swagger:model
 type User struct {
-	Id        int64  `json:"id" sql:"AUTO_INCREMENT" gorm:"primary_key"`
-	FirstName string `json:"first_name" validate:"required"`
-	LastName  string `json:"last_name" validate:"required"`
-	Email     string `json:"email" validate:"required,email,validateEmail"`
-	Age       int64  `json:"age" validate:"required,validateAge"`
+	Id        int64  `json:"id" xml:"id" yaml:"id" sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	FirstName string `json:"first_name" xml:"first_name" yaml:"first_name" validate:"required" pii:"name"`
+	LastName  string `json:"last_name" xml:"last_name" yaml:"last_name" validate:"required" pii:"name"`
+	Email     string `json:"email" xml:"email" yaml:"email" validate:"required,email,validateEmail" pii:"email"`
+	Age       int64  `json:"age" xml:"age" yaml:"age" validate:"required,validateAge"`
+}
+
+// String formats the user for logs, with its personal data redacted
+func (u User) String() string {
+	return redact.Format(u)
+}
+
+// UserFilter selects the users whose names and email contain the given texts, ignoring case, and whose age is within
+// the given bounds. Empty texts and zero bounds match every user.
+type UserFilter struct {
+	FirstName string `json:"first_name,omitempty" xml:"first_name,omitempty" yaml:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty" xml:"last_name,omitempty" yaml:"last_name,omitempty"`
+	Email     string `json:"email,omitempty" xml:"email,omitempty" yaml:"email,omitempty"`
+	MinAge    int64  `json:"min_age,omitempty" xml:"min_age,omitempty" yaml:"min_age,omitempty"`
+	MaxAge    int64  `json:"max_age,omitempty" xml:"max_age,omitempty" yaml:"max_age,omitempty"`
+}
+
+// Matches tells whether a user meets every criterion of the filter
+func (f UserFilter) Matches(user User) bool {
+	contains := func(value string, text string) bool {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(text))
+	}
+	if !contains(user.FirstName, f.FirstName) || !contains(user.LastName, f.LastName) || !contains(user.Email, f.Email) {
+		return false
+	}
+	if f.MinAge != 0 && user.Age < f.MinAge {
+		return false
+	}
+	if f.MaxAge != 0 && user.Age > f.MaxAge {
+		return false
+	}
+	return true
 }
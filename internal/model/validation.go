@@ -0,0 +1,18 @@
+package model
+
+// FieldError represents a validation failure on a single user field.
+// swagger:model
+type FieldError struct {
+	Field   string `json:"field" xml:"field" yaml:"field"`
+	Code    string `json:"code" xml:"code" yaml:"code"`
+	Message string `json:"message" xml:"message" yaml:"message"`
+}
+
+// ValidationResult represents the outcome of validating a user without saving it.
+// User holds the user as it would be saved, after normalization.
+// swagger:model
+type ValidationResult struct {
+	Valid  bool         `json:"valid" xml:"valid" yaml:"valid"`
+	Errors []FieldError `json:"errors" xml:"errors>error" yaml:"errors"`
+	User   *User        `json:"user,omitempty" xml:"user,omitempty" yaml:"user,omitempty"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// States of a webhook delivery
+const (
+	DELIVERY_PENDING   = "pending"
+	DELIVERY_DELIVERED = "delivered"
+	// DELIVERY_DEAD is the state of a delivery that failed too many times, it is only sent again when redelivered
+	DELIVERY_DEAD = "dead"
+)
+
+// Webhook represents a subscription of a target URL to user events.
+// swagger:model
+type Webhook struct {
+	Id  int64  `json:"id" xml:"id" yaml:"id" gorm:"primary_key"`
+	Url string `json:"url" xml:"url" yaml:"url"`
+	// Events are the types of the events sent to the URL, every type when empty
+	Events    []string  `json:"events" xml:"events>event" yaml:"events" gorm:"serializer:json"`
+	Secret    string    `json:"-" xml:"-" yaml:"-"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at" yaml:"created_at"`
+}
+
+// WebhookRequest represents the subscription to create.
+// swagger:model
+type WebhookRequest struct {
+	Url    string   `json:"url" xml:"url" yaml:"url"`
+	Events []string `json:"events" xml:"events>event" yaml:"events"`
+	// Secret signs the deliveries, a random one is generated when empty
+	Secret string `json:"secret,omitempty" xml:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// WebhookCreated represents a newly created subscription, with the only copy of its secret.
+// swagger:model
+type WebhookCreated struct {
+	Webhook `yaml:",inline"`
+	Secret  string `json:"secret" xml:"secret" yaml:"secret"`
+}
+
+// WebhookDelivery represents the sending of an event to a webhook, with the outcome of its last attempt.
+// swagger:model
+type WebhookDelivery struct {
+	Id             int64      `json:"id" xml:"id" yaml:"id" gorm:"primary_key"`
+	WebhookId      int64      `json:"webhook_id" xml:"webhook_id" yaml:"webhook_id" gorm:"uniqueIndex:idx_webhook_event"`
+	EventId        int64      `json:"event_id" xml:"event_id" yaml:"event_id" gorm:"uniqueIndex:idx_webhook_event"`
+	EventType      string     `json:"event_type" xml:"event_type" yaml:"event_type"`
+	Payload        string     `json:"-" xml:"-" yaml:"-"`
+	Status         string     `json:"status" xml:"status" yaml:"status" gorm:"index"`
+	Attempts       int        `json:"attempts" xml:"attempts" yaml:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" xml:"next_attempt_at,omitempty" yaml:"next_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty" xml:"last_status_code,omitempty" yaml:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty" xml:"last_error,omitempty" yaml:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" xml:"created_at" yaml:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" xml:"delivered_at,omitempty" yaml:"delivered_at,omitempty"`
+}
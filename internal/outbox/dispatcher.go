@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"time"
+)
+
+// Default settings of the dispatcher
+const (
+	DefaultInterval  = time.Second
+	DefaultBatchSize = 100
+)
+
+// Dispatcher sends the events of the outbox to a sink, at least once and in order for each user.
+// An event is removed from the outbox once sent; when it cannot be sent, the later events of its user wait for it
+// while the events of other users are still sent.
+// A single dispatcher must run for an outbox, several would send events out of order.
+type Dispatcher struct {
+	Repository repository.IOutboxRepository
+	Sink       ISink
+	// Interval is how often the outbox is checked, DefaultInterval when zero
+	Interval time.Duration
+	// BatchSize is the number of events read at once, DefaultBatchSize when zero
+	BatchSize int
+}
+
+// Run dispatches the pending events every Interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Dispatch(ctx); err != nil {
+			log.Error.Printf("Unable to dispatch events: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends the pending events and returns how many were sent.
+// The outbox is read once through, batch after batch, so that failed events do not hide the events after them.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	sent := 0
+	blocked := map[int64]bool{}
+	afterId := int64(0)
+	for {
+		events, err := d.Repository.DbPendingEvents(afterId, batchSize)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, event := range events {
+			afterId = event.Id
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+			if blocked[event.UserId] {
+				continue
+			}
+
+			if err := d.Sink.Send(ctx, event); err != nil {
+				log.Error.Printf("Unable to send event %v %v of user %v, attempt %v: %v", event.Id, event.Type, event.UserId, event.Attempts+1, err)
+				blocked[event.UserId] = true
+				if err := d.Repository.DbFailEvent(event.Id, err.Error()); err != nil {
+					return sent, err
+				}
+				continue
+			}
+			// the event is sent again when it cannot be removed
+			if err := d.Repository.DbDeleteEvent(event.Id); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+
+		if len(events) < batchSize {
+			return sent, nil
+		}
+	}
+}
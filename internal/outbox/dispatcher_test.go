@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type outboxRepositoryMock struct {
+	events []model.Event
+}
+
+func (orm *outboxRepositoryMock) DbPendingEvents(afterId int64, limit int) ([]model.Event, error) {
+	events := []model.Event{}
+	for _, event := range orm.events {
+		if event.Id > afterId && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (orm *outboxRepositoryMock) DbDeleteEvent(id int64) error {
+	for i, event := range orm.events {
+		if event.Id == id {
+			orm.events = append(orm.events[:i], orm.events[i+1:]...)
+		}
+	}
+	return nil
+}
+
+func (orm *outboxRepositoryMock) DbFailEvent(id int64, reason string) error {
+	for i := range orm.events {
+		if orm.events[i].Id == id {
+			orm.events[i].Attempts++
+			orm.events[i].LastError = reason
+		}
+	}
+	return nil
+}
+
+type sinkMock struct {
+	failingUsers map[int64]bool
+	sent         []int64
+}
+
+func (sm *sinkMock) Send(ctx context.Context, event model.Event) error {
+	if sm.failingUsers[event.UserId] {
+		return errors.New("sink unavailable")
+	}
+	sm.sent = append(sm.sent, event.Id)
+	return nil
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	repository := &outboxRepositoryMock{events: []model.Event{
+		{Id: 1, UserId: 10, Type: model.EVENT_USER_CREATED},
+		{Id: 2, UserId: 20, Type: model.EVENT_USER_CREATED},
+		{Id: 3, UserId: 10, Type: model.EVENT_USER_UPDATED},
+		{Id: 4, UserId: 30, Type: model.EVENT_USER_CREATED},
+		{Id: 5, UserId: 20, Type: model.EVENT_USER_UPDATED},
+		{Id: 6, UserId: 20, Type: model.EVENT_USER_DELETED},
+	}}
+	sink := &sinkMock{failingUsers: map[int64]bool{20: true}}
+	dispatcher := &Dispatcher{Repository: repository, Sink: sink, BatchSize: 2}
+
+	sent, err := dispatcher.Dispatch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, []int64{1, 3, 4}, sink.sent)
+	assert.Len(t, repository.events, 3, "the events of a user wait for its failed event")
+	assert.Equal(t, int64(2), repository.events[0].Id)
+	assert.Equal(t, 1, repository.events[0].Attempts)
+	assert.Equal(t, "sink unavailable", repository.events[0].LastError)
+	assert.Equal(t, 0, repository.events[1].Attempts, "later events of a failed user are not tried")
+
+	sink.failingUsers = nil
+	sent, err = dispatcher.Dispatch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, []int64{1, 3, 4, 2, 5, 6}, sink.sent)
+	assert.Empty(t, repository.events)
+}
+
+func TestDispatcher_Dispatch_PoisonedHead(t *testing.T) {
+	// Given
+	repository := &outboxRepositoryMock{events: []model.Event{
+		{Id: 1, UserId: 10, Type: model.EVENT_USER_CREATED},
+		{Id: 2, UserId: 10, Type: model.EVENT_USER_UPDATED},
+		{Id: 3, UserId: 10, Type: model.EVENT_USER_UPDATED},
+		{Id: 4, UserId: 10, Type: model.EVENT_USER_UPDATED},
+		{Id: 5, UserId: 20, Type: model.EVENT_USER_CREATED},
+		{Id: 6, UserId: 30, Type: model.EVENT_USER_CREATED},
+	}}
+	sink := &sinkMock{failingUsers: map[int64]bool{10: true}}
+	dispatcher := &Dispatcher{Repository: repository, Sink: sink, BatchSize: 2}
+
+	// When
+	sent, err := dispatcher.Dispatch(context.Background())
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []int64{5, 6}, sink.sent, "the events of other users are sent past the failing ones")
+	assert.Len(t, repository.events, 4)
+	assert.Equal(t, 1, repository.events[0].Attempts)
+	assert.Equal(t, 0, repository.events[1].Attempts)
+
+	repository.events = append(repository.events, model.Event{Id: 7, UserId: 20, Type: model.EVENT_USER_UPDATED})
+	sent, err = dispatcher.Dispatch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent, "new events are sent while the head is still failing")
+	assert.Equal(t, []int64{5, 6, 7}, sink.sent)
+	assert.Equal(t, 2, repository.events[0].Attempts)
+}
+
+func TestSinks(t *testing.T) {
+	event := model.Event{Id: 1, Type: model.EVENT_USER_DELETED, UserId: 7, Data: []byte(`{"id":7}`), Payload: "enc:k1:secret"}
+	expected := `{"id":1,"type":"UserDeleted","user_id":7,"occurred_at":"0001-01-01T00:00:00Z","data":{"id":7}}` + "\n"
+
+	var stdout bytes.Buffer
+	assert.Nil(t, (&StdoutSink{Writer: &stdout}).Send(context.Background(), event))
+	assert.Equal(t, expected, stdout.String())
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	fileSink := &FileSink{Path: path}
+	assert.Nil(t, fileSink.Send(context.Background(), event))
+	assert.Nil(t, fileSink.Send(context.Background(), event))
+	assert.Nil(t, fileSink.Close())
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expected+expected, string(content))
+}
@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"io"
+	"os"
+	"sync"
+)
+
+// Names of the built-in sinks, used to configure where events are dispatched
+const (
+	SINK_STDOUT = "stdout"
+	SINK_FILE   = "file"
+)
+
+// ISink delivers events to their consumers. Send returns once the event is delivered, events may be sent more than once.
+type ISink interface {
+	Send(ctx context.Context, event model.Event) error
+}
+
+// StdoutSink writes events as NDJSON, one event per line
+type StdoutSink struct {
+	// Writer receives the events, os.Stdout is used when nil
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func (ss *StdoutSink) Send(ctx context.Context, event model.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	writer := ss.Writer
+	if writer == nil {
+		writer = os.Stdout
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	_, err = writer.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends events to an NDJSON file, synced to disk before Send returns
+type FileSink struct {
+	Path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (fs *FileSink) Send(ctx context.Context, event model.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		file, err := os.OpenFile(fs.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		fs.file = file
+	}
+	if _, err := fs.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+// Close closes the file, which is opened again by the next Send
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.file.Close()
+	fs.file = nil
+	return err
+}
+
+// MultiSink sends every event to each of its sinks, in order. An event that one of them fails to receive is sent to
+// all of them again.
+type MultiSink []ISink
+
+func (ms MultiSink) Send(ctx context.Context, event model.Event) error {
+	for _, sink := range ms {
+		if err := sink.Send(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests per Period, as a token bucket holding Requests tokens and refilled continuously
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token, set when the request is not allowed
+	RetryAfter time.Duration
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// String formats the limit as parsed by ParseLimit, e.g. "30/1m0s"
+func (l Limit) String() string {
+	return fmt.Sprintf("%v/%v", l.Requests, l.Period)
+}
+
+// tokensPerSecond is the refill rate of the bucket
+func (l Limit) tokensPerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Take refills a bucket holding tokens for the elapsed time, then takes a token if one is left.
+// It returns the tokens left in the bucket, so that stores only have to keep the token count and its update time.
+func (l Limit) Take(tokens float64, elapsed time.Duration) (float64, Result) {
+
+	tokens = math.Min(float64(l.Requests), tokens+elapsed.Seconds()*l.tokensPerSecond())
+
+	result := Result{Limit: l.Requests}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - tokens)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = l.duration(float64(l.Requests) - tokens)
+	return tokens, result
+}
+
+// duration returns the time needed to refill the given number of tokens
+func (l Limit) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.tokensPerSecond() * float64(time.Second)))
+}
+
+// ParseLimit reads a limit written as "<requests>/<period>", e.g. "30/1m"
+func ParseLimit(value string) (Limit, error) {
+
+	requests, period, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return Limit{}, fmt.Errorf("limit %q should be written as <requests>/<period>", value)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("limit %q should allow a positive number of requests", value)
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || duration <= 0 {
+		return Limit{}, fmt.Errorf("limit %q should have a positive period", value)
+	}
+	return Limit{Requests: count, Period: duration}, nil
+}
+
+// Rule applies a limit to the requests of a route.
+// Method matches any method when empty, and Path matches every path under it when it ends with "/*".
+type Rule struct {
+	Method string
+	Path   string
+	Limit  Limit
+}
+
+// ParseRule reads a rule written as "[<method>] <path>=<limit>", e.g. "POST /users=30/1m"
+func ParseRule(value string) (Rule, error) {
+
+	route, limit, found := strings.Cut(value, "=")
+	if !found {
+		return Rule{}, fmt.Errorf("rule %q should be written as [<method>] <path>=<limit>", value)
+	}
+
+	rule := Rule{}
+	fields := strings.Fields(route)
+	switch len(fields) {
+	case 1:
+		rule.Path = fields[0]
+	case 2:
+		rule.Method, rule.Path = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return Rule{}, fmt.Errorf("rule %q should be written as [<method>] <path>=<limit>", value)
+	}
+	if !strings.HasPrefix(rule.Path, "/") {
+		return Rule{}, errors.New("rule path should start with /")
+	}
+
+	var err error
+	if rule.Limit, err = ParseLimit(limit); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule applies to the request, trailing slashes are ignored
+func (rule Rule) Matches(r *http.Request) bool {
+	return rule.MatchesRoute(r.Method, r.URL.Path)
+}
+
+// MatchesRoute reports whether the rule applies to the requests with the given method and path
+func (rule Rule) MatchesRoute(method string, path string) bool {
+
+	if rule.Method != "" && rule.Method != method {
+		return false
+	}
+	path = strings.TrimSuffix(path, "/")
+	if prefix, ok := strings.CutSuffix(rule.Path, "/*"); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == strings.TrimSuffix(rule.Path, "/")
+}
+
+func (rule Rule) String() string {
+	return strings.TrimSpace(rule.Method + " " + rule.Path)
+}
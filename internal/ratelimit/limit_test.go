@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		value string
+		rule  Rule
+		err   bool
+	}{
+		{value: "POST /users=30/1m", rule: Rule{Method: "POST", Path: "/users", Limit: Limit{Requests: 30, Period: time.Minute}}},
+		{value: "delete /users/*= 5/10s", rule: Rule{Method: "DELETE", Path: "/users/*", Limit: Limit{Requests: 5, Period: 10 * time.Second}}},
+		{value: "/apikeys/*=10/1h", rule: Rule{Path: "/apikeys/*", Limit: Limit{Requests: 10, Period: time.Hour}}},
+		{value: "POST /users", err: true},
+		{value: "POST users=30/1m", err: true},
+		{value: "POST /users=30", err: true},
+		{value: "POST /users=0/1m", err: true},
+		{value: "POST /users=30/-1m", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rule, err := ParseRule(tt.value)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.rule, rule)
+		})
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	exact := Rule{Method: "POST", Path: "/users"}
+	prefix := Rule{Path: "/users/*"}
+	tests := []struct {
+		rule    Rule
+		method  string
+		path    string
+		matches bool
+	}{
+		{rule: exact, method: "POST", path: "/users", matches: true},
+		{rule: exact, method: "POST", path: "/users/", matches: true},
+		{rule: exact, method: "GET", path: "/users", matches: false},
+		{rule: exact, method: "POST", path: "/users/validate", matches: false},
+		{rule: prefix, method: "GET", path: "/users", matches: true},
+		{rule: prefix, method: "DELETE", path: "/users/3", matches: true},
+		{rule: prefix, method: "GET", path: "/usersearch", matches: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule.String()+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.matches, tt.rule.Matches(httptest.NewRequest(tt.method, tt.path, nil)))
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &MemoryStore{Now: func() time.Time { return now }}
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+	take := func(key string) Result {
+		result, err := store.Take(context.Background(), key, limit)
+		assert.Nil(t, err)
+		return result
+	}
+
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}, take("a"))
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}, take("a"))
+	assert.Equal(t, Result{Allowed: false, Limit: 2, Remaining: 0, Reset: 10 * time.Second, RetryAfter: 5 * time.Second}, take("a"))
+	assert.True(t, take("b").Allowed, "each key has its own bucket")
+
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}, take("a"))
+
+	now = now.Add(time.Hour)
+	take("c")
+	assert.Len(t, store.buckets, 1, "full buckets are swept")
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limiter throttles the requests of each client, answering 429 once its bucket is empty
+type Limiter struct {
+	Store IStore
+	// Default applies to the requests matched by no rule, which are not limited when it is zero
+	Default Limit
+	// Rules set the limits of some routes, the first matching rule is used and has its own bucket
+	Rules []Rule
+}
+
+// Middleware limits the requests and describes the limit in RateLimit-* headers.
+// Requests are let through when the store fails, so that an outage of a shared store does not stop the API.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		limit, result := l.Take(r.Context(), r.Method, r.URL.Path, ClientKey(r))
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%v;w=%v", limit.Requests, seconds(limit.Period)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", seconds(result.RetryAfter))
+			utils.ResponseLocalizedError(w, r, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Take takes a token for a request of a client from the bucket of the first rule matching the method and path of the
+// request, or of the default limit. The limit returned is unlimited, and the request allowed, when no limit applies or
+// when the store fails.
+func (l *Limiter) Take(ctx context.Context, method string, path string, client string) (Limit, Result) {
+
+	limit, route := l.Default, "*"
+	for _, rule := range l.Rules {
+		if rule.MatchesRoute(method, path) {
+			limit, route = rule.Limit, rule.String()
+			break
+		}
+	}
+	if limit.Unlimited() {
+		return Limit{}, Result{Allowed: true}
+	}
+
+	result, err := l.Store.Take(ctx, route+"|"+client, limit)
+	if err != nil {
+		log.Error.Printf("Unable to apply rate limit: %v", err)
+		return Limit{}, Result{Allowed: true}
+	}
+	if !result.Allowed {
+		log.Info.Printf("Rate limit %v exceeded on %v by %v", limit, route, client)
+	}
+	return limit, result
+}
+
+// ClientKey identifies the caller of a request: the subject of its token or api key once authenticated, its IP otherwise
+func ClientKey(r *http.Request) string {
+	return Key(r.Context(), r.RemoteAddr)
+}
+
+// Key identifies a caller from the claims on its context, or from its address when it is not authenticated
+func Key(ctx context.Context, remoteAddr string) string {
+	if claims, ok := auth.ClaimsFromContext(ctx); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds a duration up to whole seconds, as the RateLimit-* and Retry-After headers expect
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
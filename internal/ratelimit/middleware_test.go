@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type storeMock struct{}
+
+func (sm *storeMock) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := &Limiter{
+		Store:   &MemoryStore{Now: func() time.Time { return now }},
+		Default: Limit{Requests: 3, Period: time.Minute},
+		Rules:   []Rule{{Method: http.MethodPost, Path: "/users", Limit: Limit{Requests: 1, Period: time.Minute}}},
+	}
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	call := func(method string, remoteAddr string, subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", nil)
+		req.RemoteAddr = remoteAddr
+		if subject != "" {
+			req = req.WithContext(auth.NewContext(req.Context(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call(http.MethodPost, "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", rr.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=60", rr.Header().Get("RateLimit-Policy"))
+
+	rr = call(http.MethodPost, "10.0.0.1:5678", "")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Equal(t, `{"code":429,"message":"Request rate too high, requests from this this user are throttled."}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "10.0.0.2:1234", "").Code, "other clients have their own bucket")
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "10.0.0.1:1234", "apikey:1").Code, "authenticated callers are keyed by subject")
+
+	rr = call(http.MethodGet, "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "routes matched by no rule use the default limit")
+	assert.Equal(t, "3", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "2", rr.Header().Get("RateLimit-Remaining"))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "10.0.0.1:1234", "").Code, "buckets are refilled over time")
+
+	limiter.Store = &storeMock{}
+	rr = call(http.MethodPost, "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusOK, rr.Code, "requests are let through when the store fails")
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+type authenticatorMock struct {
+	calls int
+}
+
+func (am *authenticatorMock) Scheme() string { return "Bearer" }
+
+func (am *authenticatorMock) Authenticate(ctx context.Context, credentials string) (*auth.Claims, error) {
+	am.calls++
+	return nil, errors.New("invalid signature")
+}
+
+func TestLimiter_Middleware_BeforeAuthentication(t *testing.T) {
+	// Given
+	ipLimiter := &Limiter{Store: &MemoryStore{}, Default: Limit{Requests: 2, Period: time.Minute}}
+	authenticator := &authenticatorMock{}
+	handler := ipLimiter.Middleware(auth.Middleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// When
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Authorization", "Bearer forged")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+
+	// Then
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, 2, authenticator.calls, "tokens are not verified once the IP is limited")
+}
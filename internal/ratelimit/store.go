@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IStore keeps the token buckets, a store shared by several instances can replace the in-memory one
+type IStore interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how often the memory store drops the buckets that are full again
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryStore keeps the token buckets of a single instance in memory
+type MemoryStore struct {
+	// Now returns the current time, time.Now is used when nil
+	Now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func (ms *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+
+	now := time.Now()
+	if ms.Now != nil {
+		now = ms.Now()
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.buckets == nil {
+		ms.buckets = map[string]*bucket{}
+	}
+	if now.Sub(ms.lastSweep) >= sweepInterval {
+		ms.sweep(now)
+	}
+
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		ms.buckets[key] = b
+	}
+
+	tokens, result := limit.Take(b.tokens, now.Sub(b.updated))
+	b.tokens, b.updated, b.full = tokens, now, now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops the buckets that are full again, a missing bucket behaves the same
+func (ms *MemoryStore) sweep(now time.Time) {
+	for key, b := range ms.buckets {
+		if !now.Before(b.full) {
+			delete(ms.buckets, key)
+		}
+	}
+	ms.lastSweep = now
+}
@@ -0,0 +1,125 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Modes of a Redactor
+const (
+	// MODE_MASK keeps the first letter of each value, and the domain of emails, e.g. "j***@yahoo.com"
+	MODE_MASK = "mask"
+	// MODE_HASH replaces each value by a keyed hash, so that log lines about the same person can be correlated
+	MODE_HASH = "hash"
+	// MODE_OFF logs values as they are, only meant for local development
+	MODE_OFF = "off"
+)
+
+// Kinds of personal data, set as the value of the pii tag of a field, e.g. `pii:"email"`
+const (
+	KIND_EMAIL = "email"
+	KIND_NAME  = "name"
+)
+
+const mask = "***"
+
+// hashLength is the number of hex characters kept from a hash, enough to tell people apart in logs
+const hashLength = 16
+
+// Redactor hides the personal data of the values formatted for logs
+type Redactor struct {
+	Mode string
+	// Key is the HMAC key of MODE_HASH, without which the hashes of known values could be recomputed
+	Key []byte
+}
+
+var current atomic.Pointer[Redactor]
+
+func init() {
+	current.Store(&Redactor{Mode: MODE_MASK})
+}
+
+// Configure sets the redactor used by Format
+func Configure(redactor *Redactor) {
+	current.Store(redactor)
+}
+
+// Format formats a struct for logs, see Redactor.Format
+func Format(value interface{}) string {
+	return current.Load().Format(value)
+}
+
+// Format formats a struct for logs as {name:value ...}, with the json names of its fields
+// and the string fields tagged `pii` redacted
+func (rd *Redactor) Format(value interface{}) string {
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "<nil>"
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Sprint(value)
+	}
+
+	fields := []string{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		formatted := fmt.Sprint(v.Field(i).Interface())
+		if kind, ok := field.Tag.Lookup("pii"); ok && v.Field(i).Kind() == reflect.String {
+			formatted = rd.Redact(kind, v.Field(i).String())
+		}
+		fields = append(fields, name+":"+formatted)
+	}
+	return "{" + strings.Join(fields, " ") + "}"
+}
+
+// Redact hides a value of the given kind of personal data according to the mode
+func (rd *Redactor) Redact(kind string, value string) string {
+
+	if value == "" {
+		return value
+	}
+
+	switch rd.Mode {
+	case MODE_OFF:
+		return value
+	case MODE_HASH:
+		// values are compared case-insensitively, as emails are
+		h := hmac.New(sha256.New, rd.Key)
+		h.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+		return "#" + hex.EncodeToString(h.Sum(nil))[:hashLength]
+	}
+
+	if local, domain, found := strings.Cut(value, "@"); found && kind == KIND_EMAIL {
+		return firstRune(local) + mask + "@" + domain
+	}
+	return firstRune(value) + mask
+}
+
+func firstRune(value string) string {
+	r, size := utf8.DecodeRuneInString(value)
+	if r == utf8.RuneError {
+		return ""
+	}
+	return value[:size]
+}
@@ -0,0 +1,70 @@
+package redact
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type person struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name,omitempty" pii:"name"`
+	Email  string `json:"email" pii:"email"`
+	Secret string `json:"-"`
+	Age    int64
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	masking := &Redactor{Mode: MODE_MASK}
+	hashing := &Redactor{Mode: MODE_HASH, Key: []byte("0123456789abcdef")}
+	tests := []struct {
+		name     string
+		redactor *Redactor
+		kind     string
+		value    string
+		redacted string
+	}{
+		{name: "Email", redactor: masking, kind: KIND_EMAIL, value: "john.doe@yahoo.com", redacted: "j***@yahoo.com"},
+		{name: "Email without domain", redactor: masking, kind: KIND_EMAIL, value: "john.doe", redacted: "j***"},
+		{name: "Name", redactor: masking, kind: KIND_NAME, value: "Doe", redacted: "D***"},
+		{name: "Name with accent", redactor: masking, kind: KIND_NAME, value: "Élodie", redacted: "É***"},
+		{name: "Name with at sign", redactor: masking, kind: KIND_NAME, value: "doe@home", redacted: "d***"},
+		{name: "Empty", redactor: masking, kind: KIND_NAME, value: "", redacted: ""},
+		{name: "Off", redactor: &Redactor{Mode: MODE_OFF}, kind: KIND_EMAIL, value: "john.doe@yahoo.com", redacted: "john.doe@yahoo.com"},
+		{name: "Hash", redactor: hashing, kind: KIND_EMAIL, value: "john.doe@yahoo.com", redacted: "#a9efc689beca4d73"},
+		{name: "Hash ignores case", redactor: hashing, kind: KIND_EMAIL, value: " John.Doe@Yahoo.com", redacted: "#a9efc689beca4d73"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.redacted, tt.redactor.Redact(tt.kind, tt.value))
+		})
+	}
+
+	assert.NotEqual(t, hashing.Redact(KIND_EMAIL, "john.doe@yahoo.com"), (&Redactor{Mode: MODE_HASH, Key: []byte("other")}).Redact(KIND_EMAIL, "john.doe@yahoo.com"))
+}
+
+func TestRedactor_Format(t *testing.T) {
+	redactor := &Redactor{Mode: MODE_MASK}
+	p := person{Id: 1, Name: "John", Email: "john.doe@yahoo.com", Secret: "s3cret", Age: 34}
+
+	assert.Equal(t, "{id:1 name:J*** email:j***@yahoo.com Age:34}", redactor.Format(p))
+	assert.Equal(t, "{id:1 name:J*** email:j***@yahoo.com Age:34}", redactor.Format(&p))
+	assert.Equal(t, "<nil>", redactor.Format((*person)(nil)))
+	assert.Equal(t, "42", redactor.Format(42))
+}
+
+type formatted person
+
+func (f formatted) String() string {
+	return Format(f)
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(&Redactor{Mode: MODE_MASK})
+	f := formatted{Id: 1, Name: "John", Email: "john.doe@yahoo.com"}
+
+	assert.Equal(t, "[{id:1 name:J*** email:j***@yahoo.com Age:0}]", fmt.Sprintf("%v", []formatted{f}))
+
+	Configure(&Redactor{Mode: MODE_OFF})
+	assert.Equal(t, "{id:1 name:John email:john.doe@yahoo.com Age:0}", fmt.Sprint(f))
+}
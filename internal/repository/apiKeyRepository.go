@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	API_KEY_NOT_FOUND        = "api key not found with id %v"
+	API_KEY_PREFIX_NOT_FOUND = "api key not found with prefix %v"
+)
+
+type IApiKeyRepository interface {
+	DbListApiKeys() ([]model.ApiKey, error)
+	DbCreateApiKey(key *model.ApiKey) (*model.ApiKey, error)
+	DbGetApiKeyByPrefix(prefix string) (*model.ApiKey, error)
+	DbRevokeApiKey(id int64, revokedAt time.Time) error
+	DbTouchApiKey(id int64, usedAt time.Time) error
+}
+
+type ApiKeyRepository struct {
+	DB *gorm.DB
+}
+
+func (akr *ApiKeyRepository) DbListApiKeys() ([]model.ApiKey, error) {
+
+	keys := []model.ApiKey{}
+
+	if err := akr.DB.Order("id").Find(&keys).Error; err != nil {
+		return nil, mapDbError(err, "no api keys found")
+	}
+
+	return keys, nil
+}
+
+func (akr *ApiKeyRepository) DbCreateApiKey(key *model.ApiKey) (*model.ApiKey, error) {
+
+	if err := akr.DB.Create(key).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(API_KEY_NOT_FOUND, key.Id))
+	}
+
+	return key, nil
+}
+
+func (akr *ApiKeyRepository) DbGetApiKeyByPrefix(prefix string) (*model.ApiKey, error) {
+
+	var key model.ApiKey
+
+	if err := akr.DB.Where("prefix = ?", prefix).Take(&key).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(API_KEY_PREFIX_NOT_FOUND, prefix))
+	}
+
+	return &key, nil
+}
+
+// DbRevokeApiKey marks a key as revoked, revoking a key twice keeps the first revocation time
+func (akr *ApiKeyRepository) DbRevokeApiKey(id int64, revokedAt time.Time) error {
+
+	var key model.ApiKey
+	if err := akr.DB.Take(&key, id).Error; err != nil {
+		return mapDbError(err, fmt.Sprintf(API_KEY_NOT_FOUND, id))
+	}
+
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	if err := akr.DB.Model(&key).Update("revoked_at", revokedAt).Error; err != nil {
+		return mapDbError(err, fmt.Sprintf(API_KEY_NOT_FOUND, id))
+	}
+
+	return nil
+}
+
+func (akr *ApiKeyRepository) DbTouchApiKey(id int64, usedAt time.Time) error {
+
+	result := akr.DB.Model(&model.ApiKey{}).Where("id = ?", id).Update("last_used_at", usedAt)
+	if result.Error != nil {
+		return mapDbError(result.Error, fmt.Sprintf(API_KEY_NOT_FOUND, id))
+	}
+
+	if result.RowsAffected == 0 {
+		return apperror.NotFound(fmt.Sprintf(API_KEY_NOT_FOUND, id), nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// FIELD_JOB_INPUT binds the encrypted input files of jobs, which hold personal data, to their column
+const FIELD_JOB_INPUT = "jobs.input"
+
+const (
+	JOB_NOT_FOUND = "job not found with id %v"
+	NO_JOB_FOUND  = "no jobs found"
+)
+
+type IJobRepository interface {
+	DbCreateJob(job *model.Job) (*model.Job, error)
+	// DbGetJob returns a job without its input
+	DbGetJob(id int64) (*model.Job, error)
+	// DbListJobs returns the jobs without their input, the latest first
+	DbListJobs() ([]model.Job, error)
+	// DbClaimJob marks the oldest queued job that is due as running and returns it with its input, or nil when no job
+	// is due. A job is claimed once even when several workers ask at the same time.
+	DbClaimJob(now time.Time) (*model.Job, error)
+	// DbUpdateJob records the state, progress and outcome of a job
+	DbUpdateJob(job *model.Job) error
+	// DbUpdateProgress records how far a running job went
+	DbUpdateProgress(id int64, progress model.JobProgress) error
+	// DbCancelJob cancels a queued job, or asks a running job to stop
+	DbCancelJob(id int64, now time.Time) (*model.Job, error)
+	// DbRequeueRunningJobs queues again the jobs left running by a stopped runner, and returns how many there were.
+	// The jobs asked to stop are canceled instead.
+	DbRequeueRunningJobs(now time.Time) (int, error)
+	// DbDeleteFinishedJobs deletes the jobs finished before a time, and returns how many there were
+	DbDeleteFinishedJobs(before time.Time) (int, error)
+}
+
+type JobRepository struct {
+	DB *gorm.DB
+	// Cipher encrypts the input files of jobs
+	Cipher fieldcrypt.ICipher
+}
+
+func (jr *JobRepository) DbCreateJob(job *model.Job) (*model.Job, error) {
+
+	stored := *job
+	if jr.Cipher != nil && stored.Input != "" {
+		encrypted, err := jr.Cipher.Encrypt(FIELD_JOB_INPUT, stored.Input)
+		if err != nil {
+			return nil, apperror.Internal(DB_ERROR, err)
+		}
+		stored.Input = encrypted
+	}
+	if err := jr.DB.Create(&stored).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(JOB_NOT_FOUND, job.Id))
+	}
+
+	job.Id, job.CreatedAt = stored.Id, stored.CreatedAt
+	return job, nil
+}
+
+func (jr *JobRepository) DbGetJob(id int64) (*model.Job, error) {
+
+	var job model.Job
+
+	if err := jr.DB.Omit("input").Take(&job, id).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(JOB_NOT_FOUND, id))
+	}
+
+	return &job, nil
+}
+
+func (jr *JobRepository) DbListJobs() ([]model.Job, error) {
+
+	jobs := []model.Job{}
+
+	if err := jr.DB.Omit("input").Order("id desc").Find(&jobs).Error; err != nil {
+		return nil, mapDbError(err, NO_JOB_FOUND)
+	}
+
+	return jobs, nil
+}
+
+func (jr *JobRepository) DbClaimJob(now time.Time) (*model.Job, error) {
+
+	var job model.Job
+	err := jr.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", model.JOB_QUEUED, now).
+			Order("id").Take(&job).Error
+		if err != nil {
+			return err
+		}
+		// another worker may claim the job between the read and the update, only the first update changes it
+		result := tx.Model(&model.Job{}).Where("id = ? AND status = ?", job.Id, model.JOB_QUEUED).
+			Updates(map[string]interface{}{"status": model.JOB_RUNNING, "attempts": job.Attempts + 1, "started_at": now, "next_attempt_at": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, mapDbError(err, NO_JOB_FOUND)
+	}
+
+	job.Status, job.Attempts, job.StartedAt, job.NextAttemptAt = model.JOB_RUNNING, job.Attempts+1, &now, nil
+	if jr.Cipher != nil && job.Input != "" {
+		decrypted, err := jr.Cipher.Decrypt(FIELD_JOB_INPUT, job.Input)
+		if err != nil {
+			return nil, apperror.Internal(fmt.Sprintf(DB_FIELD_DECRYPTION_ERROR, FIELD_JOB_INPUT, job.Id), err)
+		}
+		job.Input = decrypted
+	}
+	return &job, nil
+}
+
+func (jr *JobRepository) DbUpdateJob(job *model.Job) error {
+
+	err := jr.DB.Model(&model.Job{}).Where("id = ?", job.Id).
+		Select("status", "progress_done", "progress_total", "attempts", "next_attempt_at", "error", "result", "finished_at").
+		Updates(job).Error
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(JOB_NOT_FOUND, job.Id))
+	}
+
+	return nil
+}
+
+func (jr *JobRepository) DbUpdateProgress(id int64, progress model.JobProgress) error {
+
+	err := jr.DB.Model(&model.Job{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"progress_done": progress.Done, "progress_total": progress.Total}).Error
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(JOB_NOT_FOUND, id))
+	}
+
+	return nil
+}
+
+func (jr *JobRepository) DbCancelJob(id int64, now time.Time) (*model.Job, error) {
+
+	err := jr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Job{}).Where("id = ? AND status = ?", id, model.JOB_QUEUED).
+			Updates(map[string]interface{}{"status": model.JOB_CANCELED, "next_attempt_at": nil, "finished_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Job{}).Where("id = ? AND status = ?", id, model.JOB_RUNNING).
+			Update("cancel_requested", true).Error
+	})
+	if err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(JOB_NOT_FOUND, id))
+	}
+
+	return jr.DbGetJob(id)
+}
+
+func (jr *JobRepository) DbRequeueRunningJobs(now time.Time) (int, error) {
+
+	requeued := 0
+	err := jr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Job{}).Where("status = ? AND cancel_requested", model.JOB_RUNNING).
+			Updates(map[string]interface{}{"status": model.JOB_CANCELED, "finished_at": now}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&model.Job{}).Where("status = ?", model.JOB_RUNNING).
+			Updates(map[string]interface{}{"status": model.JOB_QUEUED, "next_attempt_at": nil})
+		requeued = int(result.RowsAffected)
+		return result.Error
+	})
+	if err != nil {
+		return 0, mapDbError(err, NO_JOB_FOUND)
+	}
+
+	return requeued, nil
+}
+
+func (jr *JobRepository) DbDeleteFinishedJobs(before time.Time) (int, error) {
+
+	result := jr.DB.Where("status IN ? AND finished_at < ?",
+		[]string{model.JOB_SUCCEEDED, model.JOB_FAILED, model.JOB_CANCELED}, before).Delete(&model.Job{})
+	if result.Error != nil {
+		return 0, mapDbError(result.Error, NO_JOB_FOUND)
+	}
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newJobRepository(t *testing.T) *JobRepository {
+	userRepository, keyring := newEncryptedRepository(t)
+	if err := userRepository.DB.AutoMigrate(&model.Job{}); err != nil {
+		t.Fatal(err)
+	}
+	return &JobRepository{DB: userRepository.DB, Cipher: keyring}
+}
+
+func TestJobRepo_DbClaimJob(t *testing.T) {
+	repository := newJobRepository(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Minute)
+
+	waiting, err := repository.DbCreateJob(&model.Job{Type: model.JOB_IMPORT, Status: model.JOB_QUEUED, Attempts: 1, NextAttemptAt: &later})
+	assert.Nil(t, err)
+	due, err := repository.DbCreateJob(&model.Job{Type: model.JOB_IMPORT, Status: model.JOB_QUEUED, Input: "first_name,last_name\nJohn,Doe\n"})
+	assert.Nil(t, err)
+
+	// the input is encrypted at rest, and left out of the jobs read back
+	var stored model.Job
+	assert.Nil(t, repository.DB.Take(&stored, due.Id).Error)
+	assert.True(t, strings.HasPrefix(stored.Input, "enc:k1:"), stored.Input)
+	job, err := repository.DbGetJob(due.Id)
+	assert.Nil(t, err)
+	assert.Empty(t, job.Input)
+
+	claimed, err := repository.DbClaimJob(now)
+	assert.Nil(t, err)
+	assert.Equal(t, due.Id, claimed.Id)
+	assert.Equal(t, model.JOB_RUNNING, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+	assert.Equal(t, "first_name,last_name\nJohn,Doe\n", claimed.Input)
+
+	// the job waiting for its retry is only claimed once due
+	claimed, err = repository.DbClaimJob(now)
+	assert.Nil(t, err)
+	assert.Nil(t, claimed)
+	claimed, err = repository.DbClaimJob(later)
+	assert.Nil(t, err)
+	assert.Equal(t, waiting.Id, claimed.Id)
+	assert.Equal(t, 2, claimed.Attempts)
+	assert.Nil(t, claimed.NextAttemptAt)
+
+	jobs, err := repository.DbListJobs()
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, due.Id, jobs[0].Id)
+}
+
+func TestJobRepo_DbUpdateJob(t *testing.T) {
+	repository := newJobRepository(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	job, err := repository.DbCreateJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	job, err = repository.DbClaimJob(now)
+	assert.Nil(t, err)
+	assert.Nil(t, repository.DbUpdateProgress(job.Id, model.JobProgress{Done: 2, Total: 5}))
+
+	stored, err := repository.DbGetJob(job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JobProgress{Done: 2, Total: 5}, stored.Progress)
+
+	job.Status, job.FinishedAt, job.Progress = model.JOB_SUCCEEDED, &now, model.JobProgress{Done: 5, Total: 5}
+	job.Result = &model.JobResult{Exported: 5, File: "job-1.csv"}
+	assert.Nil(t, repository.DbUpdateJob(job))
+
+	stored, err = repository.DbGetJob(job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_SUCCEEDED, stored.Status)
+	assert.Equal(t, &model.JobResult{Exported: 5, File: "job-1.csv"}, stored.Result)
+	assert.True(t, stored.Finished())
+
+	_, err = repository.DbGetJob(99)
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+}
+
+func TestJobRepo_DbCancelJob(t *testing.T) {
+	repository := newJobRepository(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	running, err := repository.DbCreateJob(&model.Job{Type: model.JOB_PURGE, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	_, err = repository.DbClaimJob(now)
+	assert.Nil(t, err)
+	queued, err := repository.DbCreateJob(&model.Job{Type: model.JOB_PURGE, Status: model.JOB_QUEUED})
+	assert.Nil(t, err)
+	interrupted, err := repository.DbCreateJob(&model.Job{Type: model.JOB_REVALIDATE, Status: model.JOB_RUNNING, Attempts: 1})
+	assert.Nil(t, err)
+
+	job, err := repository.DbCancelJob(queued.Id, now)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_CANCELED, job.Status)
+	assert.True(t, job.Finished())
+
+	job, err = repository.DbCancelJob(running.Id, now)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_RUNNING, job.Status)
+	assert.True(t, job.CancelRequested)
+
+	_, err = repository.DbCancelJob(99, now)
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	// after a restart, the job asked to stop is canceled and the other one runs again
+	requeued, err := repository.DbRequeueRunningJobs(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requeued)
+	job, err = repository.DbGetJob(running.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_CANCELED, job.Status)
+	job, err = repository.DbGetJob(interrupted.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.JOB_QUEUED, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestJobRepo_DbDeleteFinishedJobs(t *testing.T) {
+	repository := newJobRepository(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+
+	expired, err := repository.DbCreateJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_SUCCEEDED, FinishedAt: &earlier})
+	assert.Nil(t, err)
+	recent, err := repository.DbCreateJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_FAILED, FinishedAt: &now})
+	assert.Nil(t, err)
+	running, err := repository.DbCreateJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_RUNNING})
+	assert.Nil(t, err)
+
+	deleted, err := repository.DbDeleteFinishedJobs(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = repository.DbGetJob(expired.Id)
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+	for _, id := range []int64{recent.Id, running.Id} {
+		_, err = repository.DbGetJob(id)
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// FIELD_EVENT_PAYLOAD binds the encrypted payloads of events to the outbox
+const FIELD_EVENT_PAYLOAD = "outbox_events.payload"
+
+const (
+	EVENT_NOT_FOUND     = "event not found with id %v"
+	NO_EVENT_FOUND      = "no events found"
+	DB_EVENT_DATA_ERROR = "unable to read the data of event %v"
+)
+
+type IOutboxRepository interface {
+	// DbPendingEvents returns the oldest events not dispatched yet after the given id, in the order they occurred
+	DbPendingEvents(afterId int64, limit int) ([]model.Event, error)
+	// DbDeleteEvent removes a dispatched event from the outbox
+	DbDeleteEvent(id int64) error
+	// DbFailEvent records a failed dispatch of an event, which stays in the outbox
+	DbFailEvent(id int64, reason string) error
+}
+
+type OutboxRepository struct {
+	DB *gorm.DB
+	// Cipher decrypts the payloads of the events, it must be the cipher of the UserRepository writing them
+	Cipher fieldcrypt.ICipher
+}
+
+func (or *OutboxRepository) DbPendingEvents(afterId int64, limit int) ([]model.Event, error) {
+
+	events := []model.Event{}
+
+	if err := or.DB.Where("id > ?", afterId).Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, mapDbError(err, NO_EVENT_FOUND)
+	}
+
+	for i := range events {
+		payload := events[i].Payload
+		if or.Cipher != nil {
+			decrypted, err := or.Cipher.Decrypt(FIELD_EVENT_PAYLOAD, payload)
+			if err != nil {
+				return nil, apperror.Internal(fmt.Sprintf(DB_EVENT_DATA_ERROR, events[i].Id), err)
+			}
+			payload = decrypted
+		}
+		events[i].Data = json.RawMessage(payload)
+	}
+
+	return events, nil
+}
+
+func (or *OutboxRepository) DbDeleteEvent(id int64) error {
+
+	if err := or.DB.Delete(&model.Event{Id: id}).Error; err != nil {
+		return mapDbError(err, fmt.Sprintf(EVENT_NOT_FOUND, id))
+	}
+
+	return nil
+}
+
+func (or *OutboxRepository) DbFailEvent(id int64, reason string) error {
+
+	err := or.DB.Model(&model.Event{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": reason}).Error
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(EVENT_NOT_FOUND, id))
+	}
+
+	return nil
+}
+
+// recordEvent adds an event about a user to the outbox, with tx being the transaction changing the user
+func (ur *UserRepository) recordEvent(tx *gorm.DB, eventType string, userId int64, data interface{}) error {
+
+	if !ur.Events {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return apperror.Internal(fmt.Sprintf(DB_EVENT_DATA_ERROR, eventType), err)
+	}
+	event := &model.Event{Type: eventType, UserId: userId, OccurredAt: time.Now().UTC(), Payload: string(payload)}
+	if ur.Cipher != nil {
+		if event.Payload, err = ur.Cipher.Encrypt(FIELD_EVENT_PAYLOAD, event.Payload); err != nil {
+			return apperror.Internal(fmt.Sprintf(DB_EVENT_DATA_ERROR, eventType), err)
+		}
+	}
+
+	return tx.Create(event).Error
+}
+
+// inTransaction runs fn in a transaction when events are recorded, so that an event is only stored with its change
+func (ur *UserRepository) inTransaction(fn func(tx *gorm.DB) error) error {
+	if !ur.Events {
+		return fn(ur.DB)
+	}
+	return ur.DB.Transaction(fn)
+}
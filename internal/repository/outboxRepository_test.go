@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestUserRepo_Events(t *testing.T) {
+	tests := []struct {
+		name      string
+		encrypted bool
+	}{
+		{name: "CLEAR_TEXT"},
+		{name: "ENCRYPTED", encrypted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, _ := newEncryptedRepository(t)
+			if !tt.encrypted {
+				repository.Cipher = nil
+			}
+			repository.Events = true
+			if err := repository.DB.AutoMigrate(&model.Event{}); err != nil {
+				t.Fatal(err)
+			}
+			outbox := &OutboxRepository{DB: repository.DB, Cipher: repository.Cipher}
+
+			user, err := repository.DbCreateUser(&model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 34})
+			assert.Nil(t, err)
+			user.Age = 35
+			_, err = repository.DbUpdateUser(user)
+			assert.Nil(t, err)
+			_, err = repository.DbUpdateUser(&model.User{Id: 99, FirstName: "Jane", LastName: "Doe", Email: "jane.doe@yahoo.com", Age: 30})
+			assert.Nil(t, err)
+			assert.ErrorIs(t, repository.DbDeleteUser(99), apperror.ErrNotFound)
+			assert.Nil(t, repository.DbDeleteUser(user.Id))
+
+			events, err := outbox.DbPendingEvents(0, 10)
+			assert.Nil(t, err)
+			assert.Len(t, events, 3, "changes of missing users have no event")
+			assert.Equal(t, []string{model.EVENT_USER_CREATED, model.EVENT_USER_UPDATED, model.EVENT_USER_DELETED},
+				[]string{events[0].Type, events[1].Type, events[2].Type})
+			for _, event := range events {
+				assert.Equal(t, user.Id, event.UserId)
+				assert.Equal(t, tt.encrypted, strings.HasPrefix(event.Payload, "enc:"), event.Payload)
+			}
+			assert.JSONEq(t, `{"id":1,"first_name":"John","last_name":"Doe","email":"john.doe@yahoo.com","age":35}`, string(events[1].Data))
+			assert.JSONEq(t, `{"id":1}`, string(events[2].Data))
+
+			assert.Nil(t, outbox.DbFailEvent(events[0].Id, "sink unavailable"))
+			assert.Nil(t, outbox.DbDeleteEvent(events[1].Id))
+			events, err = outbox.DbPendingEvents(0, 10)
+			assert.Nil(t, err)
+			assert.Len(t, events, 2)
+			assert.Equal(t, 1, events[0].Attempts)
+			assert.Equal(t, "sink unavailable", events[0].LastError)
+		})
+	}
+}
+
+func TestUserRepo_Events_SameTransaction(t *testing.T) {
+	repository, _ := newEncryptedRepository(t)
+	repository.Events = true
+
+	// without outbox table the event cannot be written, so the user must not be either
+	_, err := repository.DbCreateUser(&model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 34})
+	assert.ErrorIs(t, err, apperror.ErrInternal)
+
+	users, err := repository.DbListUsers()
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+}
+
+func TestUserRepo_DbCreateUsers(t *testing.T) {
+	tests := []struct {
+		name      string
+		encrypted bool
+	}{
+		{name: "CLEAR_TEXT"},
+		{name: "ENCRYPTED", encrypted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, _ := newEncryptedRepository(t)
+			if !tt.encrypted {
+				repository.Cipher = nil
+			}
+			repository.Events = true
+			if err := repository.DB.AutoMigrate(&model.Event{}); err != nil {
+				t.Fatal(err)
+			}
+			outbox := &OutboxRepository{DB: repository.DB, Cipher: repository.Cipher}
+
+			created, err := repository.DbCreateUsers([]model.User{
+				{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+				{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{1, 2}, []int64{created[0].Id, created[1].Id})
+
+			users, err := repository.DbListUsers()
+			assert.Nil(t, err)
+			assert.Equal(t, created, users)
+			exists, err := repository.ExistsByFirstNameAndLastName("Grace", "Hopper")
+			assert.Nil(t, err)
+			assert.True(t, exists)
+
+			events, err := outbox.DbPendingEvents(0, 10)
+			assert.Nil(t, err)
+			assert.Len(t, events, 2)
+			for i, event := range events {
+				assert.Equal(t, model.EVENT_USER_CREATED, event.Type)
+				assert.Equal(t, created[i].Id, event.UserId)
+			}
+		})
+	}
+}
+
+func TestUserRepo_DbCreateUsers_SameTransaction(t *testing.T) {
+	repository, _ := newEncryptedRepository(t)
+	repository.Events = true
+
+	// the events of the batch cannot be written without outbox table, so none of its users is created
+	_, err := repository.DbCreateUsers([]model.User{
+		{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+		{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+	})
+	assert.ErrorIs(t, err, apperror.ErrInternal)
+
+	users, err := repository.DbListUsers()
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/gorm"
+)
+
+// DefaultReencryptBatchSize is the number of users re-encrypted per transaction
+const DefaultReencryptBatchSize = 100
+
+// Encrypted user columns, also binding each ciphertext to its column
+const (
+	FIELD_FIRST_NAME = "first_name"
+	FIELD_LAST_NAME  = "last_name"
+	FIELD_EMAIL      = "email"
+)
+
+const DB_DECRYPTION_ERROR = "unable to decrypt user %v"
+
+// userRecord is the row of a user whose personal data is encrypted,
+// with blind indexes to look users up by first name, last name and email
+type userRecord struct {
+	model.User     `gorm:"embedded"`
+	FirstNameIndex string `gorm:"index"`
+	LastNameIndex  string `gorm:"index"`
+	EmailIndex     string `gorm:"index"`
+}
+
+func (userRecord) TableName() string {
+	return "users"
+}
+
+// Migrate adds the blind index columns to the users table when encryption is enabled
+func (ur *UserRepository) Migrate() error {
+	if ur.Cipher == nil {
+		return nil
+	}
+	return ur.DB.AutoMigrate(&userRecord{})
+}
+
+// encryptUser returns the row of a user, with its personal data encrypted with the primary key.
+// Empty fields are left empty, so that updates skip them as they do in clear text.
+func (ur *UserRepository) encryptUser(user *model.User) (*userRecord, error) {
+
+	record := &userRecord{User: *user}
+	values := []struct {
+		field string
+		value *string
+		index *string
+	}{
+		{field: FIELD_FIRST_NAME, value: &record.FirstName, index: &record.FirstNameIndex},
+		{field: FIELD_LAST_NAME, value: &record.LastName, index: &record.LastNameIndex},
+		{field: FIELD_EMAIL, value: &record.Email, index: &record.EmailIndex},
+	}
+	for _, v := range values {
+		if *v.value == "" {
+			continue
+		}
+		*v.index = ur.Cipher.BlindIndex(v.field, *v.value)
+		encrypted, err := ur.Cipher.Encrypt(v.field, *v.value)
+		if err != nil {
+			return nil, apperror.Internal(DB_ERROR, err)
+		}
+		*v.value = encrypted
+	}
+	return record, nil
+}
+
+// decryptUser decrypts the personal data of a user read from the users table, in place
+func (ur *UserRepository) decryptUser(user *model.User) error {
+	if ur.Cipher == nil {
+		return nil
+	}
+	for _, v := range []struct {
+		field string
+		value *string
+	}{
+		{field: FIELD_FIRST_NAME, value: &user.FirstName},
+		{field: FIELD_LAST_NAME, value: &user.LastName},
+		{field: FIELD_EMAIL, value: &user.Email},
+	} {
+		decrypted, err := ur.Cipher.Decrypt(v.field, *v.value)
+		if err != nil {
+			return apperror.Internal(fmt.Sprintf(DB_DECRYPTION_ERROR, user.Id), err)
+		}
+		*v.value = decrypted
+	}
+	return nil
+}
+
+// ReencryptUsers encrypts with the primary key every user stored in clear text or with an older key,
+// batchSize users per transaction, and returns the number of users rewritten
+func (ur *UserRepository) ReencryptUsers(batchSize int) (int, error) {
+
+	if ur.Cipher == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultReencryptBatchSize
+	}
+
+	rewritten := 0
+	lastId := int64(0)
+	for {
+		records := []userRecord{}
+		if err := ur.DB.Where("id > ?", lastId).Order("id").Limit(batchSize).Find(&records).Error; err != nil {
+			return rewritten, mapDbError(err, NO_USER_FOUND)
+		}
+		if len(records) == 0 {
+			return rewritten, nil
+		}
+		lastId = records[len(records)-1].Id
+
+		err := ur.DB.Transaction(func(tx *gorm.DB) error {
+			for _, record := range records {
+				if !ur.needsRotation(&record) {
+					continue
+				}
+				user := record.User
+				if err := ur.decryptUser(&user); err != nil {
+					return err
+				}
+				reencrypted, err := ur.encryptUser(&user)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&userRecord{}).Where("id = ?", user.Id).Updates(reencrypted).Error; err != nil {
+					return mapDbError(err, fmt.Sprintf(USER_NOT_FOUND, user.Id))
+				}
+				rewritten++
+			}
+			return nil
+		})
+		if err != nil {
+			return rewritten, err
+		}
+	}
+}
+
+// needsRotation tells whether a field of the record is stored in clear text, without its index, or with an older key
+func (ur *UserRepository) needsRotation(record *userRecord) bool {
+	return ur.fieldNeedsRotation(record.FirstName, record.FirstNameIndex) ||
+		ur.fieldNeedsRotation(record.LastName, record.LastNameIndex) ||
+		ur.fieldNeedsRotation(record.Email, record.EmailIndex)
+}
+
+func (ur *UserRepository) fieldNeedsRotation(value string, index string) bool {
+	return value != "" && (index == "" || ur.Cipher.NeedsRotation(value))
+}
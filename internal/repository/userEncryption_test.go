@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func newEncryptedRepository(t *testing.T) (*UserRepository, *fieldcrypt.Keyring) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatal(err)
+	}
+	keyring := &fieldcrypt.Keyring{
+		Primary:  "k1",
+		Keys:     map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 32)},
+		IndexKey: bytes.Repeat([]byte{3}, 32),
+	}
+	repository := &UserRepository{DB: db, Cipher: keyring}
+	if err := repository.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return repository, keyring
+}
+
+// storedUser reads a row as it is stored, without decrypting it
+func storedUser(t *testing.T, repository *UserRepository, id int64) userRecord {
+	var record userRecord
+	if err := repository.DB.Where("id = ?", id).Take(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestUserRepo_Encryption(t *testing.T) {
+	repository, _ := newEncryptedRepository(t)
+
+	created, err := repository.DbCreateUser(&model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 34})
+	assert.Nil(t, err)
+	assert.Equal(t, "John", created.FirstName)
+
+	stored := storedUser(t, repository, created.Id)
+	for _, value := range []string{stored.FirstName, stored.LastName, stored.Email} {
+		assert.True(t, strings.HasPrefix(value, "enc:k1:"), value)
+	}
+	assert.NotEmpty(t, stored.EmailIndex)
+
+	user, err := repository.DbGetUser(created.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, model.User{Id: created.Id, FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 34}, *user)
+
+	exists, err := repository.ExistsByFirstNameAndLastName("John", "Doe")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	exists, _ = repository.ExistsByFirstNameAndLastName("John", "Smith")
+	assert.False(t, exists)
+
+	user, err = repository.DbGetUserByEmail("john.doe@yahoo.com")
+	assert.Nil(t, err)
+	assert.Equal(t, created.Id, user.Id)
+	_, err = repository.DbGetUserByEmail("jane.doe@yahoo.com")
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	user.Email = "john@doe.com"
+	_, err = repository.DbUpdateUser(user)
+	assert.Nil(t, err)
+	users, err := repository.DbListUsers()
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{{Id: created.Id, FirstName: "John", LastName: "Doe", Email: "john@doe.com", Age: 34}}, users)
+}
+
+func TestUserRepo_ReencryptUsers(t *testing.T) {
+	repository, keyring := newEncryptedRepository(t)
+
+	// users stored before encryption was enabled are found, then encrypted
+	legacy := model.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}
+	if err := repository.DB.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	exists, err := repository.ExistsByFirstNameAndLastName("Ada", "Lovelace")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	for _, name := range []string{"Grace", "Katherine"} {
+		_, err := repository.DbCreateUser(&model.User{FirstName: name, LastName: "Hopper", Email: name + "@example.com", Age: 40})
+		assert.Nil(t, err)
+	}
+
+	rewritten, err := repository.ReencryptUsers(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rewritten, "only the user in clear text is rewritten")
+	assert.True(t, strings.HasPrefix(storedUser(t, repository, legacy.Id).Email, "enc:k1:"))
+
+	keyring.Primary = "k2"
+	rewritten, err = repository.ReencryptUsers(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, rewritten)
+
+	delete(keyring.Keys, "k1")
+	users, err := repository.DbListUsers()
+	assert.Nil(t, err)
+	assert.Len(t, users, 3)
+	assert.Equal(t, "Ada", users[0].FirstName)
+	assert.True(t, strings.HasPrefix(storedUser(t, repository, legacy.Id).FirstName, "enc:k2:"))
+
+	rewritten, err = repository.ReencryptUsers(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, rewritten)
+}
+
+func TestUserRepo_DbListUsersAfter(t *testing.T) {
+	repository, _ := newEncryptedRepository(t)
+	ids := []int64{}
+	for _, name := range []string{"Ada", "Grace", "Katherine"} {
+		created, err := repository.DbCreateUser(&model.User{FirstName: name, LastName: "Hopper", Email: name + "@example.com", Age: 40})
+		assert.Nil(t, err)
+		ids = append(ids, created.Id)
+	}
+
+	users, err := repository.DbListUsersAfter(model.UserFilter{}, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{
+		{Id: ids[0], FirstName: "Ada", LastName: "Hopper", Email: "Ada@example.com", Age: 40},
+		{Id: ids[1], FirstName: "Grace", LastName: "Hopper", Email: "Grace@example.com", Age: 40},
+	}, users)
+
+	users, err = repository.DbListUsersAfter(model.UserFilter{}, ids[1], 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{{Id: ids[2], FirstName: "Katherine", LastName: "Hopper", Email: "Katherine@example.com", Age: 40}}, users)
+
+	users, err = repository.DbListUsersAfter(model.UserFilter{}, ids[2], 2)
+	assert.Nil(t, err)
+	assert.Empty(t, users)
+}
+
+func TestUserRepo_DbListUsersAfter_Filtered(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    model.UserFilter
+		limit     int
+		wantNames []string
+	}{
+		{name: "First page", filter: model.UserFilter{FirstName: "A"}, limit: 2, wantNames: []string{"Ada", "Grace"}},
+		{name: "Matches further than the limit", filter: model.UserFilter{LastName: "O", MaxAge: 60}, limit: 2, wantNames: []string{"Ada", "Linus"}},
+		{name: "Every match", filter: model.UserFilter{MinAge: 80}, wantNames: []string{"Grace", "Barbara"}},
+		{name: "Wildcard", filter: model.UserFilter{Email: "%"}, limit: 2, wantNames: []string{}},
+	}
+	for _, encrypt := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%v encrypted %v", tt.name, encrypt), func(t *testing.T) {
+				// Given
+				repository, _ := newEncryptedRepository(t)
+				if !encrypt {
+					repository.Cipher = nil
+				}
+				for _, user := range []model.User{
+					{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+					{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+					{FirstName: "Alan", LastName: "Turing", Email: "alan@example.com", Age: 41},
+					{FirstName: "Barbara", LastName: "Liskov", Email: "barbara@example.com", Age: 84},
+					{FirstName: "Linus", LastName: "Torvalds", Email: "linus@example.com", Age: 54},
+				} {
+					_, err := repository.DbCreateUser(&user)
+					assert.Nil(t, err)
+				}
+
+				// When
+				users, err := repository.DbListUsersAfter(tt.filter, 0, tt.limit)
+
+				// Then
+				assert.Nil(t, err)
+				names := []string{}
+				for _, user := range users {
+					names = append(names, user.FirstName)
+				}
+				assert.Equal(t, tt.wantNames, names)
+			})
+		}
+	}
+}
+
+func TestUserRepo_DbUpdateUser_Partial(t *testing.T) {
+	tests := []struct {
+		name    string
+		encrypt bool
+	}{
+		{name: "Clear text"},
+		{name: "Encrypted", encrypt: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository, _ := newEncryptedRepository(t)
+			if !tt.encrypt {
+				repository.Cipher = nil
+			}
+			created, err := repository.DbCreateUser(&model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 34})
+			assert.Nil(t, err)
+
+			// When
+			_, err = repository.DbUpdateUser(&model.User{Id: created.Id, FirstName: "Johnny", Age: 35})
+
+			// Then
+			assert.Nil(t, err)
+			user, err := repository.DbGetUser(created.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, model.User{Id: created.Id, FirstName: "Johnny", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 35}, *user)
+		})
+	}
+}
@@ -1,93 +1,293 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
-	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 	"gorm.io/gorm"
+	"strings"
 )
 
 const (
 	USER_NOT_FOUND = "user not found with id %v"
 	NO_USER_FOUND  = "no users found"
+	USER_CONFLICT  = "user already exists"
+	DB_UNAVAILABLE = "database unavailable"
+	DB_ERROR       = "database error"
+
+	// USER_EMAIL_NOT_FOUND is sent to clients, so the email is not part of it
+	USER_EMAIL_NOT_FOUND = "user not found with this email"
 )
 
 type IUserRepository interface {
-	DbListUsers() ([]model.User, utils.MessageErr)
-	DbCreateUser(user *model.User) (*model.User, utils.MessageErr)
-	DbGetUser(id int64) (*model.User, utils.MessageErr)
-	DbUpdateUser(user *model.User) (*model.User, utils.MessageErr)
-	DbDeleteUser(id int64) utils.MessageErr
-	ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, utils.MessageErr)
+	DbListUsers() ([]model.User, error)
+	DbListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error)
+	DbCreateUser(user *model.User) (*model.User, error)
+	DbCreateUsers(users []model.User) ([]model.User, error)
+	DbGetUser(id int64) (*model.User, error)
+	DbUpdateUser(user *model.User) (*model.User, error)
+	DbDeleteUser(id int64) error
+	ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, error)
+	DbGetUserByEmail(email string) (*model.User, error)
 	// Add other necessary GORM methods here
 }
 
 type UserRepository struct {
 	DB *gorm.DB
+	// Cipher encrypts the names and email of users, which are stored in clear text when nil
+	Cipher fieldcrypt.ICipher
+	// Events records a domain event in the outbox with every change of a user, in the same transaction
+	Events bool
 }
 
-func (ur *UserRepository) DbListUsers() ([]model.User, utils.MessageErr) {
+func (ur *UserRepository) DbListUsers() ([]model.User, error) {
 
-	var users []model.User
+	users := []model.User{}
 
 	if err := ur.DB.Find(&users).Error; err != nil {
-		return nil, utils.InternalServerError(err.Error())
+		return nil, mapDbError(err, NO_USER_FOUND)
 	}
 
-	if len(users) > 0 {
-		return users, nil
+	for i := range users {
+		if err := ur.decryptUser(&users[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil, utils.NotFoundError(NO_USER_FOUND)
+	return users, nil
 
 }
 
-func (ur *UserRepository) DbCreateUser(user *model.User) (*model.User, utils.MessageErr) {
+// DbListUsersAfter returns at most limit users matching the filter ordered by id, starting after the given id, to read
+// the users in chunks. Every matching user is returned when limit is not positive.
+func (ur *UserRepository) DbListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+
+	// encrypted names and emails cannot be compared by the database, they are matched once decrypted
+	decryptedMatch := ur.Cipher != nil && (filter.FirstName != "" || filter.LastName != "" || filter.Email != "")
+
+	users := []model.User{}
+	for {
+		chunk := []model.User{}
+		query := ur.filter(filter, afterId).Order("id")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		if err := query.Find(&chunk).Error; err != nil {
+			return nil, mapDbError(err, NO_USER_FOUND)
+		}
 
-	if err := ur.DB.Save(user).Error; err != nil {
-		return nil, utils.InternalServerError(err.Error())
+		for i := range chunk {
+			if err := ur.decryptUser(&chunk[i]); err != nil {
+				return nil, err
+			}
+			if (!decryptedMatch || filter.Matches(chunk[i])) && (limit <= 0 || len(users) < limit) {
+				users = append(users, chunk[i])
+			}
+		}
+
+		if !decryptedMatch || limit <= 0 || len(chunk) < limit || len(users) == limit {
+			return users, nil
+		}
+		afterId = chunk[len(chunk)-1].Id
+	}
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filter selects the users after the given id meeting the criteria of the filter that the database can compare, the
+// names and email containing their text ignoring case unless they are encrypted
+func (ur *UserRepository) filter(filter model.UserFilter, afterId int64) *gorm.DB {
+
+	query := ur.DB.Where("id > ?", afterId)
+	if filter.MinAge != 0 {
+		query = query.Where("age >= ?", filter.MinAge)
+	}
+	if filter.MaxAge != 0 {
+		query = query.Where("age <= ?", filter.MaxAge)
+	}
+	if ur.Cipher != nil {
+		return query
+	}
+
+	for _, criterion := range []struct{ column, text string }{
+		{FIELD_FIRST_NAME, filter.FirstName},
+		{FIELD_LAST_NAME, filter.LastName},
+		{FIELD_EMAIL, filter.Email},
+	} {
+		if criterion.text != "" {
+			query = query.Where("LOWER("+criterion.column+`) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(criterion.text))+"%")
+		}
+	}
+	return query
+}
+
+func (ur *UserRepository) DbCreateUser(user *model.User) (*model.User, error) {
+
+	err := ur.inTransaction(func(tx *gorm.DB) error {
+		if ur.Cipher != nil {
+			record, err := ur.encryptUser(user)
+			if err != nil {
+				return err
+			}
+			if err := tx.Save(record).Error; err != nil {
+				return err
+			}
+			user.Id = record.Id
+		} else if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return ur.recordEvent(tx, model.EVENT_USER_CREATED, user.Id, user)
+	})
+	if err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(USER_NOT_FOUND, user.Id))
 	}
 
 	return user, nil
 }
 
-func (ur *UserRepository) DbGetUser(id int64) (*model.User, utils.MessageErr) {
+// DbCreateUsers inserts a batch of users in a single transaction, none of them being created when one fails
+func (ur *UserRepository) DbCreateUsers(users []model.User) ([]model.User, error) {
+
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	err := ur.DB.Transaction(func(tx *gorm.DB) error {
+		if ur.Cipher != nil {
+			records := make([]userRecord, len(users))
+			for i := range users {
+				record, err := ur.encryptUser(&users[i])
+				if err != nil {
+					return err
+				}
+				records[i] = *record
+			}
+			if err := tx.Create(&records).Error; err != nil {
+				return err
+			}
+			for i := range users {
+				users[i].Id = records[i].Id
+			}
+		} else if err := tx.Create(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := ur.recordEvent(tx, model.EVENT_USER_CREATED, user.Id, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, mapDbError(err, NO_USER_FOUND)
+	}
+
+	return users, nil
+}
+
+func (ur *UserRepository) DbGetUser(id int64) (*model.User, error) {
 
 	var user model.User
 
 	if err := ur.DB.Where(model.User{Id: id}).Take(&user).Error; err != nil {
-		return nil, utils.InternalServerError(err.Error())
+		return nil, mapDbError(err, fmt.Sprintf(USER_NOT_FOUND, id))
 	}
 
 	if user.Id == id {
+		if err := ur.decryptUser(&user); err != nil {
+			return nil, err
+		}
 		return &user, nil
 	}
 
-	return nil, utils.NotFoundError(fmt.Sprintf(USER_NOT_FOUND, id))
+	return nil, apperror.NotFound(fmt.Sprintf(USER_NOT_FOUND, id), nil)
+}
+
+// DbGetUserByEmail finds a user by email, through its blind index when emails are encrypted
+func (ur *UserRepository) DbGetUserByEmail(email string) (*model.User, error) {
+
+	query := ur.DB.Where("email = ?", email)
+	if ur.Cipher != nil {
+		// users stored before encryption was enabled have no index yet
+		query = ur.DB.Where("email_index = ? OR email = ?", ur.Cipher.BlindIndex(FIELD_EMAIL, email), email)
+	}
+
+	var user model.User
+	if err := query.Take(&user).Error; err != nil {
+		return nil, mapDbError(err, USER_EMAIL_NOT_FOUND)
+	}
+
+	if err := ur.decryptUser(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (ur *UserRepository) DbUpdateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (ur *UserRepository) DbUpdateUser(user *model.User) (*model.User, error) {
 
-	if err := ur.DB.Model(&model.User{}).Where("Id = ?", user.Id).Updates(user).Error; err != nil {
-		return nil, utils.InternalServerError(err.Error())
+	err := ur.inTransaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if ur.Cipher != nil {
+			record, err := ur.encryptUser(user)
+			if err != nil {
+				return err
+			}
+			result = tx.Model(&userRecord{}).Where("Id = ?", user.Id).Updates(record)
+		} else {
+			result = tx.Model(&model.User{}).Where("Id = ?", user.Id).Updates(user)
+		}
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return ur.recordEvent(tx, model.EVENT_USER_UPDATED, user.Id, user)
+	})
+	if err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(USER_NOT_FOUND, user.Id))
 	}
 
 	return user, nil
 }
 
-func (ur *UserRepository) DbDeleteUser(id int64) utils.MessageErr {
+func (ur *UserRepository) DbDeleteUser(id int64) error {
 
-	ur.DB.Delete(&model.User{Id: id})
+	err := ur.inTransaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.User{Id: id})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return apperror.NotFound(fmt.Sprintf(USER_NOT_FOUND, id), nil)
+		}
+
+		return ur.recordEvent(tx, model.EVENT_USER_DELETED, id, model.UserDeletedData{Id: id})
+	})
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(USER_NOT_FOUND, id))
+	}
 
 	return nil
 }
 
-func (ur *UserRepository) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, utils.MessageErr) {
+func (ur *UserRepository) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, error) {
 
 	// Query to find users with the specified first and last names
+	query := ur.DB.Where("first_name = ? AND last_name = ?", firstName, lastName)
+	if ur.Cipher != nil {
+		// users stored before encryption was enabled have no index yet
+		query = ur.DB.Where("(first_name_index = ? AND last_name_index = ?) OR (first_name = ? AND last_name = ?)",
+			ur.Cipher.BlindIndex(FIELD_FIRST_NAME, firstName), ur.Cipher.BlindIndex(FIELD_LAST_NAME, lastName), firstName, lastName)
+	}
+
 	var users []model.User
-	if err := ur.DB.Where("first_name = ? AND last_name = ?", firstName, lastName).Find(&users).Error; err != nil {
-		return false, utils.InternalServerError(err.Error())
+	if err := query.Find(&users).Error; err != nil {
+		return false, mapDbError(err, NO_USER_FOUND)
 	}
 
 	if len(users) > 0 {
@@ -97,3 +297,23 @@ func (ur *UserRepository) ExistsByFirstNameAndLastName(firstName string, lastNam
 	return false, nil
 
 }
+
+// mapDbError translates gorm and driver errors into domain errors.
+// notFoundMessage is the message used when the record does not exist.
+func mapDbError(err error, notFoundMessage string) error {
+	switch {
+	case errors.As(err, new(*apperror.Error)):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperror.NotFound(notFoundMessage, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return apperror.Conflict(USER_CONFLICT, err)
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled):
+		return apperror.Unavailable(DB_UNAVAILABLE, err)
+	default:
+		return apperror.Internal(DB_ERROR, err)
+	}
+}
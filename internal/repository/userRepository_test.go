@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -147,7 +151,7 @@ func TesUserRepo_Create(t *testing.T) {
 			tt.mock()
 			got, err := tt.s.DbCreateUser(tt.request)
 			if (err != nil) != tt.wantErr {
-				fmt.Println("this is the error message: ", err.Message())
+				fmt.Println("this is the error message: ", err.Error())
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -210,7 +214,7 @@ func TesUserRepo_Update(t *testing.T) {
 			tt.mock()
 			got, err := tt.s.DbUpdateUser(tt.request)
 			if (err != nil) != tt.wantErr {
-				fmt.Println("this is the error message: ", err.Message())
+				fmt.Println("this is the error message: ", err.Error())
 				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -318,3 +322,105 @@ func TesUserRepo_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepo_GetId_Record_Not_Found(t *testing.T) {
+
+	mockDB, mock, err := NewDbMock()
+	if err != nil {
+		t.Errorf("Failed to initialize mock DB: %v", err)
+	}
+
+	s := UserRepository{DB: mockDB}
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "users" WHERE "users"."id" = $1 LIMIT 1`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "FirstName", "LastName", "Email", "Age"}))
+
+	got, err := s.DbGetUser(1)
+
+	assert.Nil(t, got)
+	assert.True(t, errors.Is(err, apperror.ErrNotFound))
+	var appErr *apperror.Error
+	assert.True(t, errors.As(err, &appErr))
+	assert.EqualValues(t, "user not found with id 1", appErr.Message)
+}
+
+func TestUserRepo_GetAll_Empty(t *testing.T) {
+
+	mockDB, mock, err := NewDbMock()
+	if err != nil {
+		t.Errorf("Failed to initialize mock DB: %v", err)
+	}
+
+	s := UserRepository{DB: mockDB}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "FirstName", "LastName", "Email", "Age"}))
+
+	got, err := s.DbListUsers()
+
+	assert.Nil(t, err)
+	assert.NotNil(t, got)
+	assert.Empty(t, got)
+}
+
+func TestUserRepo_Delete_Errors(t *testing.T) {
+
+	mockDB, mock, err := NewDbMock()
+	if err != nil {
+		t.Errorf("Failed to initialize mock DB: %v", err)
+	}
+
+	s := UserRepository{DB: mockDB}
+
+	tests := []struct {
+		name string
+		mock func()
+		kind error
+	}{
+		{
+			name: "Not Found",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE "users"."id" = $1`)).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			kind: apperror.ErrNotFound,
+		},
+		{
+			name: "Unavailable",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE "users"."id" = $1`)).
+					WithArgs(1).
+					WillReturnError(driver.ErrBadConn)
+				mock.ExpectRollback()
+			},
+			kind: apperror.ErrUnavailable,
+		},
+		{
+			name: "Internal",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE "users"."id" = $1`)).
+					WithArgs(1).
+					WillReturnError(errors.New("disk I/O error"))
+				mock.ExpectRollback()
+			},
+			kind: apperror.ErrInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := s.DbDeleteUser(1)
+			assert.True(t, errors.Is(err, tt.kind), "got %v", err)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Test failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/fieldcrypt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Encrypted webhook columns, also binding each ciphertext to its column
+const (
+	FIELD_WEBHOOK_SECRET   = "webhooks.secret"
+	FIELD_DELIVERY_PAYLOAD = "webhook_deliveries.payload"
+)
+
+const (
+	WEBHOOK_NOT_FOUND         = "webhook not found with id %v"
+	DELIVERY_NOT_FOUND        = "delivery not found with id %v"
+	NO_DELIVERY_FOUND         = "no deliveries found"
+	DB_FIELD_DECRYPTION_ERROR = "unable to decrypt %v of row %v"
+)
+
+type IWebhookRepository interface {
+	DbListWebhooks() ([]model.Webhook, error)
+	DbCreateWebhook(webhook *model.Webhook) (*model.Webhook, error)
+	DbGetWebhook(id int64) (*model.Webhook, error)
+	// DbDeleteWebhook removes a webhook with its deliveries
+	DbDeleteWebhook(id int64) error
+	// DbCreateDeliveries stores new deliveries, skipping those of an event already delivered to the same webhook
+	DbCreateDeliveries(deliveries []model.WebhookDelivery) error
+	// DbListDeliveries returns the deliveries of a webhook, the latest first
+	DbListDeliveries(webhookId int64) ([]model.WebhookDelivery, error)
+	// DbDueDeliveries returns the pending deliveries whose next attempt is due, the oldest first
+	DbDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error)
+	// DbUpdateDelivery records the outcome of an attempt
+	DbUpdateDelivery(delivery *model.WebhookDelivery) error
+	// DbRedeliver makes a delivery of a webhook pending again, with all its attempts
+	DbRedeliver(webhookId int64, deliveryId int64, now time.Time) (*model.WebhookDelivery, error)
+}
+
+type WebhookRepository struct {
+	DB *gorm.DB
+	// Cipher encrypts the secrets of webhooks and the payloads of deliveries, which hold personal data
+	Cipher fieldcrypt.ICipher
+}
+
+func (wr *WebhookRepository) DbListWebhooks() ([]model.Webhook, error) {
+
+	webhooks := []model.Webhook{}
+
+	if err := wr.DB.Order("id").Find(&webhooks).Error; err != nil {
+		return nil, mapDbError(err, "no webhooks found")
+	}
+
+	for i := range webhooks {
+		if err := wr.decrypt(FIELD_WEBHOOK_SECRET, &webhooks[i].Secret, webhooks[i].Id); err != nil {
+			return nil, err
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (wr *WebhookRepository) DbCreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+
+	stored := *webhook
+	if err := wr.encrypt(FIELD_WEBHOOK_SECRET, &stored.Secret); err != nil {
+		return nil, err
+	}
+	if err := wr.DB.Create(&stored).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(WEBHOOK_NOT_FOUND, webhook.Id))
+	}
+
+	webhook.Id = stored.Id
+	return webhook, nil
+}
+
+func (wr *WebhookRepository) DbGetWebhook(id int64) (*model.Webhook, error) {
+
+	var webhook model.Webhook
+
+	if err := wr.DB.Take(&webhook, id).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(WEBHOOK_NOT_FOUND, id))
+	}
+
+	if err := wr.decrypt(FIELD_WEBHOOK_SECRET, &webhook.Secret, id); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (wr *WebhookRepository) DbDeleteWebhook(id int64) error {
+
+	err := wr.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Webhook{Id: id})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apperror.NotFound(fmt.Sprintf(WEBHOOK_NOT_FOUND, id), nil)
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error
+	})
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(WEBHOOK_NOT_FOUND, id))
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepository) DbCreateDeliveries(deliveries []model.WebhookDelivery) error {
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	stored := make([]model.WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		stored[i] = delivery
+		if err := wr.encrypt(FIELD_DELIVERY_PAYLOAD, &stored[i].Payload); err != nil {
+			return err
+		}
+	}
+
+	if err := wr.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&stored).Error; err != nil {
+		return mapDbError(err, NO_DELIVERY_FOUND)
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepository) DbListDeliveries(webhookId int64) ([]model.WebhookDelivery, error) {
+
+	if _, err := wr.DbGetWebhook(webhookId); err != nil {
+		return nil, err
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	if err := wr.DB.Where("webhook_id = ?", webhookId).Order("id desc").Find(&deliveries).Error; err != nil {
+		return nil, mapDbError(err, NO_DELIVERY_FOUND)
+	}
+
+	return deliveries, nil
+}
+
+func (wr *WebhookRepository) DbDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+
+	deliveries := []model.WebhookDelivery{}
+
+	err := wr.DB.Where("status = ? AND next_attempt_at <= ?", model.DELIVERY_PENDING, now).
+		Order("next_attempt_at, id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, mapDbError(err, NO_DELIVERY_FOUND)
+	}
+
+	for i := range deliveries {
+		if err := wr.decrypt(FIELD_DELIVERY_PAYLOAD, &deliveries[i].Payload, deliveries[i].Id); err != nil {
+			return nil, err
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (wr *WebhookRepository) DbUpdateDelivery(delivery *model.WebhookDelivery) error {
+
+	err := wr.DB.Model(&model.WebhookDelivery{}).Where("id = ?", delivery.Id).
+		Select("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").
+		Updates(delivery).Error
+	if err != nil {
+		return mapDbError(err, fmt.Sprintf(DELIVERY_NOT_FOUND, delivery.Id))
+	}
+
+	return nil
+}
+
+func (wr *WebhookRepository) DbRedeliver(webhookId int64, deliveryId int64, now time.Time) (*model.WebhookDelivery, error) {
+
+	var delivery model.WebhookDelivery
+	if err := wr.DB.Where("id = ? AND webhook_id = ?", deliveryId, webhookId).Take(&delivery).Error; err != nil {
+		return nil, mapDbError(err, fmt.Sprintf(DELIVERY_NOT_FOUND, deliveryId))
+	}
+
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt = model.DELIVERY_PENDING, 0, &now
+	if err := wr.DbUpdateDelivery(&delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (wr *WebhookRepository) encrypt(field string, value *string) error {
+	if wr.Cipher == nil {
+		return nil
+	}
+	encrypted, err := wr.Cipher.Encrypt(field, *value)
+	if err != nil {
+		return apperror.Internal(DB_ERROR, err)
+	}
+	*value = encrypted
+	return nil
+}
+
+func (wr *WebhookRepository) decrypt(field string, value *string, id int64) error {
+	if wr.Cipher == nil {
+		return nil
+	}
+	decrypted, err := wr.Cipher.Decrypt(field, *value)
+	if err != nil {
+		return apperror.Internal(fmt.Sprintf(DB_FIELD_DECRYPTION_ERROR, field, id), err)
+	}
+	*value = decrypted
+	return nil
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+)
+
+type ApiKeyRoutes struct {
+	Controller *controller.ApiKeyController
+	// Codecs negotiates the request and response formats, the default registry is used when nil
+	Codecs *codec.Registry
+}
+
+func (akr *ApiKeyRoutes) ApiKeyRoutes(r chi.Router) {
+
+	codecs := akr.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+
+	r.Route("/apikeys", func(r chi.Router) {
+		r.Use(negotiate(codecs))
+		r.Get("/", akr.Controller.ListApiKeys)             // GET /apikeys
+		r.Post("/", akr.Controller.CreateApiKey)           // POST /apikeys
+		r.Delete("/{key_id}", akr.Controller.RevokeApiKey) // DELETE /apikeys/3
+	})
+}
+
+// Groups returns the route groups of the api key API
+func (akr *ApiKeyRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_API_KEYS, Routes: akr.ApiKeyRoutes},
+	}
+}
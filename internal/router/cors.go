@@ -0,0 +1,116 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// corsIgnoredMethods are never sent cross-origin by browsers, or are answered without a preflight
+var corsIgnoredMethods = []string{http.MethodHead, http.MethodOptions, http.MethodConnect, http.MethodTrace}
+
+// anyMethodCount is the number of methods chi registers for a route handling every method, e.g. a file server
+const anyMethodCount = 9
+
+// CorsPolicy describes which browser origins may call the API
+type CorsPolicy struct {
+	// AllowedOrigins are origins such as "https://app.example.com", "https://*.example.com" for its subdomains,
+	// or "*" for every origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response, in seconds
+	MaxAge int
+}
+
+// Handler returns the middleware answering preflight requests and adding CORS headers to responses
+func (cp *CorsPolicy) Handler() func(http.Handler) http.Handler {
+
+	options := cors.Options{
+		AllowedMethods:   cp.AllowedMethods,
+		AllowedHeaders:   cp.AllowedHeaders,
+		ExposedHeaders:   cp.ExposedHeaders,
+		AllowCredentials: cp.AllowCredentials,
+		MaxAge:           cp.MaxAge,
+	}
+	if contains(cp.AllowedOrigins, "*") {
+		options.AllowedOrigins = []string{"*"}
+	} else {
+		options.AllowOriginFunc = func(r *http.Request, origin string) bool {
+			return cp.AllowOrigin(origin)
+		}
+	}
+	return cors.Handler(options)
+}
+
+// AllowOrigin reports whether a browser origin may call the API.
+// A "*." wildcard matches one or more subdomain labels, never the domain itself or another domain ending the same way.
+func (cp *CorsPolicy) AllowOrigin(origin string) bool {
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range cp.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		prefix, domain, found := strings.Cut(allowed, "*.")
+		if !found || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, "."+domain) {
+			continue
+		}
+		subdomain := origin[len(prefix) : len(origin)-len(domain)-1]
+		if subdomain != "" && !strings.ContainsAny(subdomain, "/:@*") {
+			return true
+		}
+	}
+	return false
+}
+
+// Check rejects a policy letting every origin send credentials, and one whose methods miss a registered route
+func (cp *CorsPolicy) Check(routes chi.Routes) error {
+
+	if cp.AllowCredentials && contains(cp.AllowedOrigins, "*") {
+		return errors.New("cors: credentials cannot be allowed for every origin")
+	}
+
+	methodsByRoute := map[string][]string{}
+	err := chi.Walk(routes, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		methodsByRoute[route] = append(methodsByRoute[route], method)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+	for route, methods := range methodsByRoute {
+		if len(methods) >= anyMethodCount {
+			continue
+		}
+		for _, method := range methods {
+			if !containsFold(corsIgnoredMethods, method) && !containsFold(cp.AllowedMethods, method) {
+				missing = append(missing, method+" "+route)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("cors: allowed methods %v do not cover %v", cp.AllowedMethods, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsPolicy_AllowOrigin(t *testing.T) {
+	policy := CorsPolicy{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org", "http://*.localhost:3000"}}
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "https://app.example.com", allowed: true},
+		{origin: "https://APP.example.com", allowed: true},
+		{origin: "http://app.example.com", allowed: false},
+		{origin: "https://other.example.com", allowed: false},
+		{origin: "https://app.example.org", allowed: true},
+		{origin: "https://a.b.example.org", allowed: true},
+		{origin: "https://example.org", allowed: false},
+		{origin: "https://.example.org", allowed: false},
+		{origin: "https://evilexample.org", allowed: false},
+		{origin: "https://example.org.evil.com", allowed: false},
+		{origin: "https://evil.com/.example.org", allowed: false},
+		{origin: "http://web.localhost:3000", allowed: true},
+		{origin: "http://web.localhost:4000", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, policy.AllowOrigin(tt.origin))
+		})
+	}
+}
+
+func TestCorsPolicy_Handler(t *testing.T) {
+	policy := CorsPolicy{AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: []string{"GET", "PUT"}, AllowCredentials: true}
+	r := chi.NewRouter()
+	r.Use(policy.Handler())
+	r.Put("/users/1", func(w http.ResponseWriter, r *http.Request) {})
+
+	preflight := func(origin string, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/users/1", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := preflight("https://app.example.com", http.MethodPut)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "PUT", rr.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+
+	assert.Empty(t, preflight("https://app.example.net", http.MethodPut).Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, preflight("https://app.example.com", http.MethodDelete).Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestCorsPolicy_Check(t *testing.T) {
+	userRoutes := UserRoutes{Controller: &controller.UserController{}}
+	r := chi.NewRouter()
+	Mount(r, userRoutes.Groups(), nil, nil, nil)
+
+	tests := []struct {
+		name   string
+		policy CorsPolicy
+		err    string
+	}{
+		{name: "Every method", policy: CorsPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"}}},
+		{
+			name:   "Missing PUT",
+			policy: CorsPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST", "PATCH", "DELETE"}},
+			err:    "cors: allowed methods [GET POST PATCH DELETE] do not cover PUT /users/{user_id}/",
+		},
+		{
+			name:   "Credentials for every origin",
+			policy: CorsPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"}, AllowCredentials: true},
+			err:    "cors: credentials cannot be allowed for every origin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(r)
+			if tt.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+)
+
+// Defaults of the double-submit cookie and the header echoing it
+const (
+	CSRF_COOKIE = "csrf_token"
+	CSRF_HEADER = "X-CSRF-Token"
+)
+
+const csrfTokenBytes = 32
+
+// Csrf protects browser sessions with double-submit cookies: safe requests get a random token in a cookie readable by
+// scripts of the page, which must send it back in a header with unsafe requests. Other sites can make browsers send
+// the cookie but cannot read it.
+//
+// Only requests carrying cookies, the credentials browsers send on their own, are checked. Requests sending an
+// Authorization header are not, browsers never add one to cross-site requests, and neither are those only presenting a
+// client certificate, which are made by service clients rather than by browser sessions.
+type Csrf struct {
+	// CookieName and HeaderName default to CSRF_COOKIE and CSRF_HEADER
+	CookieName string
+	HeaderName string
+}
+
+// Handler returns the middleware issuing tokens and rejecting unsafe requests without a matching one
+func (c *Csrf) Handler(next http.Handler) http.Handler {
+	cookieName, headerName := c.CookieName, c.HeaderName
+	if cookieName == "" {
+		cookieName = CSRF_COOKIE
+	}
+	if headerName == "" {
+		headerName = CSRF_HEADER
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		cookie, err := r.Cookie(cookieName)
+		if isSafeMethod(r.Method) {
+			if err != nil || cookie.Value == "" {
+				issueCsrfToken(w, r, cookieName)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "" || len(r.Cookies()) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(headerName)
+		if err != nil || cookie.Value == "" || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+			log.Info.Printf("Rejected %v %v without a valid CSRF token", r.Method, r.URL.Path)
+			utils.ResponseLocalizedError(w, r, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func issueCsrfToken(w http.ResponseWriter, r *http.Request, cookieName string) {
+	token := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		log.Error.Printf("Cannot generate a CSRF token: %v", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(token),
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCsrf_Handler(t *testing.T) {
+	handler := (&Csrf{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	session := &http.Cookie{Name: "session", Value: "abc"}
+	token := &http.Cookie{Name: CSRF_COOKIE, Value: "token"}
+
+	tests := []struct {
+		name           string
+		method         string
+		cookies        []*http.Cookie
+		header         string
+		authorization  string
+		clientCert     bool
+		expectedStatus int
+		issued         bool
+	}{
+		{name: "SAFE_ISSUES_TOKEN", method: http.MethodGet, expectedStatus: http.StatusOK, issued: true},
+		{name: "SAFE_KEEPS_TOKEN", method: http.MethodGet, cookies: []*http.Cookie{token}, expectedStatus: http.StatusOK},
+		{name: "UNSAFE_WITHOUT_COOKIES", method: http.MethodPost, expectedStatus: http.StatusOK},
+		{name: "UNSAFE_WITH_AUTHORIZATION", method: http.MethodPost, cookies: []*http.Cookie{session}, authorization: "Bearer abc", expectedStatus: http.StatusOK},
+		{name: "UNSAFE_WITHOUT_TOKEN", method: http.MethodPost, cookies: []*http.Cookie{session}, expectedStatus: http.StatusForbidden},
+		{name: "UNSAFE_WITHOUT_HEADER", method: http.MethodPut, cookies: []*http.Cookie{session, token}, expectedStatus: http.StatusForbidden},
+		{name: "UNSAFE_WRONG_HEADER", method: http.MethodDelete, cookies: []*http.Cookie{session, token}, header: "other", expectedStatus: http.StatusForbidden},
+		{name: "UNSAFE_HEADER_WITHOUT_COOKIE", method: http.MethodPost, cookies: []*http.Cookie{session}, header: "token", expectedStatus: http.StatusForbidden},
+		{name: "UNSAFE_MATCHING_TOKEN", method: http.MethodPost, cookies: []*http.Cookie{session, token}, header: "token", expectedStatus: http.StatusOK},
+		{name: "UNSAFE_CLIENT_CERT_WITHOUT_COOKIES", method: http.MethodPost, clientCert: true, expectedStatus: http.StatusOK},
+		{name: "UNSAFE_CLIENT_CERT_WITH_COOKIES", method: http.MethodPost, cookies: []*http.Cookie{session}, clientCert: true, expectedStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users/1", nil)
+			for _, cookie := range tt.cookies {
+				req.AddCookie(cookie)
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRF_HEADER, tt.header)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.clientCert {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			cookies := rr.Result().Cookies()
+			if !tt.issued {
+				assert.Empty(t, cookies)
+				return
+			}
+			assert.Len(t, cookies, 1)
+			assert.Equal(t, CSRF_COOKIE, cookies[0].Name)
+			assert.Len(t, cookies[0].Value, 43)
+			assert.False(t, cookies[0].HttpOnly)
+			assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package router
+
+import (
+	"net/http"
+)
+
+// swaggerUIScript starts Swagger UI on the documentation page. It is written here rather than rendered by a template,
+// so that the policy of the documentation pages allows it by its hash.
+const swaggerUIScript = `
+    window.onload = function() {
+      window.ui = SwaggerUIBundle({
+        url: 'swagger.yaml',
+        dom_id: '#swagger-ui',
+        deepLinking: true,
+        presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+        plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+        layout: 'StandaloneLayout'
+      })
+    }
+  `
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <title>API documentation</title>
+    <link rel="stylesheet" type="text/css" href="https://unpkg.com/swagger-ui-dist/swagger-ui.css">
+    <link rel="icon" type="image/png" href="https://unpkg.com/swagger-ui-dist/favicon-32x32.png" sizes="32x32" />
+    <link rel="icon" type="image/png" href="https://unpkg.com/swagger-ui-dist/favicon-16x16.png" sizes="16x16" />
+    <style>
+      html { box-sizing: border-box; overflow-y: scroll; }
+      *, *:before, *:after { box-sizing: inherit; }
+      body { margin: 0; background: #fafafa; }
+    </style>
+  </head>
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist/swagger-ui-bundle.js"> </script>
+    <script src="https://unpkg.com/swagger-ui-dist/swagger-ui-standalone-preset.js"> </script>
+    <script>` + swaggerUIScript + `</script>
+  </body>
+</html>
+`
+
+// swaggerUI serves the Swagger UI page of the spec
+func swaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
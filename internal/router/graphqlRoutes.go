@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/idempotency"
+	"net/http"
+)
+
+type GraphqlRoutes struct {
+	Handler http.Handler
+	// Idempotency replays the responses of the POST requests retried with the same Idempotency-Key, when set
+	Idempotency *idempotency.Guard
+}
+
+func (gr *GraphqlRoutes) GraphqlRoutes(r chi.Router) {
+	r.Get("/graphql", gr.Handler.ServeHTTP)                      // GET /graphql?query={users{total_count}}
+	r.With(gr.idempotent).Post("/graphql", gr.Handler.ServeHTTP) // POST /graphql
+}
+
+// idempotent applies the idempotency guard when one is configured
+func (gr *GraphqlRoutes) idempotent(next http.Handler) http.Handler {
+	if gr.Idempotency == nil {
+		return next
+	}
+	return gr.Idempotency.Middleware(next)
+}
+
+// Groups returns the route groups of the GraphQL API
+func (gr *GraphqlRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_GRAPHQL, Routes: gr.GraphqlRoutes},
+	}
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// Names of the route groups, used to configure which groups are public
+const (
+	GROUP_USERS    = "users"
+	GROUP_DOCS     = "docs"
+	GROUP_API_KEYS = "apikeys"
+	GROUP_WEBHOOKS = "webhooks"
+	GROUP_GRAPHQL  = "graphql"
+	GROUP_JOBS     = "jobs"
+	GROUP_SCIM     = "scim"
+)
+
+// Group is a named set of routes that can be made public or protected by configuration
+type Group struct {
+	Name   string
+	Routes func(r chi.Router)
+}
+
+// Groups returns the route groups of the user API
+func (ur *UserRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_USERS, Routes: ur.UserRoutes},
+		{Name: GROUP_DOCS, Routes: ur.SwaggerRoutes},
+	}
+}
+
+// Mount registers every group on the router, behind the authenticate middleware unless the group is listed as public.
+// Every group is public when authenticate is nil.
+// The limit middleware, when set, runs after authentication so that it can tell authenticated callers apart; requests
+// rejected by authentication are only limited by the IP limiter the router runs before it.
+func Mount(r chi.Router, groups []Group, authenticate func(http.Handler) http.Handler, limit func(http.Handler) http.Handler, public []string) {
+	for _, group := range groups {
+		routes := group.Routes
+		protected := authenticate != nil && !contains(public, group.Name)
+		r.Group(func(r chi.Router) {
+			if protected {
+				r.Use(authenticate)
+			}
+			if limit != nil {
+				r.Use(limit)
+			}
+			routes(r)
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
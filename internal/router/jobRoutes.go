@@ -0,0 +1,42 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+)
+
+type JobRoutes struct {
+	Controller *controller.JobController
+	// Codecs negotiates the request and response formats, the default registry is used when nil
+	Codecs *codec.Registry
+}
+
+func (jr *JobRoutes) JobRoutes(r chi.Router) {
+
+	codecs := jr.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+
+	// the file is sent in the format it was written in, so it is registered outside of the negotiated routes
+	r.Get("/jobs/{job_id}/file", jr.Controller.DownloadJobFile) // GET /jobs/3/file
+
+	r.Route("/jobs", func(r chi.Router) {
+		r.Use(negotiate(codecs))
+		r.Get("/", jr.Controller.ListJobs)                      // GET /jobs
+		r.Post("/import", jr.Controller.SubmitImport)           // POST /jobs/import
+		r.Post("/export", jr.Controller.SubmitExport)           // POST /jobs/export?format=csv
+		r.Post("/purge", jr.Controller.SubmitPurge)             // POST /jobs/purge?email=example.com
+		r.Post("/revalidate", jr.Controller.SubmitRevalidation) // POST /jobs/revalidate
+		r.Get("/{job_id}", jr.Controller.GetJob)                // GET /jobs/3
+		r.Post("/{job_id}/cancel", jr.Controller.CancelJob)     // POST /jobs/3/cancel
+	})
+}
+
+// Groups returns the route groups of the job API
+func (jr *JobRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_JOBS, Routes: jr.JobRoutes},
+	}
+}
@@ -0,0 +1,39 @@
+package router
+
+import (
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
+	"net/http"
+)
+
+// negotiate rejects requests whose body or expected response format has no codec in the registry,
+// and puts the registry and the codec negotiated for the response on the request context
+func negotiate(registry *codec.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			response, ok := registry.ForAccept(r.Header.Get("Accept"))
+			if !ok {
+				utils.ResponseLocalizedError(w, r, http.StatusNotAcceptable)
+				return
+			}
+
+			if hasBody(r) {
+				if _, ok := registry.ForContentType(r.Header.Get("Content-Type")); !ok {
+					utils.ResponseLocalizedError(w, r, http.StatusUnsupportedMediaType)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(codec.NewContext(r.Context(), registry, response)))
+		})
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength != 0
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectToHttps redirects every request to the same URL over HTTPS on the given port
+func RedirectToHttps(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
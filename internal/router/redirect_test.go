@@ -0,0 +1,30 @@
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHttps(t *testing.T) {
+	tests := []struct {
+		port     string
+		url      string
+		location string
+	}{
+		{port: "8443", url: "http://localhost:8089/users/1?fields=email", location: "https://localhost:8443/users/1?fields=email"},
+		{port: "443", url: "http://api.example.com/users/", location: "https://api.example.com/users/"},
+		{port: "8443", url: "http://[::1]:8089/docs", location: "https://[::1]:8443/docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			RedirectToHttps(tt.port).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, tt.url, nil))
+
+			assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+			assert.Equal(t, tt.location, rr.Header().Get("Location"))
+		})
+	}
+}
@@ -3,20 +3,49 @@ package router
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/idempotency"
 	"net/http"
-	//"github.com/go-chi/jwtauth/v5"
+)
+
+// Routes of long-lived requests, which are not cut by the request timeout
+const (
+	// EVENTS_PATH streams the user events until the client leaves
+	EVENTS_PATH = "/users/events"
+	// EXPORT_PATH exports the users, which may take longer than the request timeout
+	EXPORT_PATH = "/users/export"
+	// IMPORT_PATH imports users, which may take longer than the request timeout
+	IMPORT_PATH = "/users/import"
 )
 
 type UserRoutes struct {
 	Controller *controller.UserController
+	// Codecs negotiates the request and response formats, the default registry is used when nil
+	Codecs *codec.Registry
+	// Idempotency replays the responses of user creations retried with the same Idempotency-Key, when set
+	Idempotency *idempotency.Guard
 }
 
 func (ur *UserRoutes) UserRoutes(r chi.Router) {
 
+	codecs := ur.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+
+	// the stream is always text/event-stream, so it is registered outside of the negotiated routes
+	r.Get(EVENTS_PATH, ur.Controller.StreamEvents) // GET /users/events
+	// the export chooses its format from its format parameter, falling back to the Accept header
+	r.Get(EXPORT_PATH, ur.Controller.ExportUsers) // GET /users/export?format=csv
+
 	r.Route("/users", func(r chi.Router) {
-		r.With(paginate).Get("/", ur.Controller.ListUsers)
-		r.Post("/", ur.Controller.SaveUser) // POST /users
+		r.Use(negotiate(codecs))
+		r.Get("/", ur.Controller.ListUsers)                          // GET /users?limit=100&after_id=200
+		r.With(ur.idempotent).Post("/", ur.Controller.SaveUser)      // POST /users
+		r.Post("/validate", ur.Controller.ValidateUser)              // POST /users/validate
+		r.Post("/validate/{field}", ur.Controller.ValidateUserField) // POST /users/validate/first_name
+		r.Post("/import", ur.Controller.ImportUsers)                 // POST /users/import
 		////r.Get("/search", SearchUsers) // GET /users/search
 
 		r.Route("/{user_id}", func(r chi.Router) {
@@ -31,24 +60,21 @@ func (ur *UserRoutes) UserRoutes(r chi.Router) {
 }
 
 func (ur *UserRoutes) SwaggerRoutes(r chi.Router) {
-	// Serve the Swagger JSON
-	r.Handle("/swagger.yaml", http.FileServer(http.Dir("./api")))
-	opts := middleware.SwaggerUIOpts{SpecURL: "swagger.yaml"}
-	sh := middleware.SwaggerUI(opts, nil)
-	r.Handle("/docs", sh)
-
-	// documentation for share
+	// documentation for share, Redoc has no inline script
 	opts1 := middleware.RedocOpts{SpecURL: "swagger.yaml", Path: "doc"}
 	sh1 := middleware.Redoc(opts1, nil)
+
+	r.Use(contentSecurityPolicy(DocsContentSecurityPolicy(swaggerUIScript)))
+	// Serve the Swagger JSON
+	r.Handle("/swagger.yaml", http.FileServer(http.Dir("./api")))
+	r.Get("/docs", swaggerUI)
 	r.Handle("/doc", sh1)
 }
 
-// paginate is a stub, but very possible to implement middleware logic
-// to handle the request params for handling a paginated request.
-func paginate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// just a stub.. some ideas are to look at URL query params for something like
-		// the page number, or the limit, and send a query cursor down the chain
-		next.ServeHTTP(w, r)
-	})
+// idempotent applies the idempotency guard when one is configured
+func (ur *UserRoutes) idempotent(next http.Handler) http.Handler {
+	if ur.Idempotency == nil {
+		return next
+	}
+	return ur.Idempotency.Middleware(next)
 }
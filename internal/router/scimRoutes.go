@@ -0,0 +1,34 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/scim"
+)
+
+type ScimRoutes struct {
+	Handler *scim.Handler
+}
+
+func (sr *ScimRoutes) ScimRoutes(r chi.Router) {
+
+	r.Route(scim.PATH, func(r chi.Router) {
+		r.Get("/Users", sr.Handler.ListUsers)                                // GET /scim/v2/Users?filter=userName eq "john.doe@gmail.com"
+		r.Post("/Users", sr.Handler.CreateUser)                              // POST /scim/v2/Users
+		r.Get("/Users/{id}", sr.Handler.GetUser)                             // GET /scim/v2/Users/3
+		r.Put("/Users/{id}", sr.Handler.ReplaceUser)                         // PUT /scim/v2/Users/3
+		r.Patch("/Users/{id}", sr.Handler.PatchUser)                         // PATCH /scim/v2/Users/3
+		r.Delete("/Users/{id}", sr.Handler.DeleteUser)                       // DELETE /scim/v2/Users/3
+		r.Get("/ServiceProviderConfig", sr.Handler.GetServiceProviderConfig) // GET /scim/v2/ServiceProviderConfig
+		r.Get("/ResourceTypes", sr.Handler.ListResourceTypes)                // GET /scim/v2/ResourceTypes
+		r.Get("/ResourceTypes/{id}", sr.Handler.GetResourceType)             // GET /scim/v2/ResourceTypes/User
+		r.Get("/Schemas", sr.Handler.ListSchemas)                            // GET /scim/v2/Schemas
+		r.Get("/Schemas/{id}", sr.Handler.GetSchema)                         // GET /scim/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:User
+	})
+}
+
+// Groups returns the route groups of the SCIM API
+func (sr *ScimRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_SCIM, Routes: sr.ScimRoutes},
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API_CONTENT_SECURITY_POLICY lets API responses load nothing and be framed nowhere, they are never rendered as pages
+const API_CONTENT_SECURITY_POLICY = "default-src 'none'; frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// docsContentSecurityPolicy lets the Swagger UI and Redoc pages load their bundles from unpkg and jsdelivr,
+// Redoc its fonts and web workers, and both call the API. Inline scripts are only allowed by hash.
+const docsContentSecurityPolicy = "default-src 'none'; script-src 'self' https://unpkg.com https://cdn.jsdelivr.net%v; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com https://fonts.googleapis.com; font-src https://fonts.gstatic.com; " +
+	"img-src 'self' data: https://unpkg.com https://cdn.jsdelivr.net; connect-src 'self'; worker-src blob:; " +
+	"frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// SecurityHeaders adds the headers protecting browsers rendering responses of the API
+type SecurityHeaders struct {
+	// HstsMaxAge is how long browsers only use HTTPS for the host, sent over TLS only and disabled when zero
+	HstsMaxAge time.Duration
+	// FrameOptions is "DENY" or "SAMEORIGIN"
+	FrameOptions string
+	// ContentSecurityPolicy applies to every route but the documentation pages
+	ContentSecurityPolicy string
+}
+
+// Handler returns the middleware adding the security headers to every response
+func (sh *SecurityHeaders) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		header := w.Header()
+		if sh.HstsMaxAge > 0 && r.TLS != nil {
+			header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int64(sh.HstsMaxAge.Seconds())))
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		if sh.FrameOptions != "" {
+			header.Set("X-Frame-Options", sh.FrameOptions)
+		}
+		if sh.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", sh.ContentSecurityPolicy)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DocsContentSecurityPolicy returns the policy of the documentation pages, allowing their inline scripts by their
+// SHA-256 hash
+func DocsContentSecurityPolicy(inlineScripts ...string) string {
+
+	var hashes strings.Builder
+	for _, script := range inlineScripts {
+		sum := sha256.Sum256([]byte(script))
+		hashes.WriteString(" 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'")
+	}
+	return fmt.Sprintf(docsContentSecurityPolicy, hashes.String())
+}
+
+// contentSecurityPolicy replaces the policy set by SecurityHeaders on the routes it is used on
+func contentSecurityPolicy(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if w.Header().Get("Content-Security-Policy") != "" {
+				w.Header().Set("Content-Security-Policy", policy)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders_Handler(t *testing.T) {
+	headers := SecurityHeaders{HstsMaxAge: 24 * time.Hour, FrameOptions: "DENY", ContentSecurityPolicy: API_CONTENT_SECURITY_POLICY}
+	r := chi.NewRouter()
+	r.Use(headers.Handler)
+	r.Get("/users/1", func(w http.ResponseWriter, r *http.Request) {})
+	r.Group((&UserRoutes{}).SwaggerRoutes)
+
+	tests := []struct {
+		name string
+		path string
+		tls  bool
+		hsts string
+		csp  string
+	}{
+		{name: "API", path: "/users/1", csp: API_CONTENT_SECURITY_POLICY},
+		{name: "API_OVER_TLS", path: "/users/1", tls: true, hsts: "max-age=86400; includeSubDomains", csp: API_CONTENT_SECURITY_POLICY},
+		{name: "SWAGGER_UI", path: "/docs", csp: "script-src 'self' https://unpkg.com https://cdn.jsdelivr.net 'sha256-"},
+		{name: "REDOC", path: "/doc", csp: "script-src 'self' https://unpkg.com https://cdn.jsdelivr.net 'sha256-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tt.hsts, rr.Header().Get("Strict-Transport-Security"))
+			assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "DENY", rr.Header().Get("X-Frame-Options"))
+			assert.Contains(t, rr.Header().Get("Content-Security-Policy"), tt.csp)
+		})
+	}
+}
+
+func TestDocsContentSecurityPolicy(t *testing.T) {
+	policy := DocsContentSecurityPolicy("start()")
+
+	// echo -n 'start()' | openssl dgst -sha256 -binary | base64
+	assert.True(t, strings.HasPrefix(policy, "default-src 'none'; script-src 'self' https://unpkg.com https://cdn.jsdelivr.net "+
+		"'sha256-DIm7WJS6ZKDYe5qFLPy+h4JFI9Bol5QmYC57mt3Fb00='; style-src"))
+}
+
+func TestSwaggerRoutes_InlineScripts(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use((&SecurityHeaders{ContentSecurityPolicy: API_CONTENT_SECURITY_POLICY}).Handler)
+	r.Group((&UserRoutes{}).SwaggerRoutes)
+	inlineScript := regexp.MustCompile(`(?s)<script>(.*?)</script>`)
+
+	for _, path := range []string{"/docs", "/doc"} {
+		t.Run(path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+			// every inline script of the page is allowed by its hash
+			for _, match := range inlineScript.FindAllStringSubmatch(rr.Body.String(), -1) {
+				sum := sha256.Sum256([]byte(match[1]))
+				assert.Contains(t, rr.Header().Get("Content-Security-Policy"), "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+			}
+		})
+	}
+}
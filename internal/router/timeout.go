@@ -0,0 +1,26 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"time"
+)
+
+// untimedRoutes are the methods of the routes that stay open longer than the request timeout, by path
+var untimedRoutes = map[string]string{EVENTS_PATH: http.MethodGet, EXPORT_PATH: http.MethodGet, IMPORT_PATH: http.MethodPost}
+
+// Timeout cancels the requests taking longer than timeout like middleware.Timeout, except the event stream which
+// stays open until its client leaves, and the export and the import which take as long as the number of users
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	withTimeout := middleware.Timeout(timeout)
+	return func(next http.Handler) http.Handler {
+		limited := withTimeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if method, found := untimedRoutes[r.URL.Path]; found && r.Method == method {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		deadline bool
+	}{
+		{name: "USERS", method: http.MethodGet, path: "/users/", deadline: true},
+		{name: "EVENTS", method: http.MethodGet, path: EVENTS_PATH, accept: sse.CONTENT_TYPE, deadline: false},
+		{name: "EVENTS_WITHOUT_ACCEPT", method: http.MethodGet, path: EVENTS_PATH, deadline: false},
+		{name: "EXPORT", method: http.MethodGet, path: EXPORT_PATH, deadline: false},
+		{name: "USERS_ACCEPTING_EVENT_STREAM", method: http.MethodGet, path: "/users/", accept: sse.CONTENT_TYPE, deadline: true},
+		{name: "IMPORT", method: http.MethodPost, path: IMPORT_PATH, deadline: false},
+		{name: "EXPORT_OTHER_METHOD", method: http.MethodPost, path: EXPORT_PATH, deadline: true},
+		{name: "IMPORT_OTHER_METHOD", method: http.MethodGet, path: IMPORT_PATH, accept: sse.CONTENT_TYPE, deadline: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			var deadline bool
+			handler := Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, deadline = r.Context().Deadline()
+			}))
+			request := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.accept != "" {
+				request.Header.Set("Accept", tt.accept)
+			}
+
+			// When
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			// Then
+			assert.Equal(t, tt.deadline, deadline)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+)
+
+type WebhookRoutes struct {
+	Controller *controller.WebhookController
+	// Codecs negotiates the request and response formats, the default registry is used when nil
+	Codecs *codec.Registry
+}
+
+func (wr *WebhookRoutes) WebhookRoutes(r chi.Router) {
+
+	codecs := wr.Codecs
+	if codecs == nil {
+		codecs = codec.Default()
+	}
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(negotiate(codecs))
+		r.Get("/", wr.Controller.ListWebhooks)                                              // GET /webhooks
+		r.Post("/", wr.Controller.CreateWebhook)                                            // POST /webhooks
+		r.Delete("/{webhook_id}", wr.Controller.DeleteWebhook)                              // DELETE /webhooks/3
+		r.Get("/{webhook_id}/deliveries", wr.Controller.ListDeliveries)                     // GET /webhooks/3/deliveries
+		r.Post("/{webhook_id}/deliveries/{delivery_id}/redeliver", wr.Controller.Redeliver) // POST /webhooks/3/deliveries/7/redeliver
+	})
+}
+
+// Groups returns the route groups of the webhook API
+func (wr *WebhookRoutes) Groups() []Group {
+	return []Group{
+		{Name: GROUP_WEBHOOKS, Routes: wr.WebhookRoutes},
+	}
+}
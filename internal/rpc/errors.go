@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// codesByStatus maps the HTTP statuses of the MessageErr sent by the REST API to gRPC codes
+var codesByStatus = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+}
+
+// CodeFromStatus returns the gRPC code of an HTTP status, Unknown when there is none
+func CodeFromStatus(httpStatus int) codes.Code {
+	if code, ok := codesByStatus[httpStatus]; ok {
+		return code
+	}
+	return codes.Unknown
+}
+
+// statusFromError logs an error returned by the service layer and translates it into a gRPC status, with the message
+// the REST API sends. Validation errors also carry their field violations as BadRequest details.
+func statusFromError(ctx context.Context, err error) error {
+
+	log.Error.Println(err)
+	msgErr := controller.MessageErrFromError(ctx, err)
+	st := status.New(CodeFromStatus(msgErr.Status()), msgErr.Message())
+
+	var appErr *apperror.Error
+	if errors.Is(err, apperror.ErrValidation) && errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+		t := i18n.FromContext(ctx)
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldErr := range appErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldErr.Field,
+				Description: t.FieldError(fieldErr),
+			})
+		}
+		if detailed, err := st.WithDetails(badRequest); err == nil {
+			st = detailed
+		}
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/rpc/userv1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// routes are the REST routes the methods mirror, whose rate limits and buckets they share
+var routes = map[string]struct{ method, path string }{
+	userv1.UserService_GetUser_FullMethodName:    {http.MethodGet, "/users/{user_id}"},
+	userv1.UserService_ListUsers_FullMethodName:  {http.MethodGet, "/users"},
+	userv1.UserService_CreateUser_FullMethodName: {http.MethodPost, "/users"},
+	userv1.UserService_UpdateUser_FullMethodName: {http.MethodPut, "/users/{user_id}"},
+	userv1.UserService_DeleteUser_FullMethodName: {http.MethodDelete, "/users/{user_id}"},
+	userv1.UserService_WatchUsers_FullMethodName: {http.MethodGet, "/users/events"},
+}
+
+// Server assembles the gRPC API, with the authentication, rate limits and localization of the REST API
+type Server struct {
+	Users *UserServer
+	// Authenticators check the credentials sent in the authorization metadata, e.g. "Bearer <token>".
+	// Every call is accepted when there are none and ClientCertificate is nil.
+	Authenticators []auth.IAuthenticator
+	// ClientCertificate authenticates the callers presenting a client certificate, when set
+	ClientCertificate *auth.ClientCertificate
+	// IpLimiter throttles the calls of each client IP before they are authenticated, when set
+	IpLimiter *ratelimit.Limiter
+	// Limiter throttles the calls of each client once authenticated, when set. A method shares the limit and the
+	// buckets of the REST route it mirrors, so that a client cannot get around the limit of a route by using gRPC.
+	Limiter *ratelimit.Limiter
+	// Catalog localizes the error messages from the accept-language metadata, the default catalog is used when nil
+	Catalog *i18n.Catalog
+	// TLSConfig serves the API over TLS, in plain text when nil
+	TLSConfig *tls.Config
+}
+
+// GrpcServer returns a gRPC server serving the API, to be started with Serve
+func (s *Server) GrpcServer() *grpc.Server {
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	}
+	if s.TLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.TLSConfig)))
+	}
+
+	server := grpc.NewServer(options...)
+	userv1.RegisterUserServiceServer(server, s.Users)
+	return server
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+	start := time.Now()
+	ctx, err := s.prepare(ctx, info.FullMethod)
+	var response interface{}
+	if err == nil {
+		response, err = handler(ctx, request)
+	}
+	log.Info.Printf("gRPC %v %v in %v", info.FullMethod, status.Code(err), time.Since(start))
+	return response, err
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	start := time.Now()
+	ctx, err := s.prepare(stream.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+	log.Info.Printf("gRPC %v %v in %v", info.FullMethod, status.Code(err), time.Since(start))
+	return err
+}
+
+// prepare puts the translator and the claims of the caller on the context of a call, or returns the status rejecting
+// the call when the caller is not authenticated or exceeds its rate limit
+func (s *Server) prepare(ctx context.Context, fullMethod string) (context.Context, error) {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	catalog := s.Catalog
+	if catalog == nil {
+		catalog = i18n.Default()
+	}
+	t := catalog.Translator(i18n.ParseAcceptLanguage(first(md, "accept-language"))...)
+	ctx = i18n.NewContext(ctx, t)
+
+	// with no credentials read yet the ip limiter keys every call by its IP, like the REST API
+	if err := s.limit(ctx, s.IpLimiter, fullMethod); err != nil {
+		return ctx, err
+	}
+	ctx, err := s.authenticate(ctx, md)
+	if err != nil {
+		return ctx, err
+	}
+	return ctx, s.limit(ctx, s.Limiter, fullMethod)
+}
+
+// authenticate puts the claims of the caller on the context of a call
+func (s *Server) authenticate(ctx context.Context, md metadata.MD) (context.Context, error) {
+
+	if len(s.Authenticators) == 0 && s.ClientCertificate == nil {
+		return ctx, nil
+	}
+
+	t := i18n.FromContext(ctx)
+	authorization := first(md, "authorization")
+	if authorization == "" {
+		if claims, ok := s.certificateClaims(ctx); ok {
+			return auth.NewContext(ctx, claims), nil
+		}
+	}
+
+	scheme, credentials, _ := strings.Cut(authorization, " ")
+	credentials = strings.TrimSpace(credentials)
+	for _, authenticator := range s.Authenticators {
+		if !strings.EqualFold(scheme, authenticator.Scheme()) || credentials == "" {
+			continue
+		}
+
+		claims, err := authenticator.Authenticate(ctx, credentials)
+		if errors.Is(err, apperror.ErrUnavailable) {
+			log.Error.Println(err)
+			return ctx, status.Error(codes.Unavailable, t.Status(http.StatusServiceUnavailable))
+		}
+		if err != nil {
+			log.Info.Printf("Rejected %v credentials: %v", authenticator.Scheme(), err)
+			break
+		}
+		return auth.NewContext(ctx, claims), nil
+	}
+
+	return ctx, status.Error(codes.Unauthenticated, t.Status(http.StatusUnauthorized))
+}
+
+// limit takes a token for a call from the bucket of the REST route its method mirrors, and returns a ResourceExhausted
+// status telling when to retry once the bucket is empty
+func (s *Server) limit(ctx context.Context, limiter *ratelimit.Limiter, fullMethod string) error {
+
+	if limiter == nil {
+		return nil
+	}
+	route, ok := routes[fullMethod]
+	if !ok {
+		route.method, route.path = http.MethodPost, fullMethod
+	}
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+
+	_, result := limiter.Take(ctx, route.method, route.path, ratelimit.Key(ctx, remoteAddr))
+	if result.Allowed {
+		return nil
+	}
+	st := status.New(codes.ResourceExhausted, i18n.FromContext(ctx).Status(http.StatusTooManyRequests))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// certificateClaims returns the claims of a caller whose client certificate was verified during the TLS handshake
+func (s *Server) certificateClaims(ctx context.Context) (*auth.Claims, bool) {
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || s.ClientCertificate == nil {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	subject := tlsInfo.State.VerifiedChains[0][0].Subject
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: auth.CERT_SUBJECT + subject.String()},
+		Roles:            s.ClientCertificate.Roles[subject.CommonName],
+	}, true
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream replaces the context of a stream with the one carrying the translator and claims
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (cs *contextStream) Context() context.Context {
+	return cs.ctx
+}
@@ -0,0 +1,236 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/rpc/userv1"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+)
+
+// Page sizes of ListUsers
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+const (
+	ERROR_PAGE_TOKEN_INVALID = "page_token is invalid"
+	ERROR_USER_REQUIRED      = "user is required"
+	ERROR_WATCH_FELL_BEHIND  = "watch fell behind the user events, resume with last_event_id"
+)
+
+// UserServer implements the gRPC UserService on top of the service layer of the REST API, authorizing each call with
+// the same policy
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	UserService service.IUserService
+	// Policy decides which callers may perform each operation
+	Policy authz.IPolicy
+	// Events streams the changes of users to WatchUsers, which is unimplemented when nil
+	Events *sse.Broker
+}
+
+func (us *UserServer) GetUser(ctx context.Context, request *userv1.GetUserRequest) (*userv1.User, error) {
+
+	if err := us.authorize(ctx, authz.ACTION_READ, request.GetId()); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	user, err := us.UserService.GetUser(request.GetId())
+	if err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	return toUserMessage(user), nil
+}
+
+// ListUsers pages through the users ordered by id, the page token being the id of the last user of the previous page
+func (us *UserServer) ListUsers(ctx context.Context, request *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+
+	if err := us.authorize(ctx, authz.ACTION_LIST, 0); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	afterId, err := parsePageToken(request.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ERROR_PAGE_TOKEN_INVALID)
+	}
+	pageSize := int(request.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	// one more user than the page tells whether there is a next page
+	users, err := us.UserService.ListUsersAfter(model.UserFilter{}, afterId, pageSize+1)
+	if err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	response := &userv1.ListUsersResponse{Users: []*userv1.User{}}
+	for i := 0; i < len(users) && i < pageSize; i++ {
+		response.Users = append(response.Users, toUserMessage(&users[i]))
+	}
+	if len(users) > pageSize {
+		response.NextPageToken = formatPageToken(users[pageSize-1].Id)
+	}
+
+	return response, nil
+}
+
+func (us *UserServer) CreateUser(ctx context.Context, request *userv1.CreateUserRequest) (*userv1.User, error) {
+
+	if err := us.authorize(ctx, authz.ACTION_CREATE, 0); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+	if request.GetUser() == nil {
+		return nil, status.Error(codes.InvalidArgument, ERROR_USER_REQUIRED)
+	}
+
+	user := toUser(request.GetUser())
+	user.Id = 0
+	created, err := us.UserService.SaveUser(user)
+	if err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	return toUserMessage(created), nil
+}
+
+func (us *UserServer) UpdateUser(ctx context.Context, request *userv1.UpdateUserRequest) (*userv1.User, error) {
+
+	if request.GetUser() == nil {
+		return nil, status.Error(codes.InvalidArgument, ERROR_USER_REQUIRED)
+	}
+	if err := us.authorize(ctx, authz.ACTION_UPDATE, request.GetUser().GetId()); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	updated, err := us.UserService.UpdateUser(toUser(request.GetUser()))
+	if err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	return toUserMessage(updated), nil
+}
+
+func (us *UserServer) DeleteUser(ctx context.Context, request *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+
+	if err := us.authorize(ctx, authz.ACTION_DELETE, request.GetId()); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	if err := us.UserService.DeleteUser(request.GetId()); err != nil {
+		return nil, statusFromError(ctx, err)
+	}
+
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// WatchUsers sends the changes of users until the client cancels, resuming after last_event_id like the
+// Last-Event-ID of the event stream. A watch that falls behind ends with Unavailable, and should be resumed.
+func (us *UserServer) WatchUsers(request *userv1.WatchUsersRequest, stream userv1.UserService_WatchUsersServer) error {
+
+	ctx := stream.Context()
+	if us.Events == nil {
+		return us.UnimplementedUserServiceServer.WatchUsers(request, stream)
+	}
+	if err := us.authorize(ctx, authz.ACTION_LIST, 0); err != nil {
+		return statusFromError(ctx, err)
+	}
+
+	subscription := us.Events.Subscribe(request.LastEventId)
+	defer us.Events.Unsubscribe(subscription)
+
+	if subscription.Reset {
+		if err := stream.Send(&userv1.UserEvent{Id: subscription.LastId, Type: sse.EVENT_RESET}); err != nil {
+			return err
+		}
+	}
+	for _, event := range subscription.Missed {
+		if err := stream.Send(toEventMessage(event)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return status.Error(codes.Unavailable, ERROR_WATCH_FELL_BEHIND)
+			}
+			if err := stream.Send(toEventMessage(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// authorize asks the policy whether the caller may perform the action, on the given user when userId is not zero
+func (us *UserServer) authorize(ctx context.Context, action string, userId int64) error {
+	return authz.Authorize(ctx, us.Policy, action, userId)
+}
+
+func toUser(message *userv1.User) *model.User {
+	return &model.User{
+		Id:        message.GetId(),
+		FirstName: message.GetFirstName(),
+		LastName:  message.GetLastName(),
+		Email:     message.GetEmail(),
+		Age:       message.GetAge(),
+	}
+}
+
+func toUserMessage(user *model.User) *userv1.User {
+	return &userv1.User{
+		Id:        user.Id,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		Age:       user.Age,
+	}
+}
+
+func toEventMessage(event model.Event) *userv1.UserEvent {
+	message := &userv1.UserEvent{
+		Id:         event.Id,
+		Type:       event.Type,
+		UserId:     event.UserId,
+		OccurredAt: timestamppb.New(event.OccurredAt),
+	}
+	if event.Type != model.EVENT_USER_DELETED {
+		var user model.User
+		if err := json.Unmarshal(event.Data, &user); err == nil {
+			message.User = toUserMessage(&user)
+		}
+	}
+	return message
+}
+
+func formatPageToken(lastId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastId, 10)))
+}
+
+func parsePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(decoded), 10, 64)
+}
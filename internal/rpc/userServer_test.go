@@ -0,0 +1,424 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	mocksAuthz "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/authz"
+	mocks "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/ratelimit"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/rpc/userv1"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type authenticatorMock struct {
+	authenticate func(credentials string) (*auth.Claims, error)
+}
+
+func (am *authenticatorMock) Scheme() string {
+	return "Bearer"
+}
+
+func (am *authenticatorMock) Authenticate(ctx context.Context, credentials string) (*auth.Claims, error) {
+	return am.authenticate(credentials)
+}
+
+// dial serves the server on an in-memory listener, and returns a client calling it
+func dial(t *testing.T, server *Server) userv1.UserServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := server.GrpcServer()
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return userv1.NewUserServiceClient(conn)
+}
+
+func TestCodeFromStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		code   codes.Code
+	}{
+		{status: http.StatusBadRequest, code: codes.InvalidArgument},
+		{status: http.StatusUnauthorized, code: codes.Unauthenticated},
+		{status: http.StatusForbidden, code: codes.PermissionDenied},
+		{status: http.StatusNotFound, code: codes.NotFound},
+		{status: http.StatusConflict, code: codes.AlreadyExists},
+		{status: http.StatusTooManyRequests, code: codes.ResourceExhausted},
+		{status: http.StatusInternalServerError, code: codes.Internal},
+		{status: http.StatusServiceUnavailable, code: codes.Unavailable},
+		{status: http.StatusTeapot, code: codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.code, CodeFromStatus(tt.status))
+		})
+	}
+}
+
+func TestUserServer(t *testing.T) {
+	john := &model.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}
+	johnMessage := &userv1.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}
+
+	tests := []struct {
+		name     string
+		mock     func(userService *mocks.IUserService)
+		call     func(client userv1.UserServiceClient) (proto.Message, error)
+		code     codes.Code
+		response proto.Message
+	}{
+		{
+			name: "Get user",
+			mock: func(userService *mocks.IUserService) { userService.On("GetUser", int64(1)).Return(john, nil) },
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.GetUser(context.Background(), &userv1.GetUserRequest{Id: 1})
+			},
+			code:     codes.OK,
+			response: johnMessage,
+		},
+		{
+			name: "Get missing user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(2)).Return(nil, apperror.NotFound("user not found", nil))
+			},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.GetUser(context.Background(), &userv1.GetUserRequest{Id: 2})
+			},
+			code: codes.NotFound,
+		},
+		{
+			name: "Create user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", &model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}).Return(john, nil)
+			},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				// the id is given by the server
+				return client.CreateUser(context.Background(), &userv1.CreateUserRequest{User: &userv1.User{Id: 7, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}})
+			},
+			code:     codes.OK,
+			response: johnMessage,
+		},
+		{
+			name: "Create user without user",
+			mock: func(userService *mocks.IUserService) {},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.CreateUser(context.Background(), &userv1.CreateUserRequest{})
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "Create duplicate user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", mock.Anything).Return(nil, apperror.Conflict("user already exists", nil))
+			},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.CreateUser(context.Background(), &userv1.CreateUserRequest{User: johnMessage})
+			},
+			code: codes.AlreadyExists,
+		},
+		{
+			name: "Update user",
+			mock: func(userService *mocks.IUserService) { userService.On("UpdateUser", john).Return(john, nil) },
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.UpdateUser(context.Background(), &userv1.UpdateUserRequest{User: johnMessage})
+			},
+			code:     codes.OK,
+			response: johnMessage,
+		},
+		{
+			name: "Update user with the database down",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("UpdateUser", john).Return(nil, apperror.Unavailable("database unavailable", errors.New("connection refused")))
+			},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.UpdateUser(context.Background(), &userv1.UpdateUserRequest{User: johnMessage})
+			},
+			code: codes.Unavailable,
+		},
+		{
+			name: "Delete user",
+			mock: func(userService *mocks.IUserService) { userService.On("DeleteUser", int64(1)).Return(nil) },
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.DeleteUser(context.Background(), &userv1.DeleteUserRequest{Id: 1})
+			},
+			code:     codes.OK,
+			response: &userv1.DeleteUserResponse{},
+		},
+		{
+			name: "Delete user failing",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("DeleteUser", int64(1)).Return(apperror.Internal("unable to delete user", errors.New("disk full")))
+			},
+			call: func(client userv1.UserServiceClient) (proto.Message, error) {
+				return client.DeleteUser(context.Background(), &userv1.DeleteUserRequest{Id: 1})
+			},
+			code: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := new(mocks.IUserService)
+			tt.mock(userService)
+			client := dial(t, &Server{Users: &UserServer{UserService: userService}})
+
+			response, err := tt.call(client)
+
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.response != nil {
+				assert.True(t, proto.Equal(tt.response, response), "%v", response)
+			}
+			userService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserServer_Validation(t *testing.T) {
+	userService := new(mocks.IUserService)
+	userService.On("SaveUser", mock.Anything).Return(nil,
+		apperror.Validation("invalid user", []model.FieldError{{Field: "first_name", Code: "required", Message: "invalid_request"}}))
+	client := dial(t, &Server{Users: &UserServer{UserService: userService}})
+
+	tests := []struct {
+		name        string
+		language    string
+		description string
+	}{
+		{name: "Default language", description: "User first name is required"},
+		{name: "Accept-Language", language: "fr", description: "Le champ prénom est obligatoire"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.language != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "accept-language", tt.language)
+			}
+
+			_, err := client.CreateUser(ctx, &userv1.CreateUserRequest{User: &userv1.User{LastName: "Doe"}})
+
+			st := status.Convert(err)
+			assert.Equal(t, codes.InvalidArgument, st.Code())
+			if assert.Len(t, st.Details(), 1) {
+				badRequest := st.Details()[0].(*errdetails.BadRequest)
+				assert.Equal(t, "first_name", badRequest.FieldViolations[0].Field)
+				assert.Equal(t, tt.description, badRequest.FieldViolations[0].Description)
+			}
+		})
+	}
+}
+
+func TestUserServer_ListUsers(t *testing.T) {
+	users := []model.User{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}, {Id: 5}}
+	userService := new(mocks.IUserService)
+	userService.On("ListUsersAfter", model.UserFilter{}, mock.Anything, 3).Return(func(filter model.UserFilter, afterId int64, limit int) []model.User {
+		page := []model.User{}
+		for _, user := range users {
+			if user.Id > afterId && len(page) < limit {
+				page = append(page, user)
+			}
+		}
+		return page
+	}, nil)
+	client := dial(t, &Server{Users: &UserServer{UserService: userService}})
+
+	pages := [][]int64{}
+	token := ""
+	for {
+		response, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{PageSize: 2, PageToken: token})
+		if !assert.NoError(t, err) {
+			return
+		}
+		page := []int64{}
+		for _, user := range response.Users {
+			page = append(page, user.Id)
+		}
+		pages = append(pages, page)
+		if token = response.NextPageToken; token == "" {
+			break
+		}
+	}
+	assert.Equal(t, [][]int64{{1, 2}, {3, 4}, {5}}, pages)
+
+	_, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{PageToken: "not a token"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUserServer_WatchUsers(t *testing.T) {
+	broker := &sse.Broker{}
+	broker.Publish(model.EVENT_USER_CREATED, 1, model.User{Id: 1, FirstName: "John"})
+	broker.Publish(model.EVENT_USER_DELETED, 1, model.UserDeletedData{Id: 1})
+	client := dial(t, &Server{Users: &UserServer{UserService: new(mocks.IUserService), Events: broker}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lastEventId := int64(1)
+	stream, err := client.WatchUsers(ctx, &userv1.WatchUsersRequest{LastEventId: &lastEventId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the missed event is resent
+	event, err := stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), event.Id)
+		assert.Equal(t, model.EVENT_USER_DELETED, event.Type)
+		assert.Nil(t, event.User)
+	}
+
+	broker.Publish(model.EVENT_USER_UPDATED, 1, model.User{Id: 1, FirstName: "Jane"})
+	event, err = stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), event.Id)
+		assert.Equal(t, "Jane", event.User.GetFirstName())
+		assert.NotNil(t, event.OccurredAt)
+	}
+
+	// a watch resuming from an unknown event is reset
+	lastEventId = 40
+	reset, err := client.WatchUsers(ctx, &userv1.WatchUsersRequest{LastEventId: &lastEventId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err = reset.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, sse.EVENT_RESET, event.Type)
+		assert.Equal(t, int64(3), event.Id)
+	}
+}
+
+func TestServer_Authentication(t *testing.T) {
+	userService := new(mocks.IUserService)
+	userService.On("GetUser", int64(1)).Return(&model.User{Id: 1}, nil)
+	authenticator := &authenticatorMock{authenticate: func(credentials string) (*auth.Claims, error) {
+		switch credentials {
+		case "admin", "reader":
+			return &auth.Claims{Roles: []string{credentials}}, nil
+		case "down":
+			return nil, apperror.Unavailable("keys unavailable", nil)
+		default:
+			return nil, errors.New("invalid token")
+		}
+	}}
+	policy := new(mocksAuthz.IPolicy)
+	policy.On("Authorize", mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, action string, userId int64) error {
+		if claims, _ := auth.ClaimsFromContext(ctx); claims.Roles[0] != "admin" {
+			return apperror.Forbidden("forbidden")
+		}
+		return nil
+	})
+	client := dial(t, &Server{
+		Users:          &UserServer{UserService: userService, Policy: policy},
+		Authenticators: []auth.IAuthenticator{authenticator},
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          codes.Code
+	}{
+		{name: "Authorized", authorization: "Bearer admin", code: codes.OK},
+		{name: "Without credentials", code: codes.Unauthenticated},
+		{name: "Unknown scheme", authorization: "Basic admin", code: codes.Unauthenticated},
+		{name: "Invalid credentials", authorization: "Bearer nope", code: codes.Unauthenticated},
+		{name: "Authentication unavailable", authorization: "Bearer down", code: codes.Unavailable},
+		{name: "Forbidden", authorization: "Bearer reader", code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+
+			_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: 1})
+
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	// Given
+	userService := new(mocks.IUserService)
+	userService.On("SaveUser", mock.Anything).Return(&model.User{Id: 1}, nil)
+	userService.On("GetUser", int64(1)).Return(&model.User{Id: 1}, nil)
+	authenticator := &authenticatorMock{authenticate: func(credentials string) (*auth.Claims, error) {
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: credentials}}, nil
+	}}
+	now := time.Now()
+	limiter := &ratelimit.Limiter{
+		Store: &ratelimit.MemoryStore{Now: func() time.Time { return now }},
+		Rules: []ratelimit.Rule{{Method: http.MethodPost, Path: "/users", Limit: ratelimit.Limit{Requests: 1, Period: time.Minute}}},
+	}
+	client := dial(t, &Server{Users: &UserServer{UserService: userService}, Authenticators: []auth.IAuthenticator{authenticator}, Limiter: limiter})
+	create := func(subject string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+subject)
+		_, err := client.CreateUser(ctx, &userv1.CreateUserRequest{User: &userv1.User{FirstName: "John"}})
+		return err
+	}
+
+	// When
+	first, second := create("apikey:1"), create("apikey:1")
+
+	// Then
+	assert.NoError(t, first)
+	st := status.Convert(second)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		assert.Equal(t, time.Minute, st.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+	}
+	assert.NoError(t, create("apikey:2"), "other callers have their own bucket")
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer apikey:1")
+	_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: 1})
+	assert.NoError(t, err, "methods mirroring other routes are not limited by the rule")
+
+	// the REST route shares the bucket of the method
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/users/", nil)
+	request = request.WithContext(auth.NewContext(request.Context(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "apikey:2"}}))
+	limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestServer_IpRateLimit(t *testing.T) {
+	// Given
+	authenticator := &authenticatorMock{authenticate: func(credentials string) (*auth.Claims, error) {
+		return nil, errors.New("invalid token")
+	}}
+	ipLimiter := &ratelimit.Limiter{Store: &ratelimit.MemoryStore{}, Default: ratelimit.Limit{Requests: 2, Period: time.Minute}}
+	client := dial(t, &Server{Users: &UserServer{}, Authenticators: []auth.IAuthenticator{authenticator}, IpLimiter: ipLimiter})
+
+	// When
+	results := []codes.Code{}
+	for i := 0; i < 3; i++ {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer forged")
+		_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: 1})
+		results = append(results, status.Code(err))
+	}
+
+	// Then
+	assert.Equal(t, []codes.Code{codes.Unauthenticated, codes.Unauthenticated, codes.ResourceExhausted}, results)
+}
@@ -0,0 +1,105 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"net/http"
+	"strconv"
+)
+
+// SCIM error types, sent in the scimType of 400 and 409 errors
+const (
+	SCIM_TYPE_INVALID_FILTER = "invalidFilter"
+	SCIM_TYPE_INVALID_SYNTAX = "invalidSyntax"
+	SCIM_TYPE_INVALID_PATH   = "invalidPath"
+	SCIM_TYPE_INVALID_VALUE  = "invalidValue"
+	SCIM_TYPE_NO_TARGET      = "noTarget"
+	SCIM_TYPE_UNIQUENESS     = "uniqueness"
+)
+
+const (
+	ERROR_BODY_INVALID     = "body should be a SCIM %v"
+	ERROR_OPERATIONS       = "Operations should list at least one operation"
+	ERROR_OPERATION        = "unknown operation %q, expected add, replace or remove"
+	ERROR_OPERATION_PATH   = "remove operations need a path"
+	ERROR_OPERATION_VALUE  = "the value of %q should be %v"
+	ERROR_NO_TARGET        = "no value of %q matches the filter"
+	ERROR_INACTIVE         = "users cannot be created inactive"
+	ERROR_USER_NOT_FOUND   = "user %v not found"
+	ERROR_UNKNOWN_RESOURCE = "unknown %v %q"
+)
+
+// Error is the SCIM error answered for a request
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// ErrorResponse is the body of an Error, whose status is a string as SCIM defines it
+// swagger:model ScimError
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ErrorResponse{Schemas: []string{SCHEMA_ERROR}, Status: strconv.Itoa(e.Status), ScimType: e.ScimType, Detail: e.Detail})
+}
+
+// respondError logs an error and writes it as a SCIM error. Errors of the service layer get the status and the
+// localized message the REST API sends, a validation failure on names already taken being a uniqueness conflict.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+
+	log.Error.Println(err)
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		msgErr := controller.MessageErrFromError(r.Context(), err)
+		scimErr = &Error{Status: msgErr.Status(), Detail: msgErr.Message()}
+		switch {
+		case scimErr.Status == http.StatusBadRequest && namesTaken(err):
+			scimErr.Status, scimErr.ScimType = http.StatusConflict, SCIM_TYPE_UNIQUENESS
+		case scimErr.Status == http.StatusBadRequest:
+			scimErr.ScimType = SCIM_TYPE_INVALID_VALUE
+		case scimErr.Status == http.StatusConflict:
+			scimErr.ScimType = SCIM_TYPE_UNIQUENESS
+		}
+	}
+	respond(w, scimErr.Status, scimErr)
+}
+
+// namesTaken tells whether a validation failure only comes from the first and last names of another user
+func namesTaken(err error) bool {
+	var appErr *apperror.Error
+	if !errors.Is(err, apperror.ErrValidation) || !errors.As(err, &appErr) || len(appErr.Fields) == 0 {
+		return false
+	}
+	for _, fieldErr := range appErr.Fields {
+		if fieldErr.Code != service.CODE_NAME_UNIQUE {
+			return false
+		}
+	}
+	return true
+}
+
+// respond writes a SCIM response
+func respond(w http.ResponseWriter, status int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		log.Error.Println(err)
+		status, response = http.StatusInternalServerError, []byte(`{"schemas":["`+SCHEMA_ERROR+`"],"status":"500"}`)
+	}
+	w.Header().Set("Content-Type", CONTENT_TYPE_SCIM+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(response)
+}
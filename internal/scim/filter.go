@@ -0,0 +1,386 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ERROR_FILTER_END       = "filter ends unexpectedly"
+	ERROR_FILTER_TOKEN     = "unexpected %q in filter"
+	ERROR_FILTER_STRING    = "unterminated string in filter"
+	ERROR_FILTER_ATTRIBUTE = "unknown attribute %q in filter"
+	ERROR_FILTER_OPERATOR  = "operator %q is not supported, expected eq, sw or co"
+	ERROR_FILTER_VALUE     = "%v %v %v compares values of different types"
+)
+
+// Kinds of attribute values
+const (
+	kindString = iota
+	kindNumber
+	kindBoolean
+)
+
+// attribute is a filterable attribute of a SCIM user, whose values are strings, int64 or bool according to its kind
+type attribute struct {
+	kind   int
+	values func(u *User) []interface{}
+}
+
+// userAttributes are the attributes of users in filters, by lowercase name
+var userAttributes = map[string]attribute{
+	"id":       {kind: kindString, values: func(u *User) []interface{} { return []interface{}{u.Id} }},
+	"username": {kind: kindString, values: func(u *User) []interface{} { return []interface{}{u.UserName} }},
+	"name.givenname": {kind: kindString, values: func(u *User) []interface{} {
+		return nameValues(u, func(name *Name) string { return name.GivenName })
+	}},
+	"name.familyname": {kind: kindString, values: func(u *User) []interface{} {
+		return nameValues(u, func(name *Name) string { return name.FamilyName })
+	}},
+	"name.formatted": {kind: kindString, values: func(u *User) []interface{} {
+		return nameValues(u, func(name *Name) string { return name.Formatted })
+	}},
+	"emails":       emailAttributes["value"],
+	"emails.value": emailAttributes["value"],
+	"emails.type":  emailAttributes["type"],
+	"active": {kind: kindBoolean, values: func(u *User) []interface{} {
+		return []interface{}{u.Active == nil || *u.Active}
+	}},
+	"age": {kind: kindNumber, values: func(u *User) []interface{} {
+		if u.Extension == nil {
+			return nil
+		}
+		return []interface{}{u.Extension.Age}
+	}},
+}
+
+// emailAttributes are the attributes of the emails of users, in the filters selecting emails in PATCH paths
+var emailAttributes = map[string]attribute{
+	"value": {kind: kindString, values: func(u *User) []interface{} {
+		return emailValues(u, func(email Email) interface{} { return email.Value })
+	}},
+	"type": {kind: kindString, values: func(u *User) []interface{} {
+		return emailValues(u, func(email Email) interface{} { return email.Type })
+	}},
+	"primary": {kind: kindBoolean, values: func(u *User) []interface{} {
+		return emailValues(u, func(email Email) interface{} { return email.Primary })
+	}},
+}
+
+func nameValues(u *User, value func(name *Name) string) []interface{} {
+	if u.Name == nil {
+		return nil
+	}
+	return []interface{}{value(u.Name)}
+}
+
+func emailValues(u *User, value func(email Email) interface{}) []interface{} {
+	values := []interface{}{}
+	for _, email := range u.Emails {
+		values = append(values, value(email))
+	}
+	return values
+}
+
+// attributeName returns the lowercase name of an attribute path, without the schema of the user or of its extension
+func attributeName(path string) string {
+	name := strings.ToLower(path)
+	for _, schema := range []string{SCHEMA_USER, SCHEMA_USER_EXTENSION} {
+		name = strings.TrimPrefix(name, strings.ToLower(schema)+":")
+	}
+	return name
+}
+
+// filter selects SCIM users
+type filter interface {
+	matches(u *User) bool
+}
+
+type andFilter struct{ left, right filter }
+
+func (f andFilter) matches(u *User) bool { return f.left.matches(u) && f.right.matches(u) }
+
+type orFilter struct{ left, right filter }
+
+func (f orFilter) matches(u *User) bool { return f.left.matches(u) || f.right.matches(u) }
+
+type notFilter struct{ filter filter }
+
+func (f notFilter) matches(u *User) bool { return !f.filter.matches(u) }
+
+// comparison matches the users with a value of the attribute equal to (eq), starting with (sw) or containing (co) the
+// given value. Strings are compared ignoring case, as the attributes of users are not case exact.
+type comparison struct {
+	// name is the lowercase name of the attribute
+	name      string
+	attribute attribute
+	operator  string
+	value     interface{}
+}
+
+func (c comparison) matches(u *User) bool {
+	for _, value := range c.attribute.values(u) {
+		text, ok := value.(string)
+		if !ok {
+			if value == c.value {
+				return true
+			}
+			continue
+		}
+		text, expected := strings.ToLower(text), strings.ToLower(c.value.(string))
+		switch c.operator {
+		case "eq":
+			ok = text == expected
+		case "sw":
+			ok = strings.HasPrefix(text, expected)
+		case "co":
+			ok = strings.Contains(text, expected)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// userNameEquals returns the userName a filter needs users to be equal to, as in the userName eq "john@example.com"
+// sent by identity providers looking for a user before provisioning it, alone or in an and
+func userNameEquals(f filter) (string, bool) {
+	switch f := f.(type) {
+	case comparison:
+		if f.name == "username" && f.operator == "eq" {
+			return f.value.(string), true
+		}
+	case andFilter:
+		if userName, ok := userNameEquals(f.left); ok {
+			return userName, true
+		}
+		return userNameEquals(f.right)
+	}
+	return "", false
+}
+
+// token is a word, a parenthesis or a quoted string of a filter
+type token struct {
+	text   string
+	quoted bool
+}
+
+// filterParser reads a filter following the grammar of RFC 7644, with the eq, sw and co operators:
+//
+//	or         = and *("or" and)
+//	and        = term *("and" term)
+//	term       = "(" or ")" / "not" "(" or ")" / attrPath SP compareOp SP compValue
+type filterParser struct {
+	tokens     []token
+	position   int
+	attributes map[string]attribute
+}
+
+// parseFilter reads a filter on the given attributes, returning an invalidFilter error when it cannot
+func parseFilter(text string, attributes map[string]attribute) (filter, error) {
+
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &filterParser{tokens: tokens, attributes: attributes}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next, ok := parser.peek(); ok {
+		return nil, filterError(ERROR_FILTER_TOKEN, next.text)
+	}
+	return parsed, nil
+}
+
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (filter, error) {
+
+	if p.keyword("not") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		negated, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{negated}, nil
+	}
+	if p.keyword("(") {
+		return p.parseGroup()
+	}
+
+	path, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	attribute, ok := p.attributes[attributeName(path.text)]
+	if path.quoted || !ok {
+		return nil, filterError(ERROR_FILTER_ATTRIBUTE, path.text)
+	}
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(operator.text)
+	if operator.quoted || (op != "eq" && op != "sw" && op != "co") {
+		return nil, filterError(ERROR_FILTER_OPERATOR, operator.text)
+	}
+	literal, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	value, kind, err := parseValue(literal)
+	if err != nil {
+		return nil, err
+	}
+	if kind != attribute.kind || (kind != kindString && op != "eq") {
+		return nil, filterError(ERROR_FILTER_VALUE, path.text, operator.text, literal.text)
+	}
+	return comparison{name: attributeName(path.text), attribute: attribute, operator: op, value: value}, nil
+}
+
+// parseGroup reads a filter in parentheses, whose opening one was read
+func (p *filterParser) parseGroup() (filter, error) {
+	grouped, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return grouped, nil
+}
+
+// parseValue reads a compared value: a string, a number or a boolean
+func parseValue(literal token) (interface{}, int, error) {
+	if literal.quoted {
+		return literal.text, kindString, nil
+	}
+	switch strings.ToLower(literal.text) {
+	case "true":
+		return true, kindBoolean, nil
+	case "false":
+		return false, kindBoolean, nil
+	}
+	number, err := strconv.ParseInt(literal.text, 10, 64)
+	if err != nil {
+		return nil, 0, filterError(ERROR_FILTER_TOKEN, literal.text)
+	}
+	return number, kindNumber, nil
+}
+
+func (p *filterParser) peek() (token, bool) {
+	if p.position >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *filterParser) next() (token, error) {
+	next, ok := p.peek()
+	if !ok {
+		return token{}, filterError(ERROR_FILTER_END)
+	}
+	p.position++
+	return next, nil
+}
+
+// keyword reads the next token when it is the given unquoted keyword, ignoring case
+func (p *filterParser) keyword(keyword string) bool {
+	next, ok := p.peek()
+	if !ok || next.quoted || !strings.EqualFold(next.text, keyword) {
+		return false
+	}
+	p.position++
+	return true
+}
+
+func (p *filterParser) expect(keyword string) error {
+	if p.keyword(keyword) {
+		return nil
+	}
+	next, err := p.next()
+	if err != nil {
+		return err
+	}
+	return filterError(ERROR_FILTER_TOKEN, next.text)
+}
+
+// tokenize splits a filter into words, parentheses and JSON strings
+func tokenize(text string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, filterError(ERROR_FILTER_STRING)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(text[i:end+1]), &value); err != nil {
+				return nil, filterError(ERROR_FILTER_TOKEN, text[i:end+1])
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(text) && !strings.ContainsRune(" \t()", rune(text[end])) {
+				end++
+			}
+			tokens = append(tokens, token{text: text[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func filterError(format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,148 @@
+package scim
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"testing"
+)
+
+var users = []model.User{
+	{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+	{Id: 2, FirstName: "Jane", LastName: "Doe", Email: "jane.doe@gmail.com", Age: 25},
+	{Id: 3, FirstName: "Ben", LastName: "Jefferson", Email: "t.jefferson@yahoo.com", Age: 39},
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		wantIds []string
+		wantErr *Error
+	}{
+		{
+			name:    "Equal ignoring case",
+			filter:  `userName eq "JOHN.DOE@gmail.com"`,
+			wantIds: []string{"1"},
+		},
+		{
+			name:    "And",
+			filter:  `name.familyName eq "Doe" and name.givenName sw "ja"`,
+			wantIds: []string{"2"},
+		},
+		{
+			name:    "Or with the extension",
+			filter:  `emails co "yahoo" OR urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age eq 30`,
+			wantIds: []string{"1", "3"},
+		},
+		{
+			name:    "Not",
+			filter:  `emails.value co "doe" and not (name.givenName eq "john")`,
+			wantIds: []string{"2"},
+		},
+		{
+			name:    "Parentheses before and",
+			filter:  `(name.givenName sw "J" or id eq "3") and active eq true`,
+			wantIds: []string{"1", "2", "3"},
+		},
+		{
+			name:    "And before or",
+			filter:  `name.givenName sw "J" or id eq "3" and active eq false`,
+			wantIds: []string{"1", "2"},
+		},
+		{
+			name:    "Attribute with its schema",
+			filter:  `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "t."`,
+			wantIds: []string{"3"},
+		},
+		{
+			name:    "Escaped string",
+			filter:  `name.formatted eq "John \"Doe\""`,
+			wantIds: []string{},
+		},
+		{
+			name:    "Unsupported operator",
+			filter:  `userName gt "a"`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: `operator "gt" is not supported, expected eq, sw or co`},
+		},
+		{
+			name:    "Unknown attribute",
+			filter:  `nickName eq "Johnny"`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: `unknown attribute "nickName" in filter`},
+		},
+		{
+			name:    "Number compared as a string",
+			filter:  `urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age co 3`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: `urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age co 3 compares values of different types`},
+		},
+		{
+			name:    "Missing expression",
+			filter:  `userName eq "john.doe@gmail.com" and`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: "filter ends unexpectedly"},
+		},
+		{
+			name:    "Missing parenthesis",
+			filter:  `(userName eq "john.doe@gmail.com"`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: "filter ends unexpectedly"},
+		},
+		{
+			name:    "Extra parenthesis",
+			filter:  `userName eq "john.doe@gmail.com")`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: `unexpected ")" in filter`},
+		},
+		{
+			name:    "Unterminated string",
+			filter:  `userName eq "john.doe@gmail.com`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_FILTER, Detail: "unterminated string in filter"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			parsed, err := parseFilter(tt.filter, userAttributes)
+
+			// Then
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.Nil(t, err)
+			ids := []string{}
+			for _, user := range users {
+				resource := fromUser(user, PATH)
+				if parsed.matches(&resource) {
+					ids = append(ids, resource.Id)
+				}
+			}
+			assert.Equal(t, tt.wantIds, ids)
+		})
+	}
+}
+
+func TestUserNameEquals(t *testing.T) {
+	tests := []struct {
+		filter       string
+		wantUserName string
+		wantOk       bool
+	}{
+		{filter: `userName eq "John.Doe@gmail.com"`, wantUserName: "John.Doe@gmail.com", wantOk: true},
+		{filter: `active eq true and urn:ietf:params:scim:schemas:core:2.0:User:userName EQ "john.doe@gmail.com"`, wantUserName: "john.doe@gmail.com", wantOk: true},
+		{filter: `userName sw "john"`},
+		{filter: `userName eq "john.doe@gmail.com" or id eq "3"`},
+		{filter: `not (userName eq "john.doe@gmail.com")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			// Given
+			parsed, err := parseFilter(tt.filter, userAttributes)
+			assert.Nil(t, err)
+
+			// When
+			userName, ok := userNameEquals(parsed)
+
+			// Then
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantUserName, userName)
+		})
+	}
+}
@@ -0,0 +1,632 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PATH is the route the SCIM API is served under
+const PATH = "/scim/v2"
+
+// Page sizes of the user list, DefaultCount being used when the query has no count
+const (
+	DefaultCount = 100
+	MaxCount     = 1000
+)
+
+const ERROR_QUERY_NUMBER = "%v should be a number"
+
+// swagger:parameters listScimUsers
+type ListUsersParams struct {
+	// A filter on userName, name.givenName, name.familyName, name.formatted, emails, emails.value, emails.type,
+	// active, id and the age of the extension, with the eq, sw and co operators combined by and, or and not
+	// in: query
+	Filter string `json:"filter"`
+	// The 1-based index of the first user of the page
+	// in: query
+	StartIndex int64 `json:"startIndex"`
+	// The most users in the page
+	// in: query
+	// maximum: 1000
+	Count int64 `json:"count"`
+}
+
+// swagger:parameters getScimUser replaceScimUser patchScimUser deleteScimUser
+type UserIdParam struct {
+	// in: path
+	Id string `json:"id"`
+}
+
+// swagger:parameters createScimUser replaceScimUser
+type UserParam struct {
+	// in: body
+	User User
+}
+
+// swagger:parameters patchScimUser
+type PatchParam struct {
+	// in: body
+	Patch PatchRequest
+}
+
+// swagger:response scimNoContent
+type noContent struct{}
+
+// swagger:parameters getScimResourceType getScimSchema
+type DiscoveryIdParam struct {
+	// in: path
+	Id string `json:"id"`
+}
+
+// Handler serves the SCIM 2.0 API of users, for identity providers provisioning accounts. Every operation goes through
+// the service layer with the same authorization as the REST API, and provisioned users pass the same validation rules,
+// updates included.
+type Handler struct {
+	UserService service.IUserService
+	// Policy decides which callers may perform each operation
+	Policy authz.IPolicy
+	// BaseURL is the url the SCIM API is reached at, given in the locations of resources. The locations are relative
+	// to the host when empty, the Host header of requests being chosen by the client.
+	BaseURL string
+	// MaxBodySize is the largest body accepted, codec.DefaultMaxBodySize when zero
+	MaxBodySize int64
+}
+
+// ListUsers Lists the users matching a SCIM filter
+//
+// This will list the users matching the filter, ordered by id, a page at a time.
+//
+// swagger:route GET /scim/v2/Users listScimUsers
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimListResponse
+//	400: ScimError
+//	401: MessageErr
+//	403: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.authorize(r, authz.ACTION_LIST, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	startIndex, err := queryNumber(query.Get("startIndex"), "startIndex", 1)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	count, err := queryNumber(query.Get("count"), "count", DefaultCount)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > MaxCount {
+		count = MaxCount
+	}
+	var selected filter
+	if text := query.Get("filter"); text != "" {
+		if selected, err = parseFilter(text, userAttributes); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	users, err := h.candidates(selected)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	baseURL := h.baseURL()
+	matching := []User{}
+	for _, user := range users {
+		resource := fromUser(user, baseURL)
+		if selected == nil || selected.matches(&resource) {
+			matching = append(matching, resource)
+		}
+	}
+
+	page := []User{}
+	if startIndex <= len(matching) {
+		end := startIndex - 1 + count
+		if end > len(matching) {
+			end = len(matching)
+		}
+		page = matching[startIndex-1 : end]
+	}
+	respond(w, http.StatusOK, ListResponse{
+		Schemas:      []string{SCHEMA_LIST_RESPONSE},
+		TotalResults: len(matching),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+// GetUser Returns a SCIM user by ID
+//
+// This will return a user.
+//
+// swagger:route GET /scim/v2/Users/{id} getScimUser
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimUser
+//	401: MessageErr
+//	403: ScimError
+//	404: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+
+	id, err := parseId(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := h.authorize(r, authz.ACTION_READ, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	user, err := h.UserService.GetUser(id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	respond(w, http.StatusOK, fromUser(*user, h.baseURL()))
+}
+
+// CreateUser Provisions a SCIM user
+//
+// This will create a user, validated with the rules of the REST API.
+//
+// swagger:route POST /scim/v2/Users createScimUser
+//
+// Consumes:
+// - application/scim+json
+// - application/json
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	201: ScimUser
+//	400: ScimError
+//	401: MessageErr
+//	403: ScimError
+//	409: ScimError
+//	413: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+
+	if err := h.authorize(r, authz.ACTION_CREATE, 0); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var resource User
+	if err := h.decode(w, r, &resource, RESOURCE_USER); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if resource.deactivated() {
+		respondError(w, r, &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_VALUE, Detail: ERROR_INACTIVE})
+		return
+	}
+	user, err := resource.toUser("")
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	created, err := h.UserService.SaveUser(user)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	log.Info.Printf("User provisioned : %v", created)
+
+	response := fromUser(*created, h.baseURL())
+	w.Header().Set("Location", response.Meta.Location)
+	respond(w, http.StatusCreated, response)
+}
+
+// ReplaceUser Replaces a SCIM user
+//
+// This will replace the attributes of a user, validated with the rules of the REST API. A user set inactive is deleted.
+//
+// swagger:route PUT /scim/v2/Users/{id} replaceScimUser
+//
+// Consumes:
+// - application/scim+json
+// - application/json
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimUser
+//	400: ScimError
+//	401: MessageErr
+//	403: ScimError
+//	404: ScimError
+//	409: ScimError
+//	413: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+
+	id, err := parseId(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := h.authorize(r, authz.ACTION_UPDATE, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var resource User
+	if err := h.decode(w, r, &resource, RESOURCE_USER); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	current, err := h.UserService.GetUser(id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	h.update(w, r, current, &resource)
+}
+
+// PatchUser Changes attributes of a SCIM user
+//
+// This will add, replace or remove attributes of a user, validated with the rules of the REST API once every
+// operation is applied. A user set inactive is deleted.
+//
+// swagger:route PATCH /scim/v2/Users/{id} patchScimUser
+//
+// Consumes:
+// - application/scim+json
+// - application/json
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimUser
+//	400: ScimError
+//	401: MessageErr
+//	403: ScimError
+//	404: ScimError
+//	409: ScimError
+//	413: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) PatchUser(w http.ResponseWriter, r *http.Request) {
+
+	id, err := parseId(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := h.authorize(r, authz.ACTION_UPDATE, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	var patch PatchRequest
+	if err := h.decode(w, r, &patch, "PatchOp"); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if len(patch.Operations) == 0 {
+		respondError(w, r, &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_SYNTAX, Detail: ERROR_OPERATIONS})
+		return
+	}
+	current, err := h.UserService.GetUser(id)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	resource := fromUser(*current, h.baseURL())
+	for _, operation := range patch.Operations {
+		if err := resource.apply(operation); err != nil {
+			respondError(w, r, err)
+			return
+		}
+	}
+
+	h.update(w, r, current, &resource)
+}
+
+// DeleteUser Deprovisions a SCIM user
+//
+// This will delete a user.
+//
+// swagger:route DELETE /scim/v2/Users/{id} deleteScimUser
+//
+// Responses:
+//
+//	204: scimNoContent
+//	401: MessageErr
+//	403: ScimError
+//	404: ScimError
+//	429: MessageErr
+//	500: ScimError
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+
+	id, err := parseId(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := h.authorize(r, authz.ACTION_DELETE, id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+
+	if err := h.UserService.DeleteUser(id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	log.Info.Printf("User deprovisioned : %v", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetServiceProviderConfig Describes the SCIM features supported
+//
+// This will describe the filtering, patching and authentication of the SCIM API.
+//
+// swagger:route GET /scim/v2/ServiceProviderConfig getScimServiceProviderConfig
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimDiscovery
+//	401: MessageErr
+//	429: MessageErr
+func (h *Handler) GetServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, serviceProviderConfig(h.baseURL()))
+}
+
+// ListResourceTypes Lists the SCIM resource types
+//
+// This will list the resource types of the SCIM API, the User only.
+//
+// swagger:route GET /scim/v2/ResourceTypes listScimResourceTypes
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimDiscovery
+//	401: MessageErr
+//	429: MessageErr
+func (h *Handler) ListResourceTypes(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, listOf(sortedDiscoveries(resourceTypes(h.baseURL()))))
+}
+
+// GetResourceType Returns a SCIM resource type by ID
+//
+// This will return a resource type.
+//
+// swagger:route GET /scim/v2/ResourceTypes/{id} getScimResourceType
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimDiscovery
+//	401: MessageErr
+//	404: ScimError
+//	429: MessageErr
+func (h *Handler) GetResourceType(w http.ResponseWriter, r *http.Request) {
+	h.respondDiscovery(w, r, resourceTypes(h.baseURL()), "resource type")
+}
+
+// ListSchemas Lists the SCIM schemas
+//
+// This will list the attributes of the core user schema and of its onboarding extension.
+//
+// swagger:route GET /scim/v2/Schemas listScimSchemas
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimDiscovery
+//	401: MessageErr
+//	429: MessageErr
+func (h *Handler) ListSchemas(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, listOf(sortedDiscoveries(schemas(h.baseURL()))))
+}
+
+// GetSchema Returns a SCIM schema by ID
+//
+// This will return a schema.
+//
+// swagger:route GET /scim/v2/Schemas/{id} getScimSchema
+//
+// Produces:
+// - application/scim+json
+//
+// Responses:
+//
+//	200: ScimDiscovery
+//	401: MessageErr
+//	404: ScimError
+//	429: MessageErr
+func (h *Handler) GetSchema(w http.ResponseWriter, r *http.Request) {
+	h.respondDiscovery(w, r, schemas(h.baseURL()), "schema")
+}
+
+// candidates returns the users a filter may select: the user found by email when the filter needs a userName, every
+// user otherwise
+func (h *Handler) candidates(selected filter) ([]model.User, error) {
+	userName, ok := userNameEquals(selected)
+	if !ok {
+		return h.UserService.GetAllUsers()
+	}
+	user, err := h.UserService.GetUserByEmail(userName)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return []model.User{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []model.User{*user}, nil
+}
+
+// update saves the user described by a SCIM user, validated by the user service like any update. A deactivated user is
+// deleted instead, which needs the permission to delete it.
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, current *model.User, resource *User) {
+
+	if resource.deactivated() {
+		h.deactivate(w, r, current)
+		return
+	}
+
+	user, err := resource.toUser(current.Email)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	user.Id = current.Id
+
+	updated, err := h.UserService.UpdateUser(user)
+	if err != nil {
+		respondError(w, r, err)
+		return
+	}
+	log.Info.Printf("User updated : %v", updated)
+
+	respond(w, http.StatusOK, fromUser(*updated, h.baseURL()))
+}
+
+// deactivate deletes a user set inactive, and answers its last state
+func (h *Handler) deactivate(w http.ResponseWriter, r *http.Request, current *model.User) {
+
+	if err := h.authorize(r, authz.ACTION_DELETE, current.Id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	if err := h.UserService.DeleteUser(current.Id); err != nil {
+		respondError(w, r, err)
+		return
+	}
+	log.Info.Printf("User deprovisioned : %v", current.Id)
+
+	inactive := false
+	resource := fromUser(*current, h.baseURL())
+	resource.Active = &inactive
+	respond(w, http.StatusOK, resource)
+}
+
+// decode reads a JSON body into target, named in the error of a body that is not one
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, target interface{}, name string) error {
+	maxBodySize := h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = codec.DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_SYNTAX, Detail: fmt.Sprintf(ERROR_BODY_INVALID, name)}
+	}
+	return nil
+}
+
+// respondDiscovery writes the document with the id of the request, or a not found error
+func (h *Handler) respondDiscovery(w http.ResponseWriter, r *http.Request, documents map[string]Discovery, kind string) {
+	id := chi.URLParam(r, "id")
+	document, ok := documents[id]
+	if !ok {
+		respondError(w, r, &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf(ERROR_UNKNOWN_RESOURCE, kind, id)})
+		return
+	}
+	respond(w, http.StatusOK, document)
+}
+
+// baseURL returns the url the SCIM API is reached at, its path when no url is configured
+func (h *Handler) baseURL() string {
+	if h.BaseURL != "" {
+		return strings.TrimSuffix(h.BaseURL, "/")
+	}
+	return PATH
+}
+
+// authorize asks the policy whether the caller may perform the action, on the given user when userId is not zero
+func (h *Handler) authorize(r *http.Request, action string, userId int64) error {
+	return authz.Authorize(r.Context(), h.Policy, action, userId)
+}
+
+// queryNumber reads a number of the query, fallback when it is missing
+func queryNumber(value string, name string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_VALUE, Detail: fmt.Sprintf(ERROR_QUERY_NUMBER, name)}
+	}
+	return number, nil
+}
+
+func sortedDiscoveries(documents map[string]Discovery) []Discovery {
+	ids := make([]string, 0, len(documents))
+	for id := range documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	sorted := make([]Discovery, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, documents[id])
+	}
+	return sorted
+}
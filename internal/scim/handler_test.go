@@ -0,0 +1,397 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	mocksAuthz "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/authz"
+	mocks "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// forbidding returns a policy refusing the action, mock.Anything refusing every action
+func forbidding(action interface{}) *mocksAuthz.IPolicy {
+	policy := new(mocksAuthz.IPolicy)
+	policy.On("Authorize", mock.Anything, action, mock.Anything).Return(apperror.Forbidden("forbidden"))
+	policy.On("Authorize", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return policy
+}
+
+// scimUser returns the JSON of the SCIM representation of a user served by the test requests
+func scimUser(t *testing.T, user model.User) string {
+	body, err := json.Marshal(fromUser(user, "http://example.com"+PATH))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHandler(t *testing.T) {
+	nameTaken := []model.FieldError{{Field: "last_name", Code: service.CODE_NAME_UNIQUE, Message: service.ERROR_NAME_UNIQUE}}
+	tests := []struct {
+		name         string
+		mock         func(userService *mocks.IUserService)
+		policy       authz.IPolicy
+		method       string
+		target       string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:       "User",
+			mock:       func(userService *mocks.IUserService) { userService.On("GetUser", int64(1)).Return(&users[0], nil) },
+			method:     http.MethodGet,
+			target:     "/Users/1",
+			wantStatus: http.StatusOK,
+			wantBody: `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User","urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User"],
+				"id":"1","userName":"john.doe@gmail.com","name":{"givenName":"John","familyName":"Doe","formatted":"John Doe"},
+				"emails":[{"value":"john.doe@gmail.com","type":"work","primary":true}],"active":true,
+				"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":30},
+				"meta":{"resourceType":"User","location":"http://example.com/scim/v2/Users/1"}}`,
+		},
+		{
+			name:       "Invalid id",
+			method:     http.MethodGet,
+			target:     "/Users/john",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"404","detail":"user john not found"}`,
+		},
+		{
+			name:       "Forbidden",
+			policy:     forbidding(mock.Anything),
+			method:     http.MethodDelete,
+			target:     "/Users/1",
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"403","detail":"You are not allowed to perform this operation."}`,
+		},
+		{
+			name:       "Users filtered and paged",
+			mock:       func(userService *mocks.IUserService) { userService.On("GetAllUsers").Return(users, nil) },
+			method:     http.MethodGet,
+			target:     "/Users?filter=" + url.QueryEscape(`name.familyName eq "doe"`) + "&startIndex=2&count=1",
+			wantStatus: http.StatusOK,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":2,"startIndex":2,"itemsPerPage":1,
+				"Resources":[` + scimUser(t, users[1]) + `]}`,
+		},
+		{
+			name: "User found by userName",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUserByEmail", "John.Doe@gmail.com").Return(&users[0], nil)
+			},
+			method:     http.MethodGet,
+			target:     "/Users?filter=" + url.QueryEscape(`userName eq "John.Doe@gmail.com"`),
+			wantStatus: http.StatusOK,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":1,"startIndex":1,"itemsPerPage":1,
+				"Resources":[` + scimUser(t, users[0]) + `]}`,
+		},
+		{
+			name: "No user found by userName",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUserByEmail", "ada@example.com").Return(nil, apperror.NotFound("user not found with this email", nil))
+			},
+			method:     http.MethodGet,
+			target:     "/Users?filter=" + url.QueryEscape(`userName eq "ada@example.com"`),
+			wantStatus: http.StatusOK,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":0,"startIndex":1,"itemsPerPage":0,"Resources":[]}`,
+		},
+		{
+			name:       "Invalid filter",
+			method:     http.MethodGet,
+			target:     "/Users?filter=" + url.QueryEscape(`userName pr`),
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidFilter",
+				"detail":"operator \"pr\" is not supported, expected eq, sw or co"}`,
+		},
+		{
+			name: "Create user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", &model.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}).
+					Return(&model.User{Id: 7, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}, nil)
+			},
+			method: http.MethodPost,
+			target: "/Users",
+			body: `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"ada@example.com","externalId":"00u1",
+				"name":{"givenName":"Ada","familyName":"Lovelace"},"emails":[{"value":"ada@example.com","type":"work","primary":true}],
+				"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":36}}`,
+			wantStatus:   http.StatusCreated,
+			wantBody:     scimUser(t, model.User{Id: 7, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36}),
+			wantLocation: "http://example.com/scim/v2/Users/7",
+		},
+		{
+			name: "Create user with taken names",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("SaveUser", &model.User{FirstName: "John", LastName: "Doe", Email: "john@example.com", Age: 30}).
+					Return(nil, apperror.Validation(service.ERROR_NAME_UNIQUE, nameTaken))
+			},
+			method: http.MethodPost,
+			target: "/Users",
+			body: `{"emails":[{"value":"john@example.com"}],"name":{"givenName":"John","familyName":"Doe"},
+				"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":30}}`,
+			wantStatus: http.StatusConflict,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"409","scimType":"uniqueness",
+				"detail":"User with the same first and last name already exists"}`,
+		},
+		{
+			name:       "Create inactive user",
+			method:     http.MethodPost,
+			target:     "/Users",
+			body:       `{"userName":"ada@example.com","name":{"givenName":"Ada","familyName":"Lovelace"},"active":false}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidValue",
+				"detail":"users cannot be created inactive"}`,
+		},
+		{
+			name:       "Create user from an invalid body",
+			method:     http.MethodPost,
+			target:     "/Users",
+			body:       `{"userName":`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidSyntax","detail":"body should be a SCIM User"}`,
+		},
+		{
+			name: "Replace user keeping its names",
+			mock: func(userService *mocks.IUserService) {
+				replaced := &model.User{Id: 2, FirstName: "jane", LastName: "doe", Email: "jane.doe@gmail.com", Age: 26}
+				userService.On("GetUser", int64(2)).Return(&users[1], nil)
+				userService.On("UpdateUser", replaced).Return(&model.User{Id: 2, FirstName: "Jane", LastName: "Doe", Email: "jane.doe@gmail.com", Age: 26}, nil)
+			},
+			method: http.MethodPut,
+			target: "/Users/2",
+			body: `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"jane.doe@gmail.com",
+				"name":{"givenName":"jane","familyName":"doe"},"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":26}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   scimUser(t, model.User{Id: 2, FirstName: "Jane", LastName: "Doe", Email: "jane.doe@gmail.com", Age: 26}),
+		},
+		{
+			name: "Patch user",
+			mock: func(userService *mocks.IUserService) {
+				patched := &model.User{Id: 1, FirstName: "John", LastName: "Roe", Email: "john.doe@gmail.com", Age: 30}
+				userService.On("GetUser", int64(1)).Return(&users[0], nil)
+				userService.On("UpdateUser", patched).Return(patched, nil)
+			},
+			method:     http.MethodPatch,
+			target:     "/Users/1",
+			body:       `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"name.familyName","value":"Roe"}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   scimUser(t, model.User{Id: 1, FirstName: "John", LastName: "Roe", Email: "john.doe@gmail.com", Age: 30}),
+		},
+		{
+			name: "Patch user into an invalid one",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(1)).Return(&users[0], nil)
+				userService.On("UpdateUser", &model.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john", Age: 30}).
+					Return(nil, apperror.Validation(service.ERROR_EMAIL_FORMAT, []model.FieldError{{Field: "email", Code: service.CODE_EMAIL_FORMAT, Message: service.ERROR_EMAIL_FORMAT}}))
+			},
+			method:     http.MethodPatch,
+			target:     "/Users/1",
+			body:       `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"userName","value":"john"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidValue",
+				"detail":"User email must be properly formatted"}`,
+		},
+		{
+			name: "Deactivate user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(1)).Return(&users[0], nil)
+				userService.On("DeleteUser", int64(1)).Return(nil)
+			},
+			method:     http.MethodPatch,
+			target:     "/Users/1",
+			body:       `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   strings.Replace(scimUser(t, users[0]), `"active":true`, `"active":false`, 1),
+		},
+		{
+			name: "Replace user as inactive",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("GetUser", int64(2)).Return(&users[1], nil)
+				userService.On("DeleteUser", int64(2)).Return(nil)
+			},
+			method:     http.MethodPut,
+			target:     "/Users/2",
+			body:       `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"jane.doe@gmail.com","active":false}`,
+			wantStatus: http.StatusOK,
+			wantBody:   strings.Replace(scimUser(t, users[1]), `"active":true`, `"active":false`, 1),
+		},
+		{
+			name:       "Deactivate user without the permission to delete it",
+			mock:       func(userService *mocks.IUserService) { userService.On("GetUser", int64(1)).Return(&users[0], nil) },
+			policy:     forbidding(authz.ACTION_DELETE),
+			method:     http.MethodPatch,
+			target:     "/Users/1",
+			body:       `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`,
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"403","detail":"You are not allowed to perform this operation."}`,
+		},
+		{
+			name:       "Patch without operations",
+			method:     http.MethodPatch,
+			target:     "/Users/1",
+			body:       `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidSyntax",
+				"detail":"Operations should list at least one operation"}`,
+		},
+		{
+			name:       "Delete user",
+			mock:       func(userService *mocks.IUserService) { userService.On("DeleteUser", int64(1)).Return(nil) },
+			method:     http.MethodDelete,
+			target:     "/Users/1",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Delete missing user",
+			mock: func(userService *mocks.IUserService) {
+				userService.On("DeleteUser", int64(9)).Return(apperror.NotFound("user not found with id 9", nil))
+			},
+			method:     http.MethodDelete,
+			target:     "/Users/9",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"404","detail":"user not found with id 9"}`,
+		},
+		{
+			name:       "Resource type",
+			method:     http.MethodGet,
+			target:     "/ResourceTypes/User",
+			wantStatus: http.StatusOK,
+			wantBody: `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:ResourceType"],"id":"User","name":"User","endpoint":"/Users",
+				"description":"User Account","schema":"urn:ietf:params:scim:schemas:core:2.0:User",
+				"schemaExtensions":[{"schema":"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User","required":true}],
+				"meta":{"resourceType":"ResourceType","location":"http://example.com/scim/v2/ResourceTypes/User"}}`,
+		},
+		{
+			name:       "Unknown schema",
+			method:     http.MethodGet,
+			target:     "/Schemas/urn:ietf:params:scim:schemas:core:2.0:Group",
+			wantStatus: http.StatusNotFound,
+			wantBody: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"404",
+				"detail":"unknown schema \"urn:ietf:params:scim:schemas:core:2.0:Group\""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			userService := new(mocks.IUserService)
+			if tt.mock != nil {
+				tt.mock(userService)
+			}
+			handler := Handler{UserService: userService, Policy: tt.policy, BaseURL: "http://example.com" + PATH}
+			r := chi.NewRouter()
+			r.Use(i18n.Default().Middleware)
+			r.Route(PATH, func(r chi.Router) {
+				r.Get("/Users", handler.ListUsers)
+				r.Post("/Users", handler.CreateUser)
+				r.Get("/Users/{id}", handler.GetUser)
+				r.Put("/Users/{id}", handler.ReplaceUser)
+				r.Patch("/Users/{id}", handler.PatchUser)
+				r.Delete("/Users/{id}", handler.DeleteUser)
+				r.Get("/ResourceTypes/{id}", handler.GetResourceType)
+				r.Get("/Schemas/{id}", handler.GetSchema)
+			})
+			req := httptest.NewRequest(tt.method, PATH+tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", CONTENT_TYPE_SCIM)
+			rr := httptest.NewRecorder()
+
+			// When
+			r.ServeHTTP(rr, req)
+
+			// Then
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			userService.AssertExpectations(t)
+			assert.Equal(t, tt.wantLocation, rr.Header().Get("Location"))
+			if tt.wantBody == "" {
+				assert.Empty(t, rr.Body.String())
+				return
+			}
+			assert.Equal(t, "application/scim+json; charset=utf-8", rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.wantBody, rr.Body.String())
+		})
+	}
+}
+
+func TestHandler_BaseURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		baseURL      string
+		wantLocation string
+	}{
+		{name: "Configured", baseURL: "https://users.example.com/scim/v2/", wantLocation: "https://users.example.com/scim/v2/Users/1"},
+		{name: "Not configured", wantLocation: "/scim/v2/Users/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			userService := new(mocks.IUserService)
+			userService.On("GetUser", int64(1)).Return(&users[0], nil)
+			handler := Handler{UserService: userService, BaseURL: tt.baseURL}
+			r := chi.NewRouter()
+			r.Get(PATH+"/Users/{id}", handler.GetUser)
+			req := httptest.NewRequest(http.MethodGet, PATH+"/Users/1", nil)
+			req.Host = "attacker.example.net"
+			rr := httptest.NewRecorder()
+
+			// When
+			r.ServeHTTP(rr, req)
+
+			// Then
+			var resource User
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resource))
+			assert.Equal(t, tt.wantLocation, resource.Meta.Location)
+		})
+	}
+}
+
+func TestHandler_Discovery(t *testing.T) {
+	// Given
+	handler := Handler{BaseURL: "https://users.example.com/scim/v2/"}
+	r := chi.NewRouter()
+	r.Get("/ServiceProviderConfig", handler.GetServiceProviderConfig)
+	r.Get("/Schemas", handler.ListSchemas)
+
+	// When
+	config := httptest.NewRecorder()
+	r.ServeHTTP(config, httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil))
+	schemas := httptest.NewRecorder()
+	r.ServeHTTP(schemas, httptest.NewRequest(http.MethodGet, "/Schemas", nil))
+
+	// Then
+	var spc struct {
+		Patch  map[string]bool        `json:"patch"`
+		Filter map[string]interface{} `json:"filter"`
+		Meta   Meta                   `json:"meta"`
+	}
+	assert.Nil(t, json.Unmarshal(config.Body.Bytes(), &spc))
+	assert.Equal(t, map[string]bool{"supported": true}, spc.Patch)
+	assert.Equal(t, map[string]interface{}{"supported": true, "maxResults": float64(MaxCount)}, spc.Filter)
+	assert.Equal(t, Meta{ResourceType: "ServiceProviderConfig", Location: "https://users.example.com/scim/v2/ServiceProviderConfig"}, spc.Meta)
+
+	var list struct {
+		TotalResults int `json:"totalResults"`
+		Resources    []struct {
+			Id         string `json:"id"`
+			Attributes []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+			} `json:"attributes"`
+		} `json:"Resources"`
+	}
+	assert.Nil(t, json.Unmarshal(schemas.Body.Bytes(), &list))
+	assert.Equal(t, 2, list.TotalResults)
+	assert.Equal(t, SCHEMA_USER, list.Resources[0].Id)
+	assert.Len(t, list.Resources[0].Attributes, 4)
+	assert.Equal(t, SCHEMA_USER_EXTENSION, list.Resources[1].Id)
+	assert.Equal(t, "age", list.Resources[1].Attributes[0].Name)
+	assert.True(t, list.Resources[1].Attributes[0].Required)
+}
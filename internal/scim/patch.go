@@ -0,0 +1,276 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Operations of a PATCH request
+const (
+	OP_ADD     = "add"
+	OP_REPLACE = "replace"
+	OP_REMOVE  = "remove"
+)
+
+// PatchRequest changes attributes of a user, its operations being applied in order
+// swagger:model ScimPatchRequest
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation adds, replaces or removes the attribute at path. Without a path, the value is an object whose keys are
+// the paths of the attributes to add or replace.
+// swagger:model ScimPatchOperation
+type PatchOperation struct {
+	// enum: add,replace,remove
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"`
+	// swagger:type object
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// apply applies an operation to the user. The user has a single email, so adding an email replaces it unless the added
+// email is not primary, and attributes the users do not store, such as displayName, are ignored.
+func (u *User) apply(operation PatchOperation) error {
+
+	op := strings.ToLower(operation.Op)
+	switch op {
+	case OP_ADD, OP_REPLACE:
+	case OP_REMOVE:
+		if operation.Path == "" {
+			return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_NO_TARGET, Detail: ERROR_OPERATION_PATH}
+		}
+	default:
+		return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_SYNTAX, Detail: fmt.Sprintf(ERROR_OPERATION, operation.Op)}
+	}
+
+	if operation.Path == "" {
+		values, err := objectValue("value", operation.Value)
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedKeys(values) {
+			if err := u.set(op, path, values[path]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return u.set(op, operation.Path, operation.Value)
+}
+
+// set applies an operation to the attribute at path
+func (u *User) set(op string, path string, value json.RawMessage) error {
+
+	name := attributeName(path)
+	attr, sub, selector := name, "", ""
+	if start := strings.Index(name, "["); start >= 0 {
+		end := strings.Index(name, "]")
+		if end < start || (end < len(name)-1 && name[end+1] != '.') {
+			return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_PATH, Detail: fmt.Sprintf(ERROR_FILTER_TOKEN, path)}
+		}
+		attr, selector = name[:start], name[start+1:end]
+		if end < len(name)-1 {
+			sub = name[end+2:]
+		}
+		if attr != "emails" {
+			return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_PATH, Detail: fmt.Sprintf(ERROR_FILTER_ATTRIBUTE, path)}
+		}
+	} else if name != strings.ToLower(SCHEMA_USER_EXTENSION) {
+		attr, sub, _ = strings.Cut(name, ".")
+	}
+
+	var err error
+	switch attr {
+	case "username":
+		u.UserName, err = stringValue(op, path, value)
+	case "name":
+		err = u.setName(op, sub, path, value)
+	case "emails":
+		err = u.setEmails(op, selector, sub, path, value)
+	case "active":
+		if op == OP_REMOVE {
+			u.Active = nil
+			return nil
+		}
+		var active bool
+		active, err = boolValue(path, value)
+		u.Active = &active
+	case "age":
+		if u.Extension == nil {
+			u.Extension = &Extension{}
+		}
+		u.Extension.Age, err = numberValue(op, path, value)
+	case strings.ToLower(SCHEMA_USER_EXTENSION):
+		if op == OP_REMOVE {
+			u.Extension = nil
+			return nil
+		}
+		var values map[string]json.RawMessage
+		if values, err = objectValue(path, value); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			if err := u.set(op, SCHEMA_USER_EXTENSION+":"+key, values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+func (u *User) setName(op string, sub string, path string, value json.RawMessage) error {
+
+	if sub == "" && op == OP_REMOVE {
+		u.Name = nil
+		return nil
+	}
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+
+	var err error
+	switch sub {
+	case "":
+		var values map[string]json.RawMessage
+		if values, err = objectValue(path, value); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(values) {
+			if err := u.setName(op, strings.ToLower(key), path+"."+key, values[key]); err != nil {
+				return err
+			}
+		}
+	case "givenname":
+		u.Name.GivenName, err = stringValue(op, path, value)
+	case "familyname":
+		u.Name.FamilyName, err = stringValue(op, path, value)
+	}
+	return err
+}
+
+// setEmails applies an operation to the emails, or to those matching the selector filter when there is one
+func (u *User) setEmails(op string, selector string, sub string, path string, value json.RawMessage) error {
+
+	if selector == "" {
+		switch {
+		case op == OP_REMOVE && (sub == "" || sub == "value"):
+			u.Emails = nil
+		case sub == "":
+			var emails []Email
+			if err := json.Unmarshal(value, &emails); err != nil {
+				return valueError(path, "an array of emails")
+			}
+			if op == OP_ADD {
+				// added emails come first, so that an added primary email becomes the email of the user
+				emails = append(emails, u.Emails...)
+			}
+			u.Emails = emails
+		case sub == "value":
+			email, err := stringValue(op, path, value)
+			if err != nil {
+				return err
+			}
+			u.Emails = []Email{{Value: email, Type: EMAIL_TYPE_WORK, Primary: true}}
+		}
+		return nil
+	}
+
+	selected, err := parseFilter(selector, emailAttributes)
+	if err != nil {
+		var scimErr *Error
+		if errors.As(err, &scimErr) {
+			scimErr.ScimType = SCIM_TYPE_INVALID_PATH
+		}
+		return err
+	}
+	emails, matched := []Email{}, false
+	for _, email := range u.Emails {
+		if !selected.matches(&User{Emails: []Email{email}}) {
+			emails = append(emails, email)
+			continue
+		}
+		matched = true
+		switch {
+		case op == OP_REMOVE:
+			continue
+		case sub == "":
+			if err := json.Unmarshal(value, &email); err != nil {
+				return valueError(path, "an email")
+			}
+		case sub == "value":
+			if email.Value, err = stringValue(op, path, value); err != nil {
+				return err
+			}
+		}
+		emails = append(emails, email)
+	}
+	if !matched {
+		return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_NO_TARGET, Detail: fmt.Sprintf(ERROR_NO_TARGET, path)}
+	}
+	u.Emails = emails
+	return nil
+}
+
+// stringValue reads the string value of an operation, empty when it is removed
+func stringValue(op string, path string, value json.RawMessage) (string, error) {
+	if op == OP_REMOVE {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return "", valueError(path, "a string")
+	}
+	return text, nil
+}
+
+// numberValue reads the integer value of an operation, zero when it is removed
+func numberValue(op string, path string, value json.RawMessage) (int64, error) {
+	if op == OP_REMOVE {
+		return 0, nil
+	}
+	var number int64
+	if err := json.Unmarshal(value, &number); err != nil {
+		return 0, valueError(path, "an integer")
+	}
+	return number, nil
+}
+
+// boolValue reads a boolean value, which some identity providers send as a "True" or "False" string
+func boolValue(path string, value json.RawMessage) (bool, error) {
+	var boolean bool
+	if err := json.Unmarshal(value, &boolean); err == nil {
+		return boolean, nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil && (strings.EqualFold(text, "true") || strings.EqualFold(text, "false")) {
+		return strings.EqualFold(text, "true"), nil
+	}
+	return false, valueError(path, "a boolean")
+}
+
+func objectValue(path string, value json.RawMessage) (map[string]json.RawMessage, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(value, &values); err != nil || values == nil {
+		return nil, valueError(path, "an object")
+	}
+	return values, nil
+}
+
+func valueError(path string, expected string) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_VALUE, Detail: fmt.Sprintf(ERROR_OPERATION_VALUE, path, expected)}
+}
+
+func sortedKeys(values map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package scim
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"testing"
+)
+
+func TestUser_Apply(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		wantUser   *model.User
+		wantErr    *Error
+	}{
+		{
+			name:       "Replace a name",
+			operations: `[{"op":"Replace","path":"name.givenName","value":"Johnny"}]`,
+			wantUser:   &model.User{FirstName: "Johnny", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+		},
+		{
+			name: "Replace without path",
+			operations: `[{"op":"replace","value":{"name.familyName":"Roe","active":"True",
+				"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age":31}}]`,
+			wantUser: &model.User{FirstName: "John", LastName: "Roe", Email: "john.doe@gmail.com", Age: 31},
+		},
+		{
+			name:       "Replace some names",
+			operations: `[{"op":"replace","path":"name","value":{"familyName":"Roe"}}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Roe", Email: "john.doe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Replace the extension",
+			operations: `[{"op":"add","path":"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User","value":{"age":40}}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 40},
+		},
+		{
+			name:       "Replace the userName",
+			operations: `[{"op":"replace","path":"userName","value":"john.roe@gmail.com"}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.roe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Replace the work email",
+			operations: `[{"op":"replace","path":"emails[type eq \"work\"].value","value":"john.roe@gmail.com"}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.roe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Add a primary email",
+			operations: `[{"op":"add","path":"emails","value":[{"value":"john.roe@gmail.com","type":"home","primary":true}]}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.roe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Add a secondary email",
+			operations: `[{"op":"add","path":"emails","value":[{"value":"john.roe@gmail.com","type":"home"}]}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Remove a name",
+			operations: `[{"op":"remove","path":"name.givenName"},{"op":"remove","path":"emails"}]`,
+			wantUser:   &model.User{LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Attributes not stored",
+			operations: `[{"op":"replace","value":{"displayName":"Johnny","externalId":"00u1"}},{"op":"add","path":"title","value":"Engineer"}]`,
+			wantUser:   &model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+		},
+		{
+			name:       "Unknown operation",
+			operations: `[{"op":"move","path":"userName"}]`,
+			wantErr:    &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_SYNTAX, Detail: `unknown operation "move", expected add, replace or remove`},
+		},
+		{
+			name:       "Remove without path",
+			operations: `[{"op":"remove"}]`,
+			wantErr:    &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_NO_TARGET, Detail: "remove operations need a path"},
+		},
+		{
+			name:       "Invalid value",
+			operations: `[{"op":"replace","path":"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age","value":"thirty"}]`,
+			wantErr: &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_VALUE,
+				Detail: `the value of "urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age" should be an integer`},
+		},
+		{
+			name:       "No email matching",
+			operations: `[{"op":"replace","path":"emails[type eq \"home\"].value","value":"john.roe@gmail.com"}]`,
+			wantErr:    &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_NO_TARGET, Detail: `no value of "emails[type eq \"home\"].value" matches the filter`},
+		},
+		{
+			name:       "Invalid email filter",
+			operations: `[{"op":"remove","path":"emails[type gt \"work\"]"}]`,
+			wantErr:    &Error{Status: http.StatusBadRequest, ScimType: SCIM_TYPE_INVALID_PATH, Detail: `operator "gt" is not supported, expected eq, sw or co`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			var operations []PatchOperation
+			if err := json.Unmarshal([]byte(tt.operations), &operations); err != nil {
+				t.Fatal(err)
+			}
+			resource := fromUser(users[0], PATH)
+
+			// When
+			var err error
+			for _, operation := range operations {
+				if err = resource.apply(operation); err != nil {
+					break
+				}
+			}
+			var user *model.User
+			if err == nil {
+				user, err = resource.toUser(users[0].Email)
+			}
+
+			// Then
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantUser, user)
+		})
+	}
+}
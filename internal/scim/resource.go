@@ -0,0 +1,153 @@
+package scim
+
+import (
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Schemas of the resources and messages of the SCIM API
+const (
+	SCHEMA_USER                    = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCHEMA_USER_EXTENSION          = "urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User"
+	SCHEMA_LIST_RESPONSE           = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCHEMA_PATCH_OP                = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCHEMA_ERROR                   = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCHEMA_SERVICE_PROVIDER_CONFIG = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCHEMA_RESOURCE_TYPE           = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCHEMA_SCHEMA                  = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+const (
+	CONTENT_TYPE_SCIM = "application/scim+json"
+	RESOURCE_USER     = "User"
+	EMAIL_TYPE_WORK   = "work"
+)
+
+// User is the SCIM representation of a model.User. The email of the user is both its userName and its only email,
+// and its age is in the onboarding extension. Users are active until deactivated, which deletes them.
+// swagger:model ScimUser
+type User struct {
+	Schemas  []string `json:"schemas"`
+	Id       string   `json:"id,omitempty"`
+	UserName string   `json:"userName,omitempty"`
+	Name     *Name    `json:"name,omitempty"`
+	Emails   []Email  `json:"emails,omitempty"`
+	Active   *bool    `json:"active,omitempty"`
+	// Extension carries the attributes of the user missing from the core schema
+	Extension *Extension `json:"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User,omitempty"`
+	Meta      *Meta      `json:"meta,omitempty"`
+}
+
+// Name is the name of a SCIM user, Formatted being read-only
+// swagger:model ScimName
+type Name struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty"`
+}
+
+// Email is an email of a SCIM user
+// swagger:model ScimEmail
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Extension holds the attributes of the onboarding schema extension
+// swagger:model ScimUserExtension
+type Extension struct {
+	Age int64 `json:"age,omitempty"`
+}
+
+// Meta describes a SCIM resource
+// swagger:model ScimMeta
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// ListResponse is a page of the resources matching a query
+// swagger:model ScimListResponse
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	// StartIndex is the 1-based index of the first resource of the page
+	StartIndex   int    `json:"startIndex"`
+	ItemsPerPage int    `json:"itemsPerPage"`
+	Resources    []User `json:"Resources"`
+}
+
+// fromUser returns the SCIM representation of a user, located under baseURL
+func fromUser(user model.User, baseURL string) User {
+	active := true
+	id := strconv.FormatInt(user.Id, 10)
+	resource := User{
+		Schemas:  []string{SCHEMA_USER, SCHEMA_USER_EXTENSION},
+		Id:       id,
+		UserName: user.Email,
+		Name: &Name{
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+			Formatted:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+		},
+		Active:    &active,
+		Extension: &Extension{Age: user.Age},
+		Meta:      &Meta{ResourceType: RESOURCE_USER, Location: baseURL + "/Users/" + id},
+	}
+	if user.Email != "" {
+		resource.Emails = []Email{{Value: user.Email, Type: EMAIL_TYPE_WORK, Primary: true}}
+	}
+	return resource
+}
+
+// toUser returns the model.User described by a SCIM user, whose email was currentEmail. The email changes when either
+// the userName or the primary email changes, the userName winning when both do, and is kept when both are missing.
+func (u *User) toUser(currentEmail string) (*model.User, error) {
+
+	user := &model.User{Email: currentEmail}
+	if u.Name != nil {
+		user.FirstName, user.LastName = u.Name.GivenName, u.Name.FamilyName
+	}
+	if u.Extension != nil {
+		user.Age = u.Extension.Age
+	}
+	email := u.primaryEmail()
+	switch {
+	case u.UserName != "" && u.UserName != currentEmail:
+		user.Email = u.UserName
+	case email != "" && email != currentEmail:
+		user.Email = email
+	}
+	return user, nil
+}
+
+// deactivated tells whether the SCIM user is set inactive
+func (u *User) deactivated() bool {
+	return u.Active != nil && !*u.Active
+}
+
+// primaryEmail returns the value of the primary email, or of the first one when none is primary
+func (u *User) primaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// parseId reads the id of a user, returning a not found error for ids that cannot be one
+func parseId(id string) (int64, error) {
+	userId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || userId <= 0 {
+		return 0, &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf(ERROR_USER_NOT_FOUND, id)}
+	}
+	return userId, nil
+}
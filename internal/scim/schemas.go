@@ -0,0 +1,117 @@
+package scim
+
+// Discovery is a document describing the service, a resource type or a schema, or a list of them, as RFC 7643 defines
+// them
+// swagger:model ScimDiscovery
+type Discovery map[string]interface{}
+
+// serviceProviderConfig describes the features of the SCIM API
+func serviceProviderConfig(baseURL string) Discovery {
+	return Discovery{
+		"schemas":        []string{SCHEMA_SERVICE_PROVIDER_CONFIG},
+		"patch":          Discovery{"supported": true},
+		"bulk":           Discovery{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         Discovery{"supported": true, "maxResults": MaxCount},
+		"changePassword": Discovery{"supported": false},
+		"sort":           Discovery{"supported": false},
+		"etag":           Discovery{"supported": false},
+		"authenticationSchemes": []Discovery{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "A JWT sent with the Bearer scheme, or an api key sent with the ApiKey scheme",
+			"primary":     true,
+		}},
+		"meta": Discovery{"resourceType": "ServiceProviderConfig", "location": baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+// resourceTypes describes the resources of the SCIM API, by id
+func resourceTypes(baseURL string) map[string]Discovery {
+	return map[string]Discovery{
+		RESOURCE_USER: {
+			"schemas":          []string{SCHEMA_RESOURCE_TYPE},
+			"id":               RESOURCE_USER,
+			"name":             RESOURCE_USER,
+			"endpoint":         "/Users",
+			"description":      "User Account",
+			"schema":           SCHEMA_USER,
+			"schemaExtensions": []Discovery{{"schema": SCHEMA_USER_EXTENSION, "required": true}},
+			"meta":             Discovery{"resourceType": "ResourceType", "location": baseURL + "/ResourceTypes/" + RESOURCE_USER},
+		},
+	}
+}
+
+// schemas describes the attributes of the resources, by schema id
+func schemas(baseURL string) map[string]Discovery {
+	return map[string]Discovery{
+		SCHEMA_USER: {
+			"schemas":     []string{SCHEMA_SCHEMA},
+			"id":          SCHEMA_USER,
+			"name":        RESOURCE_USER,
+			"description": "User Account",
+			"attributes": []Discovery{
+				attributeSchema("userName", "string", true, "The email of the user"),
+				subAttributes(attributeSchema("name", "complex", true, "The first and last names of the user"),
+					attributeSchema("givenName", "string", true, "The first name of the user"),
+					attributeSchema("familyName", "string", true, "The last name of the user"),
+					readOnly(attributeSchema("formatted", "string", false, "The first and last names of the user"))),
+				multiValued(subAttributes(attributeSchema("emails", "complex", false, "The email of the user, as its only email"),
+					attributeSchema("value", "string", true, "The email of the user"),
+					attributeSchema("type", "string", false, "Always work"),
+					attributeSchema("primary", "boolean", false, "Always true"))),
+				attributeSchema("active", "boolean", false, "Always true, deactivating a user deleting it"),
+			},
+			"meta": Discovery{"resourceType": "Schema", "location": baseURL + "/Schemas/" + SCHEMA_USER},
+		},
+		SCHEMA_USER_EXTENSION: {
+			"schemas":     []string{SCHEMA_SCHEMA},
+			"id":          SCHEMA_USER_EXTENSION,
+			"name":        "OnboardingUser",
+			"description": "Attributes of the onboarded users missing from the core schema",
+			"attributes": []Discovery{
+				attributeSchema("age", "integer", true, "The age of the user"),
+			},
+			"meta": Discovery{"resourceType": "Schema", "location": baseURL + "/Schemas/" + SCHEMA_USER_EXTENSION},
+		},
+	}
+}
+
+func attributeSchema(name string, attributeType string, required bool, description string) Discovery {
+	return Discovery{
+		"name":        name,
+		"type":        attributeType,
+		"multiValued": false,
+		"description": description,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  "readWrite",
+		"returned":    "default",
+		"uniqueness":  "none",
+	}
+}
+
+func subAttributes(attribute Discovery, subAttributes ...Discovery) Discovery {
+	attribute["subAttributes"] = subAttributes
+	return attribute
+}
+
+func multiValued(attribute Discovery) Discovery {
+	attribute["multiValued"] = true
+	return attribute
+}
+
+func readOnly(attribute Discovery) Discovery {
+	attribute["mutability"] = "readOnly"
+	return attribute
+}
+
+// listOf returns a list response with every resource in a single page
+func listOf(resources []Discovery) Discovery {
+	return Discovery{
+		"schemas":      []string{SCHEMA_LIST_RESPONSE},
+		"totalResults": len(resources),
+		"startIndex":   1,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// API_KEY_SCHEME is the Authorization scheme of api keys, e.g. "ApiKey ak_0123456789ab.secret"
+	API_KEY_SCHEME  = "ApiKey"
+	API_KEY_SUBJECT = "apikey:"
+
+	ERROR_API_KEY_NAME_REQUIRED   = "Api key name is required"
+	ERROR_API_KEY_SCOPES_REQUIRED = "Api key needs at least one scope"
+	ERROR_API_KEY_UNKNOWN_SCOPE   = "Api key scope '%v' is unknown"
+	ERROR_API_KEY_EXPIRY_PAST     = "Api key expiry must be in the future"
+	RESPONSE_API_KEY_INVALID      = "Api key did not pass validation"
+)
+
+// Codes identifying which rule an api key FieldError comes from
+const (
+	CODE_API_KEY_NAME_REQUIRED   = "api_key_name_required"
+	CODE_API_KEY_SCOPES_REQUIRED = "api_key_scopes_required"
+	CODE_API_KEY_UNKNOWN_SCOPE   = "api_key_unknown_scope"
+	CODE_API_KEY_EXPIRY_PAST     = "api_key_expiry_past"
+)
+
+// apiKeyTouchInterval limits how often the last use of a key is written
+const apiKeyTouchInterval = time.Minute
+
+// ErrInvalidApiKey is returned when an api key is unknown, revoked or expired
+var ErrInvalidApiKey = errors.New("invalid api key")
+
+type IApiKeyService interface {
+	ListApiKeys() ([]model.ApiKey, error)
+	CreateApiKey(request *model.ApiKeyRequest) (*model.ApiKeyCreated, error)
+	RevokeApiKey(id int64) error
+}
+
+// ApiKeyService manages api keys and authenticates the callers using them
+type ApiKeyService struct {
+	Repository repository.IApiKeyRepository
+}
+
+func (aks *ApiKeyService) ListApiKeys() ([]model.ApiKey, error) {
+
+	keys, err := aks.Repository.DbListApiKeys()
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// CreateApiKey stores a new key and returns it with its plaintext value, which cannot be retrieved afterwards
+func (aks *ApiKeyService) CreateApiKey(request *model.ApiKeyRequest) (*model.ApiKeyCreated, error) {
+
+	if fieldErrors := validateApiKeyRequest(request); len(fieldErrors) > 0 {
+		log.Error.Println(RESPONSE_API_KEY_INVALID)
+		return nil, apperror.Validation(RESPONSE_API_KEY_INVALID, fieldErrors)
+	}
+
+	prefix, err := randomString(6, hex.EncodeToString)
+	if err != nil {
+		return nil, apperror.Internal("unable to generate api key", err)
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, apperror.Internal("unable to generate api key", err)
+	}
+
+	key := &model.ApiKey{
+		Name:      strings.TrimSpace(request.Name),
+		Prefix:    "ak_" + prefix,
+		Hash:      hashApiKeySecret(secret),
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	created, err := aks.Repository.DbCreateApiKey(key)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	log.Info.Printf("Api key created : %v %v", created.Id, created.Prefix)
+
+	return &model.ApiKeyCreated{ApiKey: *created, Key: created.Prefix + "." + secret}, nil
+}
+
+func (aks *ApiKeyService) RevokeApiKey(id int64) error {
+
+	if err := aks.Repository.DbRevokeApiKey(id, time.Now().UTC()); err != nil {
+		log.Error.Println(err)
+		return err
+	}
+
+	log.Info.Printf("Api key revoked : %v", id)
+
+	return nil
+}
+
+// Scheme returns the Authorization scheme of api keys
+func (aks *ApiKeyService) Scheme() string {
+	return API_KEY_SCHEME
+}
+
+// Authenticate checks an api key and returns the claims of its caller: the service role, narrowed to the key scopes
+func (aks *ApiKeyService) Authenticate(ctx context.Context, credentials string) (*auth.Claims, error) {
+
+	prefix, secret, found := strings.Cut(credentials, ".")
+	if !found {
+		return nil, ErrInvalidApiKey
+	}
+
+	key, err := aks.Repository.DbGetApiKeyByPrefix(prefix)
+	if errors.Is(err, apperror.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown prefix %v", ErrInvalidApiKey, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashApiKeySecret(secret)), []byte(key.Hash)) != 1 {
+		return nil, fmt.Errorf("%w: wrong secret for %v", ErrInvalidApiKey, prefix)
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: %v is revoked", ErrInvalidApiKey, prefix)
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %v is expired", ErrInvalidApiKey, prefix)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := aks.Repository.DbTouchApiKey(key.Id, now); err != nil {
+			log.Error.Println(err)
+		}
+	}
+
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: API_KEY_SUBJECT + strconv.FormatInt(key.Id, 10)},
+		Roles:            []string{authz.ROLE_SERVICE},
+		Scopes:           key.Scopes,
+	}, nil
+}
+
+func validateApiKeyRequest(request *model.ApiKeyRequest) []model.FieldError {
+
+	fieldErrors := []model.FieldError{}
+	if strings.TrimSpace(request.Name) == "" {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "name", Code: CODE_API_KEY_NAME_REQUIRED, Message: ERROR_API_KEY_NAME_REQUIRED})
+	}
+	if len(request.Scopes) == 0 {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "scopes", Code: CODE_API_KEY_SCOPES_REQUIRED, Message: ERROR_API_KEY_SCOPES_REQUIRED})
+	}
+	for _, scope := range request.Scopes {
+		if !authz.KnownAction(scope) {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: "scopes", Code: CODE_API_KEY_UNKNOWN_SCOPE, Message: fmt.Sprintf(ERROR_API_KEY_UNKNOWN_SCOPE, scope)})
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "expires_at", Code: CODE_API_KEY_EXPIRY_PAST, Message: ERROR_API_KEY_EXPIRY_PAST})
+	}
+	return fieldErrors
+}
+
+// hashApiKeySecret hashes the random secret part of a key, its entropy makes a slow hash unnecessary
+func hashApiKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return encode(data), nil
+}
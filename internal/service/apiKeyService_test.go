@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	createApiKeyDomain      func(key *model.ApiKey) (*model.ApiKey, error)
+	getApiKeyByPrefixDomain func(prefix string) (*model.ApiKey, error)
+	touchApiKeyDomain       func(id int64, usedAt time.Time) error
+)
+
+type MockApiKeyRepo struct{}
+
+func (m *MockApiKeyRepo) DbListApiKeys() ([]model.ApiKey, error) {
+	return []model.ApiKey{}, nil
+}
+
+func (m *MockApiKeyRepo) DbCreateApiKey(key *model.ApiKey) (*model.ApiKey, error) {
+	return createApiKeyDomain(key)
+}
+
+func (m *MockApiKeyRepo) DbGetApiKeyByPrefix(prefix string) (*model.ApiKey, error) {
+	return getApiKeyByPrefixDomain(prefix)
+}
+
+func (m *MockApiKeyRepo) DbRevokeApiKey(id int64, revokedAt time.Time) error {
+	return nil
+}
+
+func (m *MockApiKeyRepo) DbTouchApiKey(id int64, usedAt time.Time) error {
+	return touchApiKeyDomain(id, usedAt)
+}
+
+func TestApiKeyService_CreateApiKey(t *testing.T) {
+	// Given
+	apiKeyService := ApiKeyService{Repository: &MockApiKeyRepo{}}
+	var stored *model.ApiKey
+	createApiKeyDomain = func(key *model.ApiKey) (*model.ApiKey, error) {
+		stored = key
+		key.Id = 1
+		return key, nil
+	}
+
+	// When
+	created, err := apiKeyService.CreateApiKey(&model.ApiKeyRequest{Name: " billing ", Scopes: []string{authz.ACTION_READ}})
+
+	// Then
+	assert.Nil(t, err)
+	assert.EqualValues(t, "billing", created.Name)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix+"."))
+	secret := strings.TrimPrefix(created.Key, created.Prefix+".")
+	assert.EqualValues(t, hashApiKeySecret(secret), stored.Hash)
+	assert.NotContains(t, stored.Hash, secret)
+}
+
+func TestApiKeyService_CreateApiKey_Invalid(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		request model.ApiKeyRequest
+		codes   []string
+	}{
+		{name: "Missing name and scopes", request: model.ApiKeyRequest{}, codes: []string{CODE_API_KEY_NAME_REQUIRED, CODE_API_KEY_SCOPES_REQUIRED}},
+		{name: "Unknown scope", request: model.ApiKeyRequest{Name: "billing", Scopes: []string{"users:read", "users:purge"}}, codes: []string{CODE_API_KEY_UNKNOWN_SCOPE}},
+		{name: "Expiry in the past", request: model.ApiKeyRequest{Name: "billing", Scopes: []string{"users:read"}, ExpiresAt: &past}, codes: []string{CODE_API_KEY_EXPIRY_PAST}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			apiKeyService := ApiKeyService{Repository: &MockApiKeyRepo{}}
+
+			// When
+			created, err := apiKeyService.CreateApiKey(&tt.request)
+
+			// Then
+			assert.Nil(t, created)
+			assert.ErrorIs(t, err, apperror.ErrValidation)
+			codes := []string{}
+			for _, fieldErr := range err.(*apperror.Error).Fields {
+				codes = append(codes, fieldErr.Code)
+			}
+			assert.EqualValues(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestApiKeyService_Authenticate(t *testing.T) {
+	now := time.Now().UTC()
+	past, future, recently := now.Add(-time.Hour), now.Add(time.Hour), now.Add(-time.Second)
+	key := func(update func(key *model.ApiKey)) *model.ApiKey {
+		key := &model.ApiKey{Id: 4, Prefix: "ak_0123", Hash: hashApiKeySecret("secret"), Scopes: []string{"users:list", "users:read"}}
+		if update != nil {
+			update(key)
+		}
+		return key
+	}
+
+	tests := []struct {
+		name        string
+		credentials string
+		key         *model.ApiKey
+		touched     bool
+		err         error
+	}{
+		{name: "Valid", credentials: "ak_0123.secret", key: key(nil), touched: true},
+		{name: "Recently used", credentials: "ak_0123.secret", key: key(func(k *model.ApiKey) { k.LastUsedAt = &recently })},
+		{name: "Not expired", credentials: "ak_0123.secret", key: key(func(k *model.ApiKey) { k.ExpiresAt = &future }), touched: true},
+		{name: "Malformed", credentials: "ak_0123", err: ErrInvalidApiKey},
+		{name: "Unknown prefix", credentials: "ak_9999.secret", err: ErrInvalidApiKey},
+		{name: "Wrong secret", credentials: "ak_0123.other", key: key(nil), err: ErrInvalidApiKey},
+		{name: "Revoked", credentials: "ak_0123.secret", key: key(func(k *model.ApiKey) { k.RevokedAt = &past }), err: ErrInvalidApiKey},
+		{name: "Expired", credentials: "ak_0123.secret", key: key(func(k *model.ApiKey) { k.ExpiresAt = &past }), err: ErrInvalidApiKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			apiKeyService := ApiKeyService{Repository: &MockApiKeyRepo{}}
+			getApiKeyByPrefixDomain = func(prefix string) (*model.ApiKey, error) {
+				if tt.key == nil || prefix != tt.key.Prefix {
+					return nil, apperror.NotFound("api key not found", nil)
+				}
+				return tt.key, nil
+			}
+			touched := false
+			touchApiKeyDomain = func(id int64, usedAt time.Time) error {
+				touched = true
+				return nil
+			}
+
+			// When
+			claims, err := apiKeyService.Authenticate(context.Background(), tt.credentials)
+
+			// Then
+			assert.Equal(t, tt.touched, touched)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Nil(t, claims)
+				return
+			}
+			assert.Nil(t, err)
+			assert.EqualValues(t, "apikey:4", claims.Subject)
+			assert.EqualValues(t, []string{authz.ROLE_SERVICE}, claims.Roles)
+			assert.EqualValues(t, []string{"users:list", "users:read"}, claims.Scopes)
+		})
+	}
+}
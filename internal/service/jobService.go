@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	RESPONSE_JOB_NO_FILE = "Job %v has no file to download"
+)
+
+// Default settings of the sweep of finished jobs
+const (
+	DefaultJobResultTTL     = 24 * time.Hour
+	DefaultJobSweepInterval = time.Hour
+)
+
+// IJobRunner runs the submitted jobs in the background
+type IJobRunner interface {
+	// Wake makes the runner look for queued jobs at once
+	Wake()
+	// Cancel stops a running job
+	Cancel(id int64)
+}
+
+type IJobService interface {
+	SubmitJob(job *model.Job) (*model.Job, error)
+	ListJobs() ([]model.Job, error)
+	GetJob(id int64) (*model.Job, error)
+	CancelJob(id int64) (*model.Job, error)
+	// JobFile returns the path of the file written by a succeeded export job
+	JobFile(id int64) (string, error)
+}
+
+// JobService queues the jobs run in the background by the runner, and tracks them
+type JobService struct {
+	Repository repository.IJobRepository
+	// Runner starts the submitted jobs at once and stops the canceled ones, jobs wait for its next poll when nil
+	Runner IJobRunner
+	// Dir holds the files written by export jobs, DefaultJobsDir when empty
+	Dir string
+	// ResultTTL is how long finished jobs and their files are kept, DefaultJobResultTTL when zero
+	ResultTTL time.Duration
+	// SweepInterval is how often Sweep deletes the expired jobs, DefaultJobSweepInterval when zero
+	SweepInterval time.Duration
+}
+
+// SubmitJob queues a job, which starts as soon as a worker is free
+func (js *JobService) SubmitJob(job *model.Job) (*model.Job, error) {
+
+	job.Status, job.Progress, job.Attempts = model.JOB_QUEUED, model.JobProgress{}, 0
+	created, err := js.Repository.DbCreateJob(job)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+	log.Info.Printf("Job %v (%v) queued", created.Id, created.Type)
+
+	if js.Runner != nil {
+		js.Runner.Wake()
+	}
+	return created, nil
+}
+
+func (js *JobService) ListJobs() ([]model.Job, error) {
+
+	jobs, err := js.Repository.DbListJobs()
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (js *JobService) GetJob(id int64) (*model.Job, error) {
+
+	job, err := js.Repository.DbGetJob(id)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// CancelJob cancels a queued job at once, and stops a running job which is canceled when it returns.
+// A finished job is left as it is.
+func (js *JobService) CancelJob(id int64) (*model.Job, error) {
+
+	job, err := js.Repository.DbCancelJob(id, time.Now().UTC())
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	if job.Status == model.JOB_RUNNING && js.Runner != nil {
+		js.Runner.Cancel(id)
+	}
+	return job, nil
+}
+
+func (js *JobService) JobFile(id int64) (string, error) {
+
+	job, err := js.GetJob(id)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != model.JOB_SUCCEEDED || job.Result == nil || job.Result.File == "" {
+		return "", apperror.NotFound(fmt.Sprintf(RESPONSE_JOB_NO_FILE, id), nil)
+	}
+
+	return filepath.Join(js.dir(), job.Result.File), nil
+}
+
+// Sweep deletes the expired jobs every SweepInterval until ctx is done
+func (js *JobService) Sweep(ctx context.Context) {
+
+	interval := js.SweepInterval
+	if interval <= 0 {
+		interval = DefaultJobSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := js.SweepJobs(time.Now().UTC()); err != nil {
+			log.Error.Printf("Unable to sweep jobs: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepJobs deletes the jobs finished for longer than ResultTTL, and the files of the job directory older than that,
+// which also removes the files of jobs lost with a restart and the partial files of interrupted exports
+func (js *JobService) SweepJobs(now time.Time) error {
+
+	ttl := js.ResultTTL
+	if ttl <= 0 {
+		ttl = DefaultJobResultTTL
+	}
+	before := now.Add(-ttl)
+
+	deleted, err := js.Repository.DbDeleteFinishedJobs(before)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(js.dir())
+	if errors.Is(err, os.ErrNotExist) {
+		entries = nil
+	} else if err != nil {
+		return err
+	}
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(js.dir(), entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed++
+	}
+
+	if deleted > 0 || removed > 0 {
+		log.Info.Printf("Swept %v jobs and %v files finished before %v", deleted, removed, before.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (js *JobService) dir() string {
+	if js.Dir == "" {
+		return DefaultJobsDir
+	}
+	return js.Dir
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
+	mocksService "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobService_SubmitJob(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IJobRepository)
+	repository.On("DbCreateJob", mock.Anything).Return(func(job *model.Job) *model.Job {
+		job.Id = 3
+		return job
+	}, nil)
+	runner := new(mocksService.IJobRunner)
+	runner.On("Wake").Return()
+	jobService := JobService{Repository: repository, Runner: runner}
+
+	// When
+	job, err := jobService.SubmitJob(&model.Job{Type: model.JOB_EXPORT, Status: model.JOB_SUCCEEDED, Attempts: 2})
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), job.Id)
+	assert.Equal(t, model.JOB_QUEUED, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	runner.AssertCalled(t, "Wake")
+}
+
+func TestJobService_CancelJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantCancel bool
+	}{
+		{name: "Queued", status: model.JOB_CANCELED},
+		{name: "Running", status: model.JOB_RUNNING, wantCancel: true},
+		{name: "Finished", status: model.JOB_SUCCEEDED},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IJobRepository)
+			repository.On("DbCancelJob", int64(3), mock.Anything).Return(&model.Job{Id: 3, Status: tt.status}, nil)
+			runner := new(mocksService.IJobRunner)
+			runner.On("Cancel", int64(3)).Return()
+			jobService := JobService{Repository: repository, Runner: runner}
+
+			// When
+			job, err := jobService.CancelJob(3)
+
+			// Then
+			assert.Nil(t, err)
+			assert.Equal(t, tt.status, job.Status)
+			if tt.wantCancel {
+				runner.AssertCalled(t, "Cancel", int64(3))
+			} else {
+				runner.AssertNotCalled(t, "Cancel", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestJobService_JobFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		job         *model.Job
+		want        string
+		wantErrKind error
+	}{
+		{
+			name: "Exported",
+			job:  &model.Job{Id: 3, Status: model.JOB_SUCCEEDED, Result: &model.JobResult{Exported: 5, File: "job-3.csv"}},
+			want: filepath.Join("/var/jobs", "job-3.csv"),
+		},
+		{
+			name:        "Running",
+			job:         &model.Job{Id: 3, Status: model.JOB_RUNNING},
+			wantErrKind: apperror.ErrNotFound,
+		},
+		{
+			name:        "Not an export",
+			job:         &model.Job{Id: 3, Status: model.JOB_SUCCEEDED, Result: &model.JobResult{Deleted: 2}},
+			wantErrKind: apperror.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IJobRepository)
+			repository.On("DbGetJob", int64(3)).Return(tt.job, nil)
+			jobService := JobService{Repository: repository, Dir: "/var/jobs"}
+
+			// When
+			file, err := jobService.JobFile(3)
+
+			// Then
+			if tt.wantErrKind != nil {
+				assert.ErrorIs(t, err, tt.wantErrKind)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, file)
+		})
+	}
+}
+
+func TestJobService_SweepJobs(t *testing.T) {
+	// Given
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	files := map[string]time.Time{
+		"job-3.csv":         now.Add(-2 * time.Hour),
+		"job-4.ndjson.part": now.Add(-3 * time.Hour),
+		"job-5.csv":         now.Add(-time.Minute),
+	}
+	for name, modified := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+		assert.Nil(t, os.Chtimes(filepath.Join(dir, name), modified, modified))
+	}
+	repository := new(mocksRepo.IJobRepository)
+	repository.On("DbDeleteFinishedJobs", now.Add(-time.Hour)).Return(2, nil)
+	jobService := JobService{Repository: repository, Dir: dir, ResultTTL: time.Hour}
+
+	// When
+	err := jobService.SweepJobs(now)
+
+	// Then
+	assert.Nil(t, err)
+	repository.AssertCalled(t, "DbDeleteFinishedJobs", now.Add(-time.Hour))
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "job-5.csv", entries[0].Name())
+}
+
+func TestJobService_SweepJobs_NoDir(t *testing.T) {
+	// Given
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repository := new(mocksRepo.IJobRepository)
+	repository.On("DbDeleteFinishedJobs", now.Add(-DefaultJobResultTTL)).Return(0, nil)
+	jobService := JobService{Repository: repository, Dir: filepath.Join(t.TempDir(), "jobs")}
+
+	// When
+	err := jobService.SweepJobs(now)
+
+	// Then
+	assert.Nil(t, err)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+)
+
+// DefaultExportChunkSize is the number of users read from the database at a time by an export
+const DefaultExportChunkSize = 500
+
+const RESPONSE_EXPORT_ERROR = "Unable to write the exported users"
+
+type IUserExportService interface {
+	ExportUsers(ctx context.Context, filter model.UserFilter, rows codec.IRowWriter) (int, error)
+}
+
+// UserExportService writes the users matching a filter, reading them from the database in chunks so that exports
+// of any size take constant memory
+type UserExportService struct {
+	Repository repository.IUserRepository
+	// ChunkSize is the number of users read at a time, DefaultExportChunkSize when zero
+	ChunkSize int
+}
+
+// ExportUsers writes the matching users ordered by id, flushing the rows after every chunk, and returns how many were
+// written. The export stops when ctx is done, e.g. when the client leaves.
+func (ues *UserExportService) ExportUsers(ctx context.Context, filter model.UserFilter, rows codec.IRowWriter) (int, error) {
+
+	chunkSize := ues.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultExportChunkSize
+	}
+
+	written := 0
+	afterId := int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, apperror.Unavailable(RESPONSE_EXPORT_ERROR, err)
+		}
+
+		users, err := ues.Repository.DbListUsersAfter(filter, afterId, chunkSize)
+		if err != nil {
+			log.Error.Println(err)
+			return written, err
+		}
+		if len(users) == 0 {
+			break
+		}
+		afterId = users[len(users)-1].Id
+
+		for i := range users {
+			if err := rows.Write(&users[i]); err != nil {
+				return written, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+			}
+			written++
+		}
+		if err := rows.Flush(); err != nil {
+			return written, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+		}
+	}
+
+	if err := rows.Close(); err != nil {
+		return written, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+	log.Info.Printf("Users exported: %v", written)
+	return written, nil
+}
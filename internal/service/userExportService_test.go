@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strconv"
+	"testing"
+)
+
+func TestUserExportService_ExportUsers(t *testing.T) {
+	users := []model.User{
+		{Id: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+		{Id: 2, FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+		{Id: 4, FirstName: "Alan", LastName: "Turing", Email: "alan@example.com", Age: 41},
+		{Id: 7, FirstName: "Linus", LastName: "Torvalds", Email: "linus@example.com", Age: 54},
+		{Id: 9, FirstName: "Barbara", LastName: "Liskov", Email: "barbara@example.com", Age: 84},
+	}
+
+	tests := []struct {
+		name        string
+		filter      model.UserFilter
+		want        string
+		wantWritten int
+		wantChunks  []int64
+	}{
+		{
+			name:        "All",
+			want:        "1\n2\n4\n7\n9\n",
+			wantWritten: 5,
+			wantChunks:  []int64{0, 2, 7, 9},
+		},
+		{
+			name:        "Filtered",
+			filter:      model.UserFilter{FirstName: "a", MaxAge: 60},
+			want:        "1\n4\n",
+			wantWritten: 2,
+			wantChunks:  []int64{0, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			chunks := []int64{}
+			repository.On("DbListUsersAfter", tt.filter, mock.Anything, 2).Return(func(filter model.UserFilter, afterId int64, limit int) []model.User {
+				chunks = append(chunks, afterId)
+				chunk := []model.User{}
+				for _, user := range users {
+					if filter.Matches(user) && user.Id > afterId && len(chunk) < limit {
+						chunk = append(chunk, user)
+					}
+				}
+				return chunk
+			}, nil)
+			body := &bytes.Buffer{}
+			exportService := UserExportService{Repository: repository, ChunkSize: 2}
+
+			// When
+			written, err := exportService.ExportUsers(context.Background(), tt.filter, &idWriter{body: body})
+
+			// Then
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantWritten, written)
+			assert.Equal(t, tt.want, body.String())
+			assert.Equal(t, tt.wantChunks, chunks)
+		})
+	}
+}
+
+func TestUserExportService_ExportUsers_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancel      bool
+		list        error
+		write       error
+		wantKind    error
+		wantWritten int
+	}{
+		{name: "ClientLeft", cancel: true, wantKind: apperror.ErrUnavailable},
+		{name: "Database", list: apperror.Unavailable("database unavailable", nil), wantKind: apperror.ErrUnavailable},
+		{name: "Write", write: errors.New("broken pipe"), wantKind: apperror.ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			repository.On("DbListUsersAfter", model.UserFilter{}, int64(0), DefaultExportChunkSize).Return([]model.User{{Id: 1, FirstName: "Ada"}}, tt.list)
+			repository.On("DbListUsersAfter", model.UserFilter{}, int64(1), DefaultExportChunkSize).Return([]model.User{}, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+			exportService := UserExportService{Repository: repository}
+
+			// When
+			written, err := exportService.ExportUsers(ctx, model.UserFilter{}, &idWriter{body: &bytes.Buffer{}, err: tt.write})
+
+			// Then
+			assert.ErrorIs(t, err, tt.wantKind)
+			assert.Equal(t, tt.wantWritten, written)
+		})
+	}
+}
+
+// idWriter writes the id of each user on its own line, failing every write with err when set
+type idWriter struct {
+	body *bytes.Buffer
+	err  error
+}
+
+func (iw *idWriter) Write(v interface{}) error {
+	if iw.err != nil {
+		return iw.err
+	}
+	_, err := iw.body.WriteString(strconv.FormatInt(v.(*model.User).Id, 10) + "\n")
+	return err
+}
+
+func (iw *idWriter) Flush() error {
+	return nil
+}
+
+func (iw *idWriter) Close() error {
+	return nil
+}
+
+var _ codec.IRowWriter = &idWriter{}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"io"
+)
+
+// DefaultImportBatchSize is the number of users inserted per transaction by an import
+const DefaultImportBatchSize = 100
+
+const (
+	ERROR_INVALID_VALUE   = "User %v has an invalid value"
+	ERROR_INVALID_ROW     = "Row cannot be read: %v"
+	ERROR_NAME_DUPLICATE  = "User with the same first and last name appears earlier in the import"
+	RESPONSE_IMPORT_ERROR = "Unable to read the imported users: %v"
+	RESPONSE_IMPORT_STOP  = "The import stopped before the end of the file"
+)
+
+// Codes identifying why an imported row is rejected, besides the codes of the validation rules
+const (
+	CODE_INVALID_VALUE  = "invalid_value"
+	CODE_INVALID_ROW    = "invalid_row"
+	CODE_NAME_DUPLICATE = "name_duplicate"
+)
+
+type IUserImportService interface {
+	ImportUsers(ctx context.Context, rows codec.IRowReader, dryRun bool) (*model.ImportReport, error)
+}
+
+// UserImportService creates users in bulk from the rows of a file, rejecting the rows that fail validation while
+// importing the others
+type UserImportService struct {
+	Repository        repository.IUserRepository
+	ValidationService IUserValidationService
+	// NormalizationService cleans up users before they are validated, users are taken as-is when nil
+	NormalizationService IUserNormalizationService
+	// Events publishes the created users to the event stream, when set
+	Events IPublisher
+	// BatchSize is the number of users inserted per transaction, DefaultImportBatchSize when zero
+	BatchSize int
+}
+
+// ImportUsers reads every row, validates it like a created user and inserts the valid ones in batches.
+// Nothing is inserted on a dry run, the report telling what would be imported.
+// An error stops the import and is returned with the report of the rows read before it, the batches inserted before
+// it staying imported. The import also stops between two rows when ctx is done, e.g. when the client leaves.
+func (uis *UserImportService) ImportUsers(ctx context.Context, rows codec.IRowReader, dryRun bool) (*model.ImportReport, error) {
+
+	batchSize := uis.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	report := &model.ImportReport{DryRun: dryRun, Errors: []model.ImportError{}}
+	// names seen in earlier rows, which validation cannot find in the database before their batch is inserted
+	names := map[[2]string]bool{}
+	batch := []model.User{}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, apperror.Unavailable(RESPONSE_IMPORT_STOP, err)
+		}
+
+		var user model.User
+		line, err := rows.Read(&user)
+		if err == io.EOF {
+			break
+		}
+		var rowErr *codec.RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			report.Rejected++
+			report.Errors = append(report.Errors, rowError(rowErr))
+			continue
+		}
+		if err != nil {
+			log.Error.Println(err)
+			return report, &apperror.Error{Kind: apperror.ErrValidation, Message: fmt.Sprintf(RESPONSE_IMPORT_ERROR, err), Cause: err}
+		}
+		report.Rows++
+		// ids are given by the database
+		user.Id = 0
+
+		if uis.NormalizationService != nil {
+			uis.NormalizationService.NormalizeUser(&user)
+		}
+		fieldErrors, err := uis.ValidationService.ValidateUserFields(&user)
+		if err != nil {
+			log.Error.Println(err)
+			return report, err
+		}
+		name := [2]string{user.FirstName, user.LastName}
+		if names[name] && user.FirstName != "" && user.LastName != "" {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: "last_name", Code: CODE_NAME_DUPLICATE, Message: ERROR_NAME_DUPLICATE})
+		}
+		if len(fieldErrors) > 0 {
+			report.Rejected++
+			for _, fieldErr := range fieldErrors {
+				report.Errors = append(report.Errors, model.ImportError{Row: line, Field: fieldErr.Field, Code: fieldErr.Code, Message: fieldErr.Message})
+			}
+			continue
+		}
+		names[name] = true
+
+		batch = append(batch, user)
+		if len(batch) == batchSize {
+			if err := uis.insert(batch, report); err != nil {
+				return report, err
+			}
+			batch = []model.User{}
+		}
+	}
+	if err := uis.insert(batch, report); err != nil {
+		return report, err
+	}
+
+	log.Info.Printf("Users imported: %v of %v rows, dry run %v", report.Imported, report.Rows, dryRun)
+	return report, nil
+}
+
+// insert creates a batch of valid users, or only counts them on a dry run
+func (uis *UserImportService) insert(batch []model.User, report *model.ImportReport) error {
+
+	if report.DryRun || len(batch) == 0 {
+		report.Imported += len(batch)
+		return nil
+	}
+
+	created, err := uis.Repository.DbCreateUsers(batch)
+	if err != nil {
+		log.Error.Println(err)
+		return err
+	}
+	report.Imported += len(created)
+
+	if uis.Events != nil {
+		for _, user := range created {
+			uis.Events.Publish(model.EVENT_USER_CREATED, user.Id, user)
+		}
+	}
+	return nil
+}
+
+// rowError reports a row that cannot be read, on the field at fault when it is known
+func rowError(rowErr *codec.RowError) model.ImportError {
+	if _, ok := userFields[rowErr.Column]; !ok && rowErr.Column != "" && rowErr.Column != "id" {
+		return model.ImportError{Row: rowErr.Line, Field: rowErr.Column, Code: CODE_UNKNOWN_FIELD, Message: fmt.Sprintf(ERROR_UNKNOWN_FIELD, rowErr.Column)}
+	}
+	if rowErr.Column != "" {
+		return model.ImportError{Row: rowErr.Line, Field: rowErr.Column, Code: CODE_INVALID_VALUE, Message: fmt.Sprintf(ERROR_INVALID_VALUE, rowErr.Column)}
+	}
+	return model.ImportError{Row: rowErr.Line, Code: CODE_INVALID_ROW, Message: fmt.Sprintf(ERROR_INVALID_ROW, rowErr.Err)}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"testing"
+)
+
+const importFile = `first_name,last_name,email,age
+Ada,Lovelace,ada@example.com,36
+Grace,Hopper,grace@example.com,old
+Alan,Turing,alan.example.com,41
+Linus,Torvalds,linus@example.com,54
+Margaret,Hamilton,margaret@example.com,8
+Ada,Lovelace,ada.lovelace@example.com,36
+Katherine,Johnson,katherine@example.com,101
+John,Doe,john.doe@example.com,30
+Barbara,Liskov,barbara@example.com,84
+`
+
+func TestUserImportService_ImportUsers(t *testing.T) {
+	wantErrors := []model.ImportError{
+		{Row: 3, Field: "age", Code: CODE_INVALID_VALUE, Message: "User age has an invalid value"},
+		{Row: 4, Field: "email", Code: CODE_EMAIL_FORMAT, Message: ERROR_EMAIL_FORMAT},
+		{Row: 6, Field: "age", Code: CODE_AGE_MINIMUM, Message: ERROR_AGE_MINIMUM},
+		{Row: 7, Field: "last_name", Code: CODE_NAME_DUPLICATE, Message: ERROR_NAME_DUPLICATE},
+		{Row: 9, Field: "last_name", Code: CODE_NAME_UNIQUE, Message: ERROR_NAME_UNIQUE},
+	}
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantBatches [][]string
+	}{
+		{name: "Import", wantBatches: [][]string{{"Ada", "Linus"}, {"Katherine", "Barbara"}}},
+		{name: "DryRun", dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			repository.On("ExistsByFirstNameAndLastName", mock.Anything, mock.Anything).Return(
+				func(firstName string, lastName string) bool { return firstName == "John" && lastName == "Doe" }, nil)
+			batches := [][]string{}
+			repository.On("DbCreateUsers", mock.Anything).Return(func(users []model.User) []model.User {
+				names := []string{}
+				for i := range users {
+					assert.Zero(t, users[i].Id)
+					users[i].Id = int64(10 + i)
+					names = append(names, users[i].FirstName)
+				}
+				batches = append(batches, names)
+				return users
+			}, nil)
+			importService := UserImportService{
+				Repository:        repository,
+				ValidationService: &UserValidationService{Repository: repository},
+				BatchSize:         2,
+			}
+
+			// When
+			report, err := importService.ImportUsers(context.Background(), &codec.CSVReader{Reader: bytes.NewBufferString(importFile)}, tt.dryRun)
+
+			// Then
+			assert.Nil(t, err)
+			assert.Equal(t, &model.ImportReport{DryRun: tt.dryRun, Rows: 9, Imported: 4, Rejected: 5, Errors: wantErrors}, report)
+			if tt.dryRun {
+				repository.AssertNotCalled(t, "DbCreateUsers", mock.Anything)
+				return
+			}
+			assert.Equal(t, tt.wantBatches, batches)
+		})
+	}
+}
+
+func TestUserImportService_ImportUsers_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		insert       error
+		wantKind     error
+		wantImported int
+	}{
+		{name: "UnknownColumn", body: "first_name,nickname\nAda,Countess\n", wantKind: apperror.ErrValidation},
+		{name: "Database", body: importFile, insert: apperror.Unavailable("database unavailable", nil), wantKind: apperror.ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			repository.On("ExistsByFirstNameAndLastName", mock.Anything, mock.Anything).Return(false, nil)
+			repository.On("DbCreateUsers", mock.Anything).Return(nil, tt.insert)
+			importService := UserImportService{Repository: repository, ValidationService: &UserValidationService{Repository: repository}}
+
+			// When
+			report, err := importService.ImportUsers(context.Background(), &codec.CSVReader{Reader: bytes.NewBufferString(tt.body)}, false)
+
+			// Then
+			assert.ErrorIs(t, err, tt.wantKind)
+			assert.Zero(t, report.Imported)
+		})
+	}
+}
+
+func TestUserImportService_ImportUsers_LaterBatchFails(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IUserRepository)
+	repository.On("ExistsByFirstNameAndLastName", mock.Anything, mock.Anything).Return(false, nil)
+	repository.On("DbCreateUsers", mock.Anything).Return(func(users []model.User) []model.User { return users }, nil).Once()
+	repository.On("DbCreateUsers", mock.Anything).Return(nil, apperror.Unavailable("database unavailable", nil))
+	importService := UserImportService{Repository: repository, ValidationService: &UserValidationService{Repository: repository}, BatchSize: 2}
+
+	// When
+	report, err := importService.ImportUsers(context.Background(), &codec.CSVReader{Reader: bytes.NewBufferString(importFile)}, false)
+
+	// Then
+	assert.ErrorIs(t, err, apperror.ErrUnavailable)
+	if assert.NotNil(t, report) {
+		assert.Equal(t, 2, report.Imported, "the first batch stays imported")
+		assert.Equal(t, 8, report.Rows, "the rows after the failed batch are not read")
+		assert.Equal(t, 4, report.Rejected)
+	}
+	repository.AssertNumberOfCalls(t, "DbCreateUsers", 2)
+}
+
+func TestUserImportService_ImportUsers_Cancelled(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IUserRepository)
+	importService := UserImportService{Repository: repository, ValidationService: &UserValidationService{Repository: repository}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When the client left before the import started
+	report, err := importService.ImportUsers(ctx, &codec.CSVReader{Reader: bytes.NewBufferString(importFile)}, false)
+
+	// Then
+	assert.ErrorIs(t, err, apperror.ErrUnavailable)
+	assert.ErrorIs(t, err, context.Canceled)
+	if assert.NotNil(t, report) {
+		assert.Zero(t, report.Rows)
+	}
+	repository.AssertNotCalled(t, "DbCreateUsers", mock.Anything)
+}
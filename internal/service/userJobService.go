@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultJobsDir holds the files written by export jobs when no directory is configured. Being under the shared
+// temporary directory, it is only used once made private, see privateDir.
+var DefaultJobsDir = filepath.Join(os.TempDir(), "ps-tag-onboarding-go-jobs")
+
+const (
+	RESPONSE_JOB_FORMAT_INVALID = "Job format '%v' is not supported"
+	RESPONSE_PURGE_NO_FILTER    = "A purge needs at least one filter"
+)
+
+// ErrJobsDirNotPrivate is returned when the jobs directory cannot be made readable by the server alone
+var ErrJobsDirNotPrivate = errors.New("jobs directory is not private")
+
+// UserJobService runs the background jobs on users, each method being the handler of a job type
+type UserJobService struct {
+	ImportService IUserImportService
+	ExportService IUserExportService
+	// UserService deletes the purged users, publishing their deletion like any other
+	UserService       IUserService
+	ValidationService IUserValidationService
+	Repository        repository.IUserRepository
+	// Dir holds the files written by export jobs, DefaultJobsDir when empty
+	Dir string
+	// ChunkSize is the number of users read at a time by purges and revalidations, DefaultExportChunkSize when zero
+	ChunkSize int
+}
+
+// Import imports the file of the job, stopping between two rows when ctx is done.
+// The batches inserted before a cancellation stay imported.
+func (ujs *UserJobService) Import(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+
+	input := strings.NewReader(job.Input)
+	var rows codec.IRowReader
+	switch job.Params.Format {
+	case codec.FORMAT_CSV:
+		rows = &codec.CSVReader{Reader: input, Columns: job.Params.Columns}
+	case codec.FORMAT_NDJSON:
+		rows = &codec.NDJSONReader{Reader: input}
+	default:
+		return nil, apperror.Validation(fmt.Sprintf(RESPONSE_JOB_FORMAT_INVALID, job.Params.Format), nil)
+	}
+
+	report, err := ujs.ImportService.ImportUsers(ctx, &progressReader{IRowReader: rows, progress: progress}, job.Params.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &model.JobResult{Import: report}, nil
+}
+
+// Export writes the matching users to a file of the job directory, which is only visible once complete
+func (ujs *UserJobService) Export(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+
+	dir := ujs.dir()
+	if err := privateDir(dir); err != nil {
+		return nil, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+	name := fmt.Sprintf("job-%v.%v", job.Id, job.Params.Format)
+	partial := filepath.Join(dir, name+".part")
+	// a file left by an interrupted export is replaced rather than reused, O_EXCL not following links
+	if err := os.Remove(partial); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+	defer os.Remove(partial)
+	defer file.Close()
+
+	rows, _, err := codec.RowWriter(job.Params.Format, file)
+	if err != nil {
+		return nil, apperror.Validation(fmt.Sprintf(RESPONSE_JOB_FORMAT_INVALID, job.Params.Format), nil)
+	}
+	written, err := ujs.ExportService.ExportUsers(ctx, job.Params.Filter, &progressWriter{IRowWriter: rows, progress: progress})
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+	if err := os.Rename(partial, filepath.Join(dir, name)); err != nil {
+		return nil, apperror.Internal(RESPONSE_EXPORT_ERROR, err)
+	}
+
+	return &model.JobResult{Exported: written, File: name}, nil
+}
+
+// Purge deletes the users matching the filter of the job, which cannot be empty.
+// The users deleted before a cancellation stay deleted.
+func (ujs *UserJobService) Purge(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+
+	if job.Params.Filter == (model.UserFilter{}) {
+		return nil, apperror.Validation(RESPONSE_PURGE_NO_FILTER, nil)
+	}
+
+	deleted := 0
+	err := ujs.eachUser(ctx, job.Params.Filter, func(user *model.User) error {
+		if err := ujs.UserService.DeleteUser(user.Id); err != nil && !errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		deleted++
+		progress(model.JobProgress{Done: deleted})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info.Printf("Users purged: %v", deleted)
+	return &model.JobResult{Deleted: deleted}, nil
+}
+
+// Revalidate runs the validation rules on every stored user, reporting those that no longer pass them.
+// A name is only reported as taken on the users created after the first one holding it.
+func (ujs *UserJobService) Revalidate(ctx context.Context, job *model.Job, progress func(model.JobProgress)) (*model.JobResult, error) {
+
+	report := &model.RevalidationReport{Errors: []model.RevalidationError{}}
+	names := map[[2]string]bool{}
+	err := ujs.eachUser(ctx, model.UserFilter{}, func(user *model.User) error {
+		fieldErrors, err := ujs.ValidationService.ValidateUserFields(user)
+		if err != nil {
+			return err
+		}
+		report.Users++
+
+		invalid := false
+		for _, fieldErr := range fieldErrors {
+			// the user holds its own name, which is checked against the earlier users below
+			if fieldErr.Code == CODE_NAME_UNIQUE {
+				continue
+			}
+			invalid = true
+			report.Errors = append(report.Errors, model.RevalidationError{UserId: user.Id, Field: fieldErr.Field, Code: fieldErr.Code, Message: fieldErr.Message})
+		}
+		name := [2]string{user.FirstName, user.LastName}
+		if names[name] {
+			invalid = true
+			fieldErr := nameUniqueError("last_name")
+			report.Errors = append(report.Errors, model.RevalidationError{UserId: user.Id, Field: fieldErr.Field, Code: fieldErr.Code, Message: fieldErr.Message})
+		}
+		names[name] = true
+
+		if invalid {
+			report.Invalid++
+		}
+		progress(model.JobProgress{Done: report.Users})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info.Printf("Users revalidated: %v invalid of %v", report.Invalid, report.Users)
+	return &model.JobResult{Revalidation: report}, nil
+}
+
+// eachUser calls fn with every stored user matching the filter ordered by id, reading them in chunks, until ctx is done
+// or fn fails
+func (ujs *UserJobService) eachUser(ctx context.Context, filter model.UserFilter, fn func(user *model.User) error) error {
+
+	chunkSize := ujs.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultExportChunkSize
+	}
+
+	afterId := int64(0)
+	for {
+		users, err := ujs.Repository.DbListUsersAfter(filter, afterId, chunkSize)
+		if err != nil {
+			log.Error.Println(err)
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		afterId = users[len(users)-1].Id
+
+		for i := range users {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// privateDir creates dir readable by the server alone, and makes it so when it exists with wider permissions.
+// A symbolic link, or a directory the server cannot chmod because another user owns it, is refused.
+func privateDir(dir string) error {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %v is not a directory", ErrJobsDirNotPrivate, dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return fmt.Errorf("%w: %v", ErrJobsDirNotPrivate, err)
+		}
+	}
+	return nil
+}
+
+func (ujs *UserJobService) dir() string {
+	if ujs.Dir == "" {
+		return DefaultJobsDir
+	}
+	return ujs.Dir
+}
+
+// progressReader reports the rows read
+type progressReader struct {
+	codec.IRowReader
+	progress func(model.JobProgress)
+	rows     int
+}
+
+func (pr *progressReader) Read(v interface{}) (int, error) {
+	line, err := pr.IRowReader.Read(v)
+	if err != io.EOF {
+		pr.rows++
+		pr.progress(model.JobProgress{Done: pr.rows})
+	}
+	return line, err
+}
+
+// progressWriter reports the rows written
+type progressWriter struct {
+	codec.IRowWriter
+	progress func(model.JobProgress)
+	rows     int
+}
+
+func (pw *progressWriter) Write(v interface{}) error {
+	if err := pw.IRowWriter.Write(v); err != nil {
+		return err
+	}
+	pw.rows++
+	pw.progress(model.JobProgress{Done: pw.rows})
+	return nil
+}
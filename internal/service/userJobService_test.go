@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
+	mocksService "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var jobUsers = []model.User{
+	{Id: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Age: 36},
+	{Id: 2, FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Age: 85},
+	{Id: 4, FirstName: "Ada", LastName: "Lovelace", Email: "ada.lovelace@example.com", Age: 12},
+}
+
+// listUsersAfter makes a mocked repository return the matching jobUsers chunk by chunk
+func listUsersAfter(repository *mocksRepo.IUserRepository) {
+	repository.On("DbListUsersAfter", mock.Anything, mock.Anything, mock.Anything).Return(func(filter model.UserFilter, afterId int64, limit int) []model.User {
+		chunk := []model.User{}
+		for _, user := range jobUsers {
+			if filter.Matches(user) && user.Id > afterId && len(chunk) < limit {
+				chunk = append(chunk, user)
+			}
+		}
+		return chunk
+	}, nil)
+}
+
+func TestUserJobService_Import(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		wantFirst   []string
+		wantErrKind error
+	}{
+		{
+			name:      "CSV",
+			format:    codec.FORMAT_CSV,
+			input:     "given,last_name,email,age\nAda,Lovelace,ada@example.com,36\nGrace,Hopper,grace@example.com,85\n",
+			wantFirst: []string{"Ada", "Grace"},
+		},
+		{
+			name:      "NDJSON",
+			format:    codec.FORMAT_NDJSON,
+			input:     `{"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}` + "\n",
+			wantFirst: []string{"Ada"},
+		},
+		{
+			name:        "Unknown format",
+			format:      "xlsx",
+			wantErrKind: apperror.ErrValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			importService := new(mocksService.IUserImportService)
+			read := []string{}
+			importService.On("ImportUsers", mock.Anything, mock.Anything, true).Return(func(ctx context.Context, rows codec.IRowReader, dryRun bool) *model.ImportReport {
+				for {
+					var user model.User
+					if _, err := rows.Read(&user); err == io.EOF {
+						break
+					}
+					read = append(read, user.FirstName)
+				}
+				return &model.ImportReport{Rows: len(read), Imported: len(read), Errors: []model.ImportError{}}
+			}, nil)
+			progress := []model.JobProgress{}
+			userJobs := UserJobService{ImportService: importService}
+			job := &model.Job{Id: 1, Type: model.JOB_IMPORT, Input: tt.input,
+				Params: model.JobParams{Format: tt.format, DryRun: true, Columns: map[string]string{"given": "first_name"}}}
+
+			// When
+			result, err := userJobs.Import(context.Background(), job, func(p model.JobProgress) { progress = append(progress, p) })
+
+			// Then
+			if tt.wantErrKind != nil {
+				assert.ErrorIs(t, err, tt.wantErrKind)
+				importService.AssertNotCalled(t, "ImportUsers", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantFirst, read)
+			assert.Equal(t, len(tt.wantFirst), result.Import.Imported)
+			assert.Len(t, progress, len(tt.wantFirst))
+		})
+	}
+}
+
+func TestUserJobService_Export(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IUserRepository)
+	listUsersAfter(repository)
+	dir := t.TempDir()
+	userJobs := UserJobService{ExportService: &UserExportService{Repository: repository, ChunkSize: 2}, Dir: dir}
+	job := &model.Job{Id: 7, Type: model.JOB_EXPORT, Params: model.JobParams{Format: codec.FORMAT_NDJSON, Filter: model.UserFilter{LastName: "lovelace"}}}
+	progress := []model.JobProgress{}
+
+	// When
+	result, err := userJobs.Export(context.Background(), job, func(p model.JobProgress) { progress = append(progress, p) })
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, &model.JobResult{Exported: 2, File: "job-7.ndjson"}, result)
+	assert.Equal(t, []model.JobProgress{{Done: 1}, {Done: 2}}, progress)
+	body, err := os.ReadFile(filepath.Join(dir, "job-7.ndjson"))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":1,"first_name":"Ada","last_name":"Lovelace","email":"ada@example.com","age":36}`+"\n"+
+		`{"id":4,"first_name":"Ada","last_name":"Lovelace","email":"ada.lovelace@example.com","age":12}`+"\n", string(body))
+	_, err = os.Stat(filepath.Join(dir, "job-7.ndjson.part"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUserJobService_Export_PrivateDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     func(root string) string
+		wantErr bool
+	}{
+		{
+			name: "Created",
+			dir:  func(root string) string { return filepath.Join(root, "jobs") },
+		},
+		{
+			name: "Readable by others",
+			dir: func(root string) string {
+				dir := filepath.Join(root, "jobs")
+				os.Mkdir(dir, 0777)
+				os.Chmod(dir, 0777)
+				return dir
+			},
+		},
+		{
+			name: "Symbolic link",
+			dir: func(root string) string {
+				os.Mkdir(filepath.Join(root, "elsewhere"), 0700)
+				os.Symlink(filepath.Join(root, "elsewhere"), filepath.Join(root, "jobs"))
+				return filepath.Join(root, "jobs")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			listUsersAfter(repository)
+			dir := tt.dir(t.TempDir())
+			userJobs := UserJobService{ExportService: &UserExportService{Repository: repository}, Dir: dir}
+			job := &model.Job{Id: 7, Type: model.JOB_EXPORT, Params: model.JobParams{Format: codec.FORMAT_CSV}}
+
+			// When
+			_, err := userJobs.Export(context.Background(), job, func(model.JobProgress) {})
+
+			// Then
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrJobsDirNotPrivate)
+				return
+			}
+			assert.Nil(t, err)
+			info, err := os.Stat(dir)
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+			info, err = os.Stat(filepath.Join(dir, "job-7.csv"))
+			assert.Nil(t, err)
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		})
+	}
+}
+
+func TestUserJobService_Purge(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      model.UserFilter
+		deleteErr   error
+		wantDeleted []int64
+		wantErrKind error
+	}{
+		{
+			name:        "Matching users",
+			filter:      model.UserFilter{FirstName: "ada"},
+			wantDeleted: []int64{1, 4},
+		},
+		{
+			name:        "Already deleted",
+			filter:      model.UserFilter{FirstName: "ada"},
+			deleteErr:   apperror.NotFound("user not found", nil),
+			wantDeleted: []int64{1, 4},
+		},
+		{
+			name:        "Database error",
+			filter:      model.UserFilter{FirstName: "ada"},
+			deleteErr:   apperror.Unavailable("database unavailable", nil),
+			wantDeleted: []int64{1},
+			wantErrKind: apperror.ErrUnavailable,
+		},
+		{
+			name:        "No filter",
+			wantDeleted: []int64{},
+			wantErrKind: apperror.ErrValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repository := new(mocksRepo.IUserRepository)
+			listUsersAfter(repository)
+			userService := new(mocksService.IUserService)
+			deleted := []int64{}
+			userService.On("DeleteUser", mock.Anything).Return(func(id int64) error {
+				deleted = append(deleted, id)
+				return tt.deleteErr
+			})
+			userJobs := UserJobService{UserService: userService, Repository: repository, ChunkSize: 2}
+			job := &model.Job{Id: 1, Type: model.JOB_PURGE, Params: model.JobParams{Filter: tt.filter}}
+
+			// When
+			result, err := userJobs.Purge(context.Background(), job, func(model.JobProgress) {})
+
+			// Then
+			assert.Equal(t, tt.wantDeleted, deleted)
+			if tt.wantErrKind != nil {
+				assert.ErrorIs(t, err, tt.wantErrKind)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, len(tt.wantDeleted), result.Deleted)
+		})
+	}
+}
+
+func TestUserJobService_Purge_Canceled(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IUserRepository)
+	listUsersAfter(repository)
+	userService := new(mocksService.IUserService)
+	ctx, cancel := context.WithCancel(context.Background())
+	userService.On("DeleteUser", int64(1)).Run(func(mock.Arguments) { cancel() }).Return(nil)
+	userJobs := UserJobService{UserService: userService, Repository: repository}
+	job := &model.Job{Id: 1, Type: model.JOB_PURGE, Params: model.JobParams{Filter: model.UserFilter{FirstName: "ada"}}}
+
+	// When
+	_, err := userJobs.Purge(ctx, job, func(model.JobProgress) {})
+
+	// Then
+	assert.True(t, errors.Is(err, context.Canceled))
+	userService.AssertNumberOfCalls(t, "DeleteUser", 1)
+}
+
+func TestUserJobService_Revalidate(t *testing.T) {
+	// Given
+	repository := new(mocksRepo.IUserRepository)
+	listUsersAfter(repository)
+	validationService := new(mocksService.IUserValidationService)
+	validationService.On("ValidateUserFields", mock.Anything).Return(func(user *model.User) []model.FieldError {
+		fieldErrors := []model.FieldError{}
+		if user.FirstName == "Ada" {
+			// every holder of a taken name fails the create rule
+			fieldErrors = append(fieldErrors, nameUniqueError("last_name"))
+		}
+		if user.Age < 18 {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: "age", Code: CODE_AGE_MINIMUM, Message: ERROR_AGE_MINIMUM})
+		}
+		return fieldErrors
+	}, nil)
+	userJobs := UserJobService{ValidationService: validationService, Repository: repository, ChunkSize: 2}
+	job := &model.Job{Id: 1, Type: model.JOB_REVALIDATE}
+	progress := []model.JobProgress{}
+
+	// When
+	result, err := userJobs.Revalidate(context.Background(), job, func(p model.JobProgress) { progress = append(progress, p) })
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, &model.RevalidationReport{
+		Users:   3,
+		Invalid: 1,
+		Errors: []model.RevalidationError{
+			{UserId: 4, Field: "age", Code: CODE_AGE_MINIMUM, Message: ERROR_AGE_MINIMUM},
+			{UserId: 4, Field: "last_name", Code: CODE_NAME_UNIQUE, Message: ERROR_NAME_UNIQUE},
+		},
+	}, result.Revalidation)
+	assert.Equal(t, []model.JobProgress{{Done: 1}, {Done: 2}, {Done: 3}}, progress)
+}
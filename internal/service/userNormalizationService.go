@@ -0,0 +1,108 @@
+package service
+
+import (
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"strings"
+	"unicode"
+)
+
+// DefaultNameParticles are the name particles kept in lower case inside a multi-word name, e.g. "de la Cruz"
+var DefaultNameParticles = []string{
+	"al", "bin", "da", "das", "de", "del", "della", "der", "di", "do", "dos", "du", "ibn", "la", "le", "ten", "ter", "van", "von",
+}
+
+type IUserNormalizationService interface {
+	NormalizeUser(user *model.User)
+}
+
+// UserNormalizationService cleans up user input before it is validated and stored.
+// Whitespace is always trimmed and collapsed, and control and zero-width characters are always stripped;
+// the remaining rules can be switched off.
+type UserNormalizationService struct {
+	CapitalizeNames bool
+	LowercaseEmail  bool
+	// NameParticles are kept in lower case when CapitalizeNames is set, DefaultNameParticles are used when empty
+	NameParticles []string
+}
+
+// NormalizeUser rewrites the user fields in place
+func (uns *UserNormalizationService) NormalizeUser(user *model.User) {
+
+	user.FirstName = cleanText(user.FirstName)
+	user.LastName = cleanText(user.LastName)
+	user.Email = cleanText(user.Email)
+
+	if uns.CapitalizeNames {
+		user.FirstName = uns.capitalizeName(user.FirstName)
+		user.LastName = uns.capitalizeName(user.LastName)
+	}
+
+	if uns.LowercaseEmail {
+		user.Email = strings.ToLower(user.Email)
+	}
+}
+
+// cleanText strips control and zero-width characters, then trims and collapses whitespace
+func cleanText(value string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, value)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// capitalizeName capitalizes every word of a name written all in upper or lower case.
+// Words in mixed case, e.g. "McDonald", are kept as they are, and particles are lower cased unless they are the whole name.
+func (uns *UserNormalizationService) capitalizeName(name string) string {
+
+	particles := uns.NameParticles
+	if len(particles) == 0 {
+		particles = DefaultNameParticles
+	}
+
+	words := strings.Split(name, " ")
+	for i, word := range words {
+		if !isSingleCase(word) {
+			continue
+		}
+		lower := strings.ToLower(word)
+		if len(words) > 1 && contains(particles, lower) {
+			words[i] = lower
+			continue
+		}
+		words[i] = capitalizeWord(lower)
+	}
+	return strings.Join(words, " ")
+}
+
+// capitalizeWord upper cases the first letter of a word and of every part after a hyphen or an apostrophe,
+// e.g. "o'neil-smith" becomes "O'Neil-Smith"
+func capitalizeWord(word string) string {
+	runes := []rune(word)
+	startOfPart := true
+	for i, r := range runes {
+		if startOfPart && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		startOfPart = r == '-' || r == '\'' || r == '’'
+	}
+	return string(runes)
+}
+
+func isSingleCase(word string) bool {
+	return word == strings.ToLower(word) || word == strings.ToUpper(word)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
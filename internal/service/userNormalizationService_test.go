@@ -0,0 +1,72 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"testing"
+)
+
+func TestUserNormalizationService_NormalizeUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer UserNormalizationService
+		user       model.User
+		want       model.User
+	}{
+		{
+			name:       "Trims, capitalizes and lowercases",
+			normalizer: UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true},
+			user:       model.User{FirstName: " thomas ", LastName: "JEFFERSON", Email: "T.Jefferson@Yahoo.COM", Age: 40},
+			want:       model.User{FirstName: "Thomas", LastName: "Jefferson", Email: "t.jefferson@yahoo.com", Age: 40},
+		},
+		{
+			name:       "Collapses whitespace and strips invisible characters",
+			normalizer: UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true},
+			user:       model.User{FirstName: "Mary \t  Jane\u200b", LastName: "\x00Watson\ufeff", Email: " mj@example.com\n"},
+			want:       model.User{FirstName: "Mary Jane", LastName: "Watson", Email: "mj@example.com"},
+		},
+		{
+			name:       "Keeps particles in lower case",
+			normalizer: UserNormalizationService{CapitalizeNames: true},
+			user:       model.User{FirstName: "LUDWIG", LastName: "VAN BEETHOVEN"},
+			want:       model.User{FirstName: "Ludwig", LastName: "van Beethoven"},
+		},
+		{
+			name:       "Capitalizes a particle used as a whole name",
+			normalizer: UserNormalizationService{CapitalizeNames: true},
+			user:       model.User{FirstName: "de", LastName: "de la cruz"},
+			want:       model.User{FirstName: "De", LastName: "de la Cruz"},
+		},
+		{
+			name:       "Capitalizes after apostrophes and hyphens",
+			normalizer: UserNormalizationService{CapitalizeNames: true},
+			user:       model.User{FirstName: "jean-luc", LastName: "O'NEIL"},
+			want:       model.User{FirstName: "Jean-Luc", LastName: "O'Neil"},
+		},
+		{
+			name:       "Keeps mixed case words",
+			normalizer: UserNormalizationService{CapitalizeNames: true},
+			user:       model.User{FirstName: "DeShawn", LastName: "McDonald"},
+			want:       model.User{FirstName: "DeShawn", LastName: "McDonald"},
+		},
+		{
+			name:       "Uses configured particles",
+			normalizer: UserNormalizationService{CapitalizeNames: true, NameParticles: []string{"af"}},
+			user:       model.User{FirstName: "anna", LastName: "af klint"},
+			want:       model.User{FirstName: "Anna", LastName: "af Klint"},
+		},
+		{
+			name:       "Only cleans up when rules are off",
+			normalizer: UserNormalizationService{},
+			user:       model.User{FirstName: " thomas ", LastName: "JEFFERSON", Email: "T.Jefferson@Yahoo.COM "},
+			want:       model.User{FirstName: "thomas", LastName: "JEFFERSON", Email: "T.Jefferson@Yahoo.COM"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			tt.normalizer.NormalizeUser(&user)
+			assert.Equal(t, tt.want, user)
+		})
+	}
+}
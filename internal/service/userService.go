@@ -1,27 +1,44 @@
 package service
 
 import (
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
-	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
 	"strings"
 )
 
 type IUserService interface {
-	GetAllUsers() ([]model.User, utils.MessageErr)
-	GetUser(id int64) (*model.User, utils.MessageErr)
-	SaveUser(user *model.User) (*model.User, utils.MessageErr)
-	UpdateUser(user *model.User) (*model.User, utils.MessageErr)
-	DeleteUser(id int64) utils.MessageErr
+	GetAllUsers() ([]model.User, error)
+	// ListUsersAfter returns at most limit users matching the filter ordered by id, starting after the given id, or every
+	// matching user when limit is not positive
+	ListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error)
+	GetUser(id int64) (*model.User, error)
+	// GetUserByEmail finds a user by email, normalized like the emails of saved users
+	GetUserByEmail(email string) (*model.User, error)
+	SaveUser(user *model.User) (*model.User, error)
+	UpdateUser(user *model.User) (*model.User, error)
+	DeleteUser(id int64) error
+	ValidateUser(user *model.User) (*model.ValidationResult, error)
+	ValidateUserField(user *model.User, field string) (*model.ValidationResult, error)
+}
+
+// IPublisher sends the changes of users to the clients following them, like the event stream
+type IPublisher interface {
+	// Publish sends an event about a user to the current subscribers, without waiting for them
+	Publish(eventType string, userId int64, data interface{})
 }
 
 type UserService struct {
 	Repository        repository.IUserRepository
 	ValidationService IUserValidationService
+	// NormalizationService cleans up users before they are validated, users are taken as-is when nil
+	NormalizationService IUserNormalizationService
+	// Events publishes the changes of users to the event stream, when set
+	Events IPublisher
 }
 
-func (us *UserService) GetAllUsers() ([]model.User, utils.MessageErr) {
+func (us *UserService) GetAllUsers() ([]model.User, error) {
 
 	userList, err := us.Repository.DbListUsers()
 
@@ -33,7 +50,18 @@ func (us *UserService) GetAllUsers() ([]model.User, utils.MessageErr) {
 	return userList, nil
 }
 
-func (us *UserService) GetUser(id int64) (*model.User, utils.MessageErr) {
+func (us *UserService) ListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+
+	users, err := us.Repository.DbListUsersAfter(filter, afterId, limit)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (us *UserService) GetUser(id int64) (*model.User, error) {
 
 	user, err := us.Repository.DbGetUser(id)
 
@@ -45,14 +73,33 @@ func (us *UserService) GetUser(id int64) (*model.User, utils.MessageErr) {
 	return user, nil
 }
 
-func (us *UserService) SaveUser(user *model.User) (*model.User, utils.MessageErr) {
+func (us *UserService) GetUserByEmail(email string) (*model.User, error) {
+
+	probe := &model.User{Email: email}
+	us.normalize(probe)
+	user, err := us.Repository.DbGetUserByEmail(probe.Email)
+
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (us *UserService) SaveUser(user *model.User) (*model.User, error) {
+
+	us.normalize(user)
 
 	// validate user
-	validationErr := us.ValidationService.ValidateUser(user)
+	fieldErrors, err := us.ValidationService.ValidateUserFields(user)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
 
-	if len(validationErr) > 0 {
-		log.Error.Println(validationErr)
-		return nil, utils.BadRequestError(strings.Join(validationErr, ","))
+	if len(fieldErrors) > 0 {
+		return nil, validationError(fieldErrors)
 	}
 
 	// create user
@@ -64,15 +111,20 @@ func (us *UserService) SaveUser(user *model.User) (*model.User, utils.MessageErr
 	}
 
 	log.Info.Printf("User created : %v", userCreated)
+	us.publish(model.EVENT_USER_CREATED, userCreated.Id, userCreated)
 
 	return userCreated, nil
 
 }
 
-func (us *UserService) UpdateUser(user *model.User) (*model.User, utils.MessageErr) {
+// UpdateUser validates the user like a created one, except that its unchanged names do not conflict with itself, and
+// saves it. Every transport relies on it to validate updates.
+func (us *UserService) UpdateUser(user *model.User) (*model.User, error) {
 
 	log.Info.Printf("User update service ")
 
+	us.normalize(user)
+
 	log.Info.Printf("User to update: %v", user)
 
 	// load up existing user with same id
@@ -80,6 +132,24 @@ func (us *UserService) UpdateUser(user *model.User) (*model.User, utils.MessageE
 	if err != nil {
 		return nil, err
 	}
+
+	// validate user, whose unchanged names are found in the database as its own
+	fieldErrors, err := us.ValidationService.ValidateUserFields(user)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+	sameNames := user.FirstName == current.FirstName && user.LastName == current.LastName
+	otherErrors := []model.FieldError{}
+	for _, fieldErr := range fieldErrors {
+		if fieldErr.Code != CODE_NAME_UNIQUE || !sameNames {
+			otherErrors = append(otherErrors, fieldErr)
+		}
+	}
+	if len(otherErrors) > 0 {
+		return nil, validationError(otherErrors)
+	}
+
 	current.FirstName = user.FirstName
 	current.LastName = user.LastName
 	current.Email = user.Email
@@ -91,22 +161,82 @@ func (us *UserService) UpdateUser(user *model.User) (*model.User, utils.MessageE
 		return nil, err
 	}
 	log.Info.Printf("User updated with details %v", updateUser)
+	us.publish(model.EVENT_USER_UPDATED, updateUser.Id, updateUser)
 	return updateUser, nil
 }
 
-func (us *UserService) DeleteUser(id int64) utils.MessageErr {
+func (us *UserService) DeleteUser(id int64) error {
 	//verify if user exist
-	user, err := us.Repository.DbGetUser(id)
+	_, err := us.Repository.DbGetUser(id)
 	if err != nil {
 		return err
 	}
-	if user == nil {
-		log.Error.Printf("User with id '%v' cannot be found", id)
-		return utils.NotFoundError("user not found")
-	}
 	err = us.Repository.DbDeleteUser(id)
 	if err != nil {
 		return err
 	}
+	us.publish(model.EVENT_USER_DELETED, id, model.UserDeletedData{Id: id})
 	return nil
 }
+
+// ValidateUser runs the full validation rule set without saving the user
+func (us *UserService) ValidateUser(user *model.User) (*model.ValidationResult, error) {
+
+	us.normalize(user)
+
+	fieldErrors, err := us.ValidationService.ValidateUserFields(user)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return us.newValidationResult(fieldErrors, user), nil
+}
+
+// ValidateUserField runs the validation rules of a single field without saving the user
+func (us *UserService) ValidateUserField(user *model.User, field string) (*model.ValidationResult, error) {
+
+	us.normalize(user)
+
+	fieldErrors, err := us.ValidationService.ValidateUserField(user, field)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return us.newValidationResult(fieldErrors, user), nil
+}
+
+// newValidationResult reports the field errors, along with the normalized user when normalization is enabled
+func (us *UserService) newValidationResult(fieldErrors []model.FieldError, user *model.User) *model.ValidationResult {
+	if fieldErrors == nil {
+		fieldErrors = []model.FieldError{}
+	}
+	result := &model.ValidationResult{Valid: len(fieldErrors) == 0, Errors: fieldErrors}
+	if us.NormalizationService != nil {
+		result.User = user
+	}
+	return result
+}
+
+func (us *UserService) publish(eventType string, userId int64, data interface{}) {
+	if us.Events != nil {
+		us.Events.Publish(eventType, userId, data)
+	}
+}
+
+func (us *UserService) normalize(user *model.User) {
+	if us.NormalizationService != nil {
+		us.NormalizationService.NormalizeUser(user)
+	}
+}
+
+// validationError reports the failed rules of a user
+func validationError(fieldErrors []model.FieldError) error {
+	validationErr := []string{}
+	for _, fieldErr := range fieldErrors {
+		validationErr = append(validationErr, fieldErr.Message)
+	}
+	log.Error.Println(validationErr)
+	return apperror.Validation(strings.Join(validationErr, ","), fieldErrors)
+}
@@ -1,13 +1,13 @@
 package service
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
 	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
 	mocks "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/service"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
-	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
-	"net/http"
 	"testing"
 )
 
@@ -24,7 +24,7 @@ func TestUserService_Mockery_GetUser(t *testing.T) {
 	mockUserRepository := new(mocksRepo.IUserRepository)
 	mockUserRepository.On("DbGetUser", mock.AnythingOfType("int64")).Return(&user, nil)
 	mockUserValidationService := new(mocks.IUserValidationService)
-	userService := UserService{mockUserRepository, mockUserValidationService}
+	userService := UserService{Repository: mockUserRepository, ValidationService: mockUserValidationService}
 
 	// When
 	userRet, err := userService.GetUser(1)
@@ -43,9 +43,9 @@ func TestUserService_Mockery_GetUserNotFoundID(t *testing.T) {
 	mockUserRepository := new(mocksRepo.IUserRepository)
 	mockUserRepository.On("DbGetUser", mock.AnythingOfType("int64")).Return(
 		nil,
-		utils.InternalServerError("the id is not found"))
+		apperror.Internal("the id is not found", nil))
 	mockUserValidationService := new(mocks.IUserValidationService)
-	userService := UserService{mockUserRepository, mockUserValidationService}
+	userService := UserService{Repository: mockUserRepository, ValidationService: mockUserValidationService}
 
 	// When
 	user, err := userService.GetUser(1)
@@ -54,7 +54,7 @@ func TestUserService_Mockery_GetUserNotFoundID(t *testing.T) {
 	assert.Nil(t, user)
 	assert.NotNil(t, err)
 	//assert.EqualValues(t, http.StatusNotFound, err.Status())
-	assert.EqualValues(t, "the id is not found", err.Message())
+	assert.EqualValues(t, "the id is not found", err.Error())
 	//assert.EqualValues(t, "not_found", err.Error())
 }
 
@@ -77,8 +77,8 @@ func TestMessagesService_Mockery_SaveUser_Success(t *testing.T) {
 		&user,
 		nil)
 	mockUserValidationService := new(mocks.IUserValidationService)
-	mockUserValidationService.On("ValidateUser", mock.Anything).Return(nil)
-	userService := UserService{mockUserRepository, mockUserValidationService}
+	mockUserValidationService.On("ValidateUserFields", mock.Anything).Return(nil, nil)
+	userService := UserService{Repository: mockUserRepository, ValidationService: mockUserValidationService}
 	request := &model.User{
 		FirstName: "John",
 		LastName:  "Doe",
@@ -113,14 +113,15 @@ func TestUserService_Mockery_SaveUser_Invalid_Request(t *testing.T) {
 		&user,
 		nil)
 	mockUserValidationService := new(mocks.IUserValidationService)
-	mockUserValidationService.On("ValidateUser", mock.Anything).Return([]string{"invalid_request"})
-	userService := UserService{mockUserRepository, mockUserValidationService}
+	mockUserValidationService.On("ValidateUserFields", mock.Anything).Return(
+		[]model.FieldError{{Field: "first_name", Code: "required", Message: "invalid_request"}},
+		nil)
+	userService := UserService{Repository: mockUserRepository, ValidationService: mockUserValidationService}
 
 	tests := []struct {
-		request    *model.User
-		statusCode int
-		errMsg     string
-		errErr     string
+		request *model.User
+		kind    error
+		errMsg  string
 	}{
 		{
 			request: &model.User{
@@ -129,9 +130,8 @@ func TestUserService_Mockery_SaveUser_Invalid_Request(t *testing.T) {
 				Email:     "john.doe@gmail.com",
 				Age:       30,
 			},
-			statusCode: http.StatusBadRequest,
-			errMsg:     "invalid_request",
-			errErr:     "bad_request",
+			kind:   apperror.ErrValidation,
+			errMsg: "invalid_request",
 		},
 		{
 			request: &model.User{
@@ -140,9 +140,8 @@ func TestUserService_Mockery_SaveUser_Invalid_Request(t *testing.T) {
 				Email:     "john.doe@gmail.com",
 				Age:       30,
 			},
-			statusCode: http.StatusBadRequest,
-			errMsg:     "invalid_request",
-			errErr:     "bad_request",
+			kind:   apperror.ErrValidation,
+			errMsg: "invalid_request",
 		},
 	}
 	for _, tt := range tests {
@@ -152,8 +151,7 @@ func TestUserService_Mockery_SaveUser_Invalid_Request(t *testing.T) {
 		// Then
 		assert.Nil(t, msg)
 		assert.NotNil(t, err)
-		assert.EqualValues(t, tt.errMsg, err.Message())
-		assert.EqualValues(t, tt.statusCode, err.Status())
-		assert.EqualValues(t, tt.errErr, err.Error())
+		assert.EqualValues(t, tt.errMsg, err.Error())
+		assert.True(t, errors.Is(err, tt.kind))
 	}
 }
@@ -1,12 +1,14 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
-	"github.com/wexinc/ps-tag-onboarding-go/internal/utils"
-	"net/http"
 	"testing"
 )
 
@@ -14,8 +16,8 @@ func TestUserService_GetUser(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -42,9 +44,9 @@ func TestUserService_GetUserNotFoundID(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
-		return nil, utils.InternalServerError("the id is not found")
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
+		return nil, apperror.Internal("the id is not found", nil)
 	}
 
 	// When
@@ -54,7 +56,7 @@ func TestUserService_GetUserNotFoundID(t *testing.T) {
 	assert.Nil(t, user)
 	assert.NotNil(t, err)
 	//assert.EqualValues(t, http.StatusNotFound, err.Status())
-	assert.EqualValues(t, "the id is not found", err.Message())
+	assert.EqualValues(t, "the id is not found", err.Error())
 	//assert.EqualValues(t, "not_found", err.Error())
 }
 
@@ -67,8 +69,8 @@ func TestMessagesService_SaveUser_Success(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	createUserDomain = func(user *model.User) (*model.User, utils.MessageErr) {
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	createUserDomain = func(user *model.User) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -77,7 +79,7 @@ func TestMessagesService_SaveUser_Success(t *testing.T) {
 			Age:       30,
 		}, nil
 	}
-	getValidation = func(user *model.User) []string {
+	getFieldsValidation = func(user *model.User) []model.FieldError {
 		return nil
 	}
 	request := &model.User{
@@ -104,15 +106,14 @@ func TestUserService_SaveUser_Invalid_Request(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getValidation = func(user *model.User) []string {
-		return []string{"invalid_request"}
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getFieldsValidation = func(user *model.User) []model.FieldError {
+		return []model.FieldError{{Field: "first_name", Code: "required", Message: "invalid_request"}}
 	}
 	tests := []struct {
-		request    *model.User
-		statusCode int
-		errMsg     string
-		errErr     string
+		request *model.User
+		kind    error
+		errMsg  string
 	}{
 		{
 			request: &model.User{
@@ -121,9 +122,8 @@ func TestUserService_SaveUser_Invalid_Request(t *testing.T) {
 				Email:     "john.doe@gmail.com",
 				Age:       30,
 			},
-			statusCode: http.StatusBadRequest,
-			errMsg:     "invalid_request",
-			errErr:     "bad_request",
+			kind:   apperror.ErrValidation,
+			errMsg: "invalid_request",
 		},
 		{
 			request: &model.User{
@@ -132,9 +132,8 @@ func TestUserService_SaveUser_Invalid_Request(t *testing.T) {
 				Email:     "john.doe@gmail.com",
 				Age:       30,
 			},
-			statusCode: http.StatusBadRequest,
-			errMsg:     "invalid_request",
-			errErr:     "bad_request",
+			kind:   apperror.ErrValidation,
+			errMsg: "invalid_request",
 		},
 	}
 	for _, tt := range tests {
@@ -144,9 +143,8 @@ func TestUserService_SaveUser_Invalid_Request(t *testing.T) {
 		// Then
 		assert.Nil(t, msg)
 		assert.NotNil(t, err)
-		assert.EqualValues(t, tt.errMsg, err.Message())
-		assert.EqualValues(t, tt.statusCode, err.Status())
-		assert.EqualValues(t, tt.errErr, err.Error())
+		assert.EqualValues(t, tt.errMsg, err.Error())
+		assert.True(t, errors.Is(err, tt.kind))
 	}
 }
 
@@ -158,8 +156,8 @@ func TestUserService_UpdateUser_Success(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -168,7 +166,7 @@ func TestUserService_UpdateUser_Success(t *testing.T) {
 			Age:       30,
 		}, nil
 	}
-	updateUserDomain = func(user *model.User) (*model.User, utils.MessageErr) {
+	updateUserDomain = func(user *model.User) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "Johnny",
@@ -180,6 +178,9 @@ func TestUserService_UpdateUser_Success(t *testing.T) {
 	getValidation = func(user *model.User) []string {
 		return nil
 	}
+	getFieldsValidation = func(user *model.User) []model.FieldError {
+		return nil
+	}
 	request := &model.User{
 		FirstName: "Johnny",
 		LastName:  "Dover",
@@ -198,15 +199,75 @@ func TestUserService_UpdateUser_Success(t *testing.T) {
 	assert.EqualValues(t, "Dover", user.LastName)
 }
 
+func TestUserService_UpdateUser_Validation(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      model.User
+		nameTaken bool
+		wantCode  string
+	}{
+		{
+			name:      "Own names",
+			user:      model.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@yahoo.com", Age: 35},
+			nameTaken: true,
+		},
+		{
+			name:      "Names of another user",
+			user:      model.User{Id: 1, FirstName: "Jim", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+			nameTaken: true,
+			wantCode:  CODE_NAME_UNIQUE,
+		},
+		{
+			name:      "Own names with another case",
+			user:      model.User{Id: 1, FirstName: "JOHN", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30},
+			nameTaken: true,
+			wantCode:  CODE_NAME_UNIQUE,
+		},
+		{
+			name:      "Own names and an invalid email",
+			user:      model.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john", Age: 30},
+			nameTaken: true,
+			wantCode:  CODE_EMAIL_FORMAT,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			repo := new(mocksRepo.IUserRepository)
+			repo.On("DbGetUser", int64(1)).Return(&model.User{Id: 1, FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 30}, nil)
+			repo.On("ExistsByFirstNameAndLastName", tt.user.FirstName, tt.user.LastName).Return(tt.nameTaken, nil)
+			repo.On("DbUpdateUser", mock.Anything).Return(func(user *model.User) *model.User { return user }, nil)
+			userService := UserService{Repository: repo, ValidationService: &UserValidationService{Repository: repo}}
+
+			// When
+			user, err := userService.UpdateUser(&tt.user)
+
+			// Then
+			if tt.wantCode == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.user, *user)
+				return
+			}
+			assert.Nil(t, user)
+			assert.ErrorIs(t, err, apperror.ErrValidation)
+			var appErr *apperror.Error
+			if assert.True(t, errors.As(err, &appErr)) && assert.Len(t, appErr.Fields, 1) {
+				assert.Equal(t, tt.wantCode, appErr.Fields[0].Code)
+			}
+			repo.AssertNotCalled(t, "DbUpdateUser", mock.Anything)
+		})
+	}
+}
+
 // Test error scenarios where an error can occur when trying to fetch the user to update,
 // anything from a timeout error to a not found error.
 func TestUserService_UpdateUser_Failure_Getting_Former_Message(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
-		return nil, utils.InternalServerError("error getting message")
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
+		return nil, apperror.Internal("error getting message", nil)
 	}
 	getValidation = func(user *model.User) []string {
 		return nil
@@ -224,9 +285,34 @@ func TestUserService_UpdateUser_Failure_Getting_Former_Message(t *testing.T) {
 	// Then
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
-	assert.EqualValues(t, "error getting message", err.Message())
-	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
-	assert.EqualValues(t, "server_error", err.Error())
+	assert.EqualValues(t, "error getting message", err.Error())
+	assert.True(t, errors.Is(err, apperror.ErrInternal))
+}
+
+func TestUserService_ValidateUser_Normalized(t *testing.T) {
+	// Given
+	var repo repository.IUserRepository = &MockRepo{}
+	var userValidation IUserValidationService = &MockValidation{}
+	userService := UserService{
+		Repository:           repo,
+		ValidationService:    userValidation,
+		NormalizationService: &UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true},
+	}
+	var validated model.User
+	getFieldsValidation = func(user *model.User) []model.FieldError {
+		validated = *user
+		return nil
+	}
+
+	// When
+	result, err := userService.ValidateUser(&model.User{FirstName: " thomas ", LastName: "JEFFERSON", Email: "T.Jefferson@Yahoo.COM"})
+
+	// Then
+	want := model.User{FirstName: "Thomas", LastName: "Jefferson", Email: "t.jefferson@yahoo.com"}
+	assert.Nil(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, want, validated)
+	assert.Equal(t, &want, result.User)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -237,8 +323,8 @@ func TestUserService_DeleteUser_Success(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
 		return &model.User{
 			Id:        1,
 			FirstName: "John",
@@ -247,7 +333,7 @@ func TestUserService_DeleteUser_Success(t *testing.T) {
 			Age:       30,
 		}, nil
 	}
-	deleteUserDomain = func(userId int64) utils.MessageErr {
+	deleteUserDomain = func(userId int64) error {
 		return nil
 	}
 	getValidation = func(user *model.User) []string {
@@ -266,9 +352,9 @@ func TestMessagesService_DeleteMessage_Error_Getting_Message(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getUserDomain = func(userId int64) (*model.User, utils.MessageErr) {
-		return nil, utils.InternalServerError("Something went wrong getting message")
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getUserDomain = func(userId int64) (*model.User, error) {
+		return nil, apperror.Internal("Something went wrong getting message", nil)
 	}
 	getValidation = func(user *model.User) []string {
 		return nil
@@ -279,9 +365,51 @@ func TestMessagesService_DeleteMessage_Error_Getting_Message(t *testing.T) {
 
 	// Then
 	assert.NotNil(t, err)
-	assert.EqualValues(t, "Something went wrong getting message", err.Message())
-	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
-	assert.EqualValues(t, "server_error", err.Error())
+	assert.EqualValues(t, "Something went wrong getting message", err.Error())
+	assert.True(t, errors.Is(err, apperror.ErrInternal))
+}
+
+///////////////////////////////////////////////////////////////
+// 				"ValidateUser" test cases
+///////////////////////////////////////////////////////////////
+
+func TestUserService_ValidateUser_Valid(t *testing.T) {
+	// Given
+	var repo repository.IUserRepository = &MockRepo{}
+	var userValidation IUserValidationService = &MockValidation{}
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getFieldsValidation = func(user *model.User) []model.FieldError {
+		return nil
+	}
+
+	// When
+	result, err := userService.ValidateUser(&model.User{FirstName: "John"})
+
+	// Then
+	assert.Nil(t, err)
+	assert.True(t, result.Valid)
+	assert.NotNil(t, result.Errors)
+	assert.Empty(t, result.Errors)
+}
+
+func TestUserService_ValidateUser_Invalid(t *testing.T) {
+	// Given
+	var repo repository.IUserRepository = &MockRepo{}
+	var userValidation IUserValidationService = &MockValidation{}
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getFieldsValidation = func(user *model.User) []model.FieldError {
+		return []model.FieldError{{Field: "age", Code: CODE_AGE_MINIMUM, Message: ERROR_AGE_MINIMUM}}
+	}
+
+	// When
+	result, err := userService.ValidateUserField(&model.User{Age: 9}, "age")
+
+	// Then
+	assert.Nil(t, err)
+	assert.False(t, result.Valid)
+	assert.Len(t, result.Errors, 1)
+	assert.EqualValues(t, "age", result.Errors[0].Field)
+	assert.EqualValues(t, CODE_AGE_MINIMUM, result.Errors[0].Code)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -292,8 +420,8 @@ func TestUserService_GetAllUser(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getAllUsersDomain = func() ([]model.User, utils.MessageErr) {
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getAllUsersDomain = func() ([]model.User, error) {
 		return []model.User{
 			{
 				Id:        1,
@@ -330,16 +458,45 @@ func TestUserService_GetAllUsers_Error_Getting_Users(t *testing.T) {
 	// Given
 	var repo repository.IUserRepository = &MockRepo{}
 	var userValidation IUserValidationService = &MockValidation{}
-	userService := UserService{repo, userValidation}
-	getAllUsersDomain = func() ([]model.User, utils.MessageErr) {
-		return nil, utils.InternalServerError("error getting messages")
+	userService := UserService{Repository: repo, ValidationService: userValidation}
+	getAllUsersDomain = func() ([]model.User, error) {
+		return nil, apperror.Internal("error getting messages", nil)
 	}
 	messages, err := userService.GetAllUsers()
 	assert.NotNil(t, err)
 	assert.Nil(t, messages)
-	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
-	assert.EqualValues(t, "error getting messages", err.Message())
-	assert.EqualValues(t, "server_error", err.Error())
+	assert.True(t, errors.Is(err, apperror.ErrInternal))
+	assert.EqualValues(t, "error getting messages", err.Error())
+}
+
+func TestUserService_ListUsersAfter(t *testing.T) {
+	// Given
+	var repo repository.IUserRepository = &MockRepo{}
+	userService := UserService{Repository: repo}
+	getAllUsersDomain = func() ([]model.User, error) {
+		return []model.User{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}}, nil
+	}
+
+	// When
+	users, err := userService.ListUsersAfter(model.UserFilter{}, 1, 2)
+
+	// Then
+	assert.Nil(t, err)
+	assert.EqualValues(t, []model.User{{Id: 2}, {Id: 3}}, users)
+}
+
+func TestUserService_GetUserByEmail(t *testing.T) {
+	// Given
+	repo := new(mocksRepo.IUserRepository)
+	repo.On("DbGetUserByEmail", "john.doe@gmail.com").Return(&model.User{Id: 1, Email: "john.doe@gmail.com"}, nil)
+	userService := UserService{Repository: repo, NormalizationService: &UserNormalizationService{LowercaseEmail: true}}
+
+	// When
+	user, err := userService.GetUserByEmail(" John.Doe@Gmail.com")
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), user.Id)
 }
 
 // =================================================== //
@@ -347,49 +504,82 @@ func TestUserService_GetAllUsers_Error_Getting_Users(t *testing.T) {
 // =================================================== //
 
 var (
-	getUserDomain    func(userId int64) (*model.User, utils.MessageErr)
-	createUserDomain func(user *model.User) (*model.User, utils.MessageErr)
-	//createMessageDomain  func(msg *domain.Message) (*domain.Message, error_utils.MessageErr)
-	updateUserDomain  func(user *model.User) (*model.User, utils.MessageErr)
-	deleteUserDomain  func(userId int64) utils.MessageErr
-	getAllUsersDomain func() ([]model.User, utils.MessageErr)
-
-	getValidation func(user *model.User) []string
+	getUserDomain    func(userId int64) (*model.User, error)
+	createUserDomain func(user *model.User) (*model.User, error)
+	//createMessageDomain  func(msg *domain.Message) (*domain.Message, error_error)
+	updateUserDomain  func(user *model.User) (*model.User, error)
+	deleteUserDomain  func(userId int64) error
+	getAllUsersDomain func() ([]model.User, error)
+
+	getValidation       func(user *model.User) []string
+	getFieldsValidation func(user *model.User) []model.FieldError
 )
 
 // MockRepo is a struct that mocks UserRepository.
 type MockRepo struct{}
 
 // Repository mock method implementation.
-func (m *MockRepo) DbListUsers() ([]model.User, utils.MessageErr) {
+func (m *MockRepo) DbListUsers() ([]model.User, error) {
 	// Implement your mock behavior here
 	return getAllUsersDomain() // Return a mock GORM DB
 }
-func (m *MockRepo) DbCreateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (m *MockRepo) DbListUsersAfter(filter model.UserFilter, afterId int64, limit int) ([]model.User, error) {
+	users, err := getAllUsersDomain()
+	if err != nil {
+		return nil, err
+	}
+	chunk := []model.User{}
+	for _, user := range users {
+		if filter.Matches(user) && user.Id > afterId && (limit <= 0 || len(chunk) < limit) {
+			chunk = append(chunk, user)
+		}
+	}
+	return chunk, nil
+}
+func (m *MockRepo) DbCreateUser(user *model.User) (*model.User, error) {
 	// Implement your mock behavior here
 	return createUserDomain(user) // Return a mock GORM DB
 }
-func (m *MockRepo) DbGetUser(id int64) (*model.User, utils.MessageErr) {
+func (m *MockRepo) DbCreateUsers(users []model.User) ([]model.User, error) {
+	for i := range users {
+		if _, err := createUserDomain(&users[i]); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+func (m *MockRepo) DbGetUser(id int64) (*model.User, error) {
 	// Implement your mock behavior here
 	return getUserDomain(id) // Return a mock GORM DB
 }
-func (m *MockRepo) DbUpdateUser(user *model.User) (*model.User, utils.MessageErr) {
+func (m *MockRepo) DbUpdateUser(user *model.User) (*model.User, error) {
 	// Implement your mock behavior here
 	return updateUserDomain(user) // Return a mock GORM DB
 }
-func (m *MockRepo) DbDeleteUser(id int64) utils.MessageErr {
+func (m *MockRepo) DbDeleteUser(id int64) error {
 	// Implement your mock behavior here
 	return deleteUserDomain(id) // Return a mock GORM DB
 }
-func (m *MockRepo) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, utils.MessageErr) {
+func (m *MockRepo) ExistsByFirstNameAndLastName(firstName string, lastName string) (bool, error) {
 	// Implement your mock behavior here
 	return true, nil // Return a mock GORM DB
 }
+func (m *MockRepo) DbGetUserByEmail(email string) (*model.User, error) {
+	return nil, apperror.NotFound("user not found with this email", nil)
+}
 
 type MockValidation struct{}
 
-func (m *MockValidation) ValidateUser(user *model.User) []string {
-	return getValidation(user)
+func (m *MockValidation) ValidateUser(user *model.User) ([]string, error) {
+	return getValidation(user), nil
+}
+
+func (m *MockValidation) ValidateUserFields(user *model.User) ([]model.FieldError, error) {
+	return getFieldsValidation(user), nil
+}
+
+func (m *MockValidation) ValidateUserField(user *model.User, field string) ([]model.FieldError, error) {
+	return getFieldsValidation(user), nil
 }
 
 // =================================================== //
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"reflect"
 	"strings"
 )
 
@@ -12,62 +15,142 @@ const (
 	ERROR_AGE_MINIMUM          = "User does not meet minimum age requirement"
 	ERROR_EMAIL_FORMAT         = "User email must be properly formatted"
 	ERROR_NAME_UNIQUE          = "User with the same first and last name already exists"
+	ERROR_UNKNOWN_FIELD        = "unknown user field '%v'"
 	RESPONSE_USER_NOT_FOUND    = "User not found"
 	RESPONSE_VALIDATION_FAILED = "User did not pass validation"
 )
 
+// Codes identifying which rule a FieldError comes from
+const (
+	CODE_AGE_MINIMUM   = "age_minimum"
+	CODE_EMAIL_FORMAT  = "email_format"
+	CODE_NAME_UNIQUE   = "name_unique"
+	CODE_UNKNOWN_FIELD = "unknown_field"
+)
+
 type IUserValidationService interface {
-	ValidateUser(user *model.User) []string
+	ValidateUser(user *model.User) ([]string, error)
+	ValidateUserFields(user *model.User) ([]model.FieldError, error)
+	ValidateUserField(user *model.User, field string) ([]model.FieldError, error)
 }
 
 type UserValidationService struct {
 	Repository repository.IUserRepository //*repository.UserRepository
 }
 
-func (uvs *UserValidationService) ValidateUser(user *model.User) []string {
+// userFields maps the json name of each validated user field to its struct field name
+var userFields = jsonToStructFields(reflect.TypeOf(model.User{}))
+
+func (uvs *UserValidationService) ValidateUser(user *model.User) ([]string, error) {
+
+	fieldErrors, err := uvs.ValidateUserFields(user)
+	if err != nil {
+		return nil, err
+	}
 
-	validate := validator.New()
 	validationErr := []string{}
+	for _, fieldErr := range fieldErrors {
+		validationErr = append(validationErr, fieldErr.Message)
+	}
 
-	// Register custom validation function with the validator
-	validate.RegisterValidation("validateAge", uvs.validateAge)
-	validate.RegisterValidation("validateEmail", uvs.validateEmail)
+	if len(validationErr) > 0 {
+		log.Info.Println(RESPONSE_VALIDATION_FAILED)
+		return validationErr, nil
+	} else {
+		log.Info.Println("Validation successful")
+	}
+
+	return nil, nil
+}
+
+// ValidateUserFields runs the full rule set and reports every failure with the field it belongs to
+func (uvs *UserValidationService) ValidateUserFields(user *model.User) ([]model.FieldError, error) {
 
-	// Test custom field validation
-	err := validate.Struct(user)
+	fieldErrors := uvs.toFieldErrors(uvs.newValidator().Struct(user))
+
+	// validate firstName and lastName
+	unique, err := uvs.validateFirstNameLastName(user.FirstName, user.LastName)
 	if err != nil {
-		for _, err := range err.(validator.ValidationErrors) {
+		return nil, err
+	}
+	if !unique {
+		log.Error.Print("validateFirstNameLastName error")
+		fieldErrors = append(fieldErrors, nameUniqueError("last_name"))
+	}
+
+	return fieldErrors, nil
+}
+
+// ValidateUserField runs only the rules of the given field, identified by its json name.
+// Name uniqueness is checked for first_name and last_name once both names are provided.
+func (uvs *UserValidationService) ValidateUserField(user *model.User, field string) ([]model.FieldError, error) {
 
-			switch err.Tag() {
+	structField, ok := userFields[field]
+	if !ok {
+		message := fmt.Sprintf(ERROR_UNKNOWN_FIELD, field)
+		return nil, apperror.Validation(message, []model.FieldError{{Field: field, Code: CODE_UNKNOWN_FIELD, Message: message}})
+	}
 
-			case "validateAge":
-				log.Error.Print("validateAge error:", err.Error())
-				validationErr = append(validationErr, ERROR_AGE_MINIMUM)
-			case "validateEmail", "email":
-				log.Error.Print("validateEmail error:", err.Error())
-				validationErr = append(validationErr, ERROR_EMAIL_FORMAT)
-			default:
-				log.Error.Print("validation error:", err.Error())
-				validationErr = append(validationErr, err.Error())
+	fieldErrors := uvs.toFieldErrors(uvs.newValidator().StructPartial(user, structField))
 
-			}
+	if (field == "first_name" || field == "last_name") && user.FirstName != "" && user.LastName != "" {
+		unique, err := uvs.validateFirstNameLastName(user.FirstName, user.LastName)
+		if err != nil {
+			return nil, err
+		}
+		if !unique {
+			log.Error.Print("validateFirstNameLastName error")
+			fieldErrors = append(fieldErrors, nameUniqueError(field))
 		}
 	}
 
-	// validate firstName and lastName
-	if !uvs.validateFirstNameLastName(user.FirstName, user.LastName) {
-		log.Error.Print("validateFirstNameLastName error")
-		validationErr = append(validationErr, ERROR_NAME_UNIQUE)
+	return fieldErrors, nil
+}
+
+func (uvs *UserValidationService) newValidator() *validator.Validate {
+	validate := validator.New()
+
+	// Register custom validation function with the validator
+	validate.RegisterValidation("validateAge", uvs.validateAge)
+	validate.RegisterValidation("validateEmail", uvs.validateEmail)
+
+	return validate
+}
+
+func (uvs *UserValidationService) toFieldErrors(err error) []model.FieldError {
+
+	fieldErrors := []model.FieldError{}
+	if err == nil {
+		return fieldErrors
 	}
 
-	if len(validationErr) > 0 {
-		log.Info.Println(RESPONSE_VALIDATION_FAILED)
-		return validationErr
-	} else {
-		log.Info.Println("Validation successful")
+	for _, err := range err.(validator.ValidationErrors) {
+
+		fieldErr := model.FieldError{
+			Field:   structToJsonField(err.StructField()),
+			Code:    err.Tag(),
+			Message: err.Error(),
+		}
+
+		switch err.Tag() {
+
+		case "validateAge":
+			log.Error.Print("validateAge error:", err.Error())
+			fieldErr.Code = CODE_AGE_MINIMUM
+			fieldErr.Message = ERROR_AGE_MINIMUM
+		case "validateEmail", "email":
+			log.Error.Print("validateEmail error:", err.Error())
+			fieldErr.Code = CODE_EMAIL_FORMAT
+			fieldErr.Message = ERROR_EMAIL_FORMAT
+		default:
+			log.Error.Print("validation error:", err.Error())
+
+		}
+
+		fieldErrors = append(fieldErrors, fieldErr)
 	}
 
-	return nil
+	return fieldErrors
 }
 
 func (uvs *UserValidationService) validateAge(fl validator.FieldLevel) bool {
@@ -81,10 +164,37 @@ func (uvs *UserValidationService) validateEmail(fl validator.FieldLevel) bool {
 	return len(strings.TrimSpace(email)) != 0 && strings.Contains(email, "@")
 }
 
-func (uvs *UserValidationService) validateFirstNameLastName(firstName string, lastName string) bool {
+func (uvs *UserValidationService) validateFirstNameLastName(firstName string, lastName string) (bool, error) {
 	exist, err := uvs.Repository.ExistsByFirstNameAndLastName(firstName, lastName)
 	if err != nil {
-		panic(err)
+		log.Error.Print("validateFirstNameLastName error:", err)
+		return false, err
+	}
+	return !exist, nil
+}
+
+func nameUniqueError(field string) model.FieldError {
+	return model.FieldError{Field: field, Code: CODE_NAME_UNIQUE, Message: ERROR_NAME_UNIQUE}
+}
+
+func jsonToStructFields(t reflect.Type) map[string]string {
+	fields := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("validate"); !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		fields[name] = f.Name
+	}
+	return fields
+}
+
+func structToJsonField(structField string) string {
+	for name, field := range userFields {
+		if field == structField {
+			return name
+		}
 	}
-	return !exist
+	return structField
 }
@@ -2,8 +2,11 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
 	"strings"
 	"testing"
@@ -19,7 +22,8 @@ func TestAgeInvalid(t *testing.T) {
 	}
 
 	// When
-	validationErrors := userValidation.ValidateUser(&user)
+	validationErrors, err := userValidation.ValidateUser(&user)
+	assert.Nil(t, err)
 	fmt.Println("validationErrors", validationErrors)
 
 	// Then
@@ -39,7 +43,8 @@ func TestEmailInvalid(t *testing.T) {
 	}
 
 	// When
-	validationErrors := userValidation.ValidateUser(&user)
+	validationErrors, err := userValidation.ValidateUser(&user)
+	assert.Nil(t, err)
 	fmt.Println("validationErrors", validationErrors)
 
 	// Then
@@ -59,7 +64,8 @@ func TestFirstNameLastNameAlreadyExists(t *testing.T) {
 	}
 
 	// When
-	validationErrors := userValidation.ValidateUser(&user)
+	validationErrors, err := userValidation.ValidateUser(&user)
+	assert.Nil(t, err)
 	fmt.Println("validationErrors", validationErrors)
 
 	// Then
@@ -68,3 +74,84 @@ func TestFirstNameLastNameAlreadyExists(t *testing.T) {
 		t.Errorf("expected validation error, none received")
 	}
 }
+
+func TestValidateUserFields(t *testing.T) {
+	// Given
+	js := `{"first_name":"John","last_name":"Doe","email":"bad_email","age":9}`
+	userValidation := UserValidationService{&MockRepo{}}
+	var user model.User
+	if err := json.Unmarshal([]byte(js), &user); err != nil {
+		t.Errorf("failed to unmarshal lead to JSON: %v", err.Error())
+	}
+
+	// When
+	fieldErrors, err := userValidation.ValidateUserFields(&user)
+
+	// Then
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []model.FieldError{
+		{Field: "email", Code: CODE_EMAIL_FORMAT, Message: ERROR_EMAIL_FORMAT},
+		{Field: "age", Code: CODE_AGE_MINIMUM, Message: ERROR_AGE_MINIMUM},
+		{Field: "last_name", Code: CODE_NAME_UNIQUE, Message: ERROR_NAME_UNIQUE},
+	}, fieldErrors)
+}
+
+func TestValidateUserField(t *testing.T) {
+	userValidation := UserValidationService{&MockRepo{}}
+	user := model.User{FirstName: "John", LastName: "Doe", Email: "bad_email", Age: 9}
+
+	tests := []struct {
+		field string
+		codes []string
+	}{
+		{field: "first_name", codes: []string{CODE_NAME_UNIQUE}},
+		{field: "last_name", codes: []string{CODE_NAME_UNIQUE}},
+		{field: "email", codes: []string{CODE_EMAIL_FORMAT}},
+		{field: "age", codes: []string{CODE_AGE_MINIMUM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			// When
+			fieldErrors, err := userValidation.ValidateUserField(&user, tt.field)
+
+			// Then
+			assert.Nil(t, err)
+			codes := []string{}
+			for _, fieldErr := range fieldErrors {
+				assert.EqualValues(t, tt.field, fieldErr.Field)
+				codes = append(codes, fieldErr.Code)
+			}
+			assert.EqualValues(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestValidateUserField_Unknown(t *testing.T) {
+	// Given
+	userValidation := UserValidationService{&MockRepo{}}
+
+	// When
+	fieldErrors, err := userValidation.ValidateUserField(&model.User{}, "nickname")
+
+	// Then
+	assert.Nil(t, fieldErrors)
+	assert.True(t, errors.Is(err, apperror.ErrValidation))
+	assert.EqualValues(t, "unknown user field 'nickname'", err.Error())
+}
+
+func TestFirstNameLastName_Repository_Error(t *testing.T) {
+	// Given
+	mockUserRepository := new(mocksRepo.IUserRepository)
+	mockUserRepository.On("ExistsByFirstNameAndLastName", "John", "Doe").Return(
+		false,
+		apperror.Unavailable("database unavailable", nil))
+	userValidation := UserValidationService{mockUserRepository}
+	user := model.User{FirstName: "John", LastName: "Doe", Email: "john.doe@gmail.com", Age: 19}
+
+	// When
+	validationErrors, err := userValidation.ValidateUser(&user)
+
+	// Then
+	assert.Nil(t, validationErrors)
+	assert.True(t, errors.Is(err, apperror.ErrUnavailable))
+}
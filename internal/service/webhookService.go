@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/webhook"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// WEBHOOK_SECRET_PREFIX marks the secrets generated for webhooks
+	WEBHOOK_SECRET_PREFIX = "whsec_"
+	// MinWebhookSecretLength is the length of the shortest secret accepted from clients
+	MinWebhookSecretLength = 16
+	// webhookLookupTimeout bounds the resolution of the host of a webhook url
+	webhookLookupTimeout = 5 * time.Second
+
+	ERROR_WEBHOOK_URL_INVALID      = "Webhook url must be an absolute http or https url"
+	ERROR_WEBHOOK_URL_NOT_PUBLIC   = "Webhook url must resolve to public internet addresses"
+	ERROR_WEBHOOK_UNKNOWN_EVENT    = "Webhook event '%v' is unknown"
+	ERROR_WEBHOOK_SECRET_TOO_SHORT = "Webhook secret must be at least 16 characters"
+	RESPONSE_WEBHOOK_INVALID       = "Webhook did not pass validation"
+)
+
+// Codes identifying which rule a webhook FieldError comes from
+const (
+	CODE_WEBHOOK_URL_INVALID      = "webhook_url_invalid"
+	CODE_WEBHOOK_URL_NOT_PUBLIC   = "webhook_url_not_public"
+	CODE_WEBHOOK_UNKNOWN_EVENT    = "webhook_unknown_event"
+	CODE_WEBHOOK_SECRET_TOO_SHORT = "webhook_secret_too_short"
+)
+
+var webhookEvents = []string{model.EVENT_USER_CREATED, model.EVENT_USER_UPDATED, model.EVENT_USER_DELETED}
+
+type IWebhookService interface {
+	ListWebhooks() ([]model.Webhook, error)
+	CreateWebhook(request *model.WebhookRequest) (*model.WebhookCreated, error)
+	DeleteWebhook(id int64) error
+	ListDeliveries(webhookId int64) ([]model.WebhookDelivery, error)
+	Redeliver(webhookId int64, deliveryId int64) (*model.WebhookDelivery, error)
+}
+
+// WebhookService manages the webhooks notified of user events, and their deliveries
+type WebhookService struct {
+	Repository repository.IWebhookRepository
+	// AllowPrivateUrls accepts urls resolving to loopback, private or link-local addresses, for local development
+	AllowPrivateUrls bool
+	// LookupIP resolves the host of webhook urls, net.DefaultResolver is used when nil
+	LookupIP func(ctx context.Context, host string) ([]net.IP, error)
+}
+
+func (ws *WebhookService) ListWebhooks() ([]model.Webhook, error) {
+
+	webhooks, err := ws.Repository.DbListWebhooks()
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// CreateWebhook subscribes a URL to user events and returns the subscription with its secret,
+// which cannot be retrieved afterwards
+func (ws *WebhookService) CreateWebhook(request *model.WebhookRequest) (*model.WebhookCreated, error) {
+
+	if fieldErrors := ws.validateWebhookRequest(request); len(fieldErrors) > 0 {
+		log.Error.Println(RESPONSE_WEBHOOK_INVALID)
+		return nil, apperror.Validation(RESPONSE_WEBHOOK_INVALID, fieldErrors)
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		generated, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+		if err != nil {
+			return nil, apperror.Internal("unable to generate webhook secret", err)
+		}
+		secret = WEBHOOK_SECRET_PREFIX + generated
+	}
+
+	webhook := &model.Webhook{
+		Url:       strings.TrimSpace(request.Url),
+		Events:    request.Events,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+
+	created, err := ws.Repository.DbCreateWebhook(webhook)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	log.Info.Printf("Webhook created : %v", created.Id)
+
+	return &model.WebhookCreated{Webhook: *created, Secret: secret}, nil
+}
+
+func (ws *WebhookService) DeleteWebhook(id int64) error {
+
+	if err := ws.Repository.DbDeleteWebhook(id); err != nil {
+		log.Error.Println(err)
+		return err
+	}
+
+	log.Info.Printf("Webhook deleted : %v", id)
+
+	return nil
+}
+
+func (ws *WebhookService) ListDeliveries(webhookId int64) ([]model.WebhookDelivery, error) {
+
+	deliveries, err := ws.Repository.DbListDeliveries(webhookId)
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver sends a delivery again, whether it was delivered or is dead, with all its attempts
+func (ws *WebhookService) Redeliver(webhookId int64, deliveryId int64) (*model.WebhookDelivery, error) {
+
+	delivery, err := ws.Repository.DbRedeliver(webhookId, deliveryId, time.Now().UTC())
+	if err != nil {
+		log.Error.Println(err)
+		return nil, err
+	}
+
+	log.Info.Printf("Webhook %v delivery %v redelivered", webhookId, deliveryId)
+
+	return delivery, nil
+}
+
+func (ws *WebhookService) validateWebhookRequest(request *model.WebhookRequest) []model.FieldError {
+
+	fieldErrors := []model.FieldError{}
+	target, err := url.Parse(strings.TrimSpace(request.Url))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "url", Code: CODE_WEBHOOK_URL_INVALID, Message: ERROR_WEBHOOK_URL_INVALID})
+	} else if !ws.AllowPrivateUrls && !ws.resolvesToPublic(target.Hostname()) {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "url", Code: CODE_WEBHOOK_URL_NOT_PUBLIC, Message: ERROR_WEBHOOK_URL_NOT_PUBLIC})
+	}
+	for _, event := range request.Events {
+		if !contains(webhookEvents, event) {
+			fieldErrors = append(fieldErrors, model.FieldError{Field: "events", Code: CODE_WEBHOOK_UNKNOWN_EVENT, Message: fmt.Sprintf(ERROR_WEBHOOK_UNKNOWN_EVENT, event)})
+		}
+	}
+	if request.Secret != "" && len(request.Secret) < MinWebhookSecretLength {
+		fieldErrors = append(fieldErrors, model.FieldError{Field: "secret", Code: CODE_WEBHOOK_SECRET_TOO_SHORT, Message: ERROR_WEBHOOK_SECRET_TOO_SHORT})
+	}
+	return fieldErrors
+}
+
+// resolvesToPublic tells whether every address of a host is public. The deliveries check the address again when they
+// connect, as the host may resolve differently by then.
+func (ws *WebhookService) resolvesToPublic(host string) bool {
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		lookupIP := ws.LookupIP
+		if lookupIP == nil {
+			lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+				return net.DefaultResolver.LookupIP(ctx, "ip", host)
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), webhookLookupTimeout)
+		defer cancel()
+		var err error
+		if ips, err = lookupIP(ctx, host); err != nil {
+			log.Error.Printf("Unable to resolve webhook host %v : %v", host, err)
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if !webhook.IsPublicAddress(ip) {
+			return false
+		}
+	}
+	return len(ips) > 0
+}
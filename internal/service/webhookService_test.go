@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	mocksRepo "github.com/wexinc/ps-tag-onboarding-go/internal/mocks/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net"
+	"strings"
+	"testing"
+)
+
+// lookupIP resolves the hosts of the tests without DNS
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	addresses := map[string][]string{
+		"example.com":          {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		"localhost":            {"127.0.0.1", "::1"},
+		"internal.example.com": {"10.0.0.5"},
+		"mixed.example.com":    {"93.184.216.34", "192.168.1.1"},
+	}
+	if _, ok := addresses[host]; !ok {
+		return nil, errors.New("no such host")
+	}
+	ips := []net.IP{}
+	for _, address := range addresses[host] {
+		ips = append(ips, net.ParseIP(address))
+	}
+	return ips, nil
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      model.WebhookRequest
+		secret       string
+		allowPrivate bool
+	}{
+		{name: "Generated secret", request: model.WebhookRequest{Url: " https://example.com/hooks ", Events: []string{model.EVENT_USER_CREATED}}},
+		{name: "Given secret", request: model.WebhookRequest{Url: "https://example.com:9000/", Secret: "0123456789abcdef"}, secret: "0123456789abcdef"},
+		{name: "Public address", request: model.WebhookRequest{Url: "http://93.184.216.34/hooks"}},
+		{name: "Private allowed", request: model.WebhookRequest{Url: "http://localhost:9000/"}, allowPrivate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			mockWebhookRepository := new(mocksRepo.IWebhookRepository)
+			mockWebhookRepository.On("DbCreateWebhook", mock.Anything).Return(
+				func(webhook *model.Webhook) *model.Webhook {
+					webhook.Id = 1
+					return webhook
+				}, nil)
+			webhookService := WebhookService{Repository: mockWebhookRepository, AllowPrivateUrls: tt.allowPrivate, LookupIP: lookupIP}
+
+			// When
+			created, err := webhookService.CreateWebhook(&tt.request)
+
+			// Then
+			assert.Nil(t, err)
+			assert.EqualValues(t, 1, created.Id)
+			assert.EqualValues(t, strings.TrimSpace(tt.request.Url), created.Url)
+			assert.NotNil(t, created.Events)
+			if tt.secret != "" {
+				assert.EqualValues(t, tt.secret, created.Secret)
+			} else {
+				assert.True(t, strings.HasPrefix(created.Secret, WEBHOOK_SECRET_PREFIX))
+				assert.Greater(t, len(created.Secret), len(WEBHOOK_SECRET_PREFIX)+32)
+			}
+			stored := mockWebhookRepository.Calls[0].Arguments.Get(0).(*model.Webhook)
+			assert.EqualValues(t, created.Secret, stored.Secret)
+		})
+	}
+}
+
+func TestWebhookService_CreateWebhook_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		request model.WebhookRequest
+		codes   []string
+	}{
+		{name: "Missing url", request: model.WebhookRequest{}, codes: []string{CODE_WEBHOOK_URL_INVALID}},
+		{name: "Relative url", request: model.WebhookRequest{Url: "/hooks"}, codes: []string{CODE_WEBHOOK_URL_INVALID}},
+		{name: "Other scheme", request: model.WebhookRequest{Url: "ftp://example.com/hooks"}, codes: []string{CODE_WEBHOOK_URL_INVALID}},
+		{name: "Loopback host", request: model.WebhookRequest{Url: "http://localhost:9000/"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Loopback address", request: model.WebhookRequest{Url: "http://127.0.0.1/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Loopback IPv6 address", request: model.WebhookRequest{Url: "http://[::1]:8080/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Private address", request: model.WebhookRequest{Url: "https://10.0.0.5/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Private network", request: model.WebhookRequest{Url: "http://192.168.1.1/"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Metadata endpoint", request: model.WebhookRequest{Url: "http://169.254.169.254/latest/meta-data/"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Host resolving to private", request: model.WebhookRequest{Url: "https://internal.example.com/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Host resolving to public and private", request: model.WebhookRequest{Url: "https://mixed.example.com/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Unknown host", request: model.WebhookRequest{Url: "https://unknown.example.com/hooks"}, codes: []string{CODE_WEBHOOK_URL_NOT_PUBLIC}},
+		{name: "Unknown event", request: model.WebhookRequest{Url: "https://example.com", Events: []string{model.EVENT_USER_CREATED, "UserPurged"}}, codes: []string{CODE_WEBHOOK_UNKNOWN_EVENT}},
+		{name: "Short secret", request: model.WebhookRequest{Url: "https://example.com", Secret: "secret"}, codes: []string{CODE_WEBHOOK_SECRET_TOO_SHORT}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			mockWebhookRepository := new(mocksRepo.IWebhookRepository)
+			webhookService := WebhookService{Repository: mockWebhookRepository, LookupIP: lookupIP}
+
+			// When
+			created, err := webhookService.CreateWebhook(&tt.request)
+
+			// Then
+			assert.Nil(t, created)
+			assert.ErrorIs(t, err, apperror.ErrValidation)
+			codes := []string{}
+			for _, fieldErr := range err.(*apperror.Error).Fields {
+				codes = append(codes, fieldErr.Code)
+			}
+			assert.EqualValues(t, tt.codes, codes)
+			mockWebhookRepository.AssertNotCalled(t, "DbCreateWebhook", mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"encoding/json"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"sync"
+	"time"
+)
+
+// Default settings of the broker
+const (
+	DefaultBufferSize       = 1000
+	DefaultSubscriberBuffer = 64
+)
+
+// Broker fans the user events out to the subscribers of the stream, and keeps the latest ones so that a subscriber
+// reconnecting with the id of the last event it received gets the events it missed.
+// Publishing never blocks: a subscriber whose queue is full is dropped, and resumes from the buffer when it reconnects.
+type Broker struct {
+	// BufferSize is the number of events kept for resumption, DefaultBufferSize when zero
+	BufferSize int
+	// SubscriberBuffer is the number of events queued for each subscriber, DefaultSubscriberBuffer when zero
+	SubscriberBuffer int
+
+	mu          sync.Mutex
+	lastId      int64
+	buffer      []model.Event
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the events published after it was made
+type Subscription struct {
+	// Events is closed when the subscription is cancelled or the subscriber is dropped for falling behind
+	Events <-chan model.Event
+	// Missed are the buffered events published after the last event received by a resuming subscriber
+	Missed []model.Event
+	// Reset is set when a resuming subscriber missed events that are no longer buffered, Missed is then empty and
+	// LastId is the id of the last event published, from which the subscriber resumes
+	Reset  bool
+	LastId int64
+
+	events  chan model.Event
+	dropped bool
+}
+
+// Publish sends an event about a user to the current subscribers and buffers it, without waiting for the subscribers
+func (b *Broker) Publish(eventType string, userId int64, data interface{}) {
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Error.Printf("Unable to publish %v event of user %v: %v", eventType, userId, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	event := model.Event{Id: b.lastId, Type: eventType, UserId: userId, OccurredAt: time.Now().UTC(), Data: payload}
+	b.buffer = append(b.buffer, event)
+	if bufferSize := b.bufferSize(); len(b.buffer) > bufferSize {
+		b.buffer = append(b.buffer[:0], b.buffer[len(b.buffer)-bufferSize:]...)
+	}
+
+	for subscription := range b.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			log.Info.Printf("Event stream subscriber dropped after falling %v events behind", cap(subscription.events))
+			subscription.dropped = true
+			b.cancel(subscription)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on, with the buffered events published after
+// lastEventId when it is set
+func (b *Broker) Subscribe(lastEventId *int64) *Subscription {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subscription := &Subscription{LastId: b.lastId}
+	if lastEventId != nil {
+		// ids start again from 1 when the server restarts, so a later id than the last one also resets
+		oldest := b.lastId - int64(len(b.buffer)) + 1
+		subscription.Reset = *lastEventId < oldest-1 || *lastEventId > b.lastId
+		for _, event := range b.buffer {
+			if event.Id > *lastEventId && !subscription.Reset {
+				subscription.Missed = append(subscription.Missed, event)
+			}
+		}
+	}
+
+	subscriberBuffer := b.SubscriberBuffer
+	if subscriberBuffer <= 0 {
+		subscriberBuffer = DefaultSubscriberBuffer
+	}
+	events := make(chan model.Event, subscriberBuffer)
+	subscription.Events, subscription.events = events, events
+	if b.subscribers == nil {
+		b.subscribers = map[*Subscription]struct{}{}
+	}
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription
+}
+
+// Unsubscribe cancels a subscription, closing its Events
+func (b *Broker) Unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancel(subscription)
+}
+
+// Dropped tells whether a subscription was cancelled because its subscriber fell behind
+func (b *Broker) Dropped(subscription *Subscription) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return subscription.dropped
+}
+
+func (b *Broker) cancel(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; ok {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+func (b *Broker) bufferSize() int {
+	if b.BufferSize <= 0 {
+		return DefaultBufferSize
+	}
+	return b.BufferSize
+}
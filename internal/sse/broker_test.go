@@ -0,0 +1,145 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ids(events []model.Event) []int64 {
+	result := []int64{}
+	for _, event := range events {
+		result = append(result, event.Id)
+	}
+	return result
+}
+
+func TestBroker_Subscribe(t *testing.T) {
+	broker := &Broker{BufferSize: 3}
+	for i := int64(1); i <= 5; i++ {
+		broker.Publish(model.EVENT_USER_UPDATED, i, model.UserDeletedData{Id: i})
+	}
+	lastId := func(id int64) *int64 { return &id }
+
+	tests := []struct {
+		name        string
+		lastEventId *int64
+		missed      []int64
+		reset       bool
+	}{
+		{name: "New subscriber", missed: []int64{}},
+		{name: "Up to date", lastEventId: lastId(5), missed: []int64{}},
+		{name: "Resumed from the buffer", lastEventId: lastId(3), missed: []int64{4, 5}},
+		{name: "Resumed from before the buffer", lastEventId: lastId(2), missed: []int64{3, 4, 5}},
+		{name: "Missed events no longer buffered", lastEventId: lastId(1), missed: []int64{}, reset: true},
+		{name: "Id from before a restart", lastEventId: lastId(9), missed: []int64{}, reset: true},
+		{name: "Id not sent by the server", lastEventId: lastId(-1), missed: []int64{}, reset: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := broker.Subscribe(tt.lastEventId)
+			defer broker.Unsubscribe(subscription)
+
+			assert.Equal(t, tt.missed, ids(subscription.Missed))
+			assert.Equal(t, tt.reset, subscription.Reset)
+			assert.Equal(t, int64(5), subscription.LastId)
+		})
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	broker := &Broker{SubscriberBuffer: 2}
+	fast := broker.Subscribe(nil)
+	slow := broker.Subscribe(nil)
+
+	broker.Publish(model.EVENT_USER_CREATED, 1, model.User{Id: 1, FirstName: "John"})
+	event := <-fast.Events
+	assert.Equal(t, int64(1), event.Id)
+	assert.Equal(t, model.EVENT_USER_CREATED, event.Type)
+	assert.JSONEq(t, `{"id":1,"first_name":"John","last_name":"","email":"","age":0}`, string(event.Data))
+
+	// the slow subscriber does not read, and is dropped instead of blocking the publisher
+	broker.Publish(model.EVENT_USER_UPDATED, 1, model.User{Id: 1})
+	<-fast.Events
+	broker.Publish(model.EVENT_USER_DELETED, 1, model.UserDeletedData{Id: 1})
+	<-fast.Events
+
+	assert.Equal(t, []int64{1, 2}, ids(drain(slow.Events)))
+	assert.True(t, broker.Dropped(slow))
+	assert.False(t, broker.Dropped(fast))
+
+	broker.Unsubscribe(fast)
+	_, open := <-fast.Events
+	assert.False(t, open)
+	broker.Unsubscribe(fast)
+}
+
+func drain(events <-chan model.Event) []model.Event {
+	result := []model.Event{}
+	for event := range events {
+		result = append(result, event)
+	}
+	return result
+}
+
+func TestStream(t *testing.T) {
+	broker := &Broker{}
+	broker.Publish(model.EVENT_USER_CREATED, 1, model.UserDeletedData{Id: 1})
+	broker.Publish(model.EVENT_USER_CREATED, 2, model.UserDeletedData{Id: 2})
+	server := httptest.NewServer(&Stream{Broker: broker, Heartbeat: 20 * time.Millisecond})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	request.Header.Set(HEADER_LAST_EVENT, "1")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	assert.Equal(t, CONTENT_TYPE, response.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", response.Header.Get("Cache-Control"))
+
+	lines := bufio.NewScanner(response.Body)
+	next := func() string {
+		if !lines.Scan() {
+			t.Fatal("stream closed")
+		}
+		return lines.Text()
+	}
+	assert.Equal(t, "id: 2", next())
+	assert.Equal(t, "event: UserCreated", next())
+	assert.True(t, strings.HasPrefix(next(), `data: {"id":2,"type":"UserCreated","user_id":2,`))
+	assert.Equal(t, "", next())
+	assert.Equal(t, ": connected", next())
+	assert.Equal(t, "", next())
+
+	broker.Publish(model.EVENT_USER_DELETED, 2, model.UserDeletedData{Id: 2})
+	assert.Equal(t, "id: 3", next())
+	assert.Equal(t, "event: UserDeleted", next())
+	next()
+	next()
+
+	assert.Equal(t, ": heartbeat", next())
+}
+
+func TestStream_Reset(t *testing.T) {
+	broker := &Broker{}
+	broker.Publish(model.EVENT_USER_CREATED, 1, model.UserDeletedData{Id: 1})
+	request := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+	request.Header.Set(HEADER_LAST_EVENT, "40")
+	ctx, cancel := context.WithCancel(request.Context())
+	cancel()
+	recorder := httptest.NewRecorder()
+
+	(&Stream{Broker: broker}).ServeHTTP(recorder, request.WithContext(ctx))
+
+	assert.Equal(t, "id: 1\nevent: reset\ndata: {}\n\n: connected\n\n", recorder.Body.String())
+}
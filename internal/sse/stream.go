@@ -0,0 +1,119 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	CONTENT_TYPE        = "text/event-stream"
+	HEADER_LAST_EVENT   = "Last-Event-ID"
+	DefaultHeartbeat    = 15 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+
+	// EVENT_RESET tells a resuming client that events were missed, and that it should reload the users
+	EVENT_RESET = "reset"
+)
+
+// Stream serves the events of a broker as Server-Sent Events, resuming after the Last-Event-ID header when it is sent
+type Stream struct {
+	Broker *Broker
+	// Heartbeat is how often a comment is sent to keep idle connections open, DefaultHeartbeat when zero
+	Heartbeat time.Duration
+	// WriteTimeout is how long a write may block before the client is disconnected, DefaultWriteTimeout when zero
+	WriteTimeout time.Duration
+}
+
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	var lastEventId *int64
+	if header := r.Header.Get(HEADER_LAST_EVENT); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			// an id this server did not send resets the stream, EventSource clients stop reconnecting on errors
+			id = -1
+		}
+		lastEventId = &id
+	}
+
+	subscription := s.Broker.Subscribe(lastEventId)
+	defer s.Broker.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	writeTimeout := s.WriteTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = DefaultWriteTimeout
+	}
+	write := func(message string) bool {
+		// the deadline is not supported by every writer, a client that stops reading is then only dropped by the broker
+		controller.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprint(w, message); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if subscription.Reset && !write(formatReset(subscription.LastId)) {
+		return
+	}
+	for _, event := range subscription.Missed {
+		if !write(formatEvent(event)) {
+			return
+		}
+	}
+	if !write(": connected\n\n") {
+		return
+	}
+
+	heartbeat := s.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				if s.Broker.Dropped(subscription) {
+					log.Info.Printf("Event stream of %v closed after falling behind", r.RemoteAddr)
+				}
+				return
+			}
+			if !write(formatEvent(event)) {
+				return
+			}
+		case <-ticker.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// formatEvent writes an event in the Server-Sent Events format, its data being the same JSON as the outbox sinks
+func formatEvent(event model.Event) string {
+	data, err := json.Marshal(event)
+	if err != nil {
+		data = []byte("{}")
+	}
+	return fmt.Sprintf("id: %v\nevent: %v\ndata: %s\n\n", event.Id, event.Type, data)
+}
+
+// formatReset writes a reset event, with the id of the last event published so that the client resumes from it
+func formatReset(lastId int64) string {
+	return fmt.Sprintf("id: %v\nevent: %v\ndata: {}\n\n", lastId, EVENT_RESET)
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"net/http"
+)
+
+// ParseBody decodes the request body into the target model with the codec matching its Content-Type,
+// reading at most the registry's MaxBodySize bytes
+func ParseBody(w http.ResponseWriter, r *http.Request, target interface{}) MessageErr {
+
+	t := i18n.FromContext(r.Context())
+	registry := codec.RegistryFromContext(r.Context())
+	c, ok := registry.ForContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		return UnsupportedMediaTypeError(t.Status(http.StatusUnsupportedMediaType))
+	}
+
+	body := http.MaxBytesReader(w, r.Body, registry.MaxBodySize)
+	if err := c.Decode(body, target); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return RequestEntityTooLargeError(t.Status(http.StatusRequestEntityTooLarge))
+		}
+		return BadRequestError(err.Error())
+	}
+
+	return nil
+}
+
+// Respond makes the response with payload in the format negotiated from the Accept header
+func Respond(w http.ResponseWriter, r *http.Request, status int, payload interface{}) {
+
+	c, ok := codec.ResponseFromContext(r.Context())
+	if !ok {
+		c, ok = codec.RegistryFromContext(r.Context()).ForAccept(r.Header.Get("Accept"))
+	}
+	if !ok {
+		ResponseLocalizedError(w, r, http.StatusNotAcceptable)
+		return
+	}
+
+	var response bytes.Buffer
+	if err := c.Encode(&response, payload); err != nil {
+		if errors.Is(err, codec.ErrUnsupportedValue) {
+			ResponseLocalizedError(w, r, http.StatusNotAcceptable)
+			return
+		}
+		log.Error.Println(err)
+		ResponseLocalizedError(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.MediaTypes()[0]+"; charset=utf-8")
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(response.Bytes())
+}
@@ -14,8 +14,8 @@ type MessageErr interface {
 }
 
 type messageErr struct {
-	ErrMessage string `json:"message"`
 	ErrStatus  int    `json:"status"`
+	ErrMessage string `json:"message"`
 	ErrError   string `json:"error"`
 }
 
@@ -54,6 +54,30 @@ func UnprocessibleEntityError(message string) MessageErr {
 	}
 }
 
+func ForbiddenError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusForbidden,
+		ErrError:   "forbidden",
+	}
+}
+
+func ConflictError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusConflict,
+		ErrError:   "conflict",
+	}
+}
+
+func ServiceUnavailableError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusServiceUnavailable,
+		ErrError:   "service_unavailable",
+	}
+}
+
 func ApiErrFromBytes(body []byte) (MessageErr, error) {
 	var result messageErr
 	if err := json.Unmarshal(body, &result); err != nil {
@@ -69,3 +93,27 @@ func InternalServerError(message string) MessageErr {
 		ErrError:   "server_error",
 	}
 }
+
+func NotAcceptableError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusNotAcceptable,
+		ErrError:   "not_acceptable",
+	}
+}
+
+func RequestEntityTooLargeError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusRequestEntityTooLarge,
+		ErrError:   "request_too_large",
+	}
+}
+
+func UnsupportedMediaTypeError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnsupportedMediaType,
+		ErrError:   "unsupported_media_type",
+	}
+}
@@ -1,24 +1,20 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
 	"net/http"
 )
 
-// ParseJson gets json for request and fills the target model
+// ParseJson gets json for request and fills the target model.
+// The decoded body is not logged, as it may hold personal data.
 func ParseJson(r *http.Request, target interface{}) error {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 
-	if err := dec.Decode(&target); err != nil {
-		return err
-	}
-
-	log.Info.Printf("Target value decoded: %v", target)
-
-	return nil
+	return dec.Decode(target)
 }
 
 // ResponseJson makes the response with payload as json format
@@ -34,43 +30,41 @@ func ResponseJson(w http.ResponseWriter, status int, payload interface{}) {
 	w.Write(response)
 }
 
-// ResponseCustomError makes the error response with default message in json format
+// ResponseError makes the error response with default message in json format
 func ResponseError(w http.ResponseWriter, code int) {
-	var message string
-	switch code {
-	case http.StatusBadRequest:
-		message = "The request had invalid inputs or otherwise cannot be served."
-	case http.StatusUnauthorized:
-		message = "Authorization information is missing or invalid."
-	case http.StatusNotFound:
-		message = "Unable to find requested record."
-	case http.StatusRequestTimeout:
-		message = "Request took too long to process."
-	case http.StatusRequestedRangeNotSatisfiable:
-		message = "No resource available, unable to fulfill the request."
-	case http.StatusTooManyRequests:
-		message = "Request rate too high, requests from this this user are throttled."
-	case http.StatusInternalServerError:
-		message = "An error was encountered."
-	case http.StatusServiceUnavailable:
-		message = "The service is unavailable, please try again later."
-	case http.StatusGatewayTimeout:
-		message = "The service timed out waiting for an upstream response. Try again later."
-	}
+	ResponseCustomError(w, code, i18n.Default().Translator().Status(code))
+}
+
+// ResponseLocalizedError makes the error response with default message, in the language negotiated for the request
+func ResponseLocalizedError(w http.ResponseWriter, r *http.Request, code int) {
+	ResponseCustomError(w, code, i18n.FromContext(r.Context()).Status(code))
+}
 
-	ResponseCustomError(w, code, message)
+// customError is the body of the error responses with a message and the status code
+type customError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
 }
 
 // ResponseCustomError makes the error response with given message in json format
 func ResponseCustomError(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	w.Write([]byte(fmt.Sprintf(`{"code":%v,"message":"%v"}`, code, message)))
+	writeError(w, code, customError{Code: code, Message: message})
 }
 
 // ResponseCustomError makes the error response with given message in json format
 func ResponseMessageErr(w http.ResponseWriter, msgErr MessageErr) {
+	writeError(w, msgErr.Status(), messageErr{ErrStatus: msgErr.Status(), ErrMessage: msgErr.Message(), ErrError: msgErr.Error()})
+}
+
+// writeError encodes an error body, whose messages may quote the input of the client and so must be escaped
+func writeError(w http.ResponseWriter, status int, body interface{}) {
+	var response bytes.Buffer
+	enc := json.NewEncoder(&response)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(body); err != nil {
+		log.Error.Println(err)
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(msgErr.Status())
-	w.Write([]byte(fmt.Sprintf(`{"status":%v,"message":"%v","error":"%v"}`, msgErr.Status(), msgErr.Message(), msgErr.Error())))
+	w.WriteHeader(status)
+	w.Write(bytes.TrimSuffix(response.Bytes(), []byte("\n")))
 }
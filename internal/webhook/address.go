@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a delivery would connect to an address outside the public internet
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// reservedNetworks are the ranges, not covered by the net.IP predicates, that are not routed on the public internet
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+// IsPublicAddress tells whether webhooks may be sent to an address: loopback, private, link-local (which includes the
+// 169.254.169.254 metadata endpoint of cloud providers), multicast, unspecified and reserved addresses are refused
+func IsPublicAddress(ip net.IP) bool {
+
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns a client sending deliveries, which does not follow redirects nor use proxies. Unless allowPrivate
+// is set, it refuses to connect to addresses that are not public, checked once the host is resolved so that a DNS
+// record changed after the webhook was registered cannot point it to the internal network.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicOnly is a net.Dialer Control refusing connections to addresses that are not public
+func publicOnly(network string, address string, conn syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); !IsPublicAddress(ip) {
+		return fmt.Errorf("%w: %v", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
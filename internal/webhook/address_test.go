@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34", public: true},
+		{address: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{address: "127.0.0.1", public: false},
+		{address: "::1", public: false},
+		{address: "10.0.0.5", public: false},
+		{address: "172.16.3.4", public: false},
+		{address: "192.168.1.1", public: false},
+		{address: "169.254.169.254", public: false},
+		{address: "fe80::1", public: false},
+		{address: "fd00::1", public: false},
+		{address: "0.0.0.0", public: false},
+		{address: "100.64.0.1", public: false},
+		{address: "::ffff:127.0.0.1", public: false},
+		{address: "224.0.0.1", public: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			assert.Equal(t, tt.public, IsPublicAddress(net.ParseIP(tt.address)))
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowPrivate bool
+		err          error
+	}{
+		{name: "Private refused", allowPrivate: false, err: ErrPrivateAddress},
+		{name: "Private allowed", allowPrivate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer server.Close()
+			client := NewClient(time.Second, tt.allowPrivate)
+
+			// When
+			response, err := client.Post(server.URL, "application/json", nil)
+
+			// Then
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			response.Body.Close()
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/log"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default settings of the deliverer
+const (
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultTimeout     = 10 * time.Second
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+)
+
+const (
+	USER_AGENT = "ps-tag-onboarding-go-webhooks"
+
+	maxErrorLength = 255
+	// maxResponseRead is how much of a response is read, so that the connection can be reused
+	maxResponseRead = 4096
+)
+
+// Deliverer sends the pending deliveries to their webhooks, signed with the webhook secret.
+// A failed delivery is retried with an exponential backoff and jitter, until it fails MaxAttempts times and is dead.
+type Deliverer struct {
+	Repository repository.IWebhookRepository
+	// Client sends the deliveries, NewClient with DefaultTimeout, refusing addresses that are not public, is used when nil
+	Client *http.Client
+	// MaxAttempts is the number of failed attempts after which a delivery is dead, DefaultMaxAttempts when zero
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled at each retry up to MaxBackoff.
+	// DefaultBackoff and DefaultMaxBackoff are used when zero.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Interval is how often due deliveries are looked for, DefaultInterval when zero
+	Interval time.Duration
+	// BatchSize is the number of deliveries read at once, DefaultBatchSize when zero
+	BatchSize int
+	// Now returns the current time, time.Now is used when nil
+	Now func() time.Time
+	// Jitter returns a random duration in [0, max], math/rand is used when nil
+	Jitter func(max time.Duration) time.Duration
+}
+
+var defaultClient = NewClient(DefaultTimeout, false)
+
+// Run delivers the due deliveries every Interval until ctx is done
+func (d *Deliverer) Run(ctx context.Context) {
+
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Deliver(ctx); err != nil {
+			log.Error.Printf("Unable to deliver webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver attempts the deliveries that are due and returns how many succeeded
+func (d *Deliverer) Deliver(ctx context.Context) (int, error) {
+
+	batchSize := d.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	deliveries, err := d.Repository.DbDueDeliveries(d.now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	webhooks := map[int64]*model.Webhook{}
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookId]
+		if !ok {
+			webhook, err = d.Repository.DbGetWebhook(delivery.WebhookId)
+			if errors.Is(err, apperror.ErrNotFound) {
+				// deleted with its deliveries since they were read
+				continue
+			}
+			if err != nil {
+				return delivered, err
+			}
+			webhooks[delivery.WebhookId] = webhook
+		}
+
+		statusCode, sendErr := d.send(ctx, webhook, delivery)
+		d.record(delivery, statusCode, sendErr)
+		if err := d.Repository.DbUpdateDelivery(delivery); err != nil {
+			return delivered, err
+		}
+		if delivery.Status == model.DELIVERY_DELIVERED {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// send posts the payload of a delivery to its webhook, and returns the response status code
+func (d *Deliverer) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+
+	payload := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", USER_AGENT)
+	request.Header.Set(HEADER_ID, strconv.FormatInt(delivery.EventId, 10))
+	now := d.now()
+	request.Header.Set(HEADER_TIMESTAMP, strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set(HEADER_SIGNATURE, Sign(webhook.Secret, now, payload))
+
+	client := d.Client
+	if client == nil {
+		client = defaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseRead))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %v", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// record updates a delivery with the outcome of an attempt, and schedules the next one when it failed
+func (d *Deliverer) record(delivery *model.WebhookDelivery, statusCode int, err error) {
+
+	now := d.now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status, delivery.NextAttemptAt, delivery.DeliveredAt, delivery.LastError = model.DELIVERY_DELIVERED, nil, &now, ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+
+	maxAttempts := d.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if delivery.Attempts >= maxAttempts {
+		log.Error.Printf("Webhook %v delivery %v is dead after %v attempts: %v", delivery.WebhookId, delivery.Id, delivery.Attempts, err)
+		delivery.Status, delivery.NextAttemptAt = model.DELIVERY_DEAD, nil
+		return
+	}
+
+	next := now.Add(d.RetryDelay(delivery.Attempts))
+	log.Info.Printf("Webhook %v delivery %v failed, attempt %v retried at %v: %v", delivery.WebhookId, delivery.Id, delivery.Attempts, next, err)
+	delivery.NextAttemptAt = &next
+}
+
+// RetryDelay returns the delay after the given number of failed attempts: half of the backoff doubled at each attempt,
+// plus a random part of the other half, so that the retries of deliveries failing together are spread
+func (d *Deliverer) RetryDelay(attempts int) time.Duration {
+
+	delay, maxDelay := d.Backoff, d.MaxBackoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxBackoff
+	}
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	jitter := d.Jitter
+	if jitter == nil {
+		jitter = func(max time.Duration) time.Duration { return time.Duration(rand.Int63n(int64(max) + 1)) }
+	}
+	return delay/2 + jitter(delay-delay/2)
+}
+
+func (d *Deliverer) now() time.Time {
+	if d.Now != nil {
+		return d.Now().UTC()
+	}
+	return time.Now().UTC()
+}
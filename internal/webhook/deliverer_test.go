@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRepositoryMock struct {
+	webhooks   []model.Webhook
+	deliveries []model.WebhookDelivery
+}
+
+func (wrm *webhookRepositoryMock) DbListWebhooks() ([]model.Webhook, error) {
+	return append([]model.Webhook{}, wrm.webhooks...), nil
+}
+
+func (wrm *webhookRepositoryMock) DbCreateWebhook(webhook *model.Webhook) (*model.Webhook, error) {
+	webhook.Id = int64(len(wrm.webhooks) + 1)
+	wrm.webhooks = append(wrm.webhooks, *webhook)
+	return webhook, nil
+}
+
+func (wrm *webhookRepositoryMock) DbGetWebhook(id int64) (*model.Webhook, error) {
+	for _, webhook := range wrm.webhooks {
+		if webhook.Id == id {
+			return &webhook, nil
+		}
+	}
+	return nil, apperror.NotFound("webhook not found", nil)
+}
+
+func (wrm *webhookRepositoryMock) DbDeleteWebhook(id int64) error {
+	return nil
+}
+
+func (wrm *webhookRepositoryMock) DbCreateDeliveries(deliveries []model.WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		delivery.Id = int64(len(wrm.deliveries) + 1)
+		wrm.deliveries = append(wrm.deliveries, delivery)
+	}
+	return nil
+}
+
+func (wrm *webhookRepositoryMock) DbListDeliveries(webhookId int64) ([]model.WebhookDelivery, error) {
+	return append([]model.WebhookDelivery{}, wrm.deliveries...), nil
+}
+
+func (wrm *webhookRepositoryMock) DbDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	due := []model.WebhookDelivery{}
+	for _, delivery := range wrm.deliveries {
+		if delivery.Status == model.DELIVERY_PENDING && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (wrm *webhookRepositoryMock) DbUpdateDelivery(delivery *model.WebhookDelivery) error {
+	for i := range wrm.deliveries {
+		if wrm.deliveries[i].Id == delivery.Id {
+			wrm.deliveries[i] = *delivery
+		}
+	}
+	return nil
+}
+
+func (wrm *webhookRepositoryMock) DbRedeliver(webhookId int64, deliveryId int64, now time.Time) (*model.WebhookDelivery, error) {
+	return nil, nil
+}
+
+// receiver records the deliveries it gets, answering with the given statuses in turn
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestSinkAndDeliverer(t *testing.T) {
+	receiver := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	repository := &webhookRepositoryMock{webhooks: []model.Webhook{
+		{Id: 1, Url: server.URL, Secret: "whsec_one", Events: []string{}},
+		{Id: 2, Url: server.URL, Secret: "whsec_two", Events: []string{model.EVENT_USER_DELETED}},
+	}}
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	deliverer := &Deliverer{
+		Repository:  repository,
+		Client:      server.Client(),
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		Now:         func() time.Time { return now },
+		Jitter:      func(max time.Duration) time.Duration { return max },
+	}
+
+	// the event is queued for the webhooks subscribed to its type
+	sink := &Sink{Repository: repository}
+	event := model.Event{Id: 42, Type: model.EVENT_USER_CREATED, UserId: 7, Data: []byte(`{"id":7}`)}
+	assert.Nil(t, sink.Send(context.Background(), event))
+	assert.Len(t, repository.deliveries, 1)
+	repository.deliveries[0].NextAttemptAt = &now
+
+	// failed attempts are retried with a doubling backoff
+	delivered, err := deliverer.Deliver(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, delivered)
+	delivery := repository.deliveries[0]
+	assert.Equal(t, model.DELIVERY_PENDING, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.Equal(t, now.Add(time.Minute), *delivery.NextAttemptAt)
+
+	delivered, _ = deliverer.Deliver(context.Background())
+	assert.Equal(t, 0, delivered, "the retry is not due yet")
+
+	now = now.Add(time.Minute)
+	deliverer.Deliver(context.Background())
+	assert.Equal(t, 2, repository.deliveries[0].Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), *repository.deliveries[0].NextAttemptAt)
+
+	now = now.Add(2 * time.Minute)
+	delivered, err = deliverer.Deliver(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, delivered)
+	delivery = repository.deliveries[0]
+	assert.Equal(t, model.DELIVERY_DELIVERED, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, now, *delivery.DeliveredAt)
+	assert.Empty(t, delivery.LastError)
+
+	// every attempt is signed with the secret of the webhook
+	assert.Len(t, receiver.requests, 3)
+	for i, request := range receiver.requests {
+		assert.Equal(t, "42", request.Header.Get(HEADER_ID))
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		assert.Nil(t, Verify("whsec_one", request.Header.Get(HEADER_TIMESTAMP), request.Header.Get(HEADER_SIGNATURE), receiver.bodies[i], now, 5*time.Minute))
+	}
+	assert.JSONEq(t, `{"id":42,"type":"UserCreated","user_id":7,"occurred_at":"0001-01-01T00:00:00Z","data":{"id":7}}`, string(receiver.bodies[0]))
+}
+
+func TestDeliverer_Dead(t *testing.T) {
+	receiver := &receiver{statuses: []int{http.StatusGone, http.StatusGone}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	now := time.Now().UTC()
+	repository := &webhookRepositoryMock{
+		webhooks:   []model.Webhook{{Id: 1, Url: server.URL, Secret: "whsec_one"}},
+		deliveries: []model.WebhookDelivery{{Id: 1, WebhookId: 1, EventId: 5, Status: model.DELIVERY_PENDING, NextAttemptAt: &now}},
+	}
+	deliverer := &Deliverer{Repository: repository, Client: server.Client(), MaxAttempts: 2, Jitter: func(max time.Duration) time.Duration { return 0 }}
+
+	deliverer.Deliver(context.Background())
+	due := time.Now().Add(-time.Second)
+	repository.deliveries[0].NextAttemptAt = &due
+	deliverer.Deliver(context.Background())
+
+	delivery := repository.deliveries[0]
+	assert.Equal(t, model.DELIVERY_DEAD, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Nil(t, delivery.NextAttemptAt)
+	assert.Equal(t, "unexpected status 410", delivery.LastError)
+
+	delivered, _ := deliverer.Deliver(context.Background())
+	assert.Equal(t, 0, delivered)
+	assert.Len(t, receiver.requests, 2, "dead deliveries are not retried")
+}
+
+func TestDeliverer_RetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		jitter   func(max time.Duration) time.Duration
+		expected time.Duration
+	}{
+		{attempts: 1, jitter: func(max time.Duration) time.Duration { return 0 }, expected: 5 * time.Second},
+		{attempts: 1, jitter: func(max time.Duration) time.Duration { return max }, expected: 10 * time.Second},
+		{attempts: 3, jitter: func(max time.Duration) time.Duration { return max }, expected: 40 * time.Second},
+		{attempts: 4, jitter: func(max time.Duration) time.Duration { return 0 }, expected: 40 * time.Second},
+		{attempts: 8, jitter: func(max time.Duration) time.Duration { return max }, expected: 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			deliverer := &Deliverer{Backoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Jitter: tt.jitter}
+			assert.Equal(t, tt.expected, deliverer.RetryDelay(tt.attempts))
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"id":1}`)
+	signature := Sign("whsec_secret", now, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		payload   []byte
+		err       error
+	}{
+		{name: "Valid", secret: "whsec_secret", timestamp: "1700000000", signature: signature, payload: payload},
+		{name: "Wrong secret", secret: "whsec_other", timestamp: "1700000000", signature: signature, payload: payload, err: ErrInvalidSignature},
+		{name: "Changed payload", secret: "whsec_secret", timestamp: "1700000000", signature: signature, payload: []byte(`{"id":2}`), err: ErrInvalidSignature},
+		{name: "Changed timestamp", secret: "whsec_secret", timestamp: "1700000001", signature: signature, payload: payload, err: ErrInvalidSignature},
+		{name: "Malformed timestamp", secret: "whsec_secret", timestamp: "yesterday", signature: signature, payload: payload, err: ErrInvalidSignature},
+		{name: "Stale timestamp", secret: "whsec_secret", timestamp: "1699999000", signature: signature, payload: payload, err: ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, Verify(tt.secret, tt.timestamp, tt.signature, tt.payload, now, 5*time.Minute), tt.err)
+		})
+	}
+}
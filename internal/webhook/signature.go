@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Headers of the deliveries
+const (
+	HEADER_ID        = "Webhook-Id"
+	HEADER_TIMESTAMP = "Webhook-Timestamp"
+	HEADER_SIGNATURE = "Webhook-Signature"
+)
+
+// SIGNATURE_VERSION prefixes the signatures, so that the scheme can change without breaking receivers
+const SIGNATURE_VERSION = "v1="
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside the tolerance")
+)
+
+// Sign returns the Webhook-Signature of a payload sent at the given time:
+// the hex HMAC-SHA256 of "<unix timestamp>.<payload>" keyed by the webhook secret
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return SIGNATURE_VERSION + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery, as receivers should.
+// Deliveries older or newer than tolerance are refused, so that captured ones cannot be replayed later.
+func Verify(secret string, timestampHeader string, signatureHeader string, payload []byte, now time.Time, tolerance time.Duration) error {
+
+	seconds, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	timestamp := time.Unix(seconds, 0)
+	if now.Sub(timestamp) > tolerance || timestamp.Sub(now) > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	if !hmac.Equal([]byte(signatureHeader), []byte(Sign(secret, timestamp, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"time"
+)
+
+// Sink receives the events of the outbox and queues a delivery for every webhook subscribed to their type
+type Sink struct {
+	Repository repository.IWebhookRepository
+}
+
+func (s *Sink) Send(ctx context.Context, event model.Event) error {
+
+	webhooks, err := s.Repository.DbListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	deliveries := []model.WebhookDelivery{}
+	for _, webhook := range webhooks {
+		if !subscribed(webhook, event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookId:     webhook.Id,
+			EventId:       event.Id,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        model.DELIVERY_PENDING,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+	}
+
+	return s.Repository.DbCreateDeliveries(deliveries)
+}
+
+func subscribed(webhook model.Webhook, eventType string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, subscribedType := range webhook.Events {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+	return false
+}
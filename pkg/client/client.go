@@ -0,0 +1,245 @@
+// Package client is the Go client of the user API.
+//
+// A Client is configured with the url the API is served at and the credentials of the caller:
+//
+//	users := &client.Client{BaseURL: "http://localhost:8089", Token: token}
+//	user, err := users.GetUser(ctx, 3)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+//
+// Idempotent calls are retried after a network error or a temporary failure of the API, with an exponential
+// backoff. Errors answered by the API are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default settings of the client
+const (
+	DefaultMaxRetries = 3
+	DefaultBackoff    = 200 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+const (
+	HEADER_IDEMPOTENCY_KEY = "Idempotency-Key"
+	HEADER_LINK            = "Link"
+	API_KEY_SCHEME         = "ApiKey"
+	CONTENT_TYPE_JSON      = "application/json"
+)
+
+// Client calls the user API. Its fields are read on every call, and must not change while calls are running.
+type Client struct {
+	// BaseURL is the url the API is served at, e.g. "https://users.example.com"
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Token is sent as a Bearer token, and APIKey with the ApiKey scheme, when set
+	Token  string
+	APIKey string
+	// Language is sent as Accept-Language, so that the error messages are in this language
+	Language string
+	// MaxRetries is the number of times an idempotent call is retried, DefaultMaxRetries when zero and none when negative
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled at each retry up to MaxBackoff, DefaultBackoff when zero.
+	// The Retry-After header of the API is waited for instead when it is longer.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// request describes a call to the API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is encoded as JSON, unless it is an io.Reader sent as it is
+	body interface{}
+	// idempotent calls are retried
+	idempotent bool
+}
+
+// do sends a request, retrying it when idempotent, and decodes the JSON response into target when not nil.
+// It returns the response, with its body read.
+func (c *Client) do(ctx context.Context, req request, target interface{}) (*http.Response, error) {
+
+	response, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if target != nil {
+		if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+			return nil, fmt.Errorf("decoding the response of %v %v: %w", req.method, req.path, err)
+		}
+	}
+	return response, nil
+}
+
+// send sends a request, retrying it when idempotent, and returns the successful response with its body unread.
+// An error status is returned as *Error.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+
+	var body []byte
+	var reader io.Reader
+	switch b := req.body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("encoding the request of %v %v: %w", req.method, req.path, err)
+		}
+		body = encoded
+	}
+
+	maxRetries := c.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	if !req.idempotent || reader != nil {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		httpReq, err := c.newRequest(ctx, req, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := c.httpClient().Do(httpReq)
+		var retryAfter time.Duration
+		if err == nil {
+			if response.StatusCode < http.StatusBadRequest {
+				return response, nil
+			}
+			retryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+			err = errorFromResponse(response)
+		}
+
+		if attempt >= maxRetries || !retryable(ctx, err) {
+			return nil, err
+		}
+		if err := sleep(ctx, c.retryDelay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, req request, body io.Reader) (*http.Request, error) {
+
+	target := strings.TrimSuffix(c.BaseURL, "/") + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", CONTENT_TYPE_JSON)
+	}
+	if req.body != nil && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	}
+	if c.Language != "" {
+		httpReq.Header.Set("Accept-Language", c.Language)
+	}
+	switch {
+	case c.Token != "":
+		httpReq.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.APIKey != "":
+		httpReq.Header.Set("Authorization", API_KEY_SCHEME+" "+c.APIKey)
+	}
+	return httpReq, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// retryDelay returns the delay before the retry following the given attempt, with a jitter of up to a fifth of it
+func (c *Client) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+
+	delay := c.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	maxDelay := c.MaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxBackoff
+	}
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	delay += time.Duration(rand.Int63n(int64(delay)/5 + 1))
+
+	if retryAfter > delay {
+		return retryAfter
+	}
+	return delay
+}
+
+// retryable tells whether a failed call may succeed when sent again
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	apiErr, ok := err.(*Error)
+	if !ok {
+		// the request did not get a response
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds, the only form the API sends
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
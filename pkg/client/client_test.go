@@ -0,0 +1,328 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/database"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/idempotency"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/router"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/pkg/client"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newServer serves the user routes, wrapped by wrap when not nil
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userValidation := service.UserValidationService{Repository: &userRepository}
+	userNormalization := service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true}
+	userController := controller.UserController{
+		UserService: &service.UserService{
+			Repository:           &userRepository,
+			ValidationService:    &userValidation,
+			NormalizationService: &userNormalization,
+		},
+		ImportService: &service.UserImportService{
+			Repository:           &userRepository,
+			ValidationService:    &userValidation,
+			NormalizationService: &userNormalization,
+		},
+		ExportService: &service.UserExportService{Repository: &userRepository, ChunkSize: 2},
+	}
+
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	userRoutes := router.UserRoutes{Controller: &userController, Idempotency: &idempotency.Guard{Store: &idempotency.MemoryStore{}}}
+	userRoutes.UserRoutes(r)
+
+	var handler http.Handler = r
+	if wrap != nil {
+		handler = wrap(r)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// failing answers the first failures requests of each method with status, and counts the requests
+type failing struct {
+	mutex    sync.Mutex
+	status   int
+	failures int
+	requests map[string]int
+}
+
+func (f *failing) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mutex.Lock()
+		f.requests[r.Method]++
+		fail := f.requests[r.Method] <= f.failures
+		f.mutex.Unlock()
+
+		if !fail {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// the request is served but its response is lost, as when a proxy times out
+		next.ServeHTTP(httptest.NewRecorder(), r)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(f.status)
+	})
+}
+
+func TestClient_Users(t *testing.T) {
+	ctx := context.Background()
+	users := &client.Client{BaseURL: newServer(t, nil).URL}
+
+	// When
+	created, err := users.CreateUser(ctx, &client.User{FirstName: "grace", LastName: "hopper", Email: "Grace@Client.Example.com", Age: 85})
+
+	// Then
+	assert.Nil(t, err)
+	assert.Equal(t, client.User{Id: created.Id, FirstName: "Grace", LastName: "Hopper", Email: "grace@client.example.com", Age: 85}, *created)
+
+	user, err := users.GetUser(ctx, created.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, created, user)
+
+	user.Age = 86
+	updated, err := users.UpdateUser(ctx, user)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(86), updated.Age)
+
+	result, err := users.ValidateUser(ctx, &client.User{FirstName: "ada", LastName: "lovelace", Email: "ada.example.com", Age: 36})
+	assert.Nil(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, []client.FieldError{{Field: "email", Code: "email_format", Message: "User email must be properly formatted"}}, result.Errors)
+	result, err = users.ValidateUserField(ctx, &client.User{FirstName: "ada", LastName: "lovelace", Email: "ada.example.com", Age: 36}, "age")
+	assert.Nil(t, err)
+	assert.True(t, result.Valid)
+
+	listed, err := users.ListUsers(ctx, client.UserFilter{Email: "client.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, []client.User{*updated}, listed)
+
+	assert.Nil(t, users.DeleteUser(ctx, created.Id))
+	_, err = users.GetUser(ctx, created.Id)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	var apiErr *client.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, &client.Error{StatusCode: http.StatusNotFound, Code: "not_found", Message: apiErr.Message}, apiErr)
+	assert.Contains(t, apiErr.Message, "user not found")
+}
+
+func TestClient_Errors(t *testing.T) {
+	ctx := context.Background()
+	users := &client.Client{BaseURL: newServer(t, nil).URL, Language: "fr"}
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantKind error
+		wantErr  *client.Error
+	}{
+		{
+			name: "Rejected user",
+			call: func() error {
+				_, err := users.CreateUser(ctx, &client.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada.example.com", Age: 36})
+				return err
+			},
+			wantKind: client.ErrBadRequest,
+			wantErr:  &client.Error{StatusCode: http.StatusBadRequest, Code: "bad_request", Message: "L'adresse e-mail de l'utilisateur doit être correctement formatée"},
+		},
+		{
+			name: "Unknown user",
+			call: func() error {
+				_, err := users.GetUser(ctx, -1)
+				return err
+			},
+			wantKind: client.ErrNotFound,
+		},
+		{
+			name: "Too large",
+			call: func() error {
+				_, err := users.ImportUsers(ctx, client.FORMAT_CSV, strings.NewReader(strings.Repeat("x", 11<<20)), client.ImportOptions{})
+				return err
+			},
+			wantErr: &client.Error{StatusCode: http.StatusRequestEntityTooLarge, Code: "request_too_large", Message: "Le corps de la requête est trop volumineux."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := tt.call()
+
+			// Then
+			if tt.wantKind != nil {
+				assert.ErrorIs(t, err, tt.wantKind)
+			}
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestClient_Users_Pages(t *testing.T) {
+	// Given
+	requests := 0
+	server := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			next.ServeHTTP(w, r)
+		})
+	})
+	users := &client.Client{BaseURL: server.URL}
+
+	// When
+	ids := []int64{}
+	iterator := users.Users(context.Background(), client.UserFilter{MaxAge: 40}, 2)
+	for iterator.Next() {
+		ids = append(ids, iterator.User().Id)
+	}
+
+	// Then
+	assert.Nil(t, iterator.Err())
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+	assert.Equal(t, 3, requests)
+}
+
+func TestClient_Export_Import(t *testing.T) {
+	ctx := context.Background()
+	users := &client.Client{BaseURL: newServer(t, nil).URL}
+
+	var file bytes.Buffer
+	assert.Nil(t, users.ExportUsers(ctx, client.UserFilter{Email: "lobortis"}, client.FORMAT_CSV, &file))
+	assert.Equal(t, "id,first_name,last_name,email,age\n3,Branden,Spears,non.lobortis@hotmail.net,34\n5,Ira,Francis,in.lobortis.tellus@protonmail.ca,34\n", file.String())
+
+	report, err := users.ImportUsers(ctx, client.FORMAT_CSV, strings.NewReader("Given Name,last_name,email,age,Team\nEdsger,Dijkstra,edsger.example.com,72,Research\n"),
+		client.ImportOptions{DryRun: true, Columns: map[string]string{"Given Name": "first_name", "Team": "-"}})
+	assert.Nil(t, err)
+	assert.Equal(t, &client.ImportReport{DryRun: true, Rows: 1, Rejected: 1, Errors: []client.ImportError{
+		{Row: 2, Field: "email", Code: "email_format", Message: "User email must be properly formatted"},
+	}}, report)
+
+	_, err = users.ImportUsers(ctx, client.FORMAT_JSON, strings.NewReader("[]"), client.ImportOptions{})
+	assert.EqualError(t, err, `unknown import format "json", expected csv or ndjson`)
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		failures     int
+		maxRetries   int
+		wantErr      error
+		wantRequests map[string]int
+	}{
+		{
+			name:         "Recovered",
+			status:       http.StatusServiceUnavailable,
+			failures:     2,
+			wantRequests: map[string]int{http.MethodGet: 5, http.MethodPost: 3, http.MethodDelete: 3},
+		},
+		{
+			name:         "Out of retries",
+			status:       http.StatusBadGateway,
+			failures:     3,
+			maxRetries:   2,
+			wantErr:      client.ErrUnavailable,
+			wantRequests: map[string]int{http.MethodGet: 3},
+		},
+		{
+			name:         "Not retried",
+			status:       http.StatusServiceUnavailable,
+			failures:     1,
+			maxRetries:   -1,
+			wantErr:      client.ErrUnavailable,
+			wantRequests: map[string]int{http.MethodGet: 1},
+		},
+		{
+			name:         "Not temporary",
+			status:       http.StatusInternalServerError,
+			failures:     1,
+			wantErr:      client.ErrUnavailable,
+			wantRequests: map[string]int{http.MethodGet: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			ctx := context.Background()
+			failures := &failing{status: tt.status, failures: tt.failures, requests: map[string]int{}}
+			users := &client.Client{BaseURL: newServer(t, failures.wrap).URL, MaxRetries: tt.maxRetries, Backoff: time.Millisecond}
+
+			// When
+			_, err := users.GetUser(ctx, 1)
+
+			// Then
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.wantRequests, failures.requests)
+				return
+			}
+			assert.Nil(t, err)
+
+			// the creation served but lost is replayed instead of creating the user again
+			created, err := users.CreateUser(ctx, &client.User{FirstName: "Radia", LastName: "Perlman", Email: "radia@client.example.com", Age: 72})
+			assert.Nil(t, err)
+			listed, err := users.ListUsers(ctx, client.UserFilter{Email: "radia@client.example.com"})
+			assert.Nil(t, err)
+			assert.Equal(t, []client.User{*created}, listed)
+
+			// the deletion served but lost is not found by its retries
+			assert.ErrorIs(t, users.DeleteUser(ctx, created.Id), client.ErrNotFound)
+			_, err = users.GetUser(ctx, created.Id)
+			assert.ErrorIs(t, err, client.ErrNotFound)
+			assert.Equal(t, tt.wantRequests, failures.requests)
+		})
+	}
+}
+
+func TestClient_Retries_Canceled(t *testing.T) {
+	// Given
+	failures := &failing{status: http.StatusTooManyRequests, failures: 10, requests: map[string]int{}}
+	users := &client.Client{BaseURL: newServer(t, failures.wrap).URL, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	// When
+	_, err := users.ListUsers(ctx, client.UserFilter{})
+
+	// Then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, map[string]int{http.MethodGet: 1}, failures.requests)
+}
+
+func TestClient_Dependencies(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is needed to list the dependencies")
+	}
+
+	// When
+	out, err := exec.Command(goTool, "list", "-deps", ".").Output()
+
+	// Then the client does not come with the server packages, internal/log opening its log file as it is imported
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dependency := range strings.Fields(string(out)) {
+		assert.False(t, strings.HasPrefix(dependency, "github.com/wexinc/ps-tag-onboarding-go/internal/"),
+			"the client depends on %v", dependency)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize is the most read of an error response
+const maxErrorBodySize = 64 << 10
+
+// Kinds of errors answered by the API, matched with errors.Is
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnavailable  = errors.New("unavailable")
+)
+
+// Error is an error status answered by the API, with the MessageErr of its body
+type Error struct {
+	StatusCode int
+	// Code is the error of the MessageErr, e.g. "not_found", or the status text when the body has none
+	Code string
+	// Message is the message of the MessageErr, in the language of the client
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%v %v", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("%v %v: %v", e.StatusCode, e.Code, e.Message)
+}
+
+// Is matches the error with the kind of its status
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// messageErr is the body of an error response, either a MessageErr or a localized error with a numeric code.
+// The client does not import the server packages, which would come with their dependencies.
+type messageErr struct {
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// errorFromResponse reads the MessageErr of an error response, and closes its body
+func errorFromResponse(response *http.Response) *Error {
+
+	defer response.Body.Close()
+	apiErr := &Error{StatusCode: response.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+	var msgErr messageErr
+	if err := json.Unmarshal(body, &msgErr); err == nil {
+		apiErr.Code, apiErr.Message = msgErr.Error, msgErr.Message
+	} else if len(body) > 0 && !strings.HasPrefix(response.Header.Get("Content-Type"), CONTENT_TYPE_JSON) {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(response.StatusCode)), " ", "_")
+	}
+	return apiErr
+}
@@ -0,0 +1,60 @@
+package client
+
+// User is a user of the API
+type User struct {
+	Id        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Age       int64  `json:"age"`
+}
+
+// UserFilter selects the users whose names and email contain the given texts, ignoring case, and whose age is within
+// the given bounds. Empty texts and zero bounds match every user.
+type UserFilter struct {
+	FirstName string
+	LastName  string
+	Email     string
+	MinAge    int64
+	MaxAge    int64
+}
+
+// FieldError is a failed validation rule of a user field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationResult is the outcome of validating a user without saving it, User being the user as it would be saved
+type ValidationResult struct {
+	Valid  bool         `json:"valid"`
+	Errors []FieldError `json:"errors"`
+	User   *User        `json:"user,omitempty"`
+}
+
+// ImportOptions are the parameters of a bulk import
+type ImportOptions struct {
+	// DryRun validates the rows and reports the errors without saving any user
+	DryRun bool
+	// Columns maps CSV headers to user fields, a header mapped to "-" being skipped
+	Columns map[string]string
+}
+
+// ImportReport is the outcome of a bulk import, every row read being either imported or rejected
+type ImportReport struct {
+	DryRun   bool `json:"dry_run"`
+	Rows     int  `json:"rows"`
+	Imported int  `json:"imported"`
+	Rejected int  `json:"rejected"`
+	// Errors lists every failure of the rejected rows, a row failing several rules having several errors
+	Errors []ImportError `json:"errors"`
+}
+
+// ImportError is a failure of a rejected row, Row being its line in the imported file
+type ImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
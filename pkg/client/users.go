@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPageSize is the number of users read at a time by the iterator when no page size is given
+const DefaultPageSize = 100
+
+// Formats of the imported and exported files
+const (
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+	FORMAT_JSON   = "json"
+)
+
+// importContentTypes are the media types of the imported formats
+var importContentTypes = map[string]string{
+	FORMAT_CSV:    "text/csv",
+	FORMAT_NDJSON: "application/x-ndjson",
+}
+
+// ListUsers returns every user matching the filter in one response, Users reading them a page at a time instead
+func (c *Client) ListUsers(ctx context.Context, filter UserFilter) ([]User, error) {
+
+	users := []User{}
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/users", query: filterQuery(filter), idempotent: true}, &users)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Users returns an iterator over the users matching the filter, asking for pageSize users at a time (DefaultPageSize
+// when zero) and following the Link of each page to the next one:
+//
+//	users := c.Users(ctx, client.UserFilter{LastName: "doe"}, 0)
+//	for users.Next() {
+//		user := users.User()
+//		...
+//	}
+//	if err := users.Err(); err != nil {
+//		...
+//	}
+func (c *Client) Users(ctx context.Context, filter UserFilter, pageSize int) *UserIterator {
+
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	query := filterQuery(filter)
+	query.Set("limit", strconv.Itoa(pageSize))
+	return &UserIterator{client: c, ctx: ctx, next: query}
+}
+
+func (c *Client) GetUser(ctx context.Context, id int64) (*User, error) {
+
+	var user User
+	_, err := c.do(ctx, request{method: http.MethodGet, path: userPath(id), idempotent: true}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user and returns it as saved. It is sent with an Idempotency-Key, so that it is retried
+// without creating the user twice.
+func (c *Client) CreateUser(ctx context.Context, user *User) (*User, error) {
+
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{HEADER_IDEMPOTENCY_KEY: {key}}
+
+	var created User
+	_, err = c.do(ctx, request{method: http.MethodPost, path: "/users", header: header, body: user, idempotent: true}, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateUser replaces the user with the id of user, and returns it as saved
+func (c *Client) UpdateUser(ctx context.Context, user *User) (*User, error) {
+
+	var updated User
+	_, err := c.do(ctx, request{method: http.MethodPut, path: userPath(user.Id), body: user, idempotent: true}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteUser deletes a user. A retried deletion returns ErrNotFound when the first attempt deleted the user but its
+// response was lost.
+func (c *Client) DeleteUser(ctx context.Context, id int64) error {
+
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: userPath(id), idempotent: true}, nil)
+	return err
+}
+
+// ValidateUser runs the validation rules on a user without saving it
+func (c *Client) ValidateUser(ctx context.Context, user *User) (*ValidationResult, error) {
+
+	var result ValidationResult
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/users/validate", body: user, idempotent: true}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ValidateUserField runs the validation rules of a single field of a user without saving it
+func (c *Client) ValidateUserField(ctx context.Context, user *User, field string) (*ValidationResult, error) {
+
+	var result ValidationResult
+	path := "/users/validate/" + url.PathEscape(field)
+	_, err := c.do(ctx, request{method: http.MethodPost, path: path, body: user, idempotent: true}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ImportUsers creates users in bulk from a CSV or NDJSON file. It is not retried, since the file is read as it is sent.
+func (c *Client) ImportUsers(ctx context.Context, format string, file io.Reader, options ImportOptions) (*ImportReport, error) {
+
+	contentType, ok := importContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q, expected csv or ndjson", format)
+	}
+	query := url.Values{}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	headers := make([]string, 0, len(options.Columns))
+	for header := range options.Columns {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		query.Add("column", header+":"+options.Columns[header])
+	}
+
+	var report ImportReport
+	req := request{method: http.MethodPost, path: "/users/import", query: query, header: http.Header{"Content-Type": {contentType}}, body: file}
+	if _, err := c.do(ctx, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ExportUsers writes the users matching the filter to w, in the csv, ndjson or json format. It is retried until the
+// API starts sending the file; an export failing after that leaves an incomplete file and returns the error.
+func (c *Client) ExportUsers(ctx context.Context, filter UserFilter, format string, w io.Writer) error {
+
+	query := filterQuery(filter)
+	query.Set("format", format)
+	response, err := c.send(ctx, request{method: http.MethodGet, path: "/users/export", query: query, idempotent: true})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if _, err := io.Copy(w, response.Body); err != nil {
+		return fmt.Errorf("reading the export: %w", err)
+	}
+	return nil
+}
+
+// UserIterator reads users a page at a time, following the link to the next page until the last one.
+// It is not safe for concurrent use.
+type UserIterator struct {
+	client *Client
+	ctx    context.Context
+	// next is the query of the next page, nil after the last page
+	next url.Values
+	page []User
+	user User
+	err  error
+}
+
+// Next moves to the next user, reading the next page when needed, and tells whether there is one.
+// It returns false at the end of the users or on an error, returned by Err.
+func (it *UserIterator) Next() bool {
+
+	for len(it.page) == 0 {
+		if it.next == nil || it.err != nil {
+			return false
+		}
+		it.readPage()
+	}
+	it.user, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// User returns the current user
+func (it *UserIterator) User() User {
+	return it.user
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+func (it *UserIterator) readPage() {
+
+	page := []User{}
+	response, err := it.client.do(it.ctx, request{method: http.MethodGet, path: "/users", query: it.next, idempotent: true}, &page)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.page, it.next = page, nil
+
+	if link := nextLink(response.Header.Values(HEADER_LINK)); link != "" {
+		next, err := url.Parse(link)
+		if err != nil {
+			it.err = fmt.Errorf("reading the link to the next page of users: %w", err)
+			return
+		}
+		it.next = next.Query()
+	}
+}
+
+// nextLink returns the url of the link with the next relation of Link headers, or "" when there is none
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name == "rel" && strings.Trim(value, `"`) == "next" {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func filterQuery(filter UserFilter) url.Values {
+	query := url.Values{}
+	for name, value := range map[string]string{"first_name": filter.FirstName, "last_name": filter.LastName, "email": filter.Email} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	for name, value := range map[string]int64{"min_age": filter.MinAge, "max_age": filter.MaxAge} {
+		if value != 0 {
+			query.Set(name, strconv.FormatInt(value, 10))
+		}
+	}
+	return query
+}
+
+func userPath(id int64) string {
+	return "/users/" + strconv.FormatInt(id, 10)
+}
+
+// newIdempotencyKey returns a random key, identifying the retries of a request
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating an idempotency key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
@@ -1,32 +1,56 @@
 package integration_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/apperror"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/auth"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/authz"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/codec"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/controller"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/database"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/gql"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/i18n"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/jobs"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/model"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/outbox"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/repository"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/router"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/scim"
 	"github.com/wexinc/ps-tag-onboarding-go/internal/service"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/sse"
+	"github.com/wexinc/ps-tag-onboarding-go/internal/webhook"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func BuildRouter() *chi.Mux {
 	db := database.CreateNewGormDB()
 	userRepository := repository.UserRepository{DB: db}
-	userValidation := service.UserValidationService{&userRepository}
-	userService := service.UserService{&userRepository, &userValidation}
-	userController := controller.UserController{&userService}
+	userValidation := service.UserValidationService{Repository: &userRepository}
+	userNormalization := service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true}
+	userService := service.UserService{
+		Repository:           &userRepository,
+		ValidationService:    &userValidation,
+		NormalizationService: &userNormalization,
+	}
+	userController := controller.UserController{UserService: &userService}
 
 	r := chi.NewRouter()
-	userRoutes := router.UserRoutes{&userController}
+	r.Use(i18n.Default().Middleware)
+	userRoutes := router.UserRoutes{Controller: &userController}
 	userRoutes.UserRoutes(r)
 	return r
 }
@@ -63,6 +87,13 @@ func TestGetUser(t *testing.T) {
 			reqPath:      "/users/bad",
 			expectedBody: `{"status":400,"message":"user id should be a number","error":"bad_request"}`,
 		},
+		{
+			name:         "NOT_FOUND",
+			method:       http.MethodGet,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/999",
+			expectedBody: `{"status":404,"message":"user not found with id 999","error":"not_found"}`,
+		},
 	}
 
 	testServer := httptest.NewServer(BuildRouter())
@@ -150,13 +181,967 @@ func TestSaveUser(t *testing.T) {
 	verify(t, tests, testServer)
 }
 
+func TestValidateUser(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		method         string
+		rec            *httptest.ResponseRecorder
+		req            *http.Request
+		reqPath        string
+		body           io.Reader
+		expectedBody   string
+		expectedHeader string
+	}{
+		{
+			name:         "VALIDATE_OK",
+			method:       http.MethodPost,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/validate",
+			body:         bytes.NewBufferString(`{"first_name":"Ada","last_name":"Lovelace","email":"ada.lovelace@gmail.com","age":36}`),
+			expectedBody: `{"valid":true,"errors":[],"user":{"id":0,"first_name":"Ada","last_name":"Lovelace","email":"ada.lovelace@gmail.com","age":36}}`,
+		},
+		{
+			name:         "VALIDATE_FAILED",
+			method:       http.MethodPost,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/validate",
+			body:         bytes.NewBufferString(`{"first_name":"John","last_name":"Doe","email":"john.doe@yahoo.com","age":9}`),
+			expectedBody: `{"valid":false,"errors":[{"field":"age","code":"age_minimum","message":"User does not meet minimum age requirement"},{"field":"last_name","code":"name_unique","message":"User with the same first and last name already exists"}],"user":{"id":0,"first_name":"John","last_name":"Doe","email":"john.doe@yahoo.com","age":9}}`,
+		},
+		{
+			name:         "VALIDATE_FIELD_FAILED",
+			method:       http.MethodPost,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/validate/email",
+			body:         bytes.NewBufferString(`{"email":"john.doe"}`),
+			expectedBody: `{"valid":false,"errors":[{"field":"email","code":"email_format","message":"User email must be properly formatted"}],"user":{"id":0,"first_name":"","last_name":"","email":"john.doe","age":0}}`,
+		},
+		{
+			name:         "VALIDATE_DOES_NOT_SAVE",
+			method:       http.MethodPost,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/validate",
+			body:         bytes.NewBufferString(`{"first_name":"Ada","last_name":"Lovelace","email":"ada.lovelace@gmail.com","age":36}`),
+			expectedBody: `{"valid":true,"errors":[],"user":{"id":0,"first_name":"Ada","last_name":"Lovelace","email":"ada.lovelace@gmail.com","age":36}}`,
+		},
+		{
+			name:         "VALIDATE_NORMALIZED",
+			method:       http.MethodPost,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/validate",
+			body:         bytes.NewBufferString(`{"first_name":" thomas ","last_name":"JEFFERSON","email":"T.Jefferson@Yahoo.COM","age":40}`),
+			expectedBody: `{"valid":true,"errors":[],"user":{"id":0,"first_name":"Thomas","last_name":"Jefferson","email":"t.jefferson@yahoo.com","age":40}}`,
+		},
+	}
+
+	testServer := httptest.NewServer(BuildRouter())
+	defer testServer.Close()
+
+	verify(t, tests, testServer)
+}
+
+func TestLocalizedValidation(t *testing.T) {
+
+	testServer := httptest.NewServer(BuildRouter())
+	defer testServer.Close()
+
+	tests := []struct {
+		name            string
+		acceptLanguage  string
+		expectedBody    string
+		expectedContent string
+	}{
+		{
+			name:            "FR_CA",
+			acceptLanguage:  "fr-CA,fr;q=0.9,en;q=0.8",
+			expectedBody:    `{"valid":false,"errors":[{"field":"email","code":"email_format","message":"Le courriel de l'utilisateur doit être correctement formaté"},{"field":"age","code":"age_minimum","message":"L'utilisateur n'a pas l'âge minimum requis"}],"user":{"id":0,"first_name":"Grace","last_name":"Hopper","email":"grace","age":9}}`,
+			expectedContent: "fr-CA",
+		},
+		{
+			name:            "PT_BR",
+			acceptLanguage:  "pt-BR",
+			expectedBody:    `{"valid":false,"errors":[{"field":"email","code":"email_format","message":"O e-mail do usuário deve estar formatado corretamente"},{"field":"age","code":"age_minimum","message":"O usuário não atende ao requisito de idade mínima"}],"user":{"id":0,"first_name":"Grace","last_name":"Hopper","email":"grace","age":9}}`,
+			expectedContent: "pt-BR",
+		},
+		{
+			name:            "FALLBACK",
+			acceptLanguage:  "ja-JP",
+			expectedBody:    `{"valid":false,"errors":[{"field":"email","code":"email_format","message":"User email must be properly formatted"},{"field":"age","code":"age_minimum","message":"User does not meet minimum age requirement"}],"user":{"id":0,"first_name":"Grace","last_name":"Hopper","email":"grace","age":9}}`,
+			expectedContent: "en",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := bytes.NewBufferString(`{"first_name":"Grace","last_name":"Hopper","email":"grace","age":9}`)
+			request, err := http.NewRequest(http.MethodPost, testServer.URL+"/users/validate", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Accept-Language", test.acceptLanguage)
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			respBody, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.expectedBody, string(respBody))
+			assert.Equal(t, test.expectedContent, response.Header.Get("Content-Language"))
+		})
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+
+	testServer := httptest.NewServer(BuildRouter())
+	defer testServer.Close()
+
+	tests := []struct {
+		name                string
+		reqPath             string
+		contentType         string
+		accept              string
+		body                string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "XML",
+			reqPath:             "/users/validate/age",
+			contentType:         "application/xml",
+			accept:              "application/xml",
+			body:                `<user><first_name>Katherine</first_name><age>9</age></user>`,
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody:        `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<validation_result><valid>false</valid><errors><error><field>age</field><code>age_minimum</code><message>User does not meet minimum age requirement</message></error></errors><user><id>0</id><first_name>Katherine</first_name><last_name></last_name><email></email><age>9</age></user></validation_result>`,
+		},
+		{
+			name:                "CSV_TO_YAML",
+			reqPath:             "/users/validate",
+			contentType:         "text/csv",
+			accept:              "application/yaml",
+			body:                "first_name,last_name,email,age\nKatherine,Johnson,katherine.johnson@nasa.gov,101\n",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/yaml; charset=utf-8",
+			expectedBody:        "valid: true\nerrors: []\nuser:\n  id: 0\n  first_name: Katherine\n  last_name: Johnson\n  email: katherine.johnson@nasa.gov\n  age: 101\n",
+		},
+		{
+			name:                "NOT_ACCEPTABLE",
+			reqPath:             "/users/validate",
+			accept:              "application/pdf",
+			body:                `{}`,
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"code":406,"message":"The requested response format is not supported."}`,
+		},
+		{
+			name:                "UNSUPPORTED_MEDIA_TYPE",
+			reqPath:             "/users/validate",
+			contentType:         "application/pdf",
+			body:                `%PDF`,
+			expectedStatus:      http.StatusUnsupportedMediaType,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"code":415,"message":"The request body format is not supported."}`,
+		},
+		{
+			name:                "TOO_LARGE",
+			reqPath:             "/users/validate",
+			contentType:         "application/json",
+			body:                strings.Repeat(" ", int(codec.DefaultMaxBodySize)+1),
+			expectedStatus:      http.StatusRequestEntityTooLarge,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"status":413,"message":"The request body is too large.","error":"request_too_large"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodPost, testServer.URL+test.reqPath, bytes.NewBufferString(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Content-Type", test.contentType)
+			request.Header.Set("Accept", test.accept)
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			respBody, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.expectedStatus, response.StatusCode)
+			assert.Equal(t, test.expectedContentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, test.expectedBody, string(respBody))
+		})
+	}
+}
+
+func TestAuthenticationAndAuthorization(t *testing.T) {
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier := &auth.Verifier{Keys: []auth.IKeySet{&auth.KeySet{Keys: []auth.Key{{Key: secret}}}}, Issuer: "tests"}
+	policy, err := authz.LoadPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRoutes := router.UserRoutes{Controller: &controller.UserController{Policy: policy}}
+	r := chi.NewRouter()
+	router.Mount(r, []router.Group{{Name: router.GROUP_USERS, Routes: userRoutes.UserRoutes}}, verifier.Middleware, nil, nil)
+	router.Mount(r, []router.Group{{Name: router.GROUP_DOCS, Routes: func(r chi.Router) {
+		r.Get("/docs", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("docs")) })
+	}}}, verifier.Middleware, nil, []string{router.GROUP_DOCS})
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	token := func(issuer string, roles ...string) string {
+		claims := auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Issuer: issuer, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+			Roles:            roles,
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		reqPath        string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "PROTECTED_WITHOUT_TOKEN", method: http.MethodGet, reqPath: "/users/abc", expectedStatus: http.StatusUnauthorized},
+		{name: "PROTECTED_WRONG_ISSUER", method: http.MethodGet, reqPath: "/users/abc", authorization: token("others"), expectedStatus: http.StatusUnauthorized},
+		{name: "PROTECTED_WITH_TOKEN", method: http.MethodGet, reqPath: "/users/abc", authorization: token("tests"), expectedStatus: http.StatusBadRequest},
+		{name: "FORBIDDEN_ROLE", method: http.MethodDelete, reqPath: "/users/1", authorization: token("tests", "viewer"), expectedStatus: http.StatusForbidden},
+		{name: "PUBLIC", method: http.MethodGet, reqPath: "/docs", expectedStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequest(test.method, testServer.URL+test.reqPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			assert.Equal(t, test.expectedStatus, response.StatusCode)
+		})
+	}
+}
+
+func TestApiKeys(t *testing.T) {
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	verifier := &auth.Verifier{Keys: []auth.IKeySet{&auth.KeySet{Keys: []auth.Key{{Key: secret}}}}}
+	policy, err := authz.LoadPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userService := service.UserService{Repository: &userRepository, ValidationService: &service.UserValidationService{Repository: &userRepository}}
+	apiKeyService := service.ApiKeyService{Repository: &repository.ApiKeyRepository{DB: db}}
+	userRoutes := router.UserRoutes{Controller: &controller.UserController{UserService: &userService, Policy: policy}}
+	apiKeyRoutes := router.ApiKeyRoutes{Controller: &controller.ApiKeyController{ApiKeyService: &apiKeyService, Policy: policy}}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	router.Mount(r, append(userRoutes.Groups(), apiKeyRoutes.Groups()...), auth.Middleware(verifier, &apiKeyService), nil, nil)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	token := func(roles ...string) string {
+		claims := auth.Claims{RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}, Roles: roles}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+	call := func(method string, path string, authorization string, body string) (int, string) {
+		request, err := http.NewRequest(method, testServer.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Authorization", authorization)
+		if body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		responseBody, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(responseBody)
+	}
+
+	request := `{"name":"reporting","scopes":["users:list"]}`
+	status, _ := call(http.MethodPost, "/apikeys/", token("editor"), request)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body := call(http.MethodPost, "/apikeys/", token("admin"), `{"name":"reporting","scopes":["users:purge"]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "Api key scope 'users:purge' is unknown")
+
+	status, body = call(http.MethodPost, "/apikeys/", token("admin"), request)
+	assert.Equal(t, http.StatusCreated, status)
+	var created model.ApiKeyCreated
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix+"."))
+
+	status, _ = call(http.MethodGet, "/users/", "ApiKey "+created.Key, "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = call(http.MethodDelete, "/users/1", "ApiKey "+created.Key, "")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, _ = call(http.MethodGet, "/apikeys/", "ApiKey "+created.Key, "")
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body = call(http.MethodGet, "/apikeys/", token("admin"), "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, created.Prefix)
+	assert.Contains(t, body, "last_used_at")
+	assert.NotContains(t, body, created.Key)
+
+	status, _ = call(http.MethodDelete, fmt.Sprintf("/apikeys/%v", created.Id), token("admin"), "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = call(http.MethodGet, "/users/", "ApiKey "+created.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestWebhooks(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db, Events: true}
+	webhookRepository := repository.WebhookRepository{DB: db}
+	userService := service.UserService{Repository: &userRepository, ValidationService: &service.UserValidationService{Repository: &userRepository}}
+	userRoutes := router.UserRoutes{Controller: &controller.UserController{UserService: &userService}}
+	webhookRoutes := router.WebhookRoutes{Controller: &controller.WebhookController{WebhookService: &service.WebhookService{Repository: &webhookRepository, AllowPrivateUrls: true}}}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	router.Mount(r, append(userRoutes.Groups(), webhookRoutes.Groups()...), nil, nil, nil)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	dispatcher := outbox.Dispatcher{Repository: &repository.OutboxRepository{DB: db}, Sink: &webhook.Sink{Repository: &webhookRepository}}
+	deliverer := webhook.Deliverer{Repository: &webhookRepository, Client: webhook.NewClient(time.Second, true), MaxAttempts: 1}
+
+	// the receiver checks the signature of every delivery, and is down until failing is false
+	var (
+		mu       sync.Mutex
+		secret   string
+		failing  = true
+		received []model.Event
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header.Get(webhook.HEADER_TIMESTAMP), r.Header.Get(webhook.HEADER_SIGNATURE), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event model.Event
+		json.Unmarshal(body, &event)
+		received = append(received, event)
+	}))
+	defer receiver.Close()
+
+	call := func(method string, path string, body string) (int, string) {
+		request, err := http.NewRequest(method, testServer.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		responseBody, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(responseBody)
+	}
+
+	status, body := call(http.MethodPost, "/webhooks/", `{"url":"ftp://example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, service.ERROR_WEBHOOK_URL_INVALID)
+
+	status, body = call(http.MethodPost, "/webhooks/", fmt.Sprintf(`{"url":%q,"events":["UserCreated","UserUpdated"]}`, receiver.URL))
+	assert.Equal(t, http.StatusCreated, status)
+	var created model.WebhookCreated
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	secret = created.Secret
+	mu.Unlock()
+
+	status, body = call(http.MethodGet, "/webhooks/", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, receiver.URL)
+	assert.NotContains(t, body, created.Secret)
+
+	status, body = call(http.MethodPost, "/users/", `{"first_name":"Hook","last_name":"Receiver","email":"hook.receiver@example.com","age":30}`)
+	assert.Equal(t, http.StatusCreated, status)
+	var user model.User
+	if err := json.Unmarshal([]byte(body), &user); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = call(http.MethodPut, fmt.Sprint("/users/", user.Id), fmt.Sprintf(`{"id":%v,"first_name":"Hook","last_name":"Receiver","email":"hook.receiver@example.com","age":31}`, user.Id))
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = call(http.MethodDelete, fmt.Sprint("/users/", user.Id), "")
+	assert.Equal(t, http.StatusOK, status)
+
+	// the deliveries of the subscribed events fail and are dead after one attempt
+	sent, err := dispatcher.Dispatch(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, sent)
+	delivered, err := deliverer.Deliver(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, delivered)
+
+	status, body = call(http.MethodGet, fmt.Sprintf("/webhooks/%v/deliveries", created.Id), "")
+	assert.Equal(t, http.StatusOK, status)
+	var deliveries []model.WebhookDelivery
+	if err := json.Unmarshal([]byte(body), &deliveries); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, model.EVENT_USER_UPDATED, deliveries[0].EventType)
+	assert.Equal(t, model.EVENT_USER_CREATED, deliveries[1].EventType)
+	for _, delivery := range deliveries {
+		assert.Equal(t, model.DELIVERY_DEAD, delivery.Status)
+		assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	}
+
+	// a redelivered delivery is sent again once the receiver is back
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	status, body = call(http.MethodPost, fmt.Sprintf("/webhooks/%v/deliveries/%v/redeliver", created.Id, deliveries[1].Id), "")
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Contains(t, body, `"status":"pending"`)
+	delivered, err = deliverer.Deliver(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, delivered)
+
+	mu.Lock()
+	assert.Len(t, received, 1)
+	assert.Equal(t, model.EVENT_USER_CREATED, received[0].Type)
+	assert.Equal(t, user.Id, received[0].UserId)
+	assert.Contains(t, string(received[0].Data), "hook.receiver@example.com")
+	mu.Unlock()
+
+	status, _ = call(http.MethodDelete, fmt.Sprint("/webhooks/", created.Id), "")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = call(http.MethodGet, fmt.Sprintf("/webhooks/%v/deliveries", created.Id), "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestUserEventStream(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	broker := &sse.Broker{}
+	userService := service.UserService{Repository: &userRepository, ValidationService: &service.UserValidationService{Repository: &userRepository}, Events: broker}
+	userRoutes := router.UserRoutes{Controller: &controller.UserController{UserService: &userService, Events: &sse.Stream{Broker: broker}}}
+	r := chi.NewRouter()
+	r.Use(router.Timeout(100 * time.Millisecond))
+	r.Use(i18n.Default().Middleware)
+	router.Mount(r, userRoutes.Groups(), nil, nil, nil)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	request, err := http.NewRequest(http.MethodGet, testServer.URL+"/users/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Accept", sse.CONTENT_TYPE)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	lines := bufio.NewScanner(response.Body)
+	next := func() string {
+		if !lines.Scan() {
+			t.Fatal("stream closed")
+		}
+		return lines.Text()
+	}
+	assert.Equal(t, ": connected", next())
+	next()
+
+	// the stream outlives the request timeout
+	time.Sleep(150 * time.Millisecond)
+	body := `{"first_name":"Stream","last_name":"Watcher","email":"stream.watcher@example.com","age":30}`
+	created, err := http.Post(testServer.URL+"/users/", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Body.Close()
+	assert.Equal(t, http.StatusCreated, created.StatusCode)
+
+	assert.Equal(t, "id: 1", next())
+	assert.Equal(t, "event: UserCreated", next())
+	assert.Contains(t, next(), `"email":"stream.watcher@example.com"`)
+}
+
+func TestGraphql(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userService := service.UserService{
+		Repository:           &userRepository,
+		ValidationService:    &service.UserValidationService{Repository: &userRepository},
+		NormalizationService: &service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true},
+	}
+	schema, err := (&gql.UserSchema{UserService: &userService}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphqlRoutes := router.GraphqlRoutes{Handler: &gql.Handler{Schema: schema}}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	router.Mount(r, graphqlRoutes.Groups(), nil, nil, nil)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	send := func(acceptLanguage string, body string) string {
+		request, err := http.NewRequest(http.MethodPost, testServer.URL+"/graphql", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept-Language", acceptLanguage)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		respBody, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		return string(respBody)
+	}
+
+	// the user is normalized and saved by the service layer
+	created := send("en", `{"query":"mutation($user: UserInput!) { createUser(user: $user) { id first_name email } }",
+		"variables":{"user":{"first_name":"ADA","last_name":"Lovelace","email":"Ada.Lovelace@Example.com","age":36}}}`)
+	var result struct {
+		Data struct {
+			CreateUser struct {
+				Id int64 `json:"id"`
+			} `json:"createUser"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(created), &result); err != nil {
+		t.Fatal(err)
+	}
+	id := result.Data.CreateUser.Id
+	assert.JSONEq(t, fmt.Sprintf(`{"data":{"createUser":{"id":%v,"first_name":"Ada","email":"ada.lovelace@example.com"}}}`, id), created)
+
+	// a batch updates the user and reads it back
+	batch := send("en", fmt.Sprintf(`[{"query":"mutation { updateUser(id: %[1]v, user: {first_name: \"Ada\", last_name: \"King\", email: \"ada.lovelace@example.com\", age: 36}) { id } }"},
+		{"query":"{ users(filter: {email: \"ada.lovelace\"}) { users { id last_name } total_count } }"}]`, id))
+	assert.JSONEq(t, fmt.Sprintf(`[{"data":{"updateUser":{"id":%[1]v}}},{"data":{"users":{"users":[{"id":%[1]v,"last_name":"King"}],"total_count":1}}}]`, id), batch)
+
+	// validation errors are localized in the extensions
+	invalid := send("fr", `{"query":"mutation { createUser(user: {first_name: \"Grace\", last_name: \"Hopper\", email: \"grace\", age: 36}) { id } }"}`)
+	assert.Contains(t, invalid, `"extensions":{"code":"BAD_USER_INPUT","fields":[{"field":"email","code":"email_format","message":"L'adresse e-mail de l'utilisateur doit être correctement formatée"}],"status":400}`)
+
+	deleted := send("en", fmt.Sprintf(`{"query":"mutation { deleteUser(id: %v) }"}`, id))
+	assert.JSONEq(t, `{"data":{"deleteUser":true}}`, deleted)
+	missing := send("en", fmt.Sprintf(`{"query":"{ user(id: %v) { id } }"}`, id))
+	assert.JSONEq(t, `{"data":{"user":null}}`, missing)
+}
+
+func TestScim(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userService := service.UserService{
+		Repository:           &userRepository,
+		ValidationService:    &service.UserValidationService{Repository: &userRepository},
+		NormalizationService: &service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true},
+	}
+	scimRoutes := router.ScimRoutes{Handler: &scim.Handler{UserService: &userService}}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	router.Mount(r, scimRoutes.Groups(), nil, nil, nil)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	send := func(method string, path string, body string, wantStatus int) string {
+		request, err := http.NewRequest(method, testServer.URL+scim.PATH+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", scim.CONTENT_TYPE_SCIM)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		respBody, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, wantStatus, response.StatusCode, string(respBody))
+		return string(respBody)
+	}
+
+	// the provisioned user is normalized and validated by the service layer
+	created := send(http.MethodPost, "/Users", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"Grace.Hopper@Example.com",
+		"name":{"givenName":"grace","familyName":"hopper"},"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":85}}`, http.StatusCreated)
+	var user scim.User
+	if err := json.Unmarshal([]byte(created), &user); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "grace.hopper@example.com", user.UserName)
+	assert.Equal(t, &scim.Name{GivenName: "Grace", FamilyName: "Hopper", Formatted: "Grace Hopper"}, user.Name)
+
+	// the identity provider finds the user by userName before changing it
+	found := send(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName eq "GRACE.HOPPER@example.com" and name.familyName sw "hop"`), "", http.StatusOK)
+	assert.Contains(t, found, `"totalResults":1`)
+	assert.Contains(t, found, fmt.Sprintf(`"id":"%v"`, user.Id))
+
+	patched := send(http.MethodPatch, "/Users/"+user.Id, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+		{"op":"Replace","path":"emails[type eq \"work\"].value","value":"grace@example.com"},
+		{"op":"Replace","value":{"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age":86,"active":"True"}}]}`, http.StatusOK)
+	assert.Contains(t, patched, `"userName":"grace@example.com"`)
+	assert.Contains(t, patched, `"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":86}`)
+
+	// updates pass the validation rules too
+	invalid := send(http.MethodPatch, "/Users/"+user.Id, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations":[{"op":"replace","path":"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User:age","value":5}]}`, http.StatusBadRequest)
+	assert.JSONEq(t, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"invalidValue",
+		"detail":"User does not meet minimum age requirement"}`, invalid)
+	taken := send(http.MethodPost, "/Users", `{"userName":"grace.h@example.com","name":{"givenName":"Grace","familyName":"Hopper"},
+		"urn:wexinc:params:scim:schemas:extension:onboarding:2.0:User":{"age":40}}`, http.StatusConflict)
+	assert.Contains(t, taken, `"scimType":"uniqueness"`)
+
+	send(http.MethodDelete, "/Users/"+user.Id, "", http.StatusNoContent)
+	send(http.MethodGet, "/Users/"+user.Id, "", http.StatusNotFound)
+}
+
+func TestImportUsers(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userValidation := service.UserValidationService{Repository: &userRepository}
+	userNormalization := service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true}
+	userImport := service.UserImportService{
+		Repository:           &userRepository,
+		ValidationService:    &userValidation,
+		NormalizationService: &userNormalization,
+		BatchSize:            2,
+	}
+	userController := controller.UserController{
+		UserService:   &service.UserService{Repository: &userRepository, ValidationService: &userValidation},
+		ImportService: &userImport,
+	}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	userRoutes := router.UserRoutes{Controller: &userController}
+	userRoutes.UserRoutes(r)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	file := "Given Name,Last Name,E-mail,Age,Department\n" +
+		"hedy,lamarr,Hedy.Lamarr@Example.com,85,Research\n" +
+		"John,Doe,john@example.com,34,Sales\n" +
+		"Radia,Perlman,radia.example.com,72,Research\n" +
+		"Frances,Allen,frances@example.com,eighty,Research\n" +
+		"Hedy,Lamarr,hedy@example.com,85,Research\n" +
+		"Karen,Sparck Jones,karen@example.com,68,Research\n" +
+		"Edsger,Dijkstra,edsger@example.com,72,Research\n"
+	send := func(query string, accept string) (*http.Response, string) {
+		request, err := http.NewRequest(http.MethodPost, testServer.URL+"/users/import?column=Given%20Name:first_name&column=Department:-"+query, strings.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "text/csv")
+		request.Header.Set("Accept", accept)
+		request.Header.Set("Accept-Language", "fr")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		respBody, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response, string(respBody)
+	}
+
+	// a dry run reports the rejected rows, localized, without creating any user
+	response, report := send("&dry_run=true", "text/csv")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, `attachment; filename="users-import-report.csv"`, response.Header.Get("Content-Disposition"))
+	assert.Equal(t, "row,field,code,message\n"+
+		"3,last_name,name_unique,Un utilisateur avec les mêmes prénom et nom existe déjà\n"+
+		"4,email,email_format,L'adresse e-mail de l'utilisateur doit être correctement formatée\n"+
+		"5,age,invalid_value,La valeur du champ âge est invalide\n"+
+		"6,last_name,name_duplicate,Un utilisateur avec les mêmes prénom et nom apparaît plus haut dans l'import\n", report)
+	_, err := userRepository.DbGetUserByEmail("hedy.lamarr@example.com")
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	// the valid rows are normalized and created in batches
+	response, report = send("", "application/json")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, report, `{"dry_run":false,"rows":7,"imported":3,"rejected":4,"errors":[`)
+	for _, email := range []string{"hedy.lamarr@example.com", "karen@example.com", "edsger@example.com"} {
+		user, err := userRepository.DbGetUserByEmail(email)
+		if assert.Nil(t, err, email) && email == "hedy.lamarr@example.com" {
+			assert.Equal(t, "Hedy", user.FirstName)
+			assert.Equal(t, "Lamarr", user.LastName)
+		}
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userController := controller.UserController{
+		UserService:   &service.UserService{Repository: &userRepository},
+		ExportService: &service.UserExportService{Repository: &userRepository, ChunkSize: 2},
+	}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	userRoutes := router.UserRoutes{Controller: &userController}
+	userRoutes.UserRoutes(r)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	tests := []struct {
+		name            string
+		query           string
+		accept          string
+		wantStatus      int
+		wantBody        string
+		wantDisposition string
+	}{
+		{
+			name:            "CSV",
+			query:           "?format=csv&email=LOBORTIS",
+			wantStatus:      http.StatusOK,
+			wantBody:        "id,first_name,last_name,email,age\n3,Branden,Spears,non.lobortis@hotmail.net,34\n5,Ira,Francis,in.lobortis.tellus@protonmail.ca,34\n",
+			wantDisposition: `attachment; filename="users.csv"`,
+		},
+		{
+			name:       "NDJSON accepted",
+			query:      "?email=lobortis",
+			accept:     "application/x-ndjson",
+			wantStatus: http.StatusOK,
+			wantBody: `{"id":3,"first_name":"Branden","last_name":"Spears","email":"non.lobortis@hotmail.net","age":34}` + "\n" +
+				`{"id":5,"first_name":"Ira","last_name":"Francis","email":"in.lobortis.tellus@protonmail.ca","age":34}` + "\n",
+			wantDisposition: `attachment; filename="users.ndjson"`,
+		},
+		{
+			name:            "No match",
+			query:           "?format=json&min_age=200",
+			wantStatus:      http.StatusOK,
+			wantBody:        "[]\n",
+			wantDisposition: `attachment; filename="users.json"`,
+		},
+		{
+			name:       "Unknown format",
+			query:      "?format=xlsx",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"status":400,"message":"format should be csv, ndjson or json","error":"bad_request"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, testServer.URL+"/users/export"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Accept", tt.accept)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			respBody, err := io.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantStatus, response.StatusCode)
+			assert.Equal(t, tt.wantBody, string(respBody))
+			assert.Equal(t, tt.wantDisposition, response.Header.Get("Content-Disposition"))
+		})
+	}
+}
+
+func TestJobs(t *testing.T) {
+
+	db := database.CreateNewGormDB()
+	userRepository := repository.UserRepository{DB: db}
+	userValidation := service.UserValidationService{Repository: &userRepository}
+	userNormalization := service.UserNormalizationService{CapitalizeNames: true, LowercaseEmail: true}
+	userService := service.UserService{
+		Repository:           &userRepository,
+		ValidationService:    &userValidation,
+		NormalizationService: &userNormalization,
+	}
+	jobRepository := repository.JobRepository{DB: db}
+	dir := t.TempDir()
+	userJobs := service.UserJobService{
+		ImportService: &service.UserImportService{
+			Repository:           &userRepository,
+			ValidationService:    &userValidation,
+			NormalizationService: &userNormalization,
+		},
+		ExportService:     &service.UserExportService{Repository: &userRepository},
+		UserService:       &userService,
+		ValidationService: &userValidation,
+		Repository:        &userRepository,
+		Dir:               dir,
+	}
+	runner := jobs.Runner{
+		Repository: &jobRepository,
+		Handlers: map[string]jobs.Handler{
+			model.JOB_IMPORT:     userJobs.Import,
+			model.JOB_EXPORT:     userJobs.Export,
+			model.JOB_PURGE:      userJobs.Purge,
+			model.JOB_REVALIDATE: userJobs.Revalidate,
+		},
+		Interval: 10 * time.Millisecond,
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go runner.Run(ctx)
+	jobController := controller.JobController{JobService: &service.JobService{Repository: &jobRepository, Runner: &runner, Dir: dir}}
+	r := chi.NewRouter()
+	r.Use(i18n.Default().Middleware)
+	jobRoutes := router.JobRoutes{Controller: &jobController}
+	jobRoutes.JobRoutes(r)
+	testServer := httptest.NewServer(r)
+	defer testServer.Close()
+
+	send := func(method string, path string, contentType string, body string) (*http.Response, string) {
+		request, err := http.NewRequest(method, testServer.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", contentType)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		respBody, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response, string(respBody)
+	}
+	// submit queues a job and polls it until it is finished
+	submit := func(path string, contentType string, body string) model.Job {
+		response, _ := send(http.MethodPost, path, contentType, body)
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+		location := response.Header.Get("Location")
+		assert.Regexp(t, `^/jobs/\d+$`, location)
+
+		var job model.Job
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			response, respBody := send(http.MethodGet, location, "", "")
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			job = model.Job{}
+			if err := json.Unmarshal([]byte(respBody), &job); err != nil {
+				t.Fatal(err)
+			}
+			if job.Finished() {
+				break
+			}
+		}
+		assert.Equal(t, model.JOB_SUCCEEDED, job.Status, job.Error)
+		return job
+	}
+
+	job := submit("/jobs/import?column=Given%20Name:first_name", "text/csv", "Given Name,last_name,email,age\n"+
+		"grace,hopper,Grace@Jobs.Example.com,85\n"+
+		"Ada,Lovelace,ada.example.com,36\n"+
+		"Barbara,Liskov,barbara@jobs.example.com,84\n")
+	assert.Equal(t, &model.ImportReport{Rows: 3, Imported: 2, Rejected: 1, Errors: []model.ImportError{
+		{Row: 3, Field: "email", Code: "email_format", Message: "User email must be properly formatted"},
+	}}, job.Result.Import)
+	imported := job.Id
+
+	job = submit("/jobs/export?format=csv&email=jobs.example.com", "", "")
+	assert.Equal(t, 2, job.Result.Exported)
+	response, file := send(http.MethodGet, fmt.Sprintf("/jobs/%v/file", job.Id), "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, `attachment; filename="users.csv"`, response.Header.Get("Content-Disposition"))
+	assert.Regexp(t, `^id,first_name,last_name,email,age\n\d+,Grace,Hopper,grace@jobs.example.com,85\n\d+,Barbara,Liskov,barbara@jobs.example.com,84\n$`, file)
+
+	job = submit("/jobs/revalidate", "", "")
+	assert.GreaterOrEqual(t, job.Result.Revalidation.Users, 7)
+
+	job = submit("/jobs/purge?email=jobs.example.com", "", "")
+	assert.Equal(t, 2, job.Result.Deleted)
+	_, err := userRepository.DbGetUserByEmail("grace@jobs.example.com")
+	assert.ErrorIs(t, err, apperror.ErrNotFound)
+
+	// a purge of every user is refused, and only the import file has no download
+	response, body := send(http.MethodPost, "/jobs/purge", "", "")
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, `{"status":400,"message":"A purge needs at least one filter","error":"bad_request"}`, body)
+	response, _ = send(http.MethodGet, fmt.Sprintf("/jobs/%v/file", imported), "", "")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, body = send(http.MethodGet, "/jobs", "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	var listed []model.Job
+	assert.Nil(t, json.Unmarshal([]byte(body), &listed))
+	assert.Equal(t, []int64{job.Id, job.Id - 1, job.Id - 2, imported}, []int64{listed[0].Id, listed[1].Id, listed[2].Id, listed[3].Id})
+}
+
 func TestUpdateUser(t *testing.T) {
 
+	// user 1 keeps its own names, which are not taken by another user
 	user := &model.User{
 		Id:        1,
-		FirstName: "Nic",
-		LastName:  "Raboy",
-		Email:     "nic.raboy_t@gmail.com",
+		FirstName: "John",
+		LastName:  "Doe",
+		Email:     "john.doe_t@gmail.com",
 		Age:       45,
 	}
 
@@ -178,7 +1163,15 @@ func TestUpdateUser(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			reqPath:      fmt.Sprint("/users/", user.Id),
 			body:         bytes.NewBuffer(jsonUser),
-			expectedBody: `{"id":1,"first_name":"Nic","last_name":"Raboy","email":"nic.raboy_t@gmail.com","age":45}`,
+			expectedBody: `{"id":1,"first_name":"John","last_name":"Doe","email":"john.doe_t@gmail.com","age":45}`,
+		},
+		{
+			name:         "UPDATE_NAME_TAKEN",
+			method:       http.MethodPut,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/2",
+			body:         bytes.NewBufferString(`{"id":2,"first_name":"John","last_name":"Doe","email":"zenia@yahoo.ca","age":34}`),
+			expectedBody: `{"status":400,"message":"User with the same first and last name already exists","error":"bad_request"}`,
 		},
 	}
 
@@ -206,6 +1199,13 @@ func TestDeleteUser(t *testing.T) {
 			reqPath:      "/users/1",
 			expectedBody: `{"status":"deleted"}`,
 		},
+		{
+			name:         "DELETE_NOT_FOUND",
+			method:       http.MethodDelete,
+			rec:          httptest.NewRecorder(),
+			reqPath:      "/users/999",
+			expectedBody: `{"status":404,"message":"user not found with id 999","error":"not_found"}`,
+		},
 	}
 
 	testServer := httptest.NewServer(BuildRouter())